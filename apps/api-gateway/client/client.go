@@ -0,0 +1,192 @@
+// Package client is a typed Go client for the gateway Admin API (/admin/v1).
+//
+// Errors returned by the API are decoded into *Error, which carries the stable problem code;
+// use IsNotFound, IsConflict and IsVersionConflict to branch on common cases.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// APIBase is the path prefix of the Admin API version this client speaks.
+const APIBase = "/admin/v1"
+
+// Client talks to one gateway. The zero value is not usable; create one with New.
+type Client struct {
+	baseURL string
+	token   string
+	http    *http.Client
+	// namespace scopes service, route, draft and revision calls (see InNamespace).
+	namespace string
+	// draft scopes service and route calls to a draft (see InDraft).
+	draft string
+}
+
+// New returns a client for the gateway at baseURL (e.g. http://localhost:8080). A non-empty
+// token is sent as a Bearer token on every request.
+func New(baseURL, token string) *Client {
+	return &Client{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		token:   token,
+		http:    &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// WithHTTPClient replaces the HTTP client used for requests (for custom TLS or timeouts).
+func (c *Client) WithHTTPClient(hc *http.Client) *Client {
+	c.http = hc
+	return c
+}
+
+// BaseURL returns the gateway address the client was created with.
+func (c *Client) BaseURL() string { return c.baseURL }
+
+// InDraft returns a copy of the client whose service and route calls edit the draft id instead
+// of the live registry. Publish the draft with PublishDraft to make the changes live.
+func (c *Client) InDraft(id string) *Client {
+	d := *c
+	d.draft = id
+	return &d
+}
+
+// Draft returns the draft the client is scoped to, or "" for the live registry.
+func (c *Client) Draft() string { return c.draft }
+
+// InNamespace returns a copy of the client whose service, route, draft and revision calls
+// address namespace ns. Without it they address the default namespace.
+func (c *Client) InNamespace(ns string) *Client {
+	n := *c
+	n.namespace = ns
+	return &n
+}
+
+// Namespace returns the namespace the client is scoped to, or "" for the default namespace.
+func (c *Client) Namespace() string { return c.namespace }
+
+// request describes one API call.
+type request struct {
+	method string
+	path   string
+	query  url.Values
+	header http.Header
+	// body is JSON-encoded unless it is a []byte, which is sent as is.
+	body        any
+	contentType string
+}
+
+// do performs req and decodes a successful JSON response into out (when non-nil). Non-2xx
+// responses are returned as *Error.
+func (c *Client) do(ctx context.Context, req request, out any) (*http.Response, error) {
+	u := c.baseURL + req.path
+	if len(req.query) > 0 {
+		u += "?" + req.query.Encode()
+	}
+	var body io.Reader
+	contentType := req.contentType
+	switch b := req.body.(type) {
+	case nil:
+	case []byte:
+		body = bytes.NewReader(b)
+	default:
+		buf, err := json.Marshal(b)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(buf)
+		if contentType == "" {
+			contentType = "application/json"
+		}
+	}
+	hr, err := http.NewRequestWithContext(ctx, req.method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range req.header {
+		hr.Header[k] = v
+	}
+	if contentType != "" {
+		hr.Header.Set("Content-Type", contentType)
+	}
+	hr.Header.Set("Accept", "application/json, application/problem+json")
+	if c.token != "" {
+		hr.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err := c.http.Do(hr)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return resp, err
+	}
+	if resp.StatusCode/100 != 2 {
+		return resp, decodeError(resp, data)
+	}
+	if out != nil && len(data) > 0 {
+		if err := json.Unmarshal(data, out); err != nil {
+			return resp, fmt.Errorf("decode %s %s response: %w", req.method, req.path, err)
+		}
+	}
+	return resp, nil
+}
+
+// ifMatch returns an If-Match header for version, or nil when version is 0.
+func ifMatch(version int64) http.Header {
+	if version <= 0 {
+		return nil
+	}
+	return http.Header{"If-Match": {`"` + strconv.FormatInt(version, 10) + `"`}}
+}
+
+// versionOf parses the ETag of resp; 0 when absent.
+func versionOf(resp *http.Response) int64 {
+	if resp == nil {
+		return 0
+	}
+	v, _ := strconv.ParseInt(strings.Trim(strings.TrimPrefix(resp.Header.Get("ETag"), "W/"), `"`), 10, 64)
+	return v
+}
+
+// scopePath is the path the resources of the client's namespace live under.
+func (c *Client) scopePath() string {
+	if c.namespace == "" {
+		return APIBase
+	}
+	return namespacePath(c.namespace)
+}
+
+func (c *Client) servicesPath() string {
+	if c.draft != "" {
+		return c.draftPath(c.draft) + "/services"
+	}
+	return c.scopePath() + "/services"
+}
+
+func (c *Client) servicePath(id string) string {
+	return c.servicesPath() + "/" + url.PathEscape(id)
+}
+
+func (c *Client) routePath(serviceID, routeID string) string {
+	return c.servicePath(serviceID) + "/routes/" + url.PathEscape(routeID)
+}
+
+func (c *Client) draftPath(id string) string {
+	return c.scopePath() + "/drafts/" + url.PathEscape(id)
+}
+
+// Health reports whether the gateway answers its /healthz endpoint.
+func (c *Client) Health(ctx context.Context) error {
+	var out map[string]any
+	_, err := c.do(ctx, request{method: http.MethodGet, path: "/healthz"}, &out)
+	return err
+}
@@ -0,0 +1,434 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// recorded is one request seen by a recorder server.
+type recorded struct {
+	method, path, query string
+	header              http.Header
+	body                string
+}
+
+// newRecorder starts a server that records every request and answers with the status, headers
+// and body respond returns for it (200 and "null" when respond is nil).
+func newRecorder(t *testing.T, respond func(r *http.Request) (int, http.Header, string)) (*Client, func() []recorded) {
+	t.Helper()
+	var mu sync.Mutex
+	var seen []recorded
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		seen = append(seen, recorded{method: r.Method, path: r.URL.EscapedPath(), query: r.URL.RawQuery, header: r.Header.Clone(), body: string(body)})
+		mu.Unlock()
+		status, header, out := http.StatusOK, http.Header(nil), "null"
+		if respond != nil {
+			status, header, out = respond(r)
+		}
+		for k, v := range header {
+			w.Header()[k] = v
+		}
+		w.WriteHeader(status)
+		io.WriteString(w, out)
+	}))
+	t.Cleanup(srv.Close)
+	return New(srv.URL+"/", "s3cret"), func() []recorded {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]recorded(nil), seen...)
+	}
+}
+
+func TestRequests(t *testing.T) {
+	c, seen := newRecorder(t, nil)
+	ctx := context.Background()
+	enabled := false
+	tests := []struct {
+		name                string
+		call                func() error
+		method, path, query string
+		contentType         string
+		ifMatch             string
+	}{
+		{
+			name: "list with filters",
+			call: func() error {
+				_, _, err := c.ListServices(ctx, ListOptions{Enabled: &enabled, Protocol: "grpc-json", Search: "users", Labels: map[string]string{"team": "core"}, Sort: "-name", Limit: 5, Cursor: "abc"})
+				return err
+			},
+			method: "GET", path: "/admin/v1/services", query: "cursor=abc&enabled=false&label=team%3Dcore&limit=5&protocol=grpc-json&q=users&sort=-name",
+		},
+		{
+			name: "create relaxing checks",
+			call: func() error {
+				_, err := c.CreateService(ctx, &Service{Name: "users"}, CreateOptions{AllowPrefixOverlap: true})
+				return err
+			},
+			method: "POST", path: "/admin/v1/services", query: "allow_prefix_overlap=true", contentType: "application/json",
+		},
+		{
+			name: "validate a new service",
+			call: func() error {
+				_, err := c.ValidateService(ctx, &Service{Name: "users"}, CreateOptions{SkipUpstreamCheck: true})
+				return err
+			},
+			method: "POST", path: "/admin/v1/services", query: "dry_run=true&skip_upstream_check=true", contentType: "application/json",
+		},
+		{
+			name:   "validate an update",
+			call:   func() error { _, err := c.ValidateService(ctx, &Service{ID: "s1"}, CreateOptions{}); return err },
+			method: "PUT", path: "/admin/v1/services/s1", query: "dry_run=true", contentType: "application/json",
+		},
+		{
+			name: "conditional update",
+			call: func() error {
+				_, err := c.UpdateService(ctx, &Service{ID: "s1", Version: 3}, CreateOptions{})
+				return err
+			},
+			method: "PUT", path: "/admin/v1/services/s1", contentType: "application/json", ifMatch: `"3"`,
+		},
+		{
+			name:   "merge patch",
+			call:   func() error { _, err := c.PatchService(ctx, "s1", []byte(`{"enabled":false}`), 4); return err },
+			method: "PATCH", path: "/admin/v1/services/s1", contentType: "application/merge-patch+json", ifMatch: `"4"`,
+		},
+		{
+			name:   "unconditional delete",
+			call:   func() error { return c.DeleteService(ctx, "s1", 0) },
+			method: "DELETE", path: "/admin/v1/services/s1",
+		},
+		{
+			name:   "escaped ids",
+			call:   func() error { _, err := c.GetRoute(ctx, "a/b", "c d"); return err },
+			method: "GET", path: "/admin/v1/services/a%2Fb/routes/c%20d",
+		},
+		{
+			name:   "routes by source",
+			call:   func() error { _, err := c.ListRoutes(ctx, "s1", "annotation"); return err },
+			method: "GET", path: "/admin/v1/services/s1/routes", query: "source=annotation",
+		},
+		{
+			name:   "conditional route delete",
+			call:   func() error { return c.DeleteRoute(ctx, "s1", "r1", 2) },
+			method: "DELETE", path: "/admin/v1/services/s1/routes/r1", ifMatch: `"2"`,
+		},
+		{
+			name:   "bulk discovery",
+			call:   func() error { _, err := c.AddDiscoveredRoutes(ctx, "s1"); return err },
+			method: "POST", path: "/admin/v1/services/s1/routes/discover/bulk",
+		},
+	}
+	for _, tt := range tests {
+		if err := tt.call(); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		all := seen()
+		r := all[len(all)-1]
+		if r.method != tt.method || r.path != tt.path || r.query != tt.query {
+			t.Errorf("%s: sent %s %s?%s, want %s %s?%s", tt.name, r.method, r.path, r.query, tt.method, tt.path, tt.query)
+		}
+		if got := r.header.Get("Content-Type"); got != tt.contentType {
+			t.Errorf("%s: Content-Type %q, want %q", tt.name, got, tt.contentType)
+		}
+		if got := r.header.Get("If-Match"); got != tt.ifMatch {
+			t.Errorf("%s: If-Match %q, want %q", tt.name, got, tt.ifMatch)
+		}
+		if got := r.header.Get("Authorization"); got != "Bearer s3cret" {
+			t.Errorf("%s: Authorization %q", tt.name, got)
+		}
+		if got := r.header.Get("Accept"); !strings.Contains(got, "application/problem+json") {
+			t.Errorf("%s: Accept %q doesn't take problems", tt.name, got)
+		}
+	}
+	if got := seen()[5].body; got != `{"enabled":false}` {
+		t.Errorf("merge patch: []byte body re-encoded as %s", got)
+	}
+}
+
+func TestVersionFromETag(t *testing.T) {
+	c, _ := newRecorder(t, func(r *http.Request) (int, http.Header, string) {
+		return http.StatusOK, http.Header{"Etag": {`W/"7"`}}, `{"id":"s1","name":"users"}`
+	})
+	svc, err := c.GetService(context.Background(), "s1")
+	if err != nil {
+		t.Fatalf("GetService: %v", err)
+	}
+	if svc.Version != 7 {
+		t.Errorf("GetService: version %d, want 7 from the ETag", svc.Version)
+	}
+	rt, err := c.GetRoute(context.Background(), "s1", "r1")
+	if err != nil || rt.Version != 7 {
+		t.Errorf("GetRoute: got %+v, %v; want version 7 from the ETag", rt, err)
+	}
+}
+
+func TestAllServicesFollowsCursors(t *testing.T) {
+	c, seen := newRecorder(t, func(r *http.Request) (int, http.Header, string) {
+		switch r.URL.Query().Get("cursor") {
+		case "":
+			return http.StatusOK, http.Header{"X-Next-Cursor": {"p2"}}, `[{"id":"a"},{"id":"b"}]`
+		case "p2":
+			return http.StatusOK, http.Header{"X-Next-Cursor": {"p3"}}, `[{"id":"c"}]`
+		}
+		return http.StatusOK, nil, `[{"id":"d"}]`
+	})
+	list, err := c.AllServices(context.Background(), ListOptions{Protocol: "http"})
+	if err != nil {
+		t.Fatalf("AllServices: %v", err)
+	}
+	var ids []string
+	for _, s := range list {
+		ids = append(ids, s.ID)
+	}
+	if !reflect.DeepEqual(ids, []string{"a", "b", "c", "d"}) {
+		t.Errorf("AllServices: got %v", ids)
+	}
+	for _, r := range seen() {
+		if !strings.Contains(r.query, "protocol=http") {
+			t.Errorf("page %s lost the filter", r.query)
+		}
+	}
+}
+
+func TestDecodeError(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		want    Error
+		message string
+		check   func(error) bool
+	}{
+		{
+			name:   "problem",
+			status: http.StatusPreconditionFailed,
+			body:   `{"type":"about:blank","title":"Precondition Failed","status":412,"code":"version_conflict","detail":"service changed"}`,
+			want:   Error{Type: "about:blank", Title: "Precondition Failed", Status: 412, Code: CodeVersionConflict, Detail: "service changed"},
+			check:  IsVersionConflict, message: "412 version_conflict: service changed",
+		},
+		{
+			name:   "validation",
+			status: http.StatusUnprocessableEntity,
+			body:   `{"title":"Validation failed","status":422,"code":"validation_failed","errors":[{"code":"required","field":"name","message":"name is required"}]}`,
+			want:   Error{Title: "Validation failed", Status: 422, Code: CodeValidationFailed, Errors: []FieldIssue{{Code: "required", Field: "name", Message: "name is required"}}},
+			check:  func(err error) bool { return !IsNotFound(err) }, message: "422 validation_failed: Validation failed; name is required",
+		},
+		{
+			name:   "not a problem",
+			status: http.StatusBadGateway,
+			body:   "upstream connect error\n",
+			want:   Error{Title: "Bad Gateway", Status: 502, Detail: "upstream connect error"},
+			check:  func(err error) bool { return !IsConflict(err) }, message: "502: upstream connect error",
+		},
+		{
+			name:   "status from the response",
+			status: http.StatusNotFound,
+			body:   `{"code":"not_found"}`,
+			want:   Error{Title: "Not Found", Status: 404, Code: CodeNotFound},
+			check:  IsNotFound, message: "404 not_found: Not Found",
+		},
+		{
+			name:   "stale draft",
+			status: http.StatusConflict,
+			body:   `{"title":"Conflict","status":409,"code":"stale_draft"}`,
+			want:   Error{Title: "Conflict", Status: 409, Code: CodeStaleDraft},
+			check:  func(err error) bool { return IsConflict(err) && IsStaleDraft(err) }, message: "409 stale_draft: Conflict",
+		},
+	}
+	for _, tt := range tests {
+		err := decodeError(&http.Response{StatusCode: tt.status}, []byte(tt.body))
+		e, ok := err.(*Error)
+		if !ok {
+			t.Fatalf("%s: got %T", tt.name, err)
+		}
+		if !reflect.DeepEqual(*e, tt.want) {
+			t.Errorf("%s: got %+v, want %+v", tt.name, *e, tt.want)
+		}
+		if err.Error() != tt.message {
+			t.Errorf("%s: message %q, want %q", tt.name, err.Error(), tt.message)
+		}
+		if !tt.check(err) {
+			t.Errorf("%s: predicate failed for %v", tt.name, err)
+		}
+	}
+
+	c, _ := newRecorder(t, func(r *http.Request) (int, http.Header, string) {
+		return http.StatusConflict, http.Header{"Content-Type": {"application/problem+json"}}, `{"status":409,"code":"conflict","title":"Conflict"}`
+	})
+	if _, err := c.CreateService(context.Background(), &Service{}, CreateOptions{}); !IsConflict(err) {
+		t.Errorf("CreateService: got %v, want a conflict", err)
+	}
+}
+
+// fakeAPI is a minimal in-memory Admin API for services and routes.
+type fakeAPI struct {
+	mu       sync.Mutex
+	next     int
+	services map[string]*Service
+	routes   map[string][]*Route
+}
+
+func newFakeAPI(t *testing.T) (*fakeAPI, *Client) {
+	f := &fakeAPI{services: map[string]*Service{}, routes: map[string][]*Route{}}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	return f, New(srv.URL, "")
+}
+
+func (f *fakeAPI) id() string {
+	f.next++
+	return strconv.Itoa(f.next)
+}
+
+func (f *fakeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	reply := func(v any) { json.NewEncoder(w).Encode(v) }
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, APIBase+"/services"), "/")[1:]
+	switch {
+	case len(parts) == 0 && r.Method == http.MethodGet:
+		list := []*Service{}
+		for _, s := range f.services {
+			list = append(list, s)
+		}
+		sort.Slice(list, func(i, j int) bool { return list[i].PublicPrefix < list[j].PublicPrefix })
+		reply(list)
+	case len(parts) == 0 && r.Method == http.MethodPost:
+		var s Service
+		json.NewDecoder(r.Body).Decode(&s)
+		s.ID, s.Version = f.id(), 1
+		f.services[s.ID] = &s
+		reply(s)
+	case len(parts) == 1 && r.Method == http.MethodPut:
+		var s Service
+		json.NewDecoder(r.Body).Decode(&s)
+		cur := f.services[parts[0]]
+		if r.Header.Get("If-Match") != `"`+strconv.FormatInt(cur.Version, 10)+`"` {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+		s.ID, s.Version = cur.ID, cur.Version+1
+		f.services[s.ID] = &s
+		reply(s)
+	case len(parts) == 1 && r.Method == http.MethodDelete:
+		delete(f.services, parts[0])
+		delete(f.routes, parts[0])
+	case len(parts) == 2 && r.Method == http.MethodGet:
+		reply(f.routes[parts[0]])
+	case len(parts) == 2 && r.Method == http.MethodPost:
+		var rt Route
+		json.NewDecoder(r.Body).Decode(&rt)
+		rt.ID, rt.ServiceID, rt.Version = f.id(), parts[0], 1
+		f.routes[parts[0]] = append(f.routes[parts[0]], &rt)
+		reply(rt)
+	case len(parts) == 3 && (r.Method == http.MethodPut || r.Method == http.MethodDelete):
+		list := f.routes[parts[0]]
+		for i, cur := range list {
+			if cur.ID != parts[2] {
+				continue
+			}
+			if r.Method == http.MethodDelete {
+				f.routes[parts[0]] = append(list[:i], list[i+1:]...)
+				return
+			}
+			var rt Route
+			json.NewDecoder(r.Body).Decode(&rt)
+			rt.ID, rt.ServiceID, rt.Version = cur.ID, cur.ServiceID, cur.Version+1
+			list[i] = &rt
+			reply(rt)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func TestImportExport(t *testing.T) {
+	ctx := context.Background()
+	f, c := newFakeAPI(t)
+	off := false
+	doc := &Document{APIVersion: DocumentAPIVersion, Kind: DocumentKind, Services: []ServiceSpec{
+		{Name: "users", PublicPrefix: "/api/users", BaseURL: "http://users/", Labels: map[string]string{"team": "core"}},
+		{Name: "orders", PublicPrefix: "/api/orders/", Protocol: "grpc-json", GRPCTarget: "orders:9090", Enabled: &off, Routes: []RouteSpec{
+			{Method: "get", Path: "/v1/orders/{id}", GRPCMethod: "/orders.v1.Orders/Get"},
+			{Method: "POST", Path: "/v1/orders", GRPCMethod: "/orders.v1.Orders/Create", Body: "*"},
+		}},
+	}}
+
+	changes, err := c.Import(ctx, doc, ImportOptions{DryRun: true})
+	if err != nil {
+		t.Fatalf("Import(dry run): %v", err)
+	}
+	if len(changes) != 4 || len(f.services) != 0 {
+		t.Fatalf("Import(dry run): %d changes, %d services stored; want 4 planned, none stored", len(changes), len(f.services))
+	}
+
+	if _, err := c.Import(ctx, doc, ImportOptions{}); err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	exported, err := c.Export(ctx)
+	if err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	want := []ServiceSpec{doc.Services[1].normalized(), doc.Services[0].normalized()}
+	if !reflect.DeepEqual(exported.Services, want) {
+		t.Fatalf("Export: got %+v\nwant %+v", exported.Services, want)
+	}
+
+	// Importing the export again changes nothing.
+	changes, err = c.Import(ctx, exported, ImportOptions{Prune: true})
+	if err != nil {
+		t.Fatalf("Import(export): %v", err)
+	}
+	for _, ch := range changes {
+		if ch.Action != ActionUnchanged {
+			t.Errorf("Import(export): %+v", ch)
+		}
+	}
+
+	// Prune drops what the document no longer lists, down to single routes.
+	doc.Services = doc.Services[1:]
+	doc.Services[0].Routes = doc.Services[0].Routes[1:]
+	doc.Services[0].Routes[0].Body = "order"
+	changes, err = c.Import(ctx, doc, ImportOptions{Prune: true})
+	if err != nil {
+		t.Fatalf("Import(prune): %v", err)
+	}
+	got := map[string]string{}
+	for _, ch := range changes {
+		got[ch.Kind+" "+ch.Service+" "+ch.Route] = ch.Action
+	}
+	wantChanges := map[string]string{
+		"service /api/orders/ ":                  ActionUnchanged,
+		"route /api/orders/ POST /v1/orders":     ActionUpdate,
+		"route /api/orders/ GET /v1/orders/{id}": ActionDelete,
+		"service /api/users/ ":                   ActionDelete,
+	}
+	if !reflect.DeepEqual(got, wantChanges) {
+		t.Errorf("Import(prune): got %v, want %v", got, wantChanges)
+	}
+	if len(f.services) != 1 {
+		t.Errorf("Import(prune): %d services left, want 1", len(f.services))
+	}
+
+	if _, err := c.Import(ctx, &Document{Kind: "Service"}, ImportOptions{}); err == nil {
+		t.Error("Import accepted a document of another kind")
+	}
+	dup := &Document{Services: []ServiceSpec{{Name: "a", PublicPrefix: "/api/a"}, {Name: "b", PublicPrefix: "/api/a/"}}}
+	if _, err := c.Import(ctx, dup, ImportOptions{DryRun: true}); err == nil {
+		t.Error("Import accepted a prefix listed twice")
+	}
+}
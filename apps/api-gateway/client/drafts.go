@@ -0,0 +1,164 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Draft is a staged copy of the registry. Edit it with a client returned by InDraft.
+type Draft struct {
+	ID          string `json:"id"`
+	Namespace   string `json:"namespace,omitempty"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	// Base fingerprints the live registry the draft was copied from.
+	Base      string    `json:"base"`
+	Services  int       `json:"services"`
+	Routes    int       `json:"routes"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Version   int64     `json:"version"`
+}
+
+// Revision is a numbered state of the live registry, recorded by a publish or rollback.
+type Revision struct {
+	Number      int64            `json:"number"`
+	Namespace   string           `json:"namespace,omitempty"`
+	Source      string           `json:"source"`
+	Message     string           `json:"message,omitempty"`
+	DraftID     string           `json:"draft_id,omitempty"`
+	RollbackOf  int64            `json:"rollback_of,omitempty"`
+	Fingerprint string           `json:"fingerprint"`
+	Changes     []SnapshotChange `json:"changes"`
+	CreatedAt   time.Time        `json:"created_at"`
+}
+
+// SnapshotChange is one difference between two states of the registry.
+type SnapshotChange struct {
+	Action  string   `json:"action"`
+	Kind    string   `json:"kind"`
+	ID      string   `json:"id"`
+	Service string   `json:"service"`
+	Route   string   `json:"route,omitempty"`
+	Fields  []string `json:"fields,omitempty"`
+}
+
+// SnapshotDiff is the difference between the live registry and a draft or revision.
+type SnapshotDiff struct {
+	// Current is false when the live registry changed since the draft was created.
+	Current bool             `json:"current"`
+	Changes []SnapshotChange `json:"changes"`
+}
+
+// ListDrafts returns every draft.
+func (c *Client) ListDrafts(ctx context.Context) ([]Draft, error) {
+	var out []Draft
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: c.scopePath() + "/drafts"}, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CreateDraft copies the live registry into a new draft.
+func (c *Client) CreateDraft(ctx context.Context, name, description string) (*Draft, error) {
+	var out Draft
+	body := map[string]string{"name": name, "description": description}
+	if _, err := c.do(ctx, request{method: http.MethodPost, path: c.scopePath() + "/drafts", body: body}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetDraft returns one draft.
+func (c *Client) GetDraft(ctx context.Context, id string) (*Draft, error) {
+	var out Draft
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: c.draftPath(id)}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DeleteDraft discards a draft.
+func (c *Client) DeleteDraft(ctx context.Context, id string) error {
+	_, err := c.do(ctx, request{method: http.MethodDelete, path: c.draftPath(id)}, nil)
+	return err
+}
+
+// DiffDraft returns the changes publishing the draft would make to the live registry.
+func (c *Client) DiffDraft(ctx context.Context, id string) (*SnapshotDiff, error) {
+	var out SnapshotDiff
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: c.draftPath(id) + "/diff"}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ValidateDraft checks the whole draft as publishing would, without publishing it.
+func (c *Client) ValidateDraft(ctx context.Context, id string, opts CreateOptions) (*ValidationReport, error) {
+	var out ValidationReport
+	if _, err := c.do(ctx, request{method: http.MethodPost, path: c.draftPath(id) + "/validate", query: opts.values(false)}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// PublishDraft makes the live registry match the draft and returns the recorded revision. A
+// stale draft fails with IsStaleDraft unless force is set.
+func (c *Client) PublishDraft(ctx context.Context, id, message string, force bool, opts CreateOptions) (*Revision, error) {
+	q := opts.values(false)
+	if force {
+		q.Set("force", "true")
+	}
+	var out Revision
+	req := request{method: http.MethodPost, path: c.draftPath(id) + "/publish", query: q, body: map[string]string{"message": message}}
+	if _, err := c.do(ctx, req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListRevisions returns the revisions of the live registry, newest first.
+func (c *Client) ListRevisions(ctx context.Context) ([]Revision, error) {
+	var out []Revision
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: c.scopePath() + "/revisions"}, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GetRevision returns one revision; number 0 is the latest.
+func (c *Client) GetRevision(ctx context.Context, number int64) (*Revision, error) {
+	var out Revision
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: c.revisionPath(number)}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DiffRevision returns the changes rolling back to the revision would make to the live registry.
+func (c *Client) DiffRevision(ctx context.Context, number int64) (*SnapshotDiff, error) {
+	var out SnapshotDiff
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: c.revisionPath(number) + "/diff"}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Rollback makes the live registry match the revision and returns the new revision recording it.
+func (c *Client) Rollback(ctx context.Context, number int64, message string) (*Revision, error) {
+	var out Revision
+	req := request{method: http.MethodPost, path: c.revisionPath(number) + "/rollback", body: map[string]string{"message": message}}
+	if _, err := c.do(ctx, req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *Client) revisionPath(number int64) string {
+	if number <= 0 {
+		return c.scopePath() + "/revisions/latest"
+	}
+	return c.scopePath() + "/revisions/" + strconv.FormatInt(number, 10)
+}
@@ -0,0 +1,45 @@
+package client
+
+import (
+	"context"
+	"testing"
+)
+
+func TestDraftRequests(t *testing.T) {
+	c, seen := newRecorder(t, nil)
+	ctx := context.Background()
+	d := c.InDraft("d1")
+	if d.Draft() != "d1" || c.Draft() != "" {
+		t.Fatalf("InDraft changed the original client")
+	}
+	tests := []struct {
+		name                string
+		call                func() error
+		method, path, query string
+	}{
+		{"service in draft", func() error { _, err := d.GetService(ctx, "s1"); return err }, "GET", "/admin/v1/drafts/d1/services/s1", ""},
+		{"route in draft", func() error { return d.DeleteRoute(ctx, "s1", "r1", 0) }, "DELETE", "/admin/v1/drafts/d1/services/s1/routes/r1", ""},
+		{"live service", func() error { _, err := c.GetService(ctx, "s1"); return err }, "GET", "/admin/v1/services/s1", ""},
+		{"create draft", func() error { _, err := c.CreateDraft(ctx, "release", ""); return err }, "POST", "/admin/v1/drafts", ""},
+		{"diff draft", func() error { _, err := c.DiffDraft(ctx, "d1"); return err }, "GET", "/admin/v1/drafts/d1/diff", ""},
+		{"validate draft", func() error { _, err := c.ValidateDraft(ctx, "d1", CreateOptions{SkipUpstreamCheck: true}); return err }, "POST", "/admin/v1/drafts/d1/validate", "skip_upstream_check=true"},
+		{"forced publish", func() error { _, err := c.PublishDraft(ctx, "d1", "ship it", true, CreateOptions{}); return err }, "POST", "/admin/v1/drafts/d1/publish", "force=true"},
+		{"latest revision", func() error { _, err := c.GetRevision(ctx, 0); return err }, "GET", "/admin/v1/revisions/latest", ""},
+		{"revision diff", func() error { _, err := c.DiffRevision(ctx, 12); return err }, "GET", "/admin/v1/revisions/12/diff", ""},
+		{"rollback", func() error { _, err := c.Rollback(ctx, 12, "undo"); return err }, "POST", "/admin/v1/revisions/12/rollback", ""},
+	}
+	for _, tt := range tests {
+		if err := tt.call(); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		all := seen()
+		r := all[len(all)-1]
+		if r.method != tt.method || r.path != tt.path || r.query != tt.query {
+			t.Errorf("%s: sent %s %s?%s, want %s %s?%s", tt.name, r.method, r.path, r.query, tt.method, tt.path, tt.query)
+		}
+	}
+	if got := seen()[6].body; got != `{"message":"ship it"}` {
+		t.Errorf("publish: body %s", got)
+	}
+}
@@ -0,0 +1,105 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Problem codes returned by the Admin API.
+const (
+	CodeInvalidRequest       = "invalid_request"
+	CodeValidationFailed     = "validation_failed"
+	CodeNotFound             = "not_found"
+	CodeMethodNotAllowed     = "method_not_allowed"
+	CodeUnauthorized         = "unauthorized"
+	CodeForbidden            = "forbidden"
+	CodeConflict             = "conflict"
+	CodeVersionConflict      = "version_conflict"
+	CodeReadOnly             = "read_only"
+	CodeStaleDraft           = "stale_draft"
+	CodePatchTestFailed      = "patch_test_failed"
+	CodeUnsupportedMediaType = "unsupported_media_type"
+	CodeUpstreamError        = "upstream_error"
+	CodeUnavailable          = "unavailable"
+	CodeInternal             = "internal_error"
+)
+
+// FieldIssue is a field-level validation error or warning.
+type FieldIssue struct {
+	Code    string `json:"code"`
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+// Error is an Admin API error response (an RFC 7807 problem). Responses that aren't problems
+// (e.g. from a proxy in front of the gateway) keep their body in Detail.
+type Error struct {
+	Type     string       `json:"type"`
+	Title    string       `json:"title"`
+	Status   int          `json:"status"`
+	Detail   string       `json:"detail,omitempty"`
+	Code     string       `json:"code"`
+	Errors   []FieldIssue `json:"errors,omitempty"`
+	Warnings []FieldIssue `json:"warnings,omitempty"`
+}
+
+func (e *Error) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d", e.Status)
+	if e.Code != "" {
+		fmt.Fprintf(&b, " %s", e.Code)
+	}
+	if e.Detail != "" {
+		fmt.Fprintf(&b, ": %s", e.Detail)
+	} else if e.Title != "" {
+		fmt.Fprintf(&b, ": %s", e.Title)
+	}
+	for _, i := range e.Errors {
+		if i.Message != e.Detail {
+			fmt.Fprintf(&b, "; %s", i.Message)
+		}
+	}
+	return b.String()
+}
+
+func decodeError(resp *http.Response, data []byte) error {
+	e := &Error{}
+	if json.Unmarshal(data, e) != nil || (e.Code == "" && e.Title == "") {
+		e = &Error{Detail: strings.TrimSpace(string(data))}
+	}
+	if e.Status == 0 {
+		e.Status = resp.StatusCode
+	}
+	if e.Title == "" {
+		e.Title = http.StatusText(resp.StatusCode)
+	}
+	return e
+}
+
+func hasStatus(err error, status int) bool {
+	var e *Error
+	return errors.As(err, &e) && e.Status == status
+}
+
+// IsNotFound reports whether err is a 404 from the API.
+func IsNotFound(err error) bool { return hasStatus(err, http.StatusNotFound) }
+
+// IsForbidden reports whether err is a 403 from the API: the caller lacks the role the request
+// needs in its namespace.
+func IsForbidden(err error) bool { return hasStatus(err, http.StatusForbidden) }
+
+// IsConflict reports whether err is a 409 from the API (duplicate prefix or route).
+func IsConflict(err error) bool { return hasStatus(err, http.StatusConflict) }
+
+// IsVersionConflict reports whether err is a 412: the resource changed since it was read.
+func IsVersionConflict(err error) bool { return hasStatus(err, http.StatusPreconditionFailed) }
+
+// IsStaleDraft reports whether err is a 409 from publishing a draft: the live registry changed
+// since the draft was created.
+func IsStaleDraft(err error) bool {
+	var e *Error
+	return errors.As(err, &e) && e.Code == CodeStaleDraft
+}
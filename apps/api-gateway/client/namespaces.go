@@ -0,0 +1,123 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Namespace roles, from least to most privileged.
+const (
+	RoleViewer = "viewer"
+	RoleEditor = "editor"
+	RoleAdmin  = "admin"
+)
+
+// Namespace is an isolated route table of the gateway, selected by request host or header.
+type Namespace struct {
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	Hosts       []string  `json:"hosts,omitempty"`
+	Members     []Member  `json:"members,omitempty"`
+	CreatedAt   time.Time `json:"created_at,omitempty"`
+	UpdatedAt   time.Time `json:"updated_at,omitempty"`
+	Version     int64     `json:"version,omitempty"`
+}
+
+// Member grants Role in a namespace to Subject: a token subject or "group:NAME".
+type Member struct {
+	Subject string `json:"subject"`
+	Role    string `json:"role"`
+}
+
+// PromoteOptions configure PromoteService. Empty upstream fields keep the target service's
+// addresses, or the source's for a new service.
+type PromoteOptions struct {
+	Message    string
+	BaseURL    string
+	SwaggerURL string
+	GRPCTarget string
+	CreateOptions
+}
+
+// PromotionPlan is the outcome of a dry-run promotion.
+type PromotionPlan struct {
+	Changes []SnapshotChange  `json:"changes"`
+	Report  *ValidationReport `json:"report"`
+}
+
+// ListNamespaces returns the namespaces the caller can view.
+func (c *Client) ListNamespaces(ctx context.Context) ([]Namespace, error) {
+	var out []Namespace
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: APIBase + "/namespaces"}, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GetNamespace returns one namespace.
+func (c *Client) GetNamespace(ctx context.Context, name string) (*Namespace, error) {
+	var out Namespace
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: namespacePath(name)}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CreateNamespace creates a namespace; it needs a global admin.
+func (c *Client) CreateNamespace(ctx context.Context, ns *Namespace) (*Namespace, error) {
+	var out Namespace
+	if _, err := c.do(ctx, request{method: http.MethodPost, path: APIBase + "/namespaces", body: ns}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// UpdateNamespace replaces the description, hosts and members of ns.Name. A non-zero
+// ns.Version makes the update conditional.
+func (c *Client) UpdateNamespace(ctx context.Context, ns *Namespace) (*Namespace, error) {
+	var out Namespace
+	req := request{method: http.MethodPut, path: namespacePath(ns.Name), header: ifMatch(ns.Version), body: ns}
+	if _, err := c.do(ctx, req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DeleteNamespace deletes an empty namespace with its drafts and revisions.
+func (c *Client) DeleteNamespace(ctx context.Context, name string) error {
+	_, err := c.do(ctx, request{method: http.MethodDelete, path: namespacePath(name)}, nil)
+	return err
+}
+
+// PromoteService copies a service of the client's namespace, with its routes, to namespace to
+// and returns the revision recording it there.
+func (c *Client) PromoteService(ctx context.Context, id, to string, opts PromoteOptions) (*Revision, error) {
+	var out Revision
+	if _, err := c.do(ctx, c.promoteRequest(id, to, opts, false), &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// PlanPromotion returns the changes PromoteService would make and their validation report.
+func (c *Client) PlanPromotion(ctx context.Context, id, to string, opts PromoteOptions) (*PromotionPlan, error) {
+	var out PromotionPlan
+	if _, err := c.do(ctx, c.promoteRequest(id, to, opts, true), &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *Client) promoteRequest(id, to string, opts PromoteOptions, dryRun bool) request {
+	body := map[string]any{"to": to, "message": opts.Message}
+	if opts.BaseURL != "" || opts.SwaggerURL != "" || opts.GRPCTarget != "" {
+		body["upstream"] = map[string]string{"base_url": opts.BaseURL, "swagger_url": opts.SwaggerURL, "grpc_target": opts.GRPCTarget}
+	}
+	return request{method: http.MethodPost, path: c.scopePath() + "/services/" + url.PathEscape(id) + "/promote", query: opts.values(dryRun), body: body}
+}
+
+func namespacePath(name string) string {
+	return APIBase + "/namespaces/" + url.PathEscape(name)
+}
@@ -0,0 +1,57 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestNamespaceRequests(t *testing.T) {
+	c, seen := newRecorder(t, nil)
+	ctx := context.Background()
+	ns := c.InNamespace("staging")
+	if ns.Namespace() != "staging" || c.Namespace() != "" {
+		t.Fatalf("InNamespace changed the original client")
+	}
+	tests := []struct {
+		name                string
+		call                func() error
+		method, path, query string
+		ifMatch             string
+	}{
+		{"service", func() error { _, err := ns.GetService(ctx, "s1"); return err }, "GET", "/admin/v1/namespaces/staging/services/s1", "", ""},
+		{"route in draft", func() error { _, err := ns.InDraft("d1").ListRoutes(ctx, "s1", ""); return err }, "GET", "/admin/v1/namespaces/staging/drafts/d1/services/s1/routes", "", ""},
+		{"drafts", func() error { _, err := ns.ListDrafts(ctx); return err }, "GET", "/admin/v1/namespaces/staging/drafts", "", ""},
+		{"revisions", func() error { _, err := ns.ListRevisions(ctx); return err }, "GET", "/admin/v1/namespaces/staging/revisions", "", ""},
+		{"namespaces are global", func() error { _, err := ns.ListNamespaces(ctx); return err }, "GET", "/admin/v1/namespaces", "", ""},
+		{"escaped name", func() error { _, err := c.GetNamespace(ctx, "a/b"); return err }, "GET", "/admin/v1/namespaces/a%2Fb", "", ""},
+		{"conditional update", func() error { _, err := c.UpdateNamespace(ctx, &Namespace{Name: "staging", Version: 2}); return err }, "PUT", "/admin/v1/namespaces/staging", "", `"2"`},
+		{"promotion plan", func() error { _, err := ns.PlanPromotion(ctx, "s1", "prod", PromoteOptions{}); return err }, "POST", "/admin/v1/namespaces/staging/services/s1/promote", "dry_run=true", ""},
+	}
+	for _, tt := range tests {
+		if err := tt.call(); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		all := seen()
+		r := all[len(all)-1]
+		if r.method != tt.method || r.path != tt.path || r.query != tt.query {
+			t.Errorf("%s: sent %s %s?%s, want %s %s?%s", tt.name, r.method, r.path, r.query, tt.method, tt.path, tt.query)
+		}
+		if got := r.header.Get("If-Match"); got != tt.ifMatch {
+			t.Errorf("%s: If-Match %q, want %q", tt.name, got, tt.ifMatch)
+		}
+	}
+
+	if _, err := ns.PromoteService(ctx, "s1", "prod", PromoteOptions{Message: "go", BaseURL: "http://users.prod"}); err != nil {
+		t.Fatalf("PromoteService: %v", err)
+	}
+	all := seen()
+	var body map[string]any
+	json.Unmarshal([]byte(all[len(all)-1].body), &body)
+	want := map[string]any{"to": "prod", "message": "go", "upstream": map[string]any{"base_url": "http://users.prod", "swagger_url": "", "grpc_target": ""}}
+	if !reflect.DeepEqual(body, want) {
+		t.Errorf("PromoteService: body %v, want %v", body, want)
+	}
+}
@@ -0,0 +1,361 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Identification of exported registry documents.
+const (
+	DocumentAPIVersion = "gateway.ecomm/v1"
+	DocumentKind       = "Registry"
+)
+
+// Document is the portable configuration of a gateway: every service with its routes, without
+// server-managed state (IDs, health, fetched swagger, timestamps, versions).
+type Document struct {
+	APIVersion string        `json:"apiVersion"`
+	Kind       string        `json:"kind"`
+	Services   []ServiceSpec `json:"services"`
+}
+
+// ServiceSpec is the configuration of one service. Services are identified by PublicPrefix.
+type ServiceSpec struct {
+	Name             string            `json:"name"`
+	Description      string            `json:"description,omitempty"`
+	PublicPrefix     string            `json:"public_prefix"`
+	BaseURL          string            `json:"base_url,omitempty"`
+	SwaggerURL       string            `json:"swagger_url,omitempty"`
+	Protocol         string            `json:"protocol,omitempty"`
+	GRPCTarget       string            `json:"grpc_target,omitempty"`
+	DescriptorSource string            `json:"descriptor_source,omitempty"`
+	Metadata         *MetadataPolicy   `json:"metadata,omitempty"`
+	Streaming        *StreamingPolicy  `json:"streaming,omitempty"`
+	Labels           map[string]string `json:"labels,omitempty"`
+	// Enabled defaults to true when omitted.
+	Enabled *bool       `json:"enabled,omitempty"`
+	Routes  []RouteSpec `json:"routes,omitempty"`
+}
+
+// RouteSpec is the configuration of one route. Routes are identified by Method and Path.
+type RouteSpec struct {
+	Method             string                   `json:"method"`
+	Path               string                   `json:"path"`
+	GRPCMethod         string                   `json:"grpc_method"`
+	QueryMapping       map[string]QueryMapEntry `json:"query_mapping,omitempty"`
+	Body               string                   `json:"body,omitempty"`
+	ResponseBody       string                   `json:"response_body,omitempty"`
+	StrictQuery        bool                     `json:"strict_query,omitempty"`
+	ParamsOverrideBody bool                     `json:"params_override_body,omitempty"`
+}
+
+// SpecOf returns the configuration of svc and its routes.
+func SpecOf(svc *Service, routes []Route) ServiceSpec {
+	enabled := svc.Enabled
+	spec := ServiceSpec{
+		Name:             svc.Name,
+		Description:      svc.Description,
+		PublicPrefix:     svc.PublicPrefix,
+		BaseURL:          svc.BaseURL,
+		SwaggerURL:       svc.SwaggerURL,
+		Protocol:         svc.Protocol,
+		GRPCTarget:       svc.GRPCTarget,
+		DescriptorSource: svc.DescriptorSource,
+		Metadata:         svc.Metadata,
+		Streaming:        svc.Streaming,
+		Labels:           svc.Labels,
+		Enabled:          &enabled,
+	}
+	for i := range routes {
+		spec.Routes = append(spec.Routes, routeSpecOf(&routes[i]))
+	}
+	return spec.normalized()
+}
+
+func routeSpecOf(rt *Route) RouteSpec {
+	return RouteSpec{
+		Method:             rt.Method,
+		Path:               rt.Path,
+		GRPCMethod:         rt.GRPCMethod,
+		QueryMapping:       rt.QueryMapping,
+		Body:               rt.Body,
+		ResponseBody:       rt.ResponseBody,
+		StrictQuery:        rt.StrictQuery,
+		ParamsOverrideBody: rt.ParamsOverrideBody,
+	}.normalized()
+}
+
+// Service returns the service described by s (without ID or version).
+func (s ServiceSpec) Service() *Service {
+	s = s.normalized()
+	return &Service{
+		Name:             s.Name,
+		Description:      s.Description,
+		PublicPrefix:     s.PublicPrefix,
+		BaseURL:          s.BaseURL,
+		SwaggerURL:       s.SwaggerURL,
+		Protocol:         s.Protocol,
+		GRPCTarget:       s.GRPCTarget,
+		DescriptorSource: s.DescriptorSource,
+		Metadata:         s.Metadata,
+		Streaming:        s.Streaming,
+		Labels:           s.Labels,
+		Enabled:          *s.Enabled,
+	}
+}
+
+// Route returns the route described by r.
+func (r RouteSpec) Route() *Route {
+	r = r.normalized()
+	return &Route{
+		Method:             r.Method,
+		Path:               r.Path,
+		GRPCMethod:         r.GRPCMethod,
+		QueryMapping:       r.QueryMapping,
+		Body:               r.Body,
+		ResponseBody:       r.ResponseBody,
+		StrictQuery:        r.StrictQuery,
+		ParamsOverrideBody: r.ParamsOverrideBody,
+	}
+}
+
+// normalized applies the gateway's defaults so equal configurations compare equal.
+func (s ServiceSpec) normalized() ServiceSpec {
+	s.PublicPrefix = normalizePrefix(s.PublicPrefix)
+	s.Protocol = strings.ToLower(strings.TrimSpace(s.Protocol))
+	if s.Protocol == "" {
+		s.Protocol = "http"
+	}
+	s.BaseURL = strings.TrimRight(s.BaseURL, "/")
+	if s.DescriptorSource == "reflection" {
+		s.DescriptorSource = ""
+	}
+	if len(s.Labels) == 0 {
+		s.Labels = nil
+	}
+	if s.Enabled == nil {
+		t := true
+		s.Enabled = &t
+	}
+	routes := make([]RouteSpec, len(s.Routes))
+	for i, r := range s.Routes {
+		routes[i] = r.normalized()
+	}
+	sort.Slice(routes, func(i, j int) bool { return routes[i].key() < routes[j].key() })
+	if len(routes) == 0 {
+		routes = nil
+	}
+	s.Routes = routes
+	return s
+}
+
+func (r RouteSpec) normalized() RouteSpec {
+	r.Method = strings.ToUpper(r.Method)
+	if len(r.QueryMapping) == 0 {
+		r.QueryMapping = nil
+	}
+	return r
+}
+
+func (r RouteSpec) key() string { return r.Path + " " + r.Method }
+
+func normalizePrefix(p string) string {
+	p = strings.TrimSpace(p)
+	if !strings.HasPrefix(p, "/") {
+		p = "/" + p
+	}
+	if !strings.HasSuffix(p, "/") {
+		p += "/"
+	}
+	return p
+}
+
+// Export reads every service and its routes.
+func (c *Client) Export(ctx context.Context) (*Document, error) {
+	services, err := c.AllServices(ctx, ListOptions{Sort: "public_prefix"})
+	if err != nil {
+		return nil, err
+	}
+	doc := &Document{APIVersion: DocumentAPIVersion, Kind: DocumentKind, Services: []ServiceSpec{}}
+	for i := range services {
+		svc := &services[i]
+		var routes []Route
+		if svc.Protocol == "grpc-json" {
+			if routes, err = c.ListRoutes(ctx, svc.ID, ""); err != nil {
+				return nil, fmt.Errorf("routes of %s: %w", svc.PublicPrefix, err)
+			}
+		}
+		doc.Services = append(doc.Services, SpecOf(svc, routes))
+	}
+	return doc, nil
+}
+
+// Change actions reported by Import.
+const (
+	ActionCreate    = "create"
+	ActionUpdate    = "update"
+	ActionDelete    = "delete"
+	ActionUnchanged = "unchanged"
+)
+
+// Change is one create, update or delete performed (or planned) by Import.
+type Change struct {
+	Action string `json:"action"`
+	// Kind is "service" or "route".
+	Kind string `json:"kind"`
+	// Service is the public prefix of the service the change belongs to.
+	Service string `json:"service"`
+	// Route is "METHOD path" for route changes.
+	Route string `json:"route,omitempty"`
+}
+
+// ImportOptions control Import.
+type ImportOptions struct {
+	// Prune deletes services, and routes of imported services, that the document doesn't list.
+	Prune bool
+	// DryRun only computes the changes.
+	DryRun bool
+	// Create relaxes the gateway's onboarding checks for created and updated services.
+	Create CreateOptions
+}
+
+// Import makes the gateway match doc: services are matched by public prefix and routes by
+// method and path, then created, updated or (with Prune) deleted. It stops at the first failing
+// change and returns the changes made so far along with the error.
+func (c *Client) Import(ctx context.Context, doc *Document, opts ImportOptions) ([]Change, error) {
+	if doc.Kind != "" && doc.Kind != DocumentKind {
+		return nil, fmt.Errorf("unsupported document kind %q (want %s)", doc.Kind, DocumentKind)
+	}
+	existing, err := c.AllServices(ctx, ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	byPrefix := map[string]*Service{}
+	for i := range existing {
+		byPrefix[existing[i].PublicPrefix] = &existing[i]
+	}
+	var changes []Change
+	seen := map[string]bool{}
+	for _, spec := range doc.Services {
+		spec = spec.normalized()
+		if seen[spec.PublicPrefix] {
+			return changes, fmt.Errorf("public_prefix %s is listed twice", spec.PublicPrefix)
+		}
+		seen[spec.PublicPrefix] = true
+		cur := byPrefix[spec.PublicPrefix]
+		svc := spec.Service()
+		var routes []Route
+		switch {
+		case cur == nil:
+			if !opts.DryRun {
+				if svc, err = c.CreateService(ctx, svc, opts.Create); err != nil {
+					return changes, fmt.Errorf("create %s: %w", spec.PublicPrefix, err)
+				}
+			}
+			changes = append(changes, Change{Action: ActionCreate, Kind: "service", Service: spec.PublicPrefix})
+		default:
+			if cur.Protocol == "grpc-json" {
+				if routes, err = c.ListRoutes(ctx, cur.ID, ""); err != nil {
+					return changes, fmt.Errorf("routes of %s: %w", spec.PublicPrefix, err)
+				}
+			}
+			curSpec := SpecOf(cur, nil)
+			wantSpec := spec
+			wantSpec.Routes = nil
+			if reflect.DeepEqual(curSpec, wantSpec) {
+				changes = append(changes, Change{Action: ActionUnchanged, Kind: "service", Service: spec.PublicPrefix})
+				svc = cur
+				break
+			}
+			svc.ID, svc.Version = cur.ID, cur.Version
+			if !opts.DryRun {
+				if svc, err = c.UpdateService(ctx, svc, opts.Create); err != nil {
+					return changes, fmt.Errorf("update %s: %w", spec.PublicPrefix, err)
+				}
+			} else {
+				svc = cur
+			}
+			changes = append(changes, Change{Action: ActionUpdate, Kind: "service", Service: spec.PublicPrefix})
+		}
+		if spec.Protocol != "grpc-json" {
+			continue
+		}
+		rc, err := c.importRoutes(ctx, svc.ID, spec, routes, opts)
+		changes = append(changes, rc...)
+		if err != nil {
+			return changes, err
+		}
+	}
+	if opts.Prune {
+		for i := range existing {
+			svc := &existing[i]
+			if seen[svc.PublicPrefix] {
+				continue
+			}
+			if !opts.DryRun {
+				if err := c.DeleteService(ctx, svc.ID, svc.Version); err != nil {
+					return changes, fmt.Errorf("delete %s: %w", svc.PublicPrefix, err)
+				}
+			}
+			changes = append(changes, Change{Action: ActionDelete, Kind: "service", Service: svc.PublicPrefix})
+		}
+	}
+	return changes, nil
+}
+
+// importRoutes reconciles the routes of one service (current holds its stored routes).
+func (c *Client) importRoutes(ctx context.Context, serviceID string, spec ServiceSpec, current []Route, opts ImportOptions) ([]Change, error) {
+	var changes []Change
+	byKey := map[string]*Route{}
+	for i := range current {
+		byKey[routeSpecOf(&current[i]).key()] = &current[i]
+	}
+	wanted := map[string]bool{}
+	for _, rs := range spec.Routes {
+		k := rs.key()
+		wanted[k] = true
+		label := rs.Method + " " + rs.Path
+		cur := byKey[k]
+		if cur != nil && reflect.DeepEqual(routeSpecOf(cur), rs) {
+			changes = append(changes, Change{Action: ActionUnchanged, Kind: "route", Service: spec.PublicPrefix, Route: label})
+			continue
+		}
+		rt := rs.Route()
+		if cur == nil {
+			if !opts.DryRun {
+				if _, err := c.CreateRoute(ctx, serviceID, rt); err != nil {
+					return changes, fmt.Errorf("create route %s of %s: %w", label, spec.PublicPrefix, err)
+				}
+			}
+			changes = append(changes, Change{Action: ActionCreate, Kind: "route", Service: spec.PublicPrefix, Route: label})
+			continue
+		}
+		rt.ID, rt.Version = cur.ID, cur.Version
+		if !opts.DryRun {
+			if _, err := c.UpdateRoute(ctx, serviceID, rt); err != nil {
+				return changes, fmt.Errorf("update route %s of %s: %w", label, spec.PublicPrefix, err)
+			}
+		}
+		changes = append(changes, Change{Action: ActionUpdate, Kind: "route", Service: spec.PublicPrefix, Route: label})
+	}
+	if !opts.Prune {
+		return changes, nil
+	}
+	for i := range current {
+		cur := &current[i]
+		if wanted[routeSpecOf(cur).key()] {
+			continue
+		}
+		label := strings.ToUpper(cur.Method) + " " + cur.Path
+		if !opts.DryRun {
+			if err := c.DeleteRoute(ctx, serviceID, cur.ID, cur.Version); err != nil {
+				return changes, fmt.Errorf("delete route %s of %s: %w", label, spec.PublicPrefix, err)
+			}
+		}
+		changes = append(changes, Change{Action: ActionDelete, Kind: "route", Service: spec.PublicPrefix, Route: label})
+	}
+	return changes, nil
+}
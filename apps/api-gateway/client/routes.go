@@ -0,0 +1,77 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// ListRoutes returns the routes of a grpc-json service; source ("manual", "annotation" or
+// "generated") filters by origin when non-empty.
+func (c *Client) ListRoutes(ctx context.Context, serviceID, source string) ([]Route, error) {
+	var q url.Values
+	if source != "" {
+		q = url.Values{"source": {source}}
+	}
+	var out []Route
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: c.servicePath(serviceID) + "/routes", query: q}, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GetRoute returns one route of a service.
+func (c *Client) GetRoute(ctx context.Context, serviceID, routeID string) (*Route, error) {
+	var out Route
+	resp, err := c.do(ctx, request{method: http.MethodGet, path: c.routePath(serviceID, routeID)}, &out)
+	if err != nil {
+		return nil, err
+	}
+	if out.Version == 0 {
+		out.Version = versionOf(resp)
+	}
+	return &out, nil
+}
+
+// CreateRoute adds rt to the service.
+func (c *Client) CreateRoute(ctx context.Context, serviceID string, rt *Route) (*Route, error) {
+	var out Route
+	if _, err := c.do(ctx, request{method: http.MethodPost, path: c.servicePath(serviceID) + "/routes", body: rt}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// UpdateRoute replaces route rt.ID of the service. A non-zero rt.Version makes it conditional.
+func (c *Client) UpdateRoute(ctx context.Context, serviceID string, rt *Route) (*Route, error) {
+	var out Route
+	req := request{method: http.MethodPut, path: c.routePath(serviceID, rt.ID), header: ifMatch(rt.Version), body: rt}
+	if _, err := c.do(ctx, req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DeleteRoute removes a route. A non-zero version makes the delete conditional.
+func (c *Client) DeleteRoute(ctx context.Context, serviceID, routeID string, version int64) error {
+	_, err := c.do(ctx, request{method: http.MethodDelete, path: c.routePath(serviceID, routeID), header: ifMatch(version)}, nil)
+	return err
+}
+
+// DiscoverRoutes lists the gRPC methods the service's upstream exposes.
+func (c *Client) DiscoverRoutes(ctx context.Context, serviceID string) ([]DiscoveredMethod, error) {
+	var out []DiscoveredMethod
+	if _, err := c.do(ctx, request{method: http.MethodGet, path: c.servicePath(serviceID) + "/routes/discover"}, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// AddDiscoveredRoutes creates routes for every discovered method that has none yet.
+func (c *Client) AddDiscoveredRoutes(ctx context.Context, serviceID string) (*BulkDiscoverResult, error) {
+	var out BulkDiscoverResult
+	if _, err := c.do(ctx, request{method: http.MethodPost, path: c.servicePath(serviceID) + "/routes/discover/bulk"}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
@@ -0,0 +1,168 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// ListOptions filter, sort and page a service listing. Zero values mean "no filter".
+type ListOptions struct {
+	Enabled  *bool
+	Protocol string
+	Status   string
+	Search   string
+	Labels   map[string]string
+	// Sort is name, public_prefix, created_at or updated_at; prefix with - for descending.
+	Sort   string
+	Limit  int
+	Cursor string
+}
+
+func (o ListOptions) values() url.Values {
+	q := url.Values{}
+	if o.Enabled != nil {
+		q.Set("enabled", strconv.FormatBool(*o.Enabled))
+	}
+	set := func(k, v string) {
+		if v != "" {
+			q.Set(k, v)
+		}
+	}
+	set("protocol", o.Protocol)
+	set("status", o.Status)
+	set("q", o.Search)
+	set("sort", o.Sort)
+	set("cursor", o.Cursor)
+	if o.Limit > 0 {
+		q.Set("limit", strconv.Itoa(o.Limit))
+	}
+	for k, v := range o.Labels {
+		q.Add("label", k+"="+v)
+	}
+	return q
+}
+
+// ListServices returns one page of services and the cursor of the next page ("" on the last).
+func (c *Client) ListServices(ctx context.Context, opts ListOptions) ([]Service, string, error) {
+	var out []Service
+	resp, err := c.do(ctx, request{method: http.MethodGet, path: c.servicesPath(), query: opts.values()}, &out)
+	if err != nil {
+		return nil, "", err
+	}
+	return out, resp.Header.Get("X-Next-Cursor"), nil
+}
+
+// AllServices follows the cursors of ListServices and returns every matching service.
+func (c *Client) AllServices(ctx context.Context, opts ListOptions) ([]Service, error) {
+	var all []Service
+	for {
+		page, next, err := c.ListServices(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, page...)
+		if next == "" {
+			return all, nil
+		}
+		opts.Cursor = next
+	}
+}
+
+// GetService returns the service with the given ID.
+func (c *Client) GetService(ctx context.Context, id string) (*Service, error) {
+	var out Service
+	resp, err := c.do(ctx, request{method: http.MethodGet, path: c.servicePath(id)}, &out)
+	if err != nil {
+		return nil, err
+	}
+	if out.Version == 0 {
+		out.Version = versionOf(resp)
+	}
+	return &out, nil
+}
+
+// CreateOptions relax the checks the gateway runs when onboarding or changing a service.
+type CreateOptions struct {
+	// AllowPrefixOverlap accepts a prefix nested in (or containing) another service's prefix.
+	AllowPrefixOverlap bool
+	// SkipUpstreamCheck doesn't probe the upstream for reachability.
+	SkipUpstreamCheck bool
+}
+
+func (o CreateOptions) values(dryRun bool) url.Values {
+	q := url.Values{}
+	if dryRun {
+		q.Set("dry_run", "true")
+	}
+	if o.AllowPrefixOverlap {
+		q.Set("allow_prefix_overlap", "true")
+	}
+	if o.SkipUpstreamCheck {
+		q.Set("skip_upstream_check", "true")
+	}
+	return q
+}
+
+// CreateService registers svc and returns the stored service.
+func (c *Client) CreateService(ctx context.Context, svc *Service, opts CreateOptions) (*Service, error) {
+	var out Service
+	if _, err := c.do(ctx, request{method: http.MethodPost, path: c.servicesPath(), query: opts.values(false), body: svc}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ValidateService runs the create checks for svc without persisting it. When svc.ID is set the
+// checks are those of an update of that service.
+func (c *Client) ValidateService(ctx context.Context, svc *Service, opts CreateOptions) (*ValidationReport, error) {
+	req := request{method: http.MethodPost, path: c.servicesPath(), query: opts.values(true), body: svc}
+	if svc.ID != "" {
+		req.method, req.path = http.MethodPut, c.servicePath(svc.ID)
+	}
+	var out ValidationReport
+	if _, err := c.do(ctx, req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// UpdateService replaces the configuration of svc.ID. A non-zero svc.Version makes the update
+// conditional: it fails with a version conflict if the service changed since it was read.
+func (c *Client) UpdateService(ctx context.Context, svc *Service, opts CreateOptions) (*Service, error) {
+	var out Service
+	req := request{method: http.MethodPut, path: c.servicePath(svc.ID), query: opts.values(false), header: ifMatch(svc.Version), body: svc}
+	if _, err := c.do(ctx, req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// PatchService applies a JSON Merge Patch (RFC 7386) to the service. patch is JSON-encoded
+// unless it is already a []byte.
+func (c *Client) PatchService(ctx context.Context, id string, patch any, version int64) (*Service, error) {
+	var out Service
+	req := request{method: http.MethodPatch, path: c.servicePath(id), header: ifMatch(version), body: patch, contentType: "application/merge-patch+json"}
+	if _, err := c.do(ctx, req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DeleteService removes a service and its routes. A non-zero version makes the delete
+// conditional.
+func (c *Client) DeleteService(ctx context.Context, id string, version int64) error {
+	_, err := c.do(ctx, request{method: http.MethodDelete, path: c.servicePath(id), header: ifMatch(version)}, nil)
+	return err
+}
+
+// RefreshService re-fetches the swagger document of an http service, or re-resolves
+// descriptors and annotated routes of a grpc-json service.
+func (c *Client) RefreshService(ctx context.Context, id string) (*Service, error) {
+	var out Service
+	if _, err := c.do(ctx, request{method: http.MethodPost, path: c.servicePath(id) + "/refresh"}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
@@ -0,0 +1,116 @@
+package client
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Service is a backend service registered with the gateway. See the Admin API documentation
+// for field semantics; server-managed fields (ID, Namespace, health, timestamps, Version) are ignored on
+// create.
+type Service struct {
+	ID               string            `json:"id,omitempty"`
+	Namespace        string            `json:"namespace,omitempty"`
+	Name             string            `json:"name"`
+	Description      string            `json:"description,omitempty"`
+	PublicPrefix     string            `json:"public_prefix"`
+	BaseURL          string            `json:"base_url,omitempty"`
+	SwaggerURL       string            `json:"swagger_url,omitempty"`
+	Protocol         string            `json:"protocol,omitempty"`
+	GRPCTarget       string            `json:"grpc_target,omitempty"`
+	DescriptorSource string            `json:"descriptor_source,omitempty"`
+	Metadata         *MetadataPolicy   `json:"metadata,omitempty"`
+	Streaming        *StreamingPolicy  `json:"streaming,omitempty"`
+	Labels           map[string]string `json:"labels,omitempty"`
+	Enabled          bool              `json:"enabled"`
+	SwaggerJSON      json.RawMessage   `json:"swagger_json,omitempty"`
+	LastRefreshed    time.Time         `json:"last_refreshed_at,omitempty"`
+	LastHealthAt     time.Time         `json:"last_health_at,omitempty"`
+	LastStatus       string            `json:"last_status,omitempty"`
+	CreatedAt        time.Time         `json:"created_at,omitempty"`
+	UpdatedAt        time.Time         `json:"updated_at,omitempty"`
+	Version          int64             `json:"version,omitempty"`
+}
+
+// MetadataPolicy maps HTTP headers to gRPC metadata for grpc-json services.
+type MetadataPolicy struct {
+	ForwardHeaders []string `json:"forward_headers,omitempty"`
+	Allow          []string `json:"allow,omitempty"`
+	Deny           []string `json:"deny,omitempty"`
+}
+
+// StreamingPolicy configures WebSocket upgrades and long-lived responses for http services.
+type StreamingPolicy struct {
+	WebSocket          *bool    `json:"websocket,omitempty"`
+	IdleTimeoutSeconds int      `json:"idle_timeout_seconds,omitempty"`
+	MaxDurationSeconds int      `json:"max_duration_seconds,omitempty"`
+	MaxConnections     int      `json:"max_connections,omitempty"`
+	AllowedOrigins     []string `json:"allowed_origins,omitempty"`
+}
+
+// QueryMapEntry renames a query parameter to an RPC field path.
+type QueryMapEntry struct {
+	Field string `json:"field"`
+	Type  string `json:"type,omitempty"`
+}
+
+// Route maps a REST method and path template of a grpc-json service to a gRPC method.
+type Route struct {
+	ID                 string                   `json:"id,omitempty"`
+	ServiceID          string                   `json:"service_id,omitempty"`
+	Method             string                   `json:"method"`
+	Path               string                   `json:"path"`
+	GRPCMethod         string                   `json:"grpc_method"`
+	QueryMapping       map[string]QueryMapEntry `json:"query_mapping,omitempty"`
+	Body               string                   `json:"body,omitempty"`
+	ResponseBody       string                   `json:"response_body,omitempty"`
+	StrictQuery        bool                     `json:"strict_query,omitempty"`
+	ParamsOverrideBody bool                     `json:"params_override_body,omitempty"`
+	Source             string                   `json:"source,omitempty"`
+	CreatedAt          time.Time                `json:"created_at,omitempty"`
+	UpdatedAt          time.Time                `json:"updated_at,omitempty"`
+	Version            int64                    `json:"version,omitempty"`
+}
+
+// ValidationReport is returned by a dry-run create or update.
+type ValidationReport struct {
+	Valid    bool         `json:"valid"`
+	DryRun   bool         `json:"dry_run,omitempty"`
+	Errors   []FieldIssue `json:"errors"`
+	Warnings []FieldIssue `json:"warnings"`
+	Service  *Service     `json:"service,omitempty"`
+}
+
+// HTTPBinding is a REST binding declared by a google.api.http option.
+type HTTPBinding struct {
+	Method       string `json:"method"`
+	Path         string `json:"path"`
+	Body         string `json:"body,omitempty"`
+	ResponseBody string `json:"response_body,omitempty"`
+}
+
+// DiscoveredMethod is a gRPC method found on a grpc-json service's upstream.
+type DiscoveredMethod struct {
+	Service    string        `json:"service"`
+	Method     string        `json:"method"`
+	GRPCMethod string        `json:"grpc_method"`
+	HTTPRules  []HTTPBinding `json:"http_rules,omitempty"`
+}
+
+// RouteChange is one entry of a route plan.
+type RouteChange struct {
+	Action     string `json:"action"`
+	GRPCMethod string `json:"grpc_method"`
+	Route      *Route `json:"route,omitempty"`
+	Current    *Route `json:"current,omitempty"`
+	Rationale  string `json:"rationale,omitempty"`
+	Reason     string `json:"reason,omitempty"`
+	Applied    bool   `json:"applied,omitempty"`
+}
+
+// BulkDiscoverResult is the outcome of adding routes for all discovered methods.
+type BulkDiscoverResult struct {
+	Created     int             `json:"created"`
+	Conflicts   []RouteChange   `json:"conflicts"`
+	Annotations json.RawMessage `json:"annotations,omitempty"`
+}
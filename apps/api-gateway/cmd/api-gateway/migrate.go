@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+
+	mg "ecomm/api-gateway/internal/migrate"
+)
+
+const migrateUsage = `Usage: api-gateway migrate <command> [flags]
+
+Manages the gateway schema in DATABASE_URL (schema GATEWAY_DB_SCHEMA).
+
+Commands:
+  status          list migrations and whether they are applied
+  up [-to N]      apply pending migrations, up to version N
+  down [-to N]    revert the newest migration, or every one above version N
+`
+
+// migrateCommand runs "api-gateway migrate" and returns the exit code.
+func migrateCommand(args []string) int {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "--help" || args[0] == "help" {
+		fmt.Fprint(os.Stderr, migrateUsage)
+		return 2
+	}
+	name := args[0]
+	if name != "status" && name != "up" && name != "down" {
+		fmt.Fprintf(os.Stderr, "unknown migrate command %q\n\n%s", name, migrateUsage)
+		return 2
+	}
+	fs := flag.NewFlagSet("api-gateway migrate "+name, flag.ContinueOnError)
+	fs.Usage = func() { fmt.Fprint(fs.Output(), migrateUsage) }
+	to := fs.Int64("to", -1, "target version")
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+	db, schema := openDB()
+	defer db.Close()
+	m, err := mg.New(db, schema)
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+	m.Logf = log.Printf
+	ctx := context.Background()
+	switch name {
+	case "status":
+		err = migrateStatus(ctx, m)
+	case "up":
+		var done []mg.Migration
+		if done, err = m.Up(ctx, max(*to, 0)); err == nil && len(done) == 0 {
+			log.Print("migrate: schema is up to date")
+		}
+	case "down":
+		target := *to
+		if target < 0 {
+			if target, err = m.Previous(ctx); err != nil {
+				break
+			}
+		}
+		var done []mg.Migration
+		if done, err = m.Down(ctx, target); err == nil && len(done) == 0 {
+			log.Print("migrate: nothing to revert")
+		}
+	}
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+	return 0
+}
+
+func migrateStatus(ctx context.Context, m *mg.Migrator) error {
+	list, err := m.Status(ctx)
+	if err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+	for _, s := range list {
+		status, at := "pending", ""
+		switch {
+		case s.Applied && s.Up == "":
+			status = "applied (unknown to this build)"
+		case s.Modified:
+			status = "applied (modified)"
+		case s.Applied:
+			status = "applied"
+		}
+		if s.Applied {
+			at = s.AppliedAt.Format("2006-01-02 15:04:05 MST")
+		}
+		fmt.Fprintf(tw, "%04d\t%s\t%s\t%s\n", s.Version, s.Name, status, at)
+	}
+	return tw.Flush()
+}
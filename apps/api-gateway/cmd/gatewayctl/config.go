@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/invopop/yaml"
+)
+
+// config is the gatewayctl configuration file: named gateways ("contexts") and the one in use.
+type config struct {
+	CurrentContext string      `json:"current-context,omitempty"`
+	Contexts       []gwContext `json:"contexts"`
+}
+
+// gwContext addresses one gateway.
+type gwContext struct {
+	Name   string `json:"name"`
+	Server string `json:"server"`
+	Token  string `json:"token,omitempty"`
+	// TokenEnv names an environment variable holding the token, keeping it out of the file.
+	TokenEnv string `json:"token-env,omitempty"`
+	// Namespace is the namespace commands address unless --namespace is given.
+	Namespace string `json:"namespace,omitempty"`
+}
+
+func (c *gwContext) token() string {
+	if c.TokenEnv != "" {
+		return os.Getenv(c.TokenEnv)
+	}
+	return c.Token
+}
+
+// configPath is $GATEWAYCTL_CONFIG or gatewayctl/config.yaml under the user config directory.
+func configPath() (string, error) {
+	if p := os.Getenv("GATEWAYCTL_CONFIG"); p != "" {
+		return p, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "gatewayctl", "config.yaml"), nil
+}
+
+// loadConfig reads the config file; a missing file is an empty config.
+func loadConfig() (*config, string, error) {
+	path, err := configPath()
+	if err != nil {
+		return nil, "", err
+	}
+	cfg := &config{}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, path, nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, "", fmt.Errorf("%s: %w", path, err)
+	}
+	return cfg, path, nil
+}
+
+func (c *config) save(path string) error {
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	// tokens may be stored inline, so keep the file private
+	return os.WriteFile(path, data, 0o600)
+}
+
+func (c *config) find(name string) int {
+	for i := range c.Contexts {
+		if c.Contexts[i].Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// context returns the named context, or the current one when name is empty. With no contexts
+// configured and no name given it returns nil and no error.
+func (c *config) context(name string) (*gwContext, error) {
+	if name == "" {
+		name = c.CurrentContext
+	}
+	if name == "" {
+		return nil, nil
+	}
+	i := c.find(name)
+	if i < 0 {
+		return nil, fmt.Errorf("context %q not found; see gatewayctl config get-contexts", name)
+	}
+	return &c.Contexts[i], nil
+}
+
+var configCommands = map[string]*command{
+	"get-contexts": {
+		usage:   "",
+		maxArgs: 0,
+		run: func(ctx context.Context, g *globals, args []string) error {
+			cfg, _, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			return render(g.output, cfg.Contexts, func(tw *tabwriter.Writer) {
+				fmt.Fprintln(tw, "CURRENT\tNAME\tSERVER\tNAMESPACE\tAUTH")
+				for _, c := range cfg.Contexts {
+					cur, auth := "", "none"
+					if c.Name == cfg.CurrentContext {
+						cur = "*"
+					}
+					switch {
+					case c.TokenEnv != "":
+						auth = "$" + c.TokenEnv
+					case c.Token != "":
+						auth = "token"
+					}
+					fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", cur, c.Name, c.Server, orDash(c.Namespace), auth)
+				}
+			})
+		},
+	},
+	"current-context": {
+		usage:   "",
+		maxArgs: 0,
+		run: func(ctx context.Context, g *globals, args []string) error {
+			cfg, _, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			if cfg.CurrentContext == "" {
+				return errors.New("no current context is set")
+			}
+			fmt.Fprintln(stdout, cfg.CurrentContext)
+			return nil
+		},
+	},
+	"use-context": {
+		usage:   "NAME",
+		minArgs: 1, maxArgs: 1,
+		run: func(ctx context.Context, g *globals, args []string) error {
+			cfg, path, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			if cfg.find(args[0]) < 0 {
+				return fmt.Errorf("context %q not found", args[0])
+			}
+			cfg.CurrentContext = args[0]
+			if err := cfg.save(path); err != nil {
+				return err
+			}
+			fmt.Fprintf(stdout, "switched to context %q\n", args[0])
+			return nil
+		},
+	},
+	"set-context": setContextCommand(),
+	"delete-context": {
+		usage:   "NAME",
+		minArgs: 1, maxArgs: 1,
+		run: func(ctx context.Context, g *globals, args []string) error {
+			cfg, path, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			i := cfg.find(args[0])
+			if i < 0 {
+				return fmt.Errorf("context %q not found", args[0])
+			}
+			cfg.Contexts = append(cfg.Contexts[:i], cfg.Contexts[i+1:]...)
+			if cfg.CurrentContext == args[0] {
+				cfg.CurrentContext = ""
+			}
+			return cfg.save(path)
+		},
+	},
+}
+
+// setContextCommand creates or updates a context. The --server/--token/--namespace global flags
+// can't be reused here since they default from the environment, so the context fields have their
+// own.
+func setContextCommand() *command {
+	var server, token, tokenEnv, namespace string
+	var use bool
+	return &command{
+		usage:   "NAME --url URL [--bearer TOKEN | --token-env VAR] [--default-namespace NS] [--use]",
+		minArgs: 1, maxArgs: 1,
+		flags: func(fs *flag.FlagSet) {
+			fs.StringVar(&server, "url", "", "gateway address, e.g. https://gateway.example.com")
+			fs.StringVar(&token, "bearer", "", "Admin API bearer token stored in the config file")
+			fs.StringVar(&tokenEnv, "token-env", "", "environment variable to read the token from instead")
+			fs.StringVar(&namespace, "default-namespace", "", "namespace to address by default (\"default\" resets it)")
+			fs.BoolVar(&use, "use", false, "make it the current context")
+		},
+		run: func(ctx context.Context, g *globals, args []string) error {
+			cfg, path, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			i := cfg.find(args[0])
+			if i < 0 {
+				if server == "" {
+					return errors.New("--url is required for a new context")
+				}
+				cfg.Contexts = append(cfg.Contexts, gwContext{Name: args[0]})
+				i = len(cfg.Contexts) - 1
+			}
+			c := &cfg.Contexts[i]
+			if server != "" {
+				c.Server = server
+			}
+			if token != "" {
+				c.Token, c.TokenEnv = token, ""
+			}
+			if tokenEnv != "" {
+				c.TokenEnv, c.Token = tokenEnv, ""
+			}
+			if namespace == "default" {
+				c.Namespace = ""
+			} else if namespace != "" {
+				c.Namespace = namespace
+			}
+			if use || cfg.CurrentContext == "" {
+				cfg.CurrentContext = c.Name
+			}
+			if err := cfg.save(path); err != nil {
+				return err
+			}
+			fmt.Fprintf(stdout, "context %q saved to %s\n", c.Name, path)
+			return nil
+		},
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// capture runs gatewayctl with args and returns what it printed.
+func capture(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	var buf bytes.Buffer
+	old := stdout
+	stdout = &buf
+	defer func() { stdout = old }()
+	err := run(context.Background(), args)
+	return buf.String(), err
+}
+
+// useConfig points gatewayctl at a config file of its own and clears the environment overrides.
+func useConfig(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "gatewayctl", "config.yaml")
+	t.Setenv("GATEWAYCTL_CONFIG", path)
+	for _, k := range []string{"GATEWAYCTL_CONTEXT", "GATEWAY_URL", "GATEWAY_TOKEN", "GATEWAYCTL_NAMESPACE", "GATEWAYCTL_DRAFT"} {
+		t.Setenv(k, "")
+	}
+	return path
+}
+
+func TestContexts(t *testing.T) {
+	path := useConfig(t)
+
+	if _, err := capture(t, "config", "current-context"); err == nil {
+		t.Error("current-context succeeded without a config file")
+	}
+	if _, err := capture(t, "config", "set-context", "prod"); err == nil {
+		t.Error("set-context created a context without --url")
+	}
+	if _, err := capture(t, "config", "set-context", "local", "--url", "http://localhost:8080", "--bearer", "dev"); err != nil {
+		t.Fatalf("set-context local: %v", err)
+	}
+	if _, err := capture(t, "config", "set-context", "prod", "--url", "https://gw.example.com", "--token-env", "PROD_TOKEN"); err != nil {
+		t.Fatalf("set-context prod: %v", err)
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0o600 {
+		t.Errorf("config file mode %v, want 0600: it can hold tokens", fi.Mode().Perm())
+	}
+
+	// The first context becomes current; later ones only with --use.
+	if out, _ := capture(t, "config", "current-context"); out != "local\n" {
+		t.Errorf("current-context = %q, want local", out)
+	}
+	if _, err := capture(t, "config", "use-context", "prod"); err != nil {
+		t.Fatalf("use-context: %v", err)
+	}
+	if _, err := capture(t, "config", "use-context", "missing"); err == nil {
+		t.Error("use-context accepted an unknown context")
+	}
+
+	out, err := capture(t, "config", "get-contexts")
+	if err != nil {
+		t.Fatalf("get-contexts: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[2], "*") || !strings.Contains(lines[2], "$PROD_TOKEN") || !strings.Contains(lines[1], "token") {
+		t.Errorf("get-contexts:\n%s", out)
+	}
+	if strings.Contains(out, "dev") {
+		t.Errorf("get-contexts printed a stored token:\n%s", out)
+	}
+
+	// Switching a context to an environment token drops the stored one.
+	if _, err := capture(t, "config", "set-context", "local", "--token-env", "LOCAL_TOKEN"); err != nil {
+		t.Fatal(err)
+	}
+	cfg, _, err := loadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c, _ := cfg.context("local"); c.Token != "" || c.TokenEnv != "LOCAL_TOKEN" || c.Server != "http://localhost:8080" {
+		t.Errorf("set-context local: got %+v", c)
+	}
+
+	if _, err := capture(t, "config", "delete-context", "prod"); err != nil {
+		t.Fatalf("delete-context: %v", err)
+	}
+	if _, err := capture(t, "config", "current-context"); err == nil {
+		t.Error("deleting the current context kept it current")
+	}
+}
+
+func TestClientFromContext(t *testing.T) {
+	useConfig(t)
+	if _, err := capture(t, "config", "set-context", "prod", "--url", "https://gw.example.com", "--token-env", "PROD_TOKEN"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := capture(t, "config", "set-context", "local", "--url", "http://localhost:9000"); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PROD_TOKEN", "from-env")
+
+	c, err := (&globals{}).client()
+	if err != nil || c.BaseURL() != "https://gw.example.com" {
+		t.Fatalf("current context: got %v, %v", c, err)
+	}
+	if c, _ := (&globals{context: "local"}).client(); c.BaseURL() != "http://localhost:9000" {
+		t.Errorf("--context local: server %s", c.BaseURL())
+	}
+	if c, _ := (&globals{server: "http://override"}).client(); c.BaseURL() != "http://override" {
+		t.Errorf("--server: server %s", c.BaseURL())
+	}
+	if _, err := (&globals{context: "missing"}).client(); err == nil {
+		t.Error("an unknown --context was accepted")
+	}
+	if c, _ := (&globals{draft: "d1"}).client(); c.Draft() != "d1" {
+		t.Errorf("--draft: client scoped to %q", c.Draft())
+	}
+
+	os.Remove(os.Getenv("GATEWAYCTL_CONFIG"))
+	if c, err := (&globals{}).client(); err != nil || c.BaseURL() != "http://localhost:8080" {
+		t.Errorf("no config: got %v, %v; want the local default", c, err)
+	}
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"ecomm/api-gateway/client"
+)
+
+// Drafts are edited with the services, routes and import commands and --draft ID.
+var draftCommands = map[string]*command{
+	"list":     {usage: "", maxArgs: 0, run: listDrafts},
+	"create":   createDraftCommand(),
+	"get":      {usage: "DRAFT", minArgs: 1, maxArgs: 1, run: getDraft},
+	"diff":     {usage: "DRAFT", minArgs: 1, maxArgs: 1, run: diffDraft},
+	"validate": validateDraftCommand(),
+	"publish":  publishDraftCommand(),
+	"delete":   {usage: "DRAFT", minArgs: 1, maxArgs: 1, run: deleteDraft},
+}
+
+var revisionCommands = map[string]*command{
+	"list":     {usage: "", maxArgs: 0, run: listRevisions},
+	"get":      {usage: "REVISION|latest", minArgs: 1, maxArgs: 1, run: getRevision},
+	"diff":     {usage: "REVISION|latest", minArgs: 1, maxArgs: 1, run: diffRevision},
+	"rollback": rollbackCommand(),
+}
+
+func listDrafts(ctx context.Context, g *globals, args []string) error {
+	c, err := g.client()
+	if err != nil {
+		return err
+	}
+	list, err := c.ListDrafts(ctx)
+	if err != nil {
+		return err
+	}
+	if list == nil {
+		list = []client.Draft{}
+	}
+	return printDrafts(g.output, list, list)
+}
+
+func createDraftCommand() *command {
+	var description string
+	return &command{
+		usage:   "NAME [--description TEXT]",
+		minArgs: 1, maxArgs: 1,
+		flags: func(fs *flag.FlagSet) {
+			fs.StringVar(&description, "description", "", "what the draft is for")
+		},
+		run: func(ctx context.Context, g *globals, args []string) error {
+			c, err := g.client()
+			if err != nil {
+				return err
+			}
+			d, err := c.CreateDraft(ctx, args[0], description)
+			if err != nil {
+				return err
+			}
+			return printDrafts(g.output, d, []client.Draft{*d})
+		},
+	}
+}
+
+func getDraft(ctx context.Context, g *globals, args []string) error {
+	c, err := g.client()
+	if err != nil {
+		return err
+	}
+	d, err := c.GetDraft(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	return printDrafts(g.output, d, []client.Draft{*d})
+}
+
+func diffDraft(ctx context.Context, g *globals, args []string) error {
+	c, err := g.client()
+	if err != nil {
+		return err
+	}
+	diff, err := c.DiffDraft(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	return printSnapshotDiff(g.output, diff)
+}
+
+func validateDraftCommand() *command {
+	var opts client.CreateOptions
+	return &command{
+		usage:   "DRAFT [--allow-prefix-overlap] [--skip-upstream-check]",
+		minArgs: 1, maxArgs: 1,
+		flags: func(fs *flag.FlagSet) {
+			fs.BoolVar(&opts.AllowPrefixOverlap, "allow-prefix-overlap", false, "accept nested prefixes")
+			fs.BoolVar(&opts.SkipUpstreamCheck, "skip-upstream-check", false, "don't probe upstreams for reachability")
+		},
+		run: func(ctx context.Context, g *globals, args []string) error {
+			c, err := g.client()
+			if err != nil {
+				return err
+			}
+			rep, err := c.ValidateDraft(ctx, args[0], opts)
+			if err != nil {
+				return err
+			}
+			if err := printReport(g.output, rep); err != nil {
+				return err
+			}
+			if !rep.Valid {
+				return fmt.Errorf("draft %s is invalid", args[0])
+			}
+			return nil
+		},
+	}
+}
+
+func publishDraftCommand() *command {
+	var message string
+	var force bool
+	var opts client.CreateOptions
+	return &command{
+		usage:   "DRAFT [-m MESSAGE] [--force] [--allow-prefix-overlap] [--skip-upstream-check]",
+		minArgs: 1, maxArgs: 1,
+		flags: func(fs *flag.FlagSet) {
+			fs.StringVar(&message, "m", "", "revision message")
+			fs.BoolVar(&force, "force", false, "publish even if the live registry changed since the draft was created")
+			fs.BoolVar(&opts.AllowPrefixOverlap, "allow-prefix-overlap", false, "accept nested prefixes")
+			fs.BoolVar(&opts.SkipUpstreamCheck, "skip-upstream-check", false, "don't probe upstreams for reachability")
+		},
+		run: func(ctx context.Context, g *globals, args []string) error {
+			c, err := g.client()
+			if err != nil {
+				return err
+			}
+			rev, err := c.PublishDraft(ctx, args[0], message, force, opts)
+			if err != nil {
+				if client.IsStaleDraft(err) {
+					return fmt.Errorf("%w\nreview \"gatewayctl drafts diff %s\" and publish with --force", err, args[0])
+				}
+				return err
+			}
+			return printRevision(g.output, rev)
+		},
+	}
+}
+
+func deleteDraft(ctx context.Context, g *globals, args []string) error {
+	c, err := g.client()
+	if err != nil {
+		return err
+	}
+	if err := c.DeleteDraft(ctx, args[0]); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "draft %s deleted\n", args[0])
+	return nil
+}
+
+func listRevisions(ctx context.Context, g *globals, args []string) error {
+	c, err := g.client()
+	if err != nil {
+		return err
+	}
+	list, err := c.ListRevisions(ctx)
+	if err != nil {
+		return err
+	}
+	if list == nil {
+		list = []client.Revision{}
+	}
+	return render(g.output, list, func(tw *tabwriter.Writer) {
+		fmt.Fprintln(tw, "REVISION\tSOURCE\tCHANGES\tCREATED\tMESSAGE")
+		for _, r := range list {
+			fmt.Fprintf(tw, "%d\t%s\t%d\t%s\t%s\n", r.Number, r.Source, len(r.Changes), since(r.CreatedAt), orDash(r.Message))
+		}
+	})
+}
+
+func getRevision(ctx context.Context, g *globals, args []string) error {
+	n, err := parseRevision(args[0])
+	if err != nil {
+		return err
+	}
+	c, err := g.client()
+	if err != nil {
+		return err
+	}
+	rev, err := c.GetRevision(ctx, n)
+	if err != nil {
+		return err
+	}
+	return printRevision(g.output, rev)
+}
+
+func diffRevision(ctx context.Context, g *globals, args []string) error {
+	n, err := parseRevision(args[0])
+	if err != nil {
+		return err
+	}
+	c, err := g.client()
+	if err != nil {
+		return err
+	}
+	diff, err := c.DiffRevision(ctx, n)
+	if err != nil {
+		return err
+	}
+	return printSnapshotDiff(g.output, diff)
+}
+
+func rollbackCommand() *command {
+	var message string
+	return &command{
+		usage:   "REVISION [-m MESSAGE]",
+		minArgs: 1, maxArgs: 1,
+		flags: func(fs *flag.FlagSet) {
+			fs.StringVar(&message, "m", "", "revision message (default: rollback to revision N)")
+		},
+		run: func(ctx context.Context, g *globals, args []string) error {
+			n, err := parseRevision(args[0])
+			if err != nil {
+				return err
+			}
+			c, err := g.client()
+			if err != nil {
+				return err
+			}
+			rev, err := c.Rollback(ctx, n, message)
+			if err != nil {
+				return err
+			}
+			return printRevision(g.output, rev)
+		},
+	}
+}
+
+// parseRevision accepts a revision number or "latest" (0).
+func parseRevision(arg string) (int64, error) {
+	if arg == "latest" {
+		return 0, nil
+	}
+	n, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("invalid revision %q (want a number or latest)", arg)
+	}
+	return n, nil
+}
+
+func printDrafts(format string, v any, list []client.Draft) error {
+	return render(format, v, func(tw *tabwriter.Writer) {
+		fmt.Fprintln(tw, "ID\tNAME\tSERVICES\tROUTES\tUPDATED\tVERSION")
+		for _, d := range list {
+			fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%s\t%d\n", d.ID, d.Name, d.Services, d.Routes, since(d.UpdatedAt), d.Version)
+		}
+	})
+}
+
+func printRevision(format string, rev *client.Revision) error {
+	return render(format, rev, func(tw *tabwriter.Writer) {
+		fmt.Fprintf(tw, "revision %d (%s)", rev.Number, rev.Source)
+		if rev.Message != "" {
+			fmt.Fprintf(tw, ": %s", rev.Message)
+		}
+		fmt.Fprintln(tw)
+		printSnapshotChanges(tw, rev.Changes)
+	})
+}
+
+func printSnapshotDiff(format string, diff *client.SnapshotDiff) error {
+	if diff.Changes == nil {
+		diff.Changes = []client.SnapshotChange{}
+	}
+	return render(format, diff, func(tw *tabwriter.Writer) {
+		if !diff.Current {
+			fmt.Fprintln(tw, "warning: the live registry changed since the draft was created")
+		}
+		printSnapshotChanges(tw, diff.Changes)
+	})
+}
+
+func printSnapshotChanges(tw *tabwriter.Writer, changes []client.SnapshotChange) {
+	if len(changes) == 0 {
+		fmt.Fprintln(tw, "no changes")
+		return
+	}
+	fmt.Fprintln(tw, "ACTION\tKIND\tSERVICE\tROUTE\tFIELDS")
+	for _, c := range changes {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", c.Action, c.Kind, c.Service, orDash(c.Route), orDash(strings.Join(c.Fields, ", ")))
+	}
+}
@@ -0,0 +1,211 @@
+// Command gatewayctl manages an API gateway through its Admin API: services, routes, route
+// discovery, swagger refresh, health status, export/import of the whole registry as YAML,
+// drafts and revisions of the registry, and namespaces and promotion between them.
+//
+// Gateways are addressed through contexts stored in the config file (see "gatewayctl config").
+// The --server and --token flags, or the GATEWAY_URL and GATEWAY_TOKEN environment variables,
+// override the current context.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+
+	"ecomm/api-gateway/client"
+)
+
+const usage = `gatewayctl manages an API gateway through its Admin API.
+
+Usage:
+  gatewayctl <command> [arguments] [flags]
+
+Commands:
+  services list|get|create|update|patch|delete|refresh   manage services
+  services promote SERVICE --to NS                        copy a service to another namespace
+  routes list|get|create|update|delete|discover          manage routes of grpc-json services
+  health [SERVICE]                                        gateway and service health
+  export [-f FILE]                                        write the registry as YAML
+  import -f FILE|DIR [--prune] [--dry-run]                make the gateway match a registry file or directory
+  drafts list|create|get|diff|validate|publish|delete    stage changes and publish them at once
+  revisions list|get|diff|rollback                        published states of the registry
+  namespaces list|get|create|update|delete                isolated route tables of the gateway
+  config get-contexts|current-context|use-context|set-context|delete-context
+
+SERVICE is a service ID, public prefix (e.g. /api/users/) or name.
+
+Global flags (accepted by every command):
+  --context NAME     gateway context to use (default: current context)
+  --server URL       gateway address, overrides the context
+  --token TOKEN      Admin API bearer token, overrides the context
+  -n, --namespace NS address services, drafts and revisions of namespace NS (default: the
+                     context's namespace, else "default")
+  --draft ID         edit services and routes of a draft instead of the live registry
+  -o, --output FMT   table (default), json or yaml
+`
+
+// globals are the flags every command accepts.
+type globals struct {
+	context   string
+	server    string
+	token     string
+	namespace string
+	draft     string
+	output    string
+}
+
+func (g *globals) register(fs *flag.FlagSet) {
+	fs.StringVar(&g.context, "context", os.Getenv("GATEWAYCTL_CONTEXT"), "gateway context")
+	fs.StringVar(&g.server, "server", os.Getenv("GATEWAY_URL"), "gateway address")
+	fs.StringVar(&g.token, "token", os.Getenv("GATEWAY_TOKEN"), "Admin API bearer token")
+	fs.StringVar(&g.namespace, "namespace", os.Getenv("GATEWAYCTL_NAMESPACE"), "namespace of services, drafts and revisions")
+	fs.StringVar(&g.namespace, "n", os.Getenv("GATEWAYCTL_NAMESPACE"), "namespace (shorthand)")
+	fs.StringVar(&g.draft, "draft", os.Getenv("GATEWAYCTL_DRAFT"), "draft to edit instead of the live registry")
+	fs.StringVar(&g.output, "output", "table", "output format: table, json or yaml")
+	fs.StringVar(&g.output, "o", "table", "output format (shorthand)")
+}
+
+// client builds an Admin API client from the flags and the selected context.
+func (g *globals) client() (*client.Client, error) {
+	cfg, _, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	server, token, namespace := g.server, g.token, g.namespace
+	if server == "" || token == "" || namespace == "" {
+		ctx, err := cfg.context(g.context)
+		if err != nil && server == "" {
+			return nil, err
+		}
+		if ctx != nil {
+			if server == "" {
+				server = ctx.Server
+			}
+			if token == "" {
+				token = ctx.token()
+			}
+			if namespace == "" {
+				namespace = ctx.Namespace
+			}
+		}
+	}
+	if server == "" {
+		server = "http://localhost:8080"
+	}
+	c := client.New(server, token)
+	if namespace != "" && namespace != "default" {
+		c = c.InNamespace(namespace)
+	}
+	if g.draft != "" {
+		c = c.InDraft(g.draft)
+	}
+	return c, nil
+}
+
+// command is a subcommand: it registers its flags on fs and runs with the remaining args.
+type command struct {
+	flags func(fs *flag.FlagSet)
+	run   func(ctx context.Context, g *globals, args []string) error
+	// minArgs and maxArgs bound the positional arguments (maxArgs < 0: unbounded).
+	minArgs, maxArgs int
+	usage            string
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if err := run(ctx, os.Args[1:]); err != nil {
+		if !errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintln(os.Stderr, "error:", err)
+		}
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, args []string) error {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		fmt.Fprint(stdout, usage)
+		return nil
+	}
+	groups := map[string]map[string]*command{
+		"services":   serviceCommands,
+		"routes":     routeCommands,
+		"drafts":     draftCommands,
+		"revisions":  revisionCommands,
+		"namespaces": namespaceCommands,
+		"config":     configCommands,
+	}
+	name, rest := args[0], args[1:]
+	var cmd *command
+	if group, ok := groups[name]; ok {
+		if len(rest) == 0 {
+			return fmt.Errorf("%s: missing subcommand (%s)", name, subcommands(group))
+		}
+		if cmd = group[rest[0]]; cmd == nil {
+			return fmt.Errorf("%s: unknown subcommand %q (%s)", name, rest[0], subcommands(group))
+		}
+		name, rest = name+" "+rest[0], rest[1:]
+	} else if cmd = topCommands[name]; cmd == nil {
+		return fmt.Errorf("unknown command %q; run gatewayctl help", name)
+	}
+	g := &globals{}
+	fs := flag.NewFlagSet("gatewayctl "+name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: gatewayctl %s %s\n", name, cmd.usage)
+		fs.PrintDefaults()
+	}
+	g.register(fs)
+	if cmd.flags != nil {
+		cmd.flags(fs)
+	}
+	pos, err := parseInterleaved(fs, rest)
+	if err != nil {
+		return err
+	}
+	if len(pos) < cmd.minArgs || (cmd.maxArgs >= 0 && len(pos) > cmd.maxArgs) {
+		fs.Usage()
+		return fmt.Errorf("%s: wrong number of arguments", name)
+	}
+	switch g.output {
+	case "table", "json", "yaml":
+	default:
+		return fmt.Errorf("unknown output format %q (want table, json or yaml)", g.output)
+	}
+	return cmd.run(ctx, g, pos)
+}
+
+// parseInterleaved parses flags that appear before, between or after positional arguments.
+func parseInterleaved(fs *flag.FlagSet, args []string) ([]string, error) {
+	var pos []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return pos, nil
+		}
+		pos = append(pos, args[0])
+		args = args[1:]
+	}
+}
+
+func subcommands(group map[string]*command) string {
+	names := make([]string, 0, len(group))
+	for n := range group {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+var topCommands = map[string]*command{
+	"health": healthCommand,
+	"export": exportCommand,
+	"import": importCommand,
+}
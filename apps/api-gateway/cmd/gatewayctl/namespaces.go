@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"ecomm/api-gateway/client"
+)
+
+var namespaceCommands = map[string]*command{
+	"list":   {usage: "", maxArgs: 0, run: listNamespaces},
+	"get":    {usage: "NAMESPACE", minArgs: 1, maxArgs: 1, run: getNamespace},
+	"create": writeNamespaceCommand(false),
+	"update": writeNamespaceCommand(true),
+	"delete": {usage: "NAMESPACE", minArgs: 1, maxArgs: 1, run: deleteNamespace},
+}
+
+// listFlags collects repeated flags, e.g. --host a --host b.
+type listFlags []string
+
+func (l *listFlags) String() string { return strings.Join(*l, ",") }
+
+func (l *listFlags) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+func listNamespaces(ctx context.Context, g *globals, args []string) error {
+	c, err := g.client()
+	if err != nil {
+		return err
+	}
+	list, err := c.ListNamespaces(ctx)
+	if err != nil {
+		return err
+	}
+	if list == nil {
+		list = []client.Namespace{}
+	}
+	return printNamespaces(g.output, list, list)
+}
+
+func getNamespace(ctx context.Context, g *globals, args []string) error {
+	c, err := g.client()
+	if err != nil {
+		return err
+	}
+	ns, err := c.GetNamespace(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	return render(g.output, ns, func(tw *tabwriter.Writer) {
+		fmt.Fprintf(tw, "Name:\t%s\n", ns.Name)
+		fmt.Fprintf(tw, "Description:\t%s\n", orDash(ns.Description))
+		fmt.Fprintf(tw, "Hosts:\t%s\n", orDash(strings.Join(ns.Hosts, ", ")))
+		fmt.Fprintf(tw, "Version:\t%d\n", ns.Version)
+		if len(ns.Members) == 0 {
+			fmt.Fprintln(tw, "Members:\t-")
+			return
+		}
+		fmt.Fprintln(tw, "Members:")
+		for _, m := range ns.Members {
+			fmt.Fprintf(tw, "  %s\t%s\n", m.Subject, m.Role)
+		}
+	})
+}
+
+// writeNamespaceCommand creates a namespace or updates one. An update replaces the hosts and
+// members given and keeps the others unless --clear-hosts or --clear-members is set.
+func writeNamespaceCommand(update bool) *command {
+	var description string
+	var hosts, members, remove listFlags
+	var clearHosts, clearMembers bool
+	usage := "NAME [--description TEXT] [--host HOST]... [--member SUBJECT=ROLE]..."
+	if update {
+		usage = "NAME [--description TEXT] [--host HOST]... [--member SUBJECT=ROLE]... [--remove-member SUBJECT]... [--clear-hosts]"
+	}
+	return &command{
+		usage:   usage,
+		minArgs: 1, maxArgs: 1,
+		flags: func(fs *flag.FlagSet) {
+			fs.StringVar(&description, "description", "", "what the namespace is for")
+			fs.Var(&hosts, "host", "request host name selecting the namespace, *.domain for subdomains (repeatable)")
+			fs.Var(&members, "member", "grant a role: SUBJECT=viewer|editor|admin, SUBJECT may be group:NAME (repeatable)")
+			if update {
+				fs.Var(&remove, "remove-member", "revoke the roles of SUBJECT (repeatable)")
+				fs.BoolVar(&clearHosts, "clear-hosts", false, "remove every host name")
+				fs.BoolVar(&clearMembers, "clear-members", false, "remove every member")
+			}
+		},
+		run: func(ctx context.Context, g *globals, args []string) error {
+			c, err := g.client()
+			if err != nil {
+				return err
+			}
+			granted, err := parseMembers(members)
+			if err != nil {
+				return err
+			}
+			if !update {
+				ns, err := c.CreateNamespace(ctx, &client.Namespace{Name: args[0], Description: description, Hosts: hosts, Members: granted})
+				if err != nil {
+					return err
+				}
+				return printNamespaces(g.output, ns, []client.Namespace{*ns})
+			}
+			ns, err := c.GetNamespace(ctx, args[0])
+			if err != nil {
+				return err
+			}
+			if description != "" {
+				ns.Description = description
+			}
+			if clearHosts {
+				ns.Hosts = nil
+			}
+			ns.Hosts = append(ns.Hosts, hosts...)
+			if clearMembers {
+				ns.Members = nil
+			}
+			ns.Members = mergeMembers(ns.Members, granted, remove)
+			out, err := c.UpdateNamespace(ctx, ns)
+			if err != nil {
+				return err
+			}
+			return printNamespaces(g.output, out, []client.Namespace{*out})
+		},
+	}
+}
+
+// parseMembers parses SUBJECT=ROLE flags.
+func parseMembers(flags []string) ([]client.Member, error) {
+	var out []client.Member
+	for _, f := range flags {
+		subject, role, ok := strings.Cut(f, "=")
+		if !ok || subject == "" {
+			return nil, fmt.Errorf("invalid --member %q (want SUBJECT=ROLE)", f)
+		}
+		out = append(out, client.Member{Subject: subject, Role: role})
+	}
+	return out, nil
+}
+
+// mergeMembers replaces the roles of granted subjects and drops removed ones.
+func mergeMembers(cur, granted []client.Member, remove []string) []client.Member {
+	drop := map[string]bool{}
+	for _, s := range remove {
+		drop[s] = true
+	}
+	for _, m := range granted {
+		drop[m.Subject] = true
+	}
+	out := []client.Member{}
+	for _, m := range cur {
+		if !drop[m.Subject] {
+			out = append(out, m)
+		}
+	}
+	return append(out, granted...)
+}
+
+func deleteNamespace(ctx context.Context, g *globals, args []string) error {
+	c, err := g.client()
+	if err != nil {
+		return err
+	}
+	if err := c.DeleteNamespace(ctx, args[0]); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "namespace %s deleted\n", args[0])
+	return nil
+}
+
+func printNamespaces(format string, v any, list []client.Namespace) error {
+	return render(format, v, func(tw *tabwriter.Writer) {
+		fmt.Fprintln(tw, "NAME\tHOSTS\tMEMBERS\tUPDATED\tVERSION")
+		for _, ns := range list {
+			fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%d\n", ns.Name, orDash(strings.Join(ns.Hosts, ",")), len(ns.Members), since(ns.UpdatedAt), ns.Version)
+		}
+	})
+}
+
+func promoteServiceCommand() *command {
+	var to string
+	var dryRun bool
+	var opts client.PromoteOptions
+	return &command{
+		usage:   "SERVICE --to NS [-m MESSAGE] [--base-url URL] [--swagger-url URL] [--grpc-target HOST:PORT] [--dry-run]",
+		minArgs: 1, maxArgs: 1,
+		flags: func(fs *flag.FlagSet) {
+			fs.StringVar(&to, "to", "", "target namespace")
+			fs.StringVar(&opts.Message, "m", "", "revision message in the target namespace")
+			fs.StringVar(&opts.BaseURL, "base-url", "", "upstream base URL in the target namespace")
+			fs.StringVar(&opts.SwaggerURL, "swagger-url", "", "upstream swagger URL in the target namespace")
+			fs.StringVar(&opts.GRPCTarget, "grpc-target", "", "upstream gRPC target in the target namespace")
+			fs.BoolVar(&dryRun, "dry-run", false, "show the changes and validation report only")
+			fs.BoolVar(&opts.AllowPrefixOverlap, "allow-prefix-overlap", false, "accept nested prefixes")
+			fs.BoolVar(&opts.SkipUpstreamCheck, "skip-upstream-check", false, "don't probe the upstream for reachability")
+		},
+		run: func(ctx context.Context, g *globals, args []string) error {
+			if to == "" {
+				return errors.New("--to is required")
+			}
+			c, err := g.client()
+			if err != nil {
+				return err
+			}
+			svc, err := resolveService(ctx, c, args[0])
+			if err != nil {
+				return err
+			}
+			if dryRun {
+				plan, err := c.PlanPromotion(ctx, svc.ID, to, opts)
+				if err != nil {
+					return err
+				}
+				err = render(g.output, plan, func(tw *tabwriter.Writer) { printSnapshotChanges(tw, plan.Changes) })
+				if err != nil || g.output == "json" || g.output == "yaml" || plan.Report == nil {
+					return err
+				}
+				if err := printReport(g.output, plan.Report); err != nil {
+					return err
+				}
+				if !plan.Report.Valid {
+					return fmt.Errorf("promoting %s to %s would fail validation", svc.PublicPrefix, to)
+				}
+				return nil
+			}
+			rev, err := c.PromoteService(ctx, svc.ID, to, opts)
+			if err != nil {
+				return err
+			}
+			return printRevision(g.output, rev)
+		},
+	}
+}
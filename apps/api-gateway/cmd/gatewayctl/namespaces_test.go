@@ -0,0 +1,30 @@
+package main
+
+import "testing"
+
+func TestContextNamespace(t *testing.T) {
+	useConfig(t)
+	if _, err := capture(t, "config", "set-context", "staging", "--url", "https://gw.example.com", "--default-namespace", "staging"); err != nil {
+		t.Fatalf("set-context: %v", err)
+	}
+	if c, _ := (&globals{}).client(); c.Namespace() != "staging" {
+		t.Errorf("context namespace: client scoped to %q", c.Namespace())
+	}
+	if c, _ := (&globals{namespace: "prod"}).client(); c.Namespace() != "prod" {
+		t.Errorf("--namespace: client scoped to %q", c.Namespace())
+	}
+	if _, err := capture(t, "config", "get-contexts", "-n", "qa"); err != nil {
+		t.Fatalf("global -n with a config command: %v", err)
+	}
+
+	// "default" resets the context to the default namespace, which clients address unscoped.
+	if _, err := capture(t, "config", "set-context", "staging", "--default-namespace", "default"); err != nil {
+		t.Fatal(err)
+	}
+	if c, _ := (&globals{}).client(); c.Namespace() != "" {
+		t.Errorf("reset context: client scoped to %q", c.Namespace())
+	}
+	if c, _ := (&globals{namespace: "default"}).client(); c.Namespace() != "" {
+		t.Errorf("--namespace default: client scoped to %q", c.Namespace())
+	}
+}
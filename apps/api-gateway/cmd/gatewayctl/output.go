@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/invopop/yaml"
+
+	"ecomm/api-gateway/client"
+)
+
+// stdout receives command output; tests replace it.
+var stdout io.Writer = os.Stdout
+
+// render prints v as JSON or YAML, or calls table to print it as aligned columns.
+func render(format string, v any, table func(tw *tabwriter.Writer)) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	case "yaml":
+		data, err := yaml.Marshal(v)
+		if err != nil {
+			return err
+		}
+		_, err = stdout.Write(data)
+		return err
+	}
+	tw := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	table(tw)
+	return tw.Flush()
+}
+
+func printServices(format string, v any, list []client.Service) error {
+	return render(format, v, func(tw *tabwriter.Writer) {
+		fmt.Fprintln(tw, "ID\tNAME\tPREFIX\tPROTOCOL\tUPSTREAM\tENABLED\tSTATUS\tVERSION")
+		for _, s := range list {
+			upstream := s.BaseURL
+			if s.Protocol != "" && s.Protocol != "http" {
+				upstream = s.GRPCTarget
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%t\t%s\t%d\n", s.ID, s.Name, s.PublicPrefix, orDash(s.Protocol), orDash(upstream), s.Enabled, orDash(s.LastStatus), s.Version)
+		}
+	})
+}
+
+func printRoutes(format string, v any, list []client.Route) error {
+	return render(format, v, func(tw *tabwriter.Writer) {
+		fmt.Fprintln(tw, "ID\tMETHOD\tPATH\tGRPC METHOD\tBODY\tSOURCE\tVERSION")
+		for _, r := range list {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%d\n", r.ID, r.Method, r.Path, r.GRPCMethod, orDash(r.Body), orDash(r.Source), r.Version)
+		}
+	})
+}
+
+func printChanges(format string, changes []client.Change) error {
+	if changes == nil {
+		changes = []client.Change{}
+	}
+	return render(format, changes, func(tw *tabwriter.Writer) {
+		fmt.Fprintln(tw, "ACTION\tKIND\tSERVICE\tROUTE")
+		for _, c := range changes {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", c.Action, c.Kind, c.Service, orDash(c.Route))
+		}
+	})
+}
+
+func printReport(format string, rep *client.ValidationReport) error {
+	return render(format, rep, func(tw *tabwriter.Writer) {
+		fmt.Fprintf(tw, "valid: %t\n", rep.Valid)
+		issues := func(kind string, list []client.FieldIssue) {
+			for _, i := range list {
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", kind, i.Code, orDash(i.Field), i.Message)
+			}
+		}
+		if len(rep.Errors)+len(rep.Warnings) > 0 {
+			fmt.Fprintln(tw, "SEVERITY\tCODE\tFIELD\tMESSAGE")
+			issues("error", rep.Errors)
+			issues("warning", rep.Warnings)
+		}
+	})
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func since(t time.Time) string {
+	if t.IsZero() || t.Unix() <= 0 {
+		return "never"
+	}
+	return time.Since(t).Round(time.Second).String() + " ago"
+}
+
+func labels(m map[string]string) string {
+	if len(m) == 0 {
+		return "-"
+	}
+	parts := make([]string, 0, len(m))
+	for k, v := range m {
+		parts = append(parts, k+"="+v)
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
+
+// readInput reads a file, or stdin for "-".
+func readInput(path string) ([]byte, error) {
+	if path == "" {
+		return nil, fmt.Errorf("-f FILE is required (use - for stdin)")
+	}
+	if path == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(path)
+}
+
+// decodeFile reads a YAML or JSON document (JSON is valid YAML) into v.
+func decodeFile(path string, v any) error {
+	data, err := readInput(path)
+	if err != nil {
+		return err
+	}
+	if err := yaml.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/invopop/yaml"
+
+	"ecomm/api-gateway/client"
+)
+
+var services = []client.Service{
+	{ID: "s1", Name: "users", PublicPrefix: "/api/users/", BaseURL: "http://users", Enabled: true, LastStatus: "healthy", Version: 3},
+	{ID: "s2", Name: "orders", PublicPrefix: "/api/orders/", Protocol: "grpc-json", GRPCTarget: "orders:9090", Version: 1},
+}
+
+func TestPrintServices(t *testing.T) {
+	var buf bytes.Buffer
+	defer func(w io.Writer) { stdout = w }(stdout)
+	stdout = &buf
+
+	if err := printServices("table", services, services); err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Join([]string{
+		"ID  NAME    PREFIX        PROTOCOL   UPSTREAM      ENABLED  STATUS   VERSION",
+		"s1  users   /api/users/   -          http://users  true     healthy  3",
+		"s2  orders  /api/orders/  grpc-json  orders:9090   false    -        1",
+		"",
+	}, "\n")
+	if got := buf.String(); got != want {
+		t.Errorf("table:\n%s\nwant:\n%s", got, want)
+	}
+
+	for _, format := range []string{"json", "yaml"} {
+		buf.Reset()
+		if err := printServices(format, services, services); err != nil {
+			t.Fatal(err)
+		}
+		var back []client.Service
+		var err error
+		if format == "json" {
+			err = json.Unmarshal(buf.Bytes(), &back)
+		} else {
+			err = yaml.Unmarshal(buf.Bytes(), &back)
+		}
+		if err != nil || len(back) != 2 || back[1].GRPCTarget != "orders:9090" {
+			t.Errorf("%s: got %+v, %v from\n%s", format, back, err, buf.String())
+		}
+	}
+}
+
+func TestPrintReport(t *testing.T) {
+	var buf bytes.Buffer
+	defer func(w io.Writer) { stdout = w }(stdout)
+	stdout = &buf
+	rep := &client.ValidationReport{
+		Errors:   []client.FieldIssue{{Code: "required", Field: "name", Message: "name is required"}},
+		Warnings: []client.FieldIssue{{Code: "unreachable", Message: "upstream did not answer"}},
+	}
+	if err := printReport("table", rep); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"valid: false", "error     required     name   name is required", "warning   unreachable  -      upstream did not answer"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("report lacks %q:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestFormatHelpers(t *testing.T) {
+	if orDash("") != "-" || orDash("x") != "x" {
+		t.Error("orDash")
+	}
+	if got := labels(map[string]string{"tier": "1", "team": "core"}); got != "team=core,tier=1" {
+		t.Errorf("labels = %q", got)
+	}
+	if got := labels(nil); got != "-" {
+		t.Errorf("labels(nil) = %q", got)
+	}
+	l := labelFlags{}
+	if err := l.Set("team=core"); err != nil || l["team"] != "core" {
+		t.Errorf("labelFlags.Set: %v, %v", l, err)
+	}
+	if err := l.Set("=x"); err == nil {
+		t.Error("labelFlags accepted an empty key")
+	}
+}
+
+func TestServicesCommands(t *testing.T) {
+	useConfig(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/admin/v1/services":
+			json.NewEncoder(w).Encode(services)
+		case "/admin/v1/services/s2":
+			w.Header().Set("ETag", `"1"`)
+			json.NewEncoder(w).Encode(services[1])
+		default:
+			w.Header().Set("Content-Type", "application/problem+json")
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"title":"Not Found","status":404,"code":"not_found","detail":"service not found"}`)
+		}
+	}))
+	defer srv.Close()
+
+	out, err := capture(t, "services", "list", "--server", srv.URL, "-o", "json")
+	if err != nil {
+		t.Fatalf("services list: %v", err)
+	}
+	if !strings.Contains(out, `"grpc_target": "orders:9090"`) {
+		t.Errorf("services list -o json:\n%s", out)
+	}
+	// Services are found by ID, prefix with or without the trailing slash, or name.
+	for _, arg := range []string{"s2", "/api/orders", "orders"} {
+		out, err := capture(t, "services", "get", arg, "--server", srv.URL)
+		if err != nil || !strings.Contains(out, "orders:9090") {
+			t.Errorf("services get %s: %v\n%s", arg, err, out)
+		}
+	}
+	if _, err := capture(t, "services", "get", "/api/missing", "--server", srv.URL); err == nil {
+		t.Error("services get of an unknown prefix succeeded")
+	}
+
+	if _, err := capture(t, "services", "list", "-o", "xml", "--server", srv.URL); err == nil {
+		t.Error("an unknown output format was accepted")
+	}
+	if _, err := capture(t, "services", "bogus"); err == nil {
+		t.Error("an unknown subcommand was accepted")
+	}
+	if _, err := capture(t, "services", "get"); err == nil {
+		t.Error("services get without an argument was accepted")
+	}
+}
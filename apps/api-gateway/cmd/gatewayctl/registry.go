@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/invopop/yaml"
+
+	"ecomm/api-gateway/client"
+	"ecomm/api-gateway/internal/gitops"
+)
+
+var exportCommand = func() *command {
+	var file string
+	return &command{
+		usage:   "[-f FILE]",
+		maxArgs: 0,
+		flags: func(fs *flag.FlagSet) {
+			fs.StringVar(&file, "f", "", "write to FILE instead of stdout")
+		},
+		run: func(ctx context.Context, g *globals, args []string) error {
+			c, err := g.client()
+			if err != nil {
+				return err
+			}
+			doc, err := c.Export(ctx)
+			if err != nil {
+				return err
+			}
+			if file == "" || file == "-" {
+				format := g.output
+				if format == "table" {
+					format = "yaml"
+				}
+				return render(format, doc, nil)
+			}
+			data, err := yaml.Marshal(doc)
+			if err != nil {
+				return err
+			}
+			if err := os.WriteFile(file, data, 0o644); err != nil {
+				return err
+			}
+			fmt.Fprintf(os.Stderr, "exported %d services to %s\n", len(doc.Services), file)
+			return nil
+		},
+	}
+}()
+
+var importCommand = func() *command {
+	var file string
+	var opts client.ImportOptions
+	return &command{
+		usage:   "-f FILE|DIR [--prune] [--dry-run] [--allow-prefix-overlap] [--skip-upstream-check]",
+		maxArgs: 0,
+		flags: func(fs *flag.FlagSet) {
+			fs.StringVar(&file, "f", "", "registry document, YAML or JSON (- for stdin), or a directory of service definitions")
+			fs.BoolVar(&opts.Prune, "prune", false, "delete services and routes the document doesn't contain")
+			fs.BoolVar(&opts.DryRun, "dry-run", false, "print the changes without making them")
+			fs.BoolVar(&opts.Create.AllowPrefixOverlap, "allow-prefix-overlap", false, "accept nested prefixes")
+			fs.BoolVar(&opts.Create.SkipUpstreamCheck, "skip-upstream-check", false, "don't probe upstreams for reachability")
+		},
+		run: func(ctx context.Context, g *globals, args []string) error {
+			doc, err := loadDocument(file)
+			if err != nil {
+				return err
+			}
+			c, err := g.client()
+			if err != nil {
+				return err
+			}
+			changes, err := c.Import(ctx, doc, opts)
+			if perr := printChanges(g.output, changes); perr != nil && err == nil {
+				err = perr
+			}
+			return err
+		},
+	}
+}()
+
+// loadDocument reads a registry document, or the service definitions of a directory as the
+// gateway reads them with GATEWAY_CONFIG_DIR.
+func loadDocument(path string) (*client.Document, error) {
+	doc := &client.Document{}
+	if fi, err := os.Stat(path); err != nil || !fi.IsDir() {
+		if err := decodeFile(path, doc); err != nil {
+			return nil, err
+		}
+		return doc, nil
+	}
+	cfg, err := gitops.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	doc.APIVersion, doc.Kind = client.DocumentAPIVersion, client.DocumentKind
+	for _, spec := range cfg.Services {
+		doc.Services = append(doc.Services, spec.ServiceSpec)
+	}
+	return doc, nil
+}
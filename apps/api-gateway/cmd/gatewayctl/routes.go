@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"ecomm/api-gateway/client"
+)
+
+var routeCommands = map[string]*command{
+	"list":     listRoutesCommand(),
+	"get":      {usage: "SERVICE ROUTE", minArgs: 2, maxArgs: 2, run: getRoute},
+	"create":   writeRouteCommand(false),
+	"update":   writeRouteCommand(true),
+	"delete":   deleteRouteCommand(),
+	"discover": discoverCommand(),
+}
+
+func listRoutesCommand() *command {
+	var source string
+	return &command{
+		usage:   "SERVICE [--source manual|annotation|generated]",
+		minArgs: 1, maxArgs: 1,
+		flags: func(fs *flag.FlagSet) {
+			fs.StringVar(&source, "source", "", "only routes of this origin")
+		},
+		run: func(ctx context.Context, g *globals, args []string) error {
+			c, err := g.client()
+			if err != nil {
+				return err
+			}
+			svc, err := resolveService(ctx, c, args[0])
+			if err != nil {
+				return err
+			}
+			list, err := c.ListRoutes(ctx, svc.ID, source)
+			if err != nil {
+				return err
+			}
+			if list == nil {
+				list = []client.Route{}
+			}
+			return printRoutes(g.output, list, list)
+		},
+	}
+}
+
+func getRoute(ctx context.Context, g *globals, args []string) error {
+	c, err := g.client()
+	if err != nil {
+		return err
+	}
+	_, rt, err := resolveRoute(ctx, c, args[0], args[1])
+	if err != nil {
+		return err
+	}
+	return printRoutes(g.output, rt, []client.Route{*rt})
+}
+
+// writeRouteCommand builds "routes create" or, with update set, "routes update". The file holds
+// a route in the export format (a RouteSpec).
+func writeRouteCommand(update bool) *command {
+	var file string
+	cmd := &command{
+		usage:   "SERVICE -f FILE",
+		minArgs: 1, maxArgs: 1,
+		flags: func(fs *flag.FlagSet) {
+			fs.StringVar(&file, "f", "", "route definition, YAML or JSON (- for stdin)")
+		},
+	}
+	if update {
+		cmd.usage = "SERVICE ROUTE -f FILE"
+		cmd.minArgs, cmd.maxArgs = 2, 2
+	}
+	cmd.run = func(ctx context.Context, g *globals, args []string) error {
+		var spec client.RouteSpec
+		if err := decodeFile(file, &spec); err != nil {
+			return err
+		}
+		c, err := g.client()
+		if err != nil {
+			return err
+		}
+		rt := spec.Route()
+		var out *client.Route
+		if update {
+			svc, cur, err := resolveRoute(ctx, c, args[0], args[1])
+			if err != nil {
+				return err
+			}
+			rt.ID, rt.Version = cur.ID, cur.Version
+			out, err = c.UpdateRoute(ctx, svc.ID, rt)
+			if err != nil {
+				return err
+			}
+		} else {
+			svc, err := resolveService(ctx, c, args[0])
+			if err != nil {
+				return err
+			}
+			if out, err = c.CreateRoute(ctx, svc.ID, rt); err != nil {
+				return err
+			}
+		}
+		return printRoutes(g.output, out, []client.Route{*out})
+	}
+	return cmd
+}
+
+func deleteRouteCommand() *command {
+	var force bool
+	return &command{
+		usage:   "SERVICE ROUTE [--force]",
+		minArgs: 2, maxArgs: 2,
+		flags: func(fs *flag.FlagSet) {
+			fs.BoolVar(&force, "force", false, "delete even if the route changed since it was looked up")
+		},
+		run: func(ctx context.Context, g *globals, args []string) error {
+			c, err := g.client()
+			if err != nil {
+				return err
+			}
+			svc, rt, err := resolveRoute(ctx, c, args[0], args[1])
+			if err != nil {
+				return err
+			}
+			version := rt.Version
+			if force {
+				version = 0
+			}
+			if err := c.DeleteRoute(ctx, svc.ID, rt.ID, version); err != nil {
+				return err
+			}
+			fmt.Fprintf(stdout, "route %s %s deleted\n", rt.Method, rt.Path)
+			return nil
+		},
+	}
+}
+
+func discoverCommand() *command {
+	var apply bool
+	return &command{
+		usage:   "SERVICE [--apply]",
+		minArgs: 1, maxArgs: 1,
+		flags: func(fs *flag.FlagSet) {
+			fs.BoolVar(&apply, "apply", false, "create routes for every discovered method that has none")
+		},
+		run: func(ctx context.Context, g *globals, args []string) error {
+			c, err := g.client()
+			if err != nil {
+				return err
+			}
+			svc, err := resolveService(ctx, c, args[0])
+			if err != nil {
+				return err
+			}
+			if apply {
+				res, err := c.AddDiscoveredRoutes(ctx, svc.ID)
+				if err != nil {
+					return err
+				}
+				return render(g.output, res, func(tw *tabwriter.Writer) {
+					fmt.Fprintf(tw, "created: %d\n", res.Created)
+					if len(res.Conflicts) > 0 {
+						fmt.Fprintln(tw, "\nCONFLICT\tGRPC METHOD\tREASON")
+						for _, ch := range res.Conflicts {
+							fmt.Fprintf(tw, "%s\t%s\t%s\n", ch.Action, ch.GRPCMethod, orDash(ch.Reason))
+						}
+					}
+				})
+			}
+			methods, err := c.DiscoverRoutes(ctx, svc.ID)
+			if err != nil {
+				return err
+			}
+			if methods == nil {
+				methods = []client.DiscoveredMethod{}
+			}
+			return render(g.output, methods, func(tw *tabwriter.Writer) {
+				fmt.Fprintln(tw, "GRPC METHOD\tHTTP BINDINGS")
+				for _, m := range methods {
+					var rules []string
+					for _, r := range m.HTTPRules {
+						rules = append(rules, r.Method+" "+r.Path)
+					}
+					fmt.Fprintf(tw, "%s\t%s\n", m.GRPCMethod, orDash(strings.Join(rules, ", ")))
+				}
+			})
+		},
+	}
+}
+
+// resolveRoute looks a route of the service up by ID or as "METHOD /path".
+func resolveRoute(ctx context.Context, c *client.Client, service, arg string) (*client.Service, *client.Route, error) {
+	svc, err := resolveService(ctx, c, service)
+	if err != nil {
+		return nil, nil, err
+	}
+	method, path, ok := strings.Cut(strings.TrimSpace(arg), " ")
+	if !ok {
+		rt, err := c.GetRoute(ctx, svc.ID, arg)
+		if err != nil {
+			return nil, nil, err
+		}
+		return svc, rt, nil
+	}
+	routes, err := c.ListRoutes(ctx, svc.ID, "")
+	if err != nil {
+		return nil, nil, err
+	}
+	for i := range routes {
+		if strings.EqualFold(routes[i].Method, method) && routes[i].Path == strings.TrimSpace(path) {
+			return svc, &routes[i], nil
+		}
+	}
+	return nil, nil, fmt.Errorf("route %q not found on %s", arg, svc.PublicPrefix)
+}
@@ -0,0 +1,336 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"ecomm/api-gateway/client"
+)
+
+var serviceCommands = map[string]*command{
+	"list":    listServicesCommand(),
+	"get":     {usage: "SERVICE", minArgs: 1, maxArgs: 1, run: getService},
+	"create":  writeServiceCommand(false),
+	"update":  writeServiceCommand(true),
+	"patch":   patchServiceCommand(),
+	"delete":  deleteServiceCommand(),
+	"refresh": {usage: "SERVICE", minArgs: 1, maxArgs: 1, run: refreshService},
+	"promote": promoteServiceCommand(),
+}
+
+// labelFlags collects repeated --label key=value flags.
+type labelFlags map[string]string
+
+func (l labelFlags) String() string { return labels(l) }
+
+func (l labelFlags) Set(v string) error {
+	k, val, ok := strings.Cut(v, "=")
+	if !ok || k == "" {
+		return fmt.Errorf("label %q must be key=value", v)
+	}
+	l[k] = val
+	return nil
+}
+
+func listServicesCommand() *command {
+	var opts client.ListOptions
+	var enabled string
+	lbls := labelFlags{}
+	return &command{
+		usage:   "[--protocol P] [--status S] [--enabled true|false] [-q TEXT] [--label k=v]... [--sort FIELD] [--limit N]",
+		maxArgs: 0,
+		flags: func(fs *flag.FlagSet) {
+			fs.StringVar(&opts.Protocol, "protocol", "", "only services of this protocol (http or grpc-json)")
+			fs.StringVar(&opts.Status, "status", "", "only services with this health status")
+			fs.StringVar(&enabled, "enabled", "", "only enabled (true) or disabled (false) services")
+			fs.StringVar(&opts.Search, "q", "", "search name, description and prefix")
+			fs.Var(lbls, "label", "only services with this label (repeatable)")
+			fs.StringVar(&opts.Sort, "sort", "", "name, public_prefix, created_at or updated_at; - for descending")
+			fs.IntVar(&opts.Limit, "limit", 0, "maximum number of services (default: all)")
+		},
+		run: func(ctx context.Context, g *globals, args []string) error {
+			c, err := g.client()
+			if err != nil {
+				return err
+			}
+			if enabled != "" {
+				b, err := strconv.ParseBool(enabled)
+				if err != nil {
+					return fmt.Errorf("--enabled: %w", err)
+				}
+				opts.Enabled = &b
+			}
+			opts.Labels = lbls
+			var list []client.Service
+			if opts.Limit > 0 {
+				list, _, err = c.ListServices(ctx, opts)
+			} else {
+				list, err = c.AllServices(ctx, opts)
+			}
+			if err != nil {
+				return err
+			}
+			if list == nil {
+				list = []client.Service{}
+			}
+			for i := range list {
+				list[i].SwaggerJSON = nil
+			}
+			return printServices(g.output, list, list)
+		},
+	}
+}
+
+func getService(ctx context.Context, g *globals, args []string) error {
+	c, err := g.client()
+	if err != nil {
+		return err
+	}
+	svc, err := resolveService(ctx, c, args[0])
+	if err != nil {
+		return err
+	}
+	svc.SwaggerJSON = nil
+	return render(g.output, svc, func(tw *tabwriter.Writer) {
+		row := func(k, v string) { fmt.Fprintf(tw, "%s:\t%s\n", k, v) }
+		row("ID", svc.ID)
+		row("Name", svc.Name)
+		row("Description", orDash(svc.Description))
+		row("Public prefix", svc.PublicPrefix)
+		row("Protocol", orDash(svc.Protocol))
+		row("Base URL", orDash(svc.BaseURL))
+		row("Swagger URL", orDash(svc.SwaggerURL))
+		row("gRPC target", orDash(svc.GRPCTarget))
+		row("Descriptor source", orDash(svc.DescriptorSource))
+		row("Labels", labels(svc.Labels))
+		row("Enabled", strconv.FormatBool(svc.Enabled))
+		row("Status", orDash(svc.LastStatus))
+		row("Last health check", since(svc.LastHealthAt))
+		row("Last refreshed", since(svc.LastRefreshed))
+		row("Version", strconv.FormatInt(svc.Version, 10))
+	})
+}
+
+// writeServiceCommand builds "services create" or, with update set, "services update". The file
+// holds a service in the export format (a ServiceSpec); its routes are ignored here, use import
+// to manage routes declaratively.
+func writeServiceCommand(update bool) *command {
+	var file string
+	var dryRun bool
+	var opts client.CreateOptions
+	cmd := &command{
+		usage:   "-f FILE [--dry-run] [--allow-prefix-overlap] [--skip-upstream-check]",
+		maxArgs: 0,
+		flags: func(fs *flag.FlagSet) {
+			fs.StringVar(&file, "f", "", "service definition, YAML or JSON (- for stdin)")
+			fs.BoolVar(&dryRun, "dry-run", false, "only validate; print the validation report")
+			fs.BoolVar(&opts.AllowPrefixOverlap, "allow-prefix-overlap", false, "accept a prefix nested in another service's prefix")
+			fs.BoolVar(&opts.SkipUpstreamCheck, "skip-upstream-check", false, "don't probe the upstream for reachability")
+		},
+	}
+	if update {
+		cmd.usage = "SERVICE " + cmd.usage
+		cmd.minArgs, cmd.maxArgs = 1, 1
+	}
+	cmd.run = func(ctx context.Context, g *globals, args []string) error {
+		var spec client.ServiceSpec
+		if err := decodeFile(file, &spec); err != nil {
+			return err
+		}
+		c, err := g.client()
+		if err != nil {
+			return err
+		}
+		svc := spec.Service()
+		if update {
+			cur, err := resolveService(ctx, c, args[0])
+			if err != nil {
+				return err
+			}
+			svc.ID, svc.Version = cur.ID, cur.Version
+		}
+		if dryRun {
+			rep, err := c.ValidateService(ctx, svc, opts)
+			if err != nil {
+				return err
+			}
+			return printReport(g.output, rep)
+		}
+		var out *client.Service
+		if update {
+			out, err = c.UpdateService(ctx, svc, opts)
+		} else {
+			out, err = c.CreateService(ctx, svc, opts)
+		}
+		if err != nil {
+			return err
+		}
+		out.SwaggerJSON = nil
+		return printServices(g.output, out, []client.Service{*out})
+	}
+	return cmd
+}
+
+func patchServiceCommand() *command {
+	var patch string
+	return &command{
+		usage:   "SERVICE -p JSON",
+		minArgs: 1, maxArgs: 1,
+		flags: func(fs *flag.FlagSet) {
+			fs.StringVar(&patch, "p", "", `JSON Merge Patch, e.g. {"enabled":false}`)
+		},
+		run: func(ctx context.Context, g *globals, args []string) error {
+			if !json.Valid([]byte(patch)) {
+				return errors.New("-p must be a JSON object")
+			}
+			c, err := g.client()
+			if err != nil {
+				return err
+			}
+			cur, err := resolveService(ctx, c, args[0])
+			if err != nil {
+				return err
+			}
+			out, err := c.PatchService(ctx, cur.ID, []byte(patch), cur.Version)
+			if err != nil {
+				return err
+			}
+			out.SwaggerJSON = nil
+			return printServices(g.output, out, []client.Service{*out})
+		},
+	}
+}
+
+func deleteServiceCommand() *command {
+	var force bool
+	return &command{
+		usage:   "SERVICE [--force]",
+		minArgs: 1, maxArgs: 1,
+		flags: func(fs *flag.FlagSet) {
+			fs.BoolVar(&force, "force", false, "delete even if the service changed since it was looked up")
+		},
+		run: func(ctx context.Context, g *globals, args []string) error {
+			c, err := g.client()
+			if err != nil {
+				return err
+			}
+			svc, err := resolveService(ctx, c, args[0])
+			if err != nil {
+				return err
+			}
+			version := svc.Version
+			if force {
+				version = 0
+			}
+			if err := c.DeleteService(ctx, svc.ID, version); err != nil {
+				return err
+			}
+			fmt.Fprintf(stdout, "service %s (%s) deleted\n", svc.Name, svc.PublicPrefix)
+			return nil
+		},
+	}
+}
+
+func refreshService(ctx context.Context, g *globals, args []string) error {
+	c, err := g.client()
+	if err != nil {
+		return err
+	}
+	svc, err := resolveService(ctx, c, args[0])
+	if err != nil {
+		return err
+	}
+	out, err := c.RefreshService(ctx, svc.ID)
+	if err != nil {
+		return err
+	}
+	out.SwaggerJSON = nil
+	return printServices(g.output, out, []client.Service{*out})
+}
+
+// resolveService looks a service up by ID, public prefix (arguments starting with "/") or name.
+func resolveService(ctx context.Context, c *client.Client, arg string) (*client.Service, error) {
+	if !strings.HasPrefix(arg, "/") {
+		svc, err := c.GetService(ctx, arg)
+		if err == nil {
+			return svc, nil
+		}
+		if !client.IsNotFound(err) {
+			return nil, err
+		}
+	}
+	all, err := c.AllServices(ctx, client.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	want := arg
+	if strings.HasPrefix(arg, "/") && !strings.HasSuffix(arg, "/") {
+		want += "/"
+	}
+	var found []client.Service
+	for _, s := range all {
+		if s.PublicPrefix == want || s.Name == arg {
+			found = append(found, s)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return nil, fmt.Errorf("service %q not found", arg)
+	case 1:
+		// listings may omit fields a full read returns; fetch it for an accurate version
+		return c.GetService(ctx, found[0].ID)
+	}
+	return nil, fmt.Errorf("%q matches %d services by name; use the ID or prefix", arg, len(found))
+}
+
+var healthCommand = &command{
+	usage:   "[SERVICE]",
+	maxArgs: 1,
+	run: func(ctx context.Context, g *globals, args []string) error {
+		c, err := g.client()
+		if err != nil {
+			return err
+		}
+		gateway := "ok"
+		if err := c.Health(ctx); err != nil {
+			gateway = err.Error()
+		}
+		var list []client.Service
+		if len(args) == 1 {
+			svc, err := resolveService(ctx, c, args[0])
+			if err != nil {
+				return err
+			}
+			list = []client.Service{*svc}
+		} else if list, err = c.AllServices(ctx, client.ListOptions{Sort: "public_prefix"}); err != nil {
+			return err
+		}
+		type serviceHealth struct {
+			Name        string `json:"name"`
+			Prefix      string `json:"public_prefix"`
+			Enabled     bool   `json:"enabled"`
+			Status      string `json:"status"`
+			LastChecked string `json:"last_checked"`
+		}
+		report := struct {
+			Gateway  string          `json:"gateway"`
+			Services []serviceHealth `json:"services"`
+		}{Gateway: gateway, Services: []serviceHealth{}}
+		for _, s := range list {
+			report.Services = append(report.Services, serviceHealth{s.Name, s.PublicPrefix, s.Enabled, orDash(s.LastStatus), since(s.LastHealthAt)})
+		}
+		return render(g.output, report, func(tw *tabwriter.Writer) {
+			fmt.Fprintf(tw, "gateway: %s\n\n", gateway)
+			fmt.Fprintln(tw, "NAME\tPREFIX\tENABLED\tSTATUS\tLAST CHECK")
+			for _, s := range report.Services {
+				fmt.Fprintf(tw, "%s\t%s\t%t\t%s\t%s\n", s.Name, s.Prefix, s.Enabled, s.Status, s.LastChecked)
+			}
+		})
+	},
+}
@@ -0,0 +1,128 @@
+package admin
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"ecomm/api-gateway/internal/registry"
+)
+
+// annotationSync reports what syncAnnotatedRoutes changed.
+type annotationSync struct {
+	Created []*registry.Route `json:"created"`
+	Updated []*registry.Route `json:"updated"`
+	Deleted []*registry.Route `json:"deleted"`
+	Skipped []skippedBinding  `json:"skipped"`
+}
+
+// skippedBinding is an annotation binding that was not applied because a manually
+// managed route already owns the same method and path, or its template is invalid or
+// ambiguous with an existing route.
+type skippedBinding struct {
+	Method     string `json:"method"`
+	Path       string `json:"path"`
+	GRPCMethod string `json:"grpc_method"`
+	RouteID    string `json:"route_id,omitempty"`
+	Reason     string `json:"reason"`
+}
+
+// syncAnnotatedRoutes reconciles a service's annotation-managed routes with the google.api.http
+// bindings found in methods. Bindings without a route are created, changed ones updated and
+// annotation routes whose binding disappeared are deleted. Manual routes are never touched:
+// a binding that collides with one, or whose template conflicts with another route, is reported
+// as skipped.
+func (h *Handler) syncAnnotatedRoutes(ctx context.Context, serviceID string, methods []discoveredMethod) (*annotationSync, error) {
+	existing, err := h.repo.ListRoutes(ctx, serviceID)
+	if err != nil {
+		return nil, err
+	}
+	byKey := map[string]*registry.Route{}
+	for _, rt := range existing {
+		byKey[routeKey(rt.Method, rt.Path)] = rt
+	}
+	res := &annotationSync{Created: []*registry.Route{}, Updated: []*registry.Route{}, Deleted: []*registry.Route{}, Skipped: []skippedBinding{}}
+	seen := map[string]bool{}
+	for _, m := range methods {
+		for _, b := range m.HTTPRules {
+			// A rule without a body maps no request body at all.
+			if b.Body == "" {
+				b.Body = registry.RouteBodyNone
+			}
+			key := routeKey(b.Method, b.Path)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			cur, ok := byKey[key]
+			if !ok {
+				rt := &registry.Route{
+					ID:           uuid.NewString(),
+					ServiceID:    serviceID,
+					Method:       b.Method,
+					Path:         b.Path,
+					GRPCMethod:   m.GRPCMethod,
+					Body:         b.Body,
+					ResponseBody: b.ResponseBody,
+					Source:       registry.RouteSourceAnnotation,
+					CreatedAt:    time.Now(),
+					UpdatedAt:    time.Now(),
+				}
+				if err := checkRoute(rt, existing); err != nil {
+					res.Skipped = append(res.Skipped, skippedBinding{
+						Method: b.Method, Path: b.Path, GRPCMethod: m.GRPCMethod, Reason: err.Error(),
+					})
+					continue
+				}
+				if err := h.repo.CreateRoute(ctx, rt); err != nil {
+					return nil, err
+				}
+				existing = append(existing, rt)
+				res.Created = append(res.Created, rt)
+				continue
+			}
+			if cur.Source != registry.RouteSourceAnnotation {
+				res.Skipped = append(res.Skipped, skippedBinding{
+					Method: b.Method, Path: b.Path, GRPCMethod: m.GRPCMethod, RouteID: cur.ID,
+					Reason: "a manually managed route already maps this method and path",
+				})
+				continue
+			}
+			if cur.GRPCMethod == m.GRPCMethod && cur.Body == b.Body && cur.ResponseBody == b.ResponseBody {
+				continue
+			}
+			next := *cur
+			next.GRPCMethod = m.GRPCMethod
+			next.Body = b.Body
+			next.ResponseBody = b.ResponseBody
+			next.UpdatedAt = time.Now()
+			if err := checkRoute(&next, existing); err != nil {
+				res.Skipped = append(res.Skipped, skippedBinding{
+					Method: b.Method, Path: b.Path, GRPCMethod: m.GRPCMethod, RouteID: cur.ID, Reason: err.Error(),
+				})
+				continue
+			}
+			*cur = next
+			if err := h.repo.UpdateRoute(ctx, cur); err != nil {
+				return nil, err
+			}
+			res.Updated = append(res.Updated, cur)
+		}
+	}
+	for key, rt := range byKey {
+		if rt.Source != registry.RouteSourceAnnotation || seen[key] {
+			continue
+		}
+		if err := h.repo.DeleteRoute(ctx, serviceID, rt.ID, rt.Version); err != nil {
+			return nil, err
+		}
+		res.Deleted = append(res.Deleted, rt)
+	}
+	return res, nil
+}
+
+func routeKey(method, path string) string {
+	return strings.ToUpper(method) + " " + path
+}
@@ -0,0 +1,61 @@
+package admin
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"ecomm/api-gateway/internal/grpcjson"
+	"ecomm/api-gateway/internal/registry"
+)
+
+func TestSyncAnnotatedRoutes(t *testing.T) {
+	ctx := context.Background()
+	repo := registry.NewMemoryRepository()
+	svc := &registry.Service{ID: uuid.NewString(), Name: "messages", PublicPrefix: "/api/messages/", Protocol: "grpc-json", GRPCTarget: "dns:///messages:9090", Enabled: true}
+	if err := repo.Create(ctx, svc); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	h := NewHandler(repo, registry.New())
+	sync := func(rules ...grpcjson.HTTPBinding) *annotationSync {
+		t.Helper()
+		res, err := h.syncAnnotatedRoutes(ctx, svc.ID, []discoveredMethod{{
+			Service: "msg.v1.Messages", Method: "Cancel", GRPCMethod: "msg.v1.Messages/Cancel", HTTPRules: rules,
+		}})
+		if err != nil {
+			t.Fatalf("sync: %v", err)
+		}
+		return res
+	}
+
+	cancel := grpcjson.HTTPBinding{Method: "POST", Path: "/v1/{name=messages/*}:cancel", Body: "*"}
+	res := sync(cancel)
+	if len(res.Created) != 1 || len(res.Skipped) != 0 {
+		t.Fatalf("first sync = %+v, want the custom-verb route created", res)
+	}
+	created := res.Created[0]
+
+	// An update with an invalid selector is skipped and leaves the stored route alone.
+	bad := cancel
+	bad.ResponseBody = "message-id"
+	res = sync(bad)
+	if len(res.Updated) != 0 || len(res.Skipped) != 1 || res.Skipped[0].RouteID != created.ID {
+		t.Fatalf("sync with a bad response_body = %+v, want it skipped", res)
+	}
+	if got, err := repo.GetRoute(ctx, svc.ID, created.ID); err != nil || got.ResponseBody != "" || got.Version != created.Version {
+		t.Fatalf("stored route = %+v, %v; want it unchanged", got, err)
+	}
+
+	good := cancel
+	good.ResponseBody = "message"
+	res = sync(good)
+	if len(res.Updated) != 1 || res.Updated[0].ResponseBody != "message" {
+		t.Fatalf("sync with a valid response_body = %+v, want the route updated", res)
+	}
+
+	res = sync()
+	if len(res.Deleted) != 1 || res.Deleted[0].ID != created.ID {
+		t.Fatalf("sync without bindings = %+v, want the route deleted", res)
+	}
+}
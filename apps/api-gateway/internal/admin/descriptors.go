@@ -0,0 +1,127 @@
+package admin
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+
+	"ecomm/api-gateway/internal/grpcjson"
+	"ecomm/api-gateway/internal/registry"
+	"ecomm/api-gateway/internal/util"
+)
+
+// maxDescriptorSetBytes bounds uploaded descriptor sets.
+const maxDescriptorSetBytes = 32 << 20
+
+// Descriptors lists (GET) or uploads (POST) FileDescriptorSets for a grpc-json service.
+// Uploads accept the binary output of `protoc --include_imports --descriptor_set_out` or, with
+// Content-Type application/json, the protojson encoding of a google.protobuf.FileDescriptorSet.
+// Each upload becomes a new version and takes effect immediately.
+func (h *Handler) Descriptors(w http.ResponseWriter, r *http.Request, serviceID string) {
+	svc, err := h.repo.Get(r.Context(), serviceID)
+	if err != nil {
+		lookupError(w, err, "service")
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		list, err := h.repo.ListDescriptorSets(r.Context(), serviceID)
+		if err != nil {
+			internalError(w, err)
+			return
+		}
+		if list == nil {
+			list = []*registry.DescriptorSet{}
+		}
+		util.JSON(w, list)
+	case http.MethodPost:
+		if strings.ToLower(svc.Protocol) != "grpc-json" {
+			invalidField(w, "protocol", "descriptor sets are only supported for protocol=grpc-json")
+			return
+		}
+		raw, err := readDescriptorSet(w, r)
+		if err != nil {
+			badRequest(w, err)
+			return
+		}
+		files, err := grpcjson.ParseDescriptorSet(raw)
+		if err != nil {
+			badRequest(w, err)
+			return
+		}
+		services := grpcjson.ServiceNames(files)
+		if len(services) == 0 {
+			badRequest(w, errors.New("descriptor set defines no services"))
+			return
+		}
+		sum := sha256.Sum256(raw)
+		ds := &registry.DescriptorSet{ServiceID: serviceID, SHA256: hex.EncodeToString(sum[:]), Size: len(raw), Services: services, Data: raw}
+		if err := h.repo.SaveDescriptorSet(r.Context(), ds); err != nil {
+			internalError(w, err)
+			return
+		}
+		grpcjson.Invalidate(svc.GRPCTarget)
+		util.JSON(w, ds)
+	default:
+		methodNotAllowed(w)
+	}
+}
+
+// readDescriptorSet returns the uploaded set in binary wire format.
+func readDescriptorSet(w http.ResponseWriter, r *http.Request) ([]byte, error) {
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxDescriptorSetBytes))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, errors.New("empty descriptor set")
+	}
+	if ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); ct != "application/json" {
+		return data, nil
+	}
+	var set descriptorpb.FileDescriptorSet
+	if err := protojson.Unmarshal(data, &set); err != nil {
+		return nil, err
+	}
+	return proto.Marshal(&set)
+}
+
+// SchemaLoader resolves the latest uploaded descriptor set for the enabled grpc-json service
+// that owns a target, for use as grpcjson.DescriptorCache.Loader.
+func SchemaLoader(repo registry.Repository) grpcjson.SchemaLoader {
+	return func(ctx context.Context, target string) (*grpcjson.Schema, error) {
+		enabled := true
+		page, err := repo.QueryServices(ctx, registry.ServiceQuery{GRPCTarget: target, Protocol: "grpc-json", Enabled: &enabled, Limit: 1})
+		if err != nil {
+			return nil, err
+		}
+		if len(page.Items) == 0 {
+			return nil, nil
+		}
+		s := page.Items[0]
+		schema := &grpcjson.Schema{DisableReflection: s.DescriptorSource == registry.DescriptorSourceSet}
+		ds, err := repo.GetDescriptorSet(ctx, s.ID, 0)
+		if errors.Is(err, sql.ErrNoRows) {
+			return schema, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		files, err := grpcjson.ParseDescriptorSet(ds.Data)
+		if err != nil {
+			return nil, err
+		}
+		schema.Files = files
+		return schema, nil
+	}
+}
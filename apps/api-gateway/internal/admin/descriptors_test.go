@@ -0,0 +1,134 @@
+package admin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+
+	"ecomm/api-gateway/internal/grpcjson"
+	"ecomm/api-gateway/internal/registry"
+)
+
+// echoSet is a FileDescriptorSet defining test.v1.Echo/Ping.
+func echoSet() *descriptorpb.FileDescriptorSet {
+	text := &descriptorpb.FieldDescriptorProto{
+		Name:   proto.String("text"),
+		Number: proto.Int32(1),
+		Type:   descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(),
+		Label:  descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+	}
+	return &descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{{
+		Name:    proto.String("test/v1/echo.proto"),
+		Package: proto.String("test.v1"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{
+			{Name: proto.String("PingRequest"), Field: []*descriptorpb.FieldDescriptorProto{text}},
+			{Name: proto.String("PingResponse"), Field: []*descriptorpb.FieldDescriptorProto{text}},
+		},
+		Service: []*descriptorpb.ServiceDescriptorProto{{
+			Name: proto.String("Echo"),
+			Method: []*descriptorpb.MethodDescriptorProto{{
+				Name:       proto.String("Ping"),
+				InputType:  proto.String(".test.v1.PingRequest"),
+				OutputType: proto.String(".test.v1.PingResponse"),
+			}},
+		}},
+	}}}
+}
+
+func TestUploadDescriptorSet(t *testing.T) {
+	ctx := context.Background()
+	repo := registry.NewMemoryRepository()
+	svc := &registry.Service{ID: uuid.NewString(), Name: "echo", PublicPrefix: "/api/echo/", Protocol: "grpc-json",
+		GRPCTarget: "echo-" + uuid.NewString() + ":9090", DescriptorSource: registry.DescriptorSourceSet, Enabled: true}
+	plain := &registry.Service{ID: uuid.NewString(), Name: "web", PublicPrefix: "/api/web/", BaseURL: "http://web:8080", Enabled: true}
+	for _, s := range []*registry.Service{svc, plain} {
+		if err := repo.Create(ctx, s); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+	}
+	h := NewHandler(repo, registry.New())
+	upload := func(serviceID, contentType string, body []byte) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		rec := httptest.NewRecorder()
+		h.Descriptors(rec, req, serviceID)
+		return rec
+	}
+
+	binary, err := proto.Marshal(echoSet())
+	if err != nil {
+		t.Fatal(err)
+	}
+	asJSON, err := protojson.Marshal(echoSet())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, up := range []struct {
+		ctype string
+		body  []byte
+	}{{"application/octet-stream", binary}, {"application/json", asJSON}} {
+		rec := upload(svc.ID, up.ctype, up.body)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("upload %s: status %d: %s", up.ctype, rec.Code, rec.Body)
+		}
+		var ds registry.DescriptorSet
+		if err := json.Unmarshal(rec.Body.Bytes(), &ds); err != nil {
+			t.Fatal(err)
+		}
+		if ds.Version != i+1 || len(ds.Services) != 1 || ds.Services[0] != "test.v1.Echo" || ds.SHA256 == "" {
+			t.Fatalf("upload %s = %+v, want version %d of test.v1.Echo", up.ctype, ds, i+1)
+		}
+	}
+	rec := httptest.NewRecorder()
+	h.Descriptors(rec, httptest.NewRequest(http.MethodGet, "/", nil), svc.ID)
+	var list []registry.DescriptorSet
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil || len(list) != 2 {
+		t.Fatalf("GET = %s, want both versions", rec.Body)
+	}
+
+	noServices := echoSet()
+	noServices.File[0].Service = nil
+	for name, tt := range map[string]struct {
+		serviceID string
+		body      []byte
+	}{
+		"not grpc-json": {plain.ID, binary},
+		"empty":         {svc.ID, nil},
+		"garbage":       {svc.ID, []byte("not a descriptor set")},
+		"no services":   {svc.ID, mustMarshal(t, noServices)},
+	} {
+		if rec := upload(tt.serviceID, "application/octet-stream", tt.body); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", name, rec.Code)
+		}
+	}
+
+	// With reflection disabled the transcoder resolves methods from the uploaded set alone.
+	defer func(l grpcjson.SchemaLoader) { grpcjson.Descriptors.Loader = l }(grpcjson.Descriptors.Loader)
+	grpcjson.Descriptors.Loader = SchemaLoader(repo)
+	md, err := grpcjson.Descriptors.ResolveMethod(ctx, svc.GRPCTarget, nil, "test.v1.Echo", "Ping")
+	if err != nil {
+		t.Fatalf("ResolveMethod: %v", err)
+	}
+	if got := md.GetOutputType().GetFullyQualifiedName(); got != "test.v1.PingResponse" {
+		t.Fatalf("output type %s, want test.v1.PingResponse", got)
+	}
+}
+
+func mustMarshal(t *testing.T, m proto.Message) []byte {
+	t.Helper()
+	raw, err := proto.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
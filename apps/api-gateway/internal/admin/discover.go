@@ -2,47 +2,42 @@ package admin
 
 import (
 	"context"
-	"sort"
 
-	"github.com/jhump/protoreflect/grpcreflect"
-	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
-	reflectpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"github.com/jhump/protoreflect/desc"
+
+	"ecomm/api-gateway/internal/grpcjson"
 )
 
 type discoveredMethod struct {
 	Service    string `json:"service"`
 	Method     string `json:"method"`
 	GRPCMethod string `json:"grpc_method"`
+	// HTTPRules are the REST bindings declared by the method's google.api.http option, if any.
+	HTTPRules []grpcjson.HTTPBinding `json:"http_rules,omitempty"`
+
+	input *desc.MessageDescriptor
 }
 
+// discoverGRPCMethods lists the methods exposed by target via reflection or the service's
+// uploaded descriptor set. Discovery is an explicit signal that the upstream's schema may
+// have changed, so cached descriptors are dropped.
 func discoverGRPCMethods(ctx context.Context, target string) ([]discoveredMethod, error) {
-	conn, err := grpc.DialContext(ctx, target, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	grpcjson.Invalidate(target)
+	conn, release, err := grpcjson.Pool.Get(target)
 	if err != nil {
 		return nil, err
 	}
-	defer conn.Close()
-
-	rc := grpcreflect.NewClient(ctx, reflectpb.NewServerReflectionClient(conn))
-	defer rc.Reset()
+	defer release()
 
-	svcs, err := rc.ListServices()
+	svcs, err := grpcjson.Descriptors.ListServices(ctx, target, conn)
 	if err != nil {
 		return nil, err
 	}
-	sort.Strings(svcs)
 	var out []discoveredMethod
-	for _, s := range svcs {
-		// skip reflection service itself
-		if s == "grpc.reflection.v1alpha.ServerReflection" {
-			continue
-		}
-		desc, err := rc.ResolveService(s)
-		if err != nil {
-			continue
-		}
-		for _, m := range desc.GetMethods() {
-			out = append(out, discoveredMethod{Service: s, Method: m.GetName(), GRPCMethod: s + "/" + m.GetName()})
+	for _, sd := range svcs {
+		s := sd.GetFullyQualifiedName()
+		for _, m := range sd.GetMethods() {
+			out = append(out, discoveredMethod{Service: s, Method: m.GetName(), GRPCMethod: s + "/" + m.GetName(), HTTPRules: grpcjson.HTTPBindings(m), input: m.GetInputType()})
 		}
 	}
 	return out, nil
@@ -0,0 +1,426 @@
+package admin
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"ecomm/api-gateway/internal/grpcjson"
+	"ecomm/api-gateway/internal/registry"
+	"ecomm/api-gateway/internal/util"
+)
+
+// DraftRequest is the payload to create a draft.
+type DraftRequest struct {
+	Name        string `json:"name" example:"move-orders-to-grpc"`
+	Description string `json:"description" example:"Onboard orders-v2 and disable the REST service"`
+}
+
+// PublishRequest is the optional payload to publish a draft or roll back to a revision.
+type PublishRequest struct {
+	Message string `json:"message" example:"orders now served over gRPC"`
+}
+
+// draftView is a draft with the size of its registry.
+type draftView struct {
+	*registry.Draft
+	Services int `json:"services"`
+	Routes   int `json:"routes"`
+}
+
+func viewDraft(d *registry.Draft) draftView {
+	v := draftView{Draft: d}
+	if d.Snapshot != nil {
+		v.Services, v.Routes = len(d.Snapshot.Services), len(d.Snapshot.Routes)
+	}
+	return v
+}
+
+// snapshotDiff is what making the live registry match a draft or revision would change.
+type snapshotDiff struct {
+	// Current is false when the live registry changed since the draft was created; publishing
+	// then requires force=true.
+	Current bool                      `json:"current"`
+	Changes []registry.SnapshotChange `json:"changes"`
+}
+
+// Drafts lists drafts or creates one as a copy of the live registry.
+// @Summary List or create drafts
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param payload body admin.DraftRequest false "Draft to create (POST)"
+// @Success 200 {array} admin.draftView
+// @Success 201 {object} admin.draftView
+// @Failure 400 {object} admin.problem
+// @Failure 401 {object} admin.problem
+// @Security BearerAuth
+// @Router /admin/v1/drafts [get]
+// @Router /admin/v1/drafts [post]
+func (h *Handler) Drafts(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		list, err := h.repo.ListDrafts(r.Context())
+		if err != nil {
+			internalError(w, err)
+			return
+		}
+		views := make([]draftView, 0, len(list))
+		for _, d := range list {
+			views = append(views, viewDraft(d))
+		}
+		util.JSON(w, views)
+	case http.MethodPost:
+		h.createDraft(w, r)
+	default:
+		methodNotAllowed(w)
+	}
+}
+
+func (h *Handler) createDraft(w http.ResponseWriter, r *http.Request) {
+	var body DraftRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		badRequest(w, err)
+		return
+	}
+	if strings.TrimSpace(body.Name) == "" {
+		badRequest(w, fieldError("required", "name", "name required"))
+		return
+	}
+	live, err := registry.TakeSnapshot(r.Context(), h.repo)
+	if err != nil {
+		internalError(w, err)
+		return
+	}
+	d := &registry.Draft{ID: uuid.NewString(), Name: strings.TrimSpace(body.Name), Description: body.Description, Base: live.Fingerprint(), Snapshot: live}
+	if err := h.repo.CreateDraft(r.Context(), d); err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	w.Header().Set("Location", h.path("/drafts/"+d.ID))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(viewDraft(d))
+}
+
+// DraftByID dispatches the actions on one draft:
+//
+//	GET, DELETE  /admin/v1/drafts/{id}
+//	GET          /admin/v1/drafts/{id}/diff
+//	POST         /admin/v1/drafts/{id}/validate
+//	POST         /admin/v1/drafts/{id}/publish
+//	*            /admin/v1/drafts/{id}/services/...
+//
+// The services of a draft are managed with the same requests as live services, under the
+// draft's path.
+func (h *Handler) DraftByID(w http.ResponseWriter, r *http.Request) {
+	rest := strings.TrimPrefix(r.URL.Path, APIBase+"/drafts/")
+	id, action, _ := strings.Cut(rest, "/")
+	if id == "" {
+		notFound(w, "resource")
+		return
+	}
+	if !validID(w, "draft", id) {
+		return
+	}
+	d, err := h.repo.GetDraft(r.Context(), id)
+	if err != nil {
+		lookupError(w, err, "draft")
+		return
+	}
+	switch {
+	case action == "" && r.Method == http.MethodGet:
+		util.JSON(w, viewDraft(d))
+	case action == "" && r.Method == http.MethodDelete:
+		if err := h.repo.DeleteDraft(r.Context(), d.ID); err != nil {
+			writeStoreError(w, err)
+			return
+		}
+		util.JSON(w, map[string]string{"deleted": d.ID})
+	case action == "diff" && r.Method == http.MethodGet:
+		h.diffDraft(w, r, d)
+	case action == "validate" && r.Method == http.MethodPost:
+		h.validateDraft(w, r, d)
+	case action == "publish" && r.Method == http.MethodPost:
+		h.publishDraft(w, r, d)
+	case action == "services" || strings.HasPrefix(action, "services/"):
+		h.serveDraft(w, r, d, "/"+action)
+	case action == "", action == "diff", action == "validate", action == "publish":
+		methodNotAllowed(w)
+	default:
+		notFound(w, "resource")
+	}
+}
+
+// diffDraft shows what publishing the draft would change in the live registry.
+// @Summary Diff a draft against the live registry
+// @Tags admin
+// @Produce json
+// @Param id path string true "Draft ID"
+// @Success 200 {object} admin.snapshotDiff
+// @Failure 404 {object} admin.problem
+// @Security BearerAuth
+// @Router /admin/v1/drafts/{id}/diff [get]
+func (h *Handler) diffDraft(w http.ResponseWriter, r *http.Request, d *registry.Draft) {
+	live, err := registry.TakeSnapshot(r.Context(), h.repo)
+	if err != nil {
+		internalError(w, err)
+		return
+	}
+	util.JSON(w, snapshotDiff{Current: live.Fingerprint() == d.Base, Changes: registry.DiffSnapshots(live, d.Snapshot)})
+}
+
+// validateDraft checks the draft registry as a whole and returns the report.
+// @Summary Validate a draft
+// @Tags admin
+// @Produce json
+// @Param id path string true "Draft ID"
+// @Param allow_prefix_overlap query bool false "Accept nested prefixes"
+// @Param skip_upstream_check query bool false "Don't probe the upstreams of changed services"
+// @Success 200 {object} admin.validationReport
+// @Failure 404 {object} admin.problem
+// @Security BearerAuth
+// @Router /admin/v1/drafts/{id}/validate [post]
+func (h *Handler) validateDraft(w http.ResponseWriter, r *http.Request, d *registry.Draft) {
+	live, err := registry.TakeSnapshot(r.Context(), h.repo)
+	if err != nil {
+		internalError(w, err)
+		return
+	}
+	rep := h.checkSnapshot(r.Context(), d.Snapshot, live, parseCheckOptions(r))
+	rep.DryRun = true
+	writeReport(w, rep)
+}
+
+// publishDraft makes the live registry match the draft in one transaction and records the
+// result as a new revision; the draft is deleted. The draft is validated first, and publishing
+// fails with 409 if the live registry changed since the draft was created, unless force=true.
+// @Summary Publish a draft
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param id path string true "Draft ID"
+// @Param payload body admin.PublishRequest false "Revision message"
+// @Param force query bool false "Publish even if the live registry changed since the draft was created"
+// @Param allow_prefix_overlap query bool false "Accept nested prefixes"
+// @Param skip_upstream_check query bool false "Don't probe the upstreams of changed services"
+// @Success 200 {object} registry.Revision
+// @Failure 400 {object} admin.problem "validation failed"
+// @Failure 404 {object} admin.problem
+// @Failure 409 {object} admin.problem "stale draft or conflict"
+// @Security BearerAuth
+// @Router /admin/v1/drafts/{id}/publish [post]
+func (h *Handler) publishDraft(w http.ResponseWriter, r *http.Request, d *registry.Draft) {
+	body, ok := decodePublishRequest(w, r)
+	if !ok {
+		return
+	}
+	force := r.URL.Query().Get("force") == "true"
+	live, err := registry.TakeSnapshot(r.Context(), h.repo)
+	if err != nil {
+		internalError(w, err)
+		return
+	}
+	if !force && live.Fingerprint() != d.Base {
+		writePublishError(w, registry.ErrStaleDraft)
+		return
+	}
+	if rep := h.checkSnapshot(r.Context(), d.Snapshot, live, parseCheckOptions(r)); !rep.Valid {
+		writeReport(w, rep)
+		return
+	}
+	rev := &registry.Revision{Source: registry.RevisionSourcePublish, Message: body.Message, DraftID: d.ID}
+	err = h.publish(r.Context(), d.Snapshot, rev, func(tx registry.Repository, live *registry.Snapshot) error {
+		if !force && live.Fingerprint() != d.Base {
+			return registry.ErrStaleDraft
+		}
+		return tx.DeleteDraft(r.Context(), d.ID)
+	})
+	if err != nil {
+		writePublishError(w, err)
+		return
+	}
+	rev.Snapshot = nil
+	util.JSON(w, rev)
+}
+
+func decodePublishRequest(w http.ResponseWriter, r *http.Request) (PublishRequest, bool) {
+	var body PublishRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil && !errors.Is(err, io.EOF) {
+			badRequest(w, err)
+			return body, false
+		}
+	}
+	return body, true
+}
+
+// writePublishError maps failures of publish: a stale draft is 409, anything else a store error.
+func writePublishError(w http.ResponseWriter, err error) {
+	if errors.Is(err, registry.ErrStaleDraft) {
+		writeError(w, http.StatusConflict, codeStaleDraft, err.Error()+"; review the diff and publish with force=true, or start a new draft")
+		return
+	}
+	writeStoreError(w, err)
+}
+
+// publish makes the live registry match target and records rev, in one transaction when the
+// repository supports it. check runs first against the live state read in the transaction.
+// When the live registry isn't the latest revision (it was changed directly, or nothing was
+// published yet), that state is captured as a revision of its own first so it can be rolled
+// back to. Runtime state is refreshed afterwards.
+func (h *Handler) publish(ctx context.Context, target *registry.Snapshot, rev *registry.Revision, check func(tx registry.Repository, live *registry.Snapshot) error) error {
+	var before *registry.Snapshot
+	run := func(tx registry.Repository) error {
+		live, err := registry.TakeSnapshot(ctx, tx)
+		if err != nil {
+			return err
+		}
+		before = live
+		if check != nil {
+			if err := check(tx, live); err != nil {
+				return err
+			}
+		}
+		if err := captureLive(ctx, tx, live); err != nil {
+			return err
+		}
+		changes := registry.DiffSnapshots(live, target)
+		if err := registry.ApplyChanges(ctx, tx, changes); err != nil {
+			return err
+		}
+		after, err := registry.TakeSnapshot(ctx, tx)
+		if err != nil {
+			return err
+		}
+		rev.Changes, rev.Snapshot, rev.Fingerprint = changes, after, after.Fingerprint()
+		return tx.CreateRevision(ctx, rev)
+	}
+	var err error
+	if t, ok := h.repo.(registry.Transactional); ok {
+		err = t.InTx(ctx, run)
+	} else {
+		err = run(h.repo)
+	}
+	if err != nil {
+		return err
+	}
+	h.refreshAfterPublish(before, rev)
+	return nil
+}
+
+// captureLive records live as a capture revision unless it is the latest revision already. An
+// empty registry without any revision is not worth recording.
+func captureLive(ctx context.Context, tx registry.Repository, live *registry.Snapshot) error {
+	latest, err := tx.GetRevision(ctx, 0)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		if len(live.Services) == 0 {
+			return nil
+		}
+	case err != nil:
+		return err
+	case latest.Fingerprint == live.Fingerprint():
+		return nil
+	}
+	return tx.CreateRevision(ctx, &registry.Revision{
+		Source:      registry.RevisionSourceCapture,
+		Message:     "live registry as changed outside of revisions",
+		Fingerprint: live.Fingerprint(),
+		Changes:     []registry.SnapshotChange{},
+		Snapshot:    live,
+	})
+}
+
+// refreshAfterPublish brings runtime state in line with a published revision, as the service
+// handlers do after single writes: cached descriptors and TLS state of every changed service
+// are dropped, before and after the change, and the routing registry is reloaded.
+func (h *Handler) refreshAfterPublish(before *registry.Snapshot, rev *registry.Revision) {
+	prev, next := map[string]*registry.Service{}, map[string]*registry.Service{}
+	for _, s := range before.Services {
+		prev[s.ID] = s
+	}
+	for _, s := range rev.Snapshot.Services {
+		next[s.ID] = s
+	}
+	for _, c := range rev.Changes {
+		if c.Kind != "service" {
+			continue
+		}
+		for _, s := range []*registry.Service{prev[c.ID], next[c.ID]} {
+			if s != nil {
+				grpcjson.Invalidate(s.GRPCTarget)
+				h.invalidateTLS(s)
+			}
+		}
+	}
+	_ = registry.LoadEnabled(h.repo, h.reg)
+}
+
+// serveDraft serves a service request (path relative to APIBase) against the draft's registry
+// with the regular handlers, then stores the draft if the request changed it. The response is
+// held back until the draft is stored, so a concurrent edit of the same draft fails the request
+// with 412 instead of being lost. Descriptor sets and upstream TLS settings are not staged.
+func (h *Handler) serveDraft(w http.ResponseWriter, r *http.Request, d *registry.Draft, path string) {
+	if strings.HasSuffix(path, "/descriptors") || strings.HasSuffix(path, "/tls") {
+		writeError(w, http.StatusNotFound, codeNotFound, "descriptor sets and upstream TLS settings are not part of drafts; change them on the live service")
+		return
+	}
+	repo := registry.NewMemoryRepositoryFrom(d.Snapshot)
+	dh := &Handler{repo: registry.InNamespace(repo, d.Namespace), reg: registry.New(), allowInsecureTLS: h.allowInsecureTLS, ns: d.Namespace}
+	_ = registry.LoadEnabled(repo, dh.reg)
+	r2 := withPath(r, APIBase+path)
+	buf := &bufferedResponse{header: http.Header{}, status: http.StatusOK}
+	if path == "/services" {
+		dh.Services(buf, r2)
+	} else {
+		dh.ServiceByID(buf, r2)
+	}
+	if buf.status < 400 && r.Method != http.MethodGet && r.Method != http.MethodHead {
+		snap, err := registry.TakeSnapshot(r.Context(), repo)
+		if err != nil {
+			internalError(w, err)
+			return
+		}
+		if snap.Fingerprint() != d.Snapshot.Fingerprint() {
+			d.Snapshot = snap
+			if err := h.repo.UpdateDraft(r.Context(), d); err != nil {
+				writeStoreError(w, err)
+				return
+			}
+		}
+	}
+	for k, v := range buf.header {
+		w.Header()[k] = v
+	}
+	w.WriteHeader(buf.status)
+	_, _ = w.Write(buf.body.Bytes())
+}
+
+// bufferedResponse records a response so it can be discarded or replayed.
+type bufferedResponse struct {
+	header http.Header
+	status int
+	wrote  bool
+	body   bytes.Buffer
+}
+
+func (b *bufferedResponse) Header() http.Header { return b.header }
+
+func (b *bufferedResponse) WriteHeader(status int) {
+	if !b.wrote {
+		b.status, b.wrote = status, true
+	}
+}
+
+func (b *bufferedResponse) Write(p []byte) (int, error) {
+	b.wrote = true
+	return b.body.Write(p)
+}
@@ -0,0 +1,75 @@
+package admin
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"ecomm/api-gateway/internal/registry"
+)
+
+// etag formats a resource version as a strong entity tag.
+func etag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+func setETag(w http.ResponseWriter, version int64) {
+	if version > 0 {
+		w.Header().Set("ETag", etag(version))
+	}
+}
+
+// expectedVersion returns the version an update must match: the one in If-Match when the
+// header is present, otherwise fallback (the version sent in the body; 0 skips the check).
+// If-Match: * only requires the resource to exist.
+func expectedVersion(r *http.Request, fallback int64) (int64, error) {
+	v := strings.TrimSpace(r.Header.Get("If-Match"))
+	if v == "" {
+		return fallback, nil
+	}
+	if v == "*" {
+		return 0, nil
+	}
+	if strings.Contains(v, ",") {
+		return 0, errors.New("If-Match must carry a single entity tag")
+	}
+	n, err := strconv.ParseInt(strings.Trim(strings.TrimPrefix(v, "W/"), `"`), 10, 64)
+	if err != nil || n <= 0 {
+		return 0, errors.New("malformed If-Match entity tag")
+	}
+	return n, nil
+}
+
+// checkIfMatch compares If-Match against the current version for requests (such as DELETE)
+// that aren't conditional in the repository. It writes 412 and returns false on mismatch.
+func checkIfMatch(w http.ResponseWriter, r *http.Request, current int64) bool {
+	want, err := expectedVersion(r, 0)
+	if err != nil {
+		badRequest(w, err)
+		return false
+	}
+	if want != 0 && want != current {
+		w.Header().Set("ETag", etag(current))
+		writeError(w, http.StatusPreconditionFailed, codeVersionConflict, registry.ErrVersionConflict.Error())
+		return false
+	}
+	return true
+}
+
+// notModified reports whether If-None-Match already names the current version, writing 304.
+func notModified(w http.ResponseWriter, r *http.Request, version int64) bool {
+	inm := r.Header.Get("If-None-Match")
+	if inm == "" || version <= 0 {
+		return false
+	}
+	for _, tag := range strings.Split(inm, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == etag(version) {
+			setETag(w, version)
+			w.WriteHeader(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
@@ -1,7 +1,9 @@
 package admin
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strings"
 	"time"
@@ -9,97 +11,159 @@ import (
 	"github.com/google/uuid"
 
 	"ecomm/api-gateway/internal/registry"
+	"ecomm/api-gateway/internal/upstream"
 	"ecomm/api-gateway/internal/util"
 )
 
 type Handler struct {
 	repo registry.Repository
 	reg  *registry.Registry
+	tlsm *upstream.TLSManager
+	// allowInsecureTLS permits insecure_skip_verify in upstream TLS settings (development only).
+	allowInsecureTLS bool
+	rbac             *RBAC
+	// ns is the namespace a scoped handler serves and base the path its resources live under
+	// (see scoped); both are empty on the handler made by NewHandler.
+	ns   string
+	base string
 }
 
 func NewHandler(repo registry.Repository, reg *registry.Registry) *Handler {
 	return &Handler{repo: repo, reg: reg}
 }
 
-// ListServices returns all registered services.
+// WithUpstreamTLS lets the handler invalidate cached upstream TLS state when services change.
+func (h *Handler) WithUpstreamTLS(m *upstream.TLSManager, allowInsecure bool) *Handler {
+	h.tlsm = m
+	h.allowInsecureTLS = allowInsecure
+	return h
+}
+
+// WithRBAC authorizes requests by namespace role. Requests must then carry the claims stored by
+// Authenticate.
+func (h *Handler) WithRBAC(a *RBAC) *Handler {
+	h.rbac = a
+	return h
+}
+
+// ListServices returns registered services, optionally filtered, sorted and paginated.
+// Without limit every matching service is returned. When more pages follow, the cursor for the
+// next one is sent in the X-Next-Cursor header and a Link rel="next" header.
 // @Summary List services
 // @Tags admin
 // @Produce json
+// @Param enabled query bool false "Only enabled (true) or disabled (false) services"
+// @Param protocol query string false "Protocol (http, grpc-json, grpc, grpc-web)"
+// @Param status query string false "Last health status (healthy, unhealthy)"
+// @Param q query string false "Substring of name or public prefix"
+// @Param label query []string false "Label selector key=value (repeatable; all must match)"
+// @Param sort query string false "name, public_prefix, created_at (default) or updated_at; prefix with - for descending"
+// @Param limit query int false "Page size (max 500)"
+// @Param cursor query string false "Cursor from X-Next-Cursor"
 // @Success 200 {array} registry.Service
-// @Router /admin/services [get]
+// @Failure 400 {object} admin.problem
+// @Router /admin/v1/services [get]
 func (h *Handler) ListServices(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "method", http.StatusMethodNotAllowed)
+		methodNotAllowed(w)
 		return
 	}
-	list, err := h.repo.List(r.Context())
+	q, err := parseServiceQuery(r.URL.Query())
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		badRequest(w, err)
 		return
 	}
-	if list == nil {
-		list = []*registry.Service{}
+	page, err := h.repo.QueryServices(r.Context(), q)
+	if err != nil {
+		internalError(w, err)
+		return
 	}
-	util.JSON(w, list)
+	if page.NextCursor != "" {
+		next := *r.URL
+		params := next.Query()
+		params.Set("cursor", page.NextCursor)
+		next.RawQuery = params.Encode()
+		w.Header().Set("X-Next-Cursor", page.NextCursor)
+		w.Header().Add("Link", "<"+next.RequestURI()+`>; rel="next"`)
+	}
+	util.JSON(w, page.Items)
 }
 
-// CreateService onboards a new backend service.
+// CreateService onboards a new backend service. Besides the required fields, the service is
+// checked for prefixes that collide with other services, protocol settings that don't fit and
+// an unreachable upstream; failures return the validation report. With dry_run=true the
+// report is returned without persisting anything.
 // @Summary Create service
 // @Tags admin
 // @Accept json
 // @Param payload body admin.CreateServiceRequest true "Service payload"
+// @Param dry_run query bool false "Validate only and return the report"
+// @Param allow_prefix_overlap query bool false "Accept a prefix nested in (or containing) another service's prefix"
+// @Param skip_upstream_check query bool false "Don't probe the upstream"
 // @Success 200 {object} registry.Service
-// @Failure 400 {string} string "bad request"
-// @Failure 401 {string} string "unauthorized"
-// @Failure 500 {string} string "internal error"
+// @Failure 400 {object} admin.problem
+// @Failure 401 {object} admin.problem "unauthorized"
+// @Failure 409 {object} admin.problem "prefix conflict"
+// @Failure 500 {object} admin.problem "internal error"
 // @Security BearerAuth
-// @Router /admin/services [post]
+// @Router /admin/v1/services [post]
 func (h *Handler) CreateService(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "method", http.StatusMethodNotAllowed)
+		methodNotAllowed(w)
 		return
 	}
 	var body CreateServiceRequest
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		badRequest(w, err)
 		return
 	}
 	if body.PublicPrefix == "" {
-		http.Error(w, "public_prefix required", http.StatusBadRequest)
+		badRequest(w, fieldError("required", "public_prefix", "public_prefix required"))
+		return
+	}
+	if err := validateStreamingPolicy(body.Streaming); err != nil {
+		badRequest(w, err)
 		return
 	}
+	dryRun := isDryRun(r)
 	protocol := strings.ToLower(strings.TrimSpace(body.Protocol))
 	if protocol == "" {
 		protocol = "http"
 	}
 	var swJSON any
+	var swErr error
 	base := strings.TrimSpace(body.BaseURL)
 	if protocol == "http" {
 		if body.SwaggerURL == "" {
-			http.Error(w, "swagger_url required for protocol=http", http.StatusBadRequest)
+			badRequest(w, fieldError("required", "swagger_url", "swagger_url required for protocol=http"))
 			return
 		}
 		var inferredBase string
-		var err error
-		swJSON, inferredBase, err = fetchSwagger(r.Context(), body.SwaggerURL)
-		if err != nil {
-			http.Error(w, "failed to fetch swagger: "+err.Error(), http.StatusBadGateway)
+		swJSON, inferredBase, swErr = fetchSwagger(r.Context(), body.SwaggerURL)
+		if swErr != nil && !dryRun {
+			upstreamError(w, body.SwaggerURL, swErr)
 			return
 		}
 		if base == "" {
 			base = inferredBase
 		}
-		if base == "" {
-			http.Error(w, "base_url missing and not derivable from swagger servers", http.StatusBadRequest)
+		if base == "" && !dryRun {
+			badRequest(w, fieldError("required", "base_url", "base_url missing and not derivable from swagger servers"))
 			return
 		}
-	} else if protocol == "grpc-json" {
+	} else if protocol == "grpc-json" || protocol == "grpc" || protocol == "grpc-web" {
 		if strings.TrimSpace(body.GRPCTarget) == "" {
-			http.Error(w, "grpc_target required for protocol=grpc-json", http.StatusBadRequest)
+			badRequest(w, fieldError("required", "grpc_target", "grpc_target required for protocol="+protocol))
+			return
+		}
+		switch body.DescriptorSource {
+		case "", registry.DescriptorSourceReflection, registry.DescriptorSourceSet:
+		default:
+			invalidField(w, "descriptor_source", "descriptor_source must be reflection or descriptor_set")
 			return
 		}
 	} else {
-		http.Error(w, "unsupported protocol", http.StatusBadRequest)
+		invalidField(w, "protocol", "unsupported protocol")
 		return
 	}
 	en := true
@@ -107,25 +171,48 @@ func (h *Handler) CreateService(w http.ResponseWriter, r *http.Request) {
 		en = *body.Enabled
 	}
 	svc := &registry.Service{
-		ID:            uuid.NewString(),
-		Name:          firstNonEmpty(body.Name, guessNameFromURL(base)),
-		Description:   body.Description,
-		PublicPrefix:  normalizePrefix(body.PublicPrefix),
-		BaseURL:       strings.TrimRight(base, "/"),
-		SwaggerURL:    body.SwaggerURL,
-		Protocol:      protocol,
-		GRPCTarget:    strings.TrimSpace(body.GRPCTarget),
-		Enabled:       en,
-		SwaggerJSON:   swJSON,
-		CreatedAt:     time.Now(),
-		UpdatedAt:     time.Now(),
-		LastRefreshed: time.Now(),
+		ID:               uuid.NewString(),
+		Name:             firstNonEmpty(body.Name, guessNameFromURL(base)),
+		Description:      body.Description,
+		PublicPrefix:     normalizePrefix(body.PublicPrefix),
+		BaseURL:          strings.TrimRight(base, "/"),
+		SwaggerURL:       body.SwaggerURL,
+		Protocol:         protocol,
+		GRPCTarget:       strings.TrimSpace(body.GRPCTarget),
+		DescriptorSource: body.DescriptorSource,
+		Metadata:         body.Metadata,
+		Streaming:        body.Streaming,
+		Labels:           body.Labels,
+		Enabled:          en,
+		SwaggerJSON:      swJSON,
+		CreatedAt:        time.Now(),
+		UpdatedAt:        time.Now(),
+		LastRefreshed:    time.Now(),
+	}
+	rep, err := h.checkService(r.Context(), svc, parseCheckOptions(r))
+	if err != nil {
+		internalError(w, err)
+		return
+	}
+	if swErr != nil {
+		rep.fail("swagger_unavailable", "swagger_url", "failed to fetch swagger: %v", swErr)
+		rep.Valid = false
+	}
+	if dryRun {
+		rep.DryRun = true
+		writeReport(w, rep)
+		return
+	}
+	if !rep.Valid {
+		writeReport(w, rep)
+		return
 	}
 	if err := h.repo.Create(r.Context(), svc); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeStoreError(w, err)
 		return
 	}
 	_ = registry.LoadEnabled(h.repo, h.reg)
+	setETag(w, svc.Version)
 	util.JSON(w, svc)
 }
 
@@ -137,95 +224,148 @@ func (h *Handler) Services(w http.ResponseWriter, r *http.Request) {
 	case http.MethodPost:
 		h.CreateService(w, r)
 	default:
-		http.Error(w, "method", http.StatusMethodNotAllowed)
+		methodNotAllowed(w)
 	}
 }
 
-// GetService retrieves a service by ID.
+// GetService retrieves a service by ID. The response carries the service version as ETag.
 // @Summary Get service by ID
 // @Tags admin
 // @Produce json
 // @Param id path string true "Service ID"
 // @Success 200 {object} registry.Service
-// @Failure 404
-// @Failure 401 {string} string
+// @Success 304
+// @Failure 404 {object} admin.problem
+// @Failure 401 {object} admin.problem
 // @Security BearerAuth
-// @Router /admin/services/{id} [get]
+// @Router /admin/v1/services/{id} [get]
 func (h *Handler) GetService(w http.ResponseWriter, r *http.Request, id string) {
 	svc, err := h.repo.Get(r.Context(), id)
 	if err != nil {
-		http.Error(w, "not found", http.StatusNotFound)
+		lookupError(w, err, "service")
 		return
 	}
+	if notModified(w, r, svc.Version) {
+		return
+	}
+	setETag(w, svc.Version)
 	util.JSON(w, svc)
 }
 
-// UpdateService updates an existing service.
+// UpdateService replaces the configuration of an existing service. The result is validated
+// like a new service; server-managed fields (swagger_json, health, timestamps) are kept. The
+// update only applies if the version in If-Match (or, without the header, a non-zero version
+// in the body) is still current. Use PATCH to change individual fields.
 // @Summary Update service
 // @Tags admin
 // @Accept json
 // @Param id path string true "Service ID"
 // @Param payload body registry.Service true "Service"
+// @Param If-Match header string false "ETag from a previous read"
+// @Param dry_run query bool false "Validate only and return the report"
 // @Success 200 {object} registry.Service
-// @Failure 400 {string} string
-// @Failure 401 {string} string
-// @Failure 500 {string} string
+// @Failure 400 {object} admin.problem
+// @Failure 401 {object} admin.problem
+// @Failure 404 {object} admin.problem
+// @Failure 409 {object} admin.problem "prefix conflict"
+// @Failure 412 {object} admin.problem "version conflict"
+// @Failure 500 {object} admin.problem
 // @Security BearerAuth
-// @Router /admin/services/{id} [put]
+// @Router /admin/v1/services/{id} [put]
 func (h *Handler) UpdateService(w http.ResponseWriter, r *http.Request, id string) {
+	cur, err := h.repo.Get(r.Context(), id)
+	if err != nil {
+		lookupError(w, err, "service")
+		return
+	}
 	var body registry.Service
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		badRequest(w, err)
 		return
 	}
-	body.ID = id
-	if err := h.repo.Update(r.Context(), &body); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	version, err := expectedVersion(r, body.Version)
+	if err != nil {
+		badRequest(w, err)
 		return
 	}
-	_ = registry.LoadEnabled(h.repo, h.reg)
-	util.JSON(w, body)
+	h.saveService(w, r, cur, &body, version)
 }
 
-// DeleteService removes a service registration.
+// DeleteService removes a service registration. With If-Match, only the given version is deleted.
 // @Summary Delete service
 // @Tags admin
 // @Param id path string true "Service ID"
+// @Param If-Match header string false "ETag from a previous read"
 // @Success 200 {object} map[string]string
-// @Failure 401 {string} string
+// @Failure 401 {object} admin.problem
+// @Failure 404 {object} admin.problem "If-Match was sent and the service does not exist"
+// @Failure 412 {object} admin.problem "version conflict"
 // @Security BearerAuth
-// @Router /admin/services/{id} [delete]
+// @Router /admin/v1/services/{id} [delete]
 func (h *Handler) DeleteService(w http.ResponseWriter, r *http.Request, id string) {
-	if err := h.repo.Delete(r.Context(), id); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	version, err := expectedVersion(r, 0)
+	if err != nil {
+		badRequest(w, err)
 		return
 	}
+	if err := h.repo.Delete(r.Context(), id, version); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			notFound(w, "service")
+			return
+		}
+		writeStoreError(w, err)
+		return
+	}
+	h.invalidateTLS(&registry.Service{ID: id})
 	_ = registry.LoadEnabled(h.repo, h.reg)
 	util.JSON(w, map[string]string{"deleted": id})
 }
 
 // RefreshService re-fetches and validates the service swagger then updates the record.
+// For grpc-json services it re-resolves descriptors and re-syncs annotation-managed routes instead.
 // @Summary Refresh service swagger
 // @Tags admin
 // @Param id path string true "Service ID"
 // @Success 200 {object} registry.Service
-// @Failure 401 {string} string
-// @Failure 502 {string} string "bad gateway"
+// @Failure 401 {object} admin.problem
+// @Failure 502 {object} admin.problem "bad gateway"
 // @Security BearerAuth
-// @Router /admin/services/{id}/refresh [post]
+// @Router /admin/v1/services/{id}/refresh [post]
 func (h *Handler) RefreshService(w http.ResponseWriter, r *http.Request, id string) {
 	svc, err := h.repo.Get(r.Context(), id)
 	if err != nil {
-		http.Error(w, "not found", http.StatusNotFound)
+		lookupError(w, err, "service")
+		return
+	}
+	if p := strings.ToLower(svc.Protocol); p == "grpc" || p == "grpc-web" {
+		invalidField(w, "protocol", "nothing to refresh for protocol="+p)
 		return
 	}
 	if strings.ToLower(svc.Protocol) == "grpc-json" {
-		http.Error(w, "refresh not supported for protocol=grpc-json", http.StatusBadRequest)
+		// No swagger to re-fetch; refreshing re-resolves descriptors and re-syncs annotated routes.
+		list, err := discoverGRPCMethods(r.Context(), svc.GRPCTarget)
+		if err != nil {
+			upstreamError(w, svc.GRPCTarget, err)
+			return
+		}
+		_, err = h.syncAnnotatedRoutes(r.Context(), svc.ID, list)
+		h.reloadRoutes(r.Context(), svc.ID)
+		if err != nil {
+			writeStoreError(w, err)
+			return
+		}
+		svc.LastRefreshed = time.Now()
+		if err := h.repo.Update(r.Context(), svc); err != nil {
+			writeStoreError(w, err)
+			return
+		}
+		setETag(w, svc.Version)
+		util.JSON(w, svc)
 		return
 	}
 	swJSON, inferredBase, err := fetchSwagger(r.Context(), svc.SwaggerURL)
 	if err != nil {
-		http.Error(w, "failed to fetch swagger: "+err.Error(), http.StatusBadGateway)
+		upstreamError(w, svc.SwaggerURL, err)
 		return
 	}
 	if svc.BaseURL == "" && inferredBase != "" {
@@ -234,21 +374,25 @@ func (h *Handler) RefreshService(w http.ResponseWriter, r *http.Request, id stri
 	svc.SwaggerJSON = swJSON
 	svc.LastRefreshed = time.Now()
 	if err := h.repo.Update(r.Context(), svc); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeStoreError(w, err)
 		return
 	}
 	_ = registry.LoadEnabled(h.repo, h.reg)
+	setETag(w, svc.Version)
 	util.JSON(w, svc)
 }
 
 // ServiceByID dispatches path-based actions to their method-specific handlers.
 func (h *Handler) ServiceByID(w http.ResponseWriter, r *http.Request) {
-	id := strings.TrimPrefix(r.URL.Path, "/admin/services/")
+	id := strings.TrimPrefix(r.URL.Path, APIBase+"/services/")
 	if id == "" {
-		http.NotFound(w, r)
+		notFound(w, "resource")
 		return
 	}
-	// discover: /admin/services/{id}/routes/discover
+	if serviceID, _, _ := strings.Cut(id, "/"); !validID(w, "service", serviceID) {
+		return
+	}
+	// discover: /admin/v1/services/{id}/routes/discover
 	if strings.HasSuffix(id, "/routes/discover") && r.Method == http.MethodGet {
 		base := strings.TrimSuffix(id, "/routes/discover")
 		if strings.HasSuffix(base, "/") {
@@ -257,7 +401,7 @@ func (h *Handler) ServiceByID(w http.ResponseWriter, r *http.Request) {
 		h.DiscoverRoutes(w, r, base)
 		return
 	}
-	// bulk discover: /admin/services/{id}/routes/discover/bulk
+	// bulk discover: /admin/v1/services/{id}/routes/discover/bulk
 	if strings.HasSuffix(id, "/routes/discover/bulk") && r.Method == http.MethodPost {
 		base := strings.TrimSuffix(id, "/routes/discover/bulk")
 		if strings.HasSuffix(base, "/") {
@@ -266,24 +410,52 @@ func (h *Handler) ServiceByID(w http.ResponseWriter, r *http.Request) {
 		h.BulkAddDiscoveredRoutes(w, r, base)
 		return
 	}
-	// refresh endpoint: /admin/services/{id}/refresh
+	// annotation sync: /admin/v1/services/{id}/routes/sync
+	if strings.HasSuffix(id, "/routes/sync") && r.Method == http.MethodPost {
+		h.SyncAnnotatedRoutes(w, r, strings.TrimSuffix(id, "/routes/sync"))
+		return
+	}
+	// descriptor sets: /admin/v1/services/{id}/descriptors
+	if strings.HasSuffix(id, "/descriptors") {
+		h.Descriptors(w, r, strings.TrimSuffix(id, "/descriptors"))
+		return
+	}
+	// upstream TLS: /admin/v1/services/{id}/tls
+	if strings.HasSuffix(id, "/tls") {
+		h.UpstreamTLS(w, r, strings.TrimSuffix(id, "/tls"))
+		return
+	}
+	// refresh endpoint: /admin/v1/services/{id}/refresh
 	if strings.HasSuffix(id, "/refresh") && r.Method == http.MethodPost {
 		id = strings.TrimSuffix(id, "/refresh")
 		h.RefreshService(w, r, id)
 		return
 	}
-	// routes collection: /admin/services/{id}/routes
+	// route planner: /admin/v1/services/{id}/routes/plan
+	if strings.HasSuffix(id, "/routes/plan") {
+		h.RoutePlan(w, r, strings.TrimSuffix(id, "/routes/plan"))
+		return
+	}
+	// compiled route table: /admin/v1/services/{id}/routes/table
+	if strings.HasSuffix(id, "/routes/table") {
+		h.RouteTable(w, r, strings.TrimSuffix(id, "/routes/table"))
+		return
+	}
+	// routes collection: /admin/v1/services/{id}/routes
 	if strings.HasSuffix(id, "/routes") {
 		id = strings.TrimSuffix(id, "/routes")
 		h.Routes(w, r, id)
 		return
 	}
-	// routes detail: /admin/services/{id}/routes/{rid}
+	// routes detail: /admin/v1/services/{id}/routes/{rid}
 	if strings.Contains(id, "/routes/") {
 		parts := strings.SplitN(id, "/routes/", 2)
 		if len(parts) == 2 {
 			serviceID := parts[0]
 			routeID := parts[1]
+			if !validID(w, "route", routeID) {
+				return
+			}
 			h.RouteByID(w, r, serviceID, routeID)
 			return
 		}
@@ -293,22 +465,34 @@ func (h *Handler) ServiceByID(w http.ResponseWriter, r *http.Request) {
 		h.GetService(w, r, id)
 	case http.MethodPut:
 		h.UpdateService(w, r, id)
+	case http.MethodPatch:
+		h.PatchService(w, r, id)
 	case http.MethodDelete:
 		h.DeleteService(w, r, id)
 	default:
-		http.Error(w, "method", http.StatusMethodNotAllowed)
+		methodNotAllowed(w)
 	}
 }
 
-// Routes lists or creates route mappings for a service
+// Routes lists or creates route mappings for a service.
+// GET accepts ?source=manual|annotation|generated to list only routes of that origin.
 func (h *Handler) Routes(w http.ResponseWriter, r *http.Request, serviceID string) {
 	switch r.Method {
 	case http.MethodGet:
 		list, err := h.repo.ListRoutes(r.Context(), serviceID)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			internalError(w, err)
 			return
 		}
+		if src := r.URL.Query().Get("source"); src != "" {
+			filtered := []*registry.Route{}
+			for _, rt := range list {
+				if rt.Source == src {
+					filtered = append(filtered, rt)
+				}
+			}
+			list = filtered
+		}
 		util.JSON(w, list)
 	case http.MethodPost:
 		var body struct {
@@ -316,58 +500,113 @@ func (h *Handler) Routes(w http.ResponseWriter, r *http.Request, serviceID strin
 			Path         string                     `json:"path"`
 			GRPCMethod   string                     `json:"grpc_method"`
 			QueryMapping registry.RouteQueryMapping `json:"query_mapping"`
+			Body         string                     `json:"body"`
+			ResponseBody string                     `json:"response_body"`
+			// ParamsOverrideBody lets path/query params replace values sent in the body
+			ParamsOverrideBody bool `json:"params_override_body"`
+			// StrictQuery rejects unknown or mistyped query params with 400
+			StrictQuery bool `json:"strict_query"`
 		}
 		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			badRequest(w, err)
 			return
 		}
 		if body.Method == "" || body.Path == "" || body.GRPCMethod == "" {
-			http.Error(w, "method, path, grpc_method required", http.StatusBadRequest)
+			badRequest(w, fieldError("required", "", "method, path, grpc_method required"))
+			return
+		}
+		rt := &registry.Route{ID: uuid.NewString(), ServiceID: serviceID, Method: body.Method, Path: body.Path, GRPCMethod: body.GRPCMethod, QueryMapping: body.QueryMapping, Body: body.Body, ResponseBody: body.ResponseBody, ParamsOverrideBody: body.ParamsOverrideBody, StrictQuery: body.StrictQuery, Source: registry.RouteSourceManual, CreatedAt: time.Now(), UpdatedAt: time.Now()}
+		if !h.validateRoute(w, r, rt) {
 			return
 		}
-		rt := &registry.Route{ID: uuid.NewString(), ServiceID: serviceID, Method: body.Method, Path: body.Path, GRPCMethod: body.GRPCMethod, QueryMapping: body.QueryMapping, CreatedAt: time.Now(), UpdatedAt: time.Now()}
 		if err := h.repo.CreateRoute(r.Context(), rt); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeStoreError(w, err)
 			return
 		}
+		h.reloadRoutes(r.Context(), serviceID)
+		setETag(w, rt.Version)
 		util.JSON(w, rt)
 	default:
-		http.Error(w, "method", http.StatusMethodNotAllowed)
+		methodNotAllowed(w)
 	}
 }
 
-// RouteByID retrieves/updates/deletes a specific route
+// validateRoute runs checkRoute against the service's current routes and writes a 400 for an
+// invalid template or a 409 for a conflict. It reports whether rt may be saved.
+func (h *Handler) validateRoute(w http.ResponseWriter, r *http.Request, rt *registry.Route) bool {
+	existing, err := h.repo.ListRoutes(r.Context(), rt.ServiceID)
+	if err != nil {
+		internalError(w, err)
+		return false
+	}
+	err = checkRoute(rt, existing)
+	var conflict *routeConflictError
+	switch {
+	case err == nil:
+		return true
+	case errors.As(err, &conflict):
+		writeError(w, http.StatusConflict, codeConflict, err.Error())
+	default:
+		badRequest(w, err)
+	}
+	return false
+}
+
+// RouteByID retrieves/replaces (PUT)/patches (PATCH, JSON Merge Patch or JSON Patch)/deletes a
+// specific route. Updating an annotation-managed or generated route turns it into a manual one. Routes carry their version
+// as ETag; updates and deletes honour If-Match (updates also a non-zero version in the body)
+// and fail with 412 when it is stale.
 func (h *Handler) RouteByID(w http.ResponseWriter, r *http.Request, serviceID, routeID string) {
 	switch r.Method {
 	case http.MethodGet:
 		rt, err := h.repo.GetRoute(r.Context(), serviceID, routeID)
 		if err != nil {
-			http.Error(w, "not found", http.StatusNotFound)
+			lookupError(w, err, "route")
+			return
+		}
+		if notModified(w, r, rt.Version) {
 			return
 		}
+		setETag(w, rt.Version)
 		util.JSON(w, rt)
-	case http.MethodPut, http.MethodPatch:
+	case http.MethodPut:
+		cur, err := h.repo.GetRoute(r.Context(), serviceID, routeID)
+		if err != nil {
+			lookupError(w, err, "route")
+			return
+		}
 		var body registry.Route
 		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			badRequest(w, err)
 			return
 		}
-		body.ID = routeID
-		body.ServiceID = serviceID
-		body.UpdatedAt = time.Now()
-		if err := h.repo.UpdateRoute(r.Context(), &body); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+		version, err := expectedVersion(r, body.Version)
+		if err != nil {
+			badRequest(w, err)
 			return
 		}
-		util.JSON(w, body)
+		body.CreatedAt = cur.CreatedAt
+		h.saveRoute(w, r, cur, &body, version)
+	case http.MethodPatch:
+		h.patchRoute(w, r, serviceID, routeID)
 	case http.MethodDelete:
-		if err := h.repo.DeleteRoute(r.Context(), serviceID, routeID); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+		version, err := expectedVersion(r, 0)
+		if err != nil {
+			badRequest(w, err)
 			return
 		}
+		if err := h.repo.DeleteRoute(r.Context(), serviceID, routeID, version); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				notFound(w, "route")
+				return
+			}
+			writeStoreError(w, err)
+			return
+		}
+		h.reloadRoutes(r.Context(), serviceID)
 		util.JSON(w, map[string]any{"deleted": routeID})
 	default:
-		http.Error(w, "method", http.StatusMethodNotAllowed)
+		methodNotAllowed(w)
 	}
 }
 
@@ -376,71 +615,95 @@ func (h *Handler) RouteByID(w http.ResponseWriter, r *http.Request, serviceID, r
 func (h *Handler) DiscoverRoutes(w http.ResponseWriter, r *http.Request, serviceID string) {
 	svc, err := h.repo.Get(r.Context(), serviceID)
 	if err != nil {
-		http.Error(w, "not found", http.StatusNotFound)
+		lookupError(w, err, "service")
 		return
 	}
 	if strings.ToLower(svc.Protocol) != "grpc-json" || svc.GRPCTarget == "" {
-		http.Error(w, "service is not grpc-json or grpc_target missing", http.StatusBadRequest)
+		invalidField(w, "protocol", "service is not grpc-json or grpc_target missing")
 		return
 	}
 	list, err := discoverGRPCMethods(r.Context(), svc.GRPCTarget)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadGateway)
+		upstreamError(w, svc.GRPCTarget, err)
 		return
 	}
 	util.JSON(w, list)
 }
 
-// BulkAddDiscoveredRoutes creates REST routes for all discovered gRPC methods using a default strategy.
-// Heuristic: GET for List/Get*, POST for Create*, PUT for Update*, DELETE for Delete*, otherwise GET.
-// Path defaults to kebab-case of method name: /list-users, /get-user, etc.
+// BulkAddDiscoveredRoutes creates REST routes for all discovered gRPC methods.
+// Methods annotated with google.api.http get their declared routes instead (see SyncAnnotatedRoutes);
+// the others get the resource-style routes inferred by the route planner (see RoutePlan).
+// Only new routes are created here; planned updates and deletions are left to RoutePlan.
 func (h *Handler) BulkAddDiscoveredRoutes(w http.ResponseWriter, r *http.Request, serviceID string) {
 	svc, err := h.repo.Get(r.Context(), serviceID)
 	if err != nil {
-		http.Error(w, "not found", http.StatusNotFound)
+		lookupError(w, err, "service")
 		return
 	}
 	if strings.ToLower(svc.Protocol) != "grpc-json" || svc.GRPCTarget == "" {
-		http.Error(w, "service is not grpc-json or grpc_target missing", http.StatusBadRequest)
+		invalidField(w, "protocol", "service is not grpc-json or grpc_target missing")
 		return
 	}
 	list, err := discoverGRPCMethods(r.Context(), svc.GRPCTarget)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadGateway)
+		upstreamError(w, svc.GRPCTarget, err)
 		return
 	}
-	// Existing routes to avoid duplicates
-	existing, _ := h.repo.ListRoutes(r.Context(), serviceID)
-	exists := map[string]bool{}
-	for _, rt := range existing {
-		key := strings.ToUpper(rt.Method) + " " + rt.Path
-		exists[key] = true
+	// Methods with google.api.http options get their declared routes; the planner covers the rest.
+	synced, err := h.syncAnnotatedRoutes(r.Context(), serviceID, list)
+	if err != nil {
+		h.reloadRoutes(r.Context(), serviceID)
+		writeStoreError(w, err)
+		return
+	}
+	plan, err := h.planRoutes(r.Context(), serviceID, list)
+	if err == nil {
+		err = h.applyPlan(r.Context(), plan, func(c *planChange) bool { return c.Action == planCreate })
+	}
+	h.reloadRoutes(r.Context(), serviceID)
+	if err != nil {
+		writeStoreError(w, err)
+		return
 	}
 	created := 0
-	for _, d := range list {
-		method := "GET"
-		upper := strings.ToUpper(d.Method)
+	conflicts := []*planChange{}
+	for _, c := range plan.Changes {
 		switch {
-		case strings.HasPrefix(upper, "CREATE"):
-			method = "POST"
-		case strings.HasPrefix(upper, "UPDATE"):
-			method = "PUT"
-		case strings.HasPrefix(upper, "DELETE"):
-			method = "DELETE"
-		default:
-			method = "GET"
-		}
-		path := "/" + toKebab(d.Method)
-		key := method + " " + path
-		if exists[key] {
-			continue
-		}
-		rt := &registry.Route{ID: uuid.NewString(), ServiceID: serviceID, Method: method, Path: path, GRPCMethod: d.GRPCMethod, CreatedAt: time.Now(), UpdatedAt: time.Now()}
-		if err := h.repo.CreateRoute(r.Context(), rt); err == nil {
+		case c.Applied:
 			created++
+		case c.Action == planConflict:
+			conflicts = append(conflicts, c)
 		}
 	}
-	util.JSON(w, map[string]any{"created": created})
+	util.JSON(w, map[string]any{"created": created, "conflicts": conflicts, "annotations": synced})
+}
+
+// SyncAnnotatedRoutes derives routes from the google.api.http options of the service's gRPC
+// methods and reconciles them with the stored annotation-managed routes.
+// Returns the created, updated, deleted and skipped (conflicting with manual routes) routes.
+func (h *Handler) SyncAnnotatedRoutes(w http.ResponseWriter, r *http.Request, serviceID string) {
+	svc, err := h.repo.Get(r.Context(), serviceID)
+	if err != nil {
+		lookupError(w, err, "service")
+		return
+	}
+	if strings.ToLower(svc.Protocol) != "grpc-json" || svc.GRPCTarget == "" {
+		invalidField(w, "protocol", "service is not grpc-json or grpc_target missing")
+		return
+	}
+	list, err := discoverGRPCMethods(r.Context(), svc.GRPCTarget)
+	if err != nil {
+		upstreamError(w, svc.GRPCTarget, err)
+		return
+	}
+	res, err := h.syncAnnotatedRoutes(r.Context(), serviceID, list)
+	// Partial syncs still changed routes, so reload either way.
+	h.reloadRoutes(r.Context(), serviceID)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	util.JSON(w, res)
 }
 
 func toKebab(s string) string {
@@ -3,12 +3,17 @@ package admin
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 
 	"github.com/getkin/kin-openapi/openapi3"
+
+	"ecomm/api-gateway/internal/registry"
 )
 
 func fetchSwagger(ctx context.Context, urlStr string) (any, string, error) {
@@ -83,3 +88,66 @@ func firstNonEmpty(a, b string) string {
 	}
 	return b
 }
+
+// validateStreamingPolicy rejects negative limits and timeouts and allowed origins that are
+// not "*" or a bare scheme://host[:port].
+func validateStreamingPolicy(sp *registry.StreamingPolicy) error {
+	if sp == nil {
+		return nil
+	}
+	if sp.IdleTimeoutSeconds < 0 || sp.MaxDurationSeconds < 0 || sp.MaxConnections < 0 {
+		return errors.New("streaming limits and timeouts must not be negative")
+	}
+	for _, o := range sp.AllowedOrigins {
+		if o == "*" {
+			continue
+		}
+		u, err := url.Parse(o)
+		if err != nil || u.Scheme == "" || u.Host == "" || strings.TrimSuffix(u.Path, "/") != "" || u.RawQuery != "" {
+			return fmt.Errorf("allowed origin %q must be \"*\" or scheme://host[:port]", o)
+		}
+	}
+	return nil
+}
+
+// maxPageSize caps the limit accepted by list endpoints.
+const maxPageSize = 500
+
+// parseServiceQuery reads list filters from query parameters: enabled, protocol, status, q,
+// label=key=value (repeatable), sort (prefix "-" for descending), limit and cursor.
+func parseServiceQuery(v url.Values) (registry.ServiceQuery, error) {
+	q := registry.ServiceQuery{
+		Protocol: v.Get("protocol"),
+		Status:   v.Get("status"),
+		Search:   v.Get("q"),
+		Cursor:   v.Get("cursor"),
+	}
+	if s := v.Get("enabled"); s != "" {
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return q, errors.New("enabled must be true or false")
+		}
+		q.Enabled = &b
+	}
+	for _, l := range v["label"] {
+		k, val, ok := strings.Cut(l, "=")
+		if !ok || k == "" {
+			return q, errors.New("label must be key=value")
+		}
+		if q.Labels == nil {
+			q.Labels = map[string]string{}
+		}
+		q.Labels[k] = val
+	}
+	if s := v.Get("sort"); s != "" {
+		q.Sort, q.Desc = strings.TrimPrefix(s, "-"), strings.HasPrefix(s, "-")
+	}
+	if s := v.Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			return q, errors.New("limit must be a positive integer")
+		}
+		q.Limit = min(n, maxPageSize)
+	}
+	return q, q.Validate()
+}
@@ -0,0 +1,342 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"ecomm/api-gateway/internal/registry"
+	"ecomm/api-gateway/internal/util"
+)
+
+// NamespaceRequest is the payload to create or update a namespace. Hosts and members are
+// replaced as a whole.
+type NamespaceRequest struct {
+	Name        string            `json:"name" example:"staging"`
+	Description string            `json:"description" example:"Pre-production"`
+	Hosts       []string          `json:"hosts" example:"staging.api.example.com"`
+	Members     []registry.Member `json:"members"`
+	Version     int64             `json:"version,omitempty"`
+}
+
+// scoped returns a copy of h that serves the services, drafts and revisions of namespace ns.
+func (h *Handler) scoped(ns string) *Handler {
+	c := *h
+	c.repo = registry.InNamespace(h.repo, ns)
+	c.ns = ns
+	c.base = APIBase + "/namespaces/" + ns
+	if ns == registry.DefaultNamespace {
+		c.base = APIBase
+	}
+	return &c
+}
+
+// path returns the path of a resource of the handler's namespace; p is relative to APIBase.
+func (h *Handler) path(p string) string {
+	if h.base == "" {
+		return APIBase + p
+	}
+	return h.base + p
+}
+
+// DefaultNamespace serves the services, drafts and revisions under APIBase itself, which are
+// those of registry.DefaultNamespace, with the same authorization as their namespaced paths.
+func (h *Handler) DefaultNamespace(w http.ResponseWriter, r *http.Request) {
+	h.serveScoped(w, r, registry.DefaultNamespace, strings.TrimPrefix(r.URL.Path, APIBase))
+}
+
+// Namespaces lists the namespaces the caller can view, or creates one (global admins only).
+// @Summary List or create namespaces
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param payload body admin.NamespaceRequest false "Namespace to create (POST)"
+// @Success 200 {array} registry.Namespace
+// @Success 201 {object} registry.Namespace
+// @Failure 400 {object} admin.problem
+// @Failure 401 {object} admin.problem
+// @Failure 403 {object} admin.problem
+// @Failure 409 {object} admin.problem "name or host taken"
+// @Security BearerAuth
+// @Router /admin/v1/namespaces [get]
+// @Router /admin/v1/namespaces [post]
+func (h *Handler) Namespaces(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		list, err := h.repo.ListNamespaces(r.Context())
+		if err != nil {
+			internalError(w, err)
+			return
+		}
+		visible := make([]*registry.Namespace, 0, len(list))
+		for _, ns := range list {
+			if h.can(r, ns, registry.RoleViewer) {
+				visible = append(visible, ns)
+			}
+		}
+		util.JSON(w, visible)
+	case http.MethodPost:
+		h.createNamespace(w, r)
+	default:
+		methodNotAllowed(w)
+	}
+}
+
+func (h *Handler) createNamespace(w http.ResponseWriter, r *http.Request) {
+	if !h.authorizeAdmin(w, r) {
+		return
+	}
+	var body NamespaceRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		badRequest(w, err)
+		return
+	}
+	if !registry.ValidNamespaceName(body.Name) {
+		invalidField(w, "name", "name must be a DNS label: lowercase letters, digits and hyphens, at most 63 characters")
+		return
+	}
+	ns := &registry.Namespace{Name: body.Name, Description: body.Description, Hosts: body.Hosts, Members: body.Members}
+	if !h.validateNamespace(w, r, ns) {
+		return
+	}
+	if err := h.repo.CreateNamespace(r.Context(), ns); err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	_ = registry.LoadEnabled(h.repo, h.reg)
+	w.Header().Set("Location", APIBase+"/namespaces/"+ns.Name)
+	setETag(w, ns.Version)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(ns)
+}
+
+// validateNamespace checks hosts and members, and that no other namespace claims a host.
+func (h *Handler) validateNamespace(w http.ResponseWriter, r *http.Request, ns *registry.Namespace) bool {
+	seen := map[string]bool{}
+	for i, host := range ns.Hosts {
+		if err := registry.ValidateHost(host); err != nil {
+			badRequest(w, fieldError("invalid", fmt.Sprintf("hosts[%d]", i), err.Error()))
+			return false
+		}
+		if seen[host] {
+			badRequest(w, fieldError("duplicate", fmt.Sprintf("hosts[%d]", i), "host "+host+" is listed twice"))
+			return false
+		}
+		seen[host] = true
+	}
+	if err := validateMembers(ns.Members); err != nil {
+		badRequest(w, err)
+		return false
+	}
+	list, err := h.repo.ListNamespaces(r.Context())
+	if err != nil {
+		internalError(w, err)
+		return false
+	}
+	for _, other := range list {
+		if other.Name == ns.Name {
+			continue
+		}
+		for _, host := range other.Hosts {
+			if seen[host] {
+				writeError(w, http.StatusConflict, codeConflict, "host "+host+" already selects namespace "+other.Name)
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// NamespaceByName dispatches the requests to one namespace:
+//
+//	GET, PUT, DELETE  /admin/v1/namespaces/{ns}
+//	*                 /admin/v1/namespaces/{ns}/services/...
+//	POST              /admin/v1/namespaces/{ns}/services/{id}/promote
+//	*                 /admin/v1/namespaces/{ns}/drafts/...
+//	*                 /admin/v1/namespaces/{ns}/revisions/...
+//
+// The services, drafts and revisions of a namespace are managed with the same requests as
+// under /admin/v1, which serves the default namespace.
+func (h *Handler) NamespaceByName(w http.ResponseWriter, r *http.Request) {
+	rest := strings.TrimPrefix(r.URL.Path, APIBase+"/namespaces/")
+	name, sub, _ := strings.Cut(rest, "/")
+	if name == "" {
+		notFound(w, "resource")
+		return
+	}
+	if sub != "" {
+		h.serveScoped(w, r, name, "/"+sub)
+		return
+	}
+	ns, err := h.repo.GetNamespace(r.Context(), name)
+	if err != nil {
+		lookupError(w, err, "namespace")
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		if !h.authorize(w, r, ns, registry.RoleViewer) || notModified(w, r, ns.Version) {
+			return
+		}
+		setETag(w, ns.Version)
+		util.JSON(w, ns)
+	case http.MethodPut:
+		h.updateNamespace(w, r, ns)
+	case http.MethodDelete:
+		h.deleteNamespace(w, r, ns)
+	default:
+		methodNotAllowed(w)
+	}
+}
+
+// updateNamespace replaces the description, hosts and members of a namespace. Namespace admins
+// manage members; only global admins change hosts, which decide what traffic the namespace
+// receives.
+// @Summary Update namespace
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param ns path string true "Namespace"
+// @Param payload body admin.NamespaceRequest true "Namespace"
+// @Param If-Match header string false "ETag from a previous read"
+// @Success 200 {object} registry.Namespace
+// @Failure 400 {object} admin.problem
+// @Failure 403 {object} admin.problem
+// @Failure 404 {object} admin.problem
+// @Failure 409 {object} admin.problem "host taken"
+// @Failure 412 {object} admin.problem "version conflict"
+// @Security BearerAuth
+// @Router /admin/v1/namespaces/{ns} [put]
+func (h *Handler) updateNamespace(w http.ResponseWriter, r *http.Request, cur *registry.Namespace) {
+	if !h.authorize(w, r, cur, registry.RoleAdmin) {
+		return
+	}
+	var body NamespaceRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		badRequest(w, err)
+		return
+	}
+	if body.Name != "" && body.Name != cur.Name {
+		invalidField(w, "name", "namespaces cannot be renamed")
+		return
+	}
+	version, err := expectedVersion(r, body.Version)
+	if err != nil {
+		badRequest(w, err)
+		return
+	}
+	ns := &registry.Namespace{Name: cur.Name, Description: body.Description, Hosts: body.Hosts, Members: body.Members, CreatedAt: cur.CreatedAt, Version: version}
+	if !sameHosts(cur.Hosts, ns.Hosts) && !h.authorizeAdmin(w, r) {
+		return
+	}
+	if !h.validateNamespace(w, r, ns) {
+		return
+	}
+	if err := h.repo.UpdateNamespace(r.Context(), ns); err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	_ = registry.LoadEnabled(h.repo, h.reg)
+	setETag(w, ns.Version)
+	util.JSON(w, ns)
+}
+
+func sameHosts(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	set := map[string]bool{}
+	for _, h := range a {
+		set[h] = true
+	}
+	for _, h := range b {
+		if !set[h] {
+			return false
+		}
+	}
+	return true
+}
+
+// deleteNamespace deletes an empty namespace with its drafts and revisions. The default
+// namespace cannot be deleted.
+// @Summary Delete namespace
+// @Tags admin
+// @Param ns path string true "Namespace"
+// @Param If-Match header string false "ETag from a previous read"
+// @Success 200 {object} map[string]string
+// @Failure 403 {object} admin.problem
+// @Failure 409 {object} admin.problem "namespace has services"
+// @Security BearerAuth
+// @Router /admin/v1/namespaces/{ns} [delete]
+func (h *Handler) deleteNamespace(w http.ResponseWriter, r *http.Request, ns *registry.Namespace) {
+	if !h.authorizeAdmin(w, r) || !checkIfMatch(w, r, ns.Version) {
+		return
+	}
+	if ns.Name == registry.DefaultNamespace {
+		writeError(w, http.StatusConflict, codeConflict, "the default namespace cannot be deleted")
+		return
+	}
+	if err := h.repo.DeleteNamespace(r.Context(), ns.Name); err != nil {
+		if errors.Is(err, registry.ErrNamespaceNotEmpty) {
+			writeError(w, http.StatusConflict, codeConflict, err.Error()+"; delete or promote them first")
+			return
+		}
+		writeStoreError(w, err)
+		return
+	}
+	_ = registry.LoadEnabled(h.repo, h.reg)
+	util.JSON(w, map[string]string{"deleted": ns.Name})
+}
+
+// serveScoped authorizes a request to the services, drafts or revisions of namespace name and
+// serves it (path relative to APIBase) with the regular handlers scoped to the namespace.
+// Reading needs the viewer role and anything else the editor role; promoting a service needs
+// the viewer role here and the editor role in the target namespace.
+func (h *Handler) serveScoped(w http.ResponseWriter, r *http.Request, name, path string) {
+	ns, err := h.repo.GetNamespace(r.Context(), name)
+	if err != nil {
+		lookupError(w, err, "namespace")
+		return
+	}
+	promote, isPromote := promotePath(path)
+	role := requiredRole(r)
+	if isPromote {
+		role = registry.RoleViewer
+	}
+	if !h.authorize(w, r, ns, role) {
+		return
+	}
+	sub := h.scoped(ns.Name)
+	r2 := withPath(r, APIBase+path)
+	switch {
+	case isPromote:
+		sub.PromoteService(w, r2, promote)
+	case path == "/services":
+		sub.Services(w, r2)
+	case strings.HasPrefix(path, "/services/"):
+		sub.ServiceByID(w, r2)
+	case path == "/drafts":
+		sub.Drafts(w, r2)
+	case strings.HasPrefix(path, "/drafts/"):
+		sub.DraftByID(w, r2)
+	case path == "/revisions":
+		sub.Revisions(w, r2)
+	case strings.HasPrefix(path, "/revisions/"):
+		sub.RevisionByID(w, r2)
+	default:
+		notFound(w, "resource")
+	}
+}
+
+// promotePath returns the service ID of a /services/{id}/promote path.
+func promotePath(path string) (string, bool) {
+	id, ok := strings.CutPrefix(path, "/services/")
+	if !ok {
+		return "", false
+	}
+	id, ok = strings.CutSuffix(id, "/promote")
+	return id, ok && id != "" && !strings.Contains(id, "/")
+}
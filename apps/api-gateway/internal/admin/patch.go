@@ -0,0 +1,274 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"ecomm/api-gateway/internal/grpcjson"
+	"ecomm/api-gateway/internal/jsonpatch"
+	"ecomm/api-gateway/internal/registry"
+	"ecomm/api-gateway/internal/util"
+)
+
+// maxPatchSize bounds PATCH request bodies.
+const maxPatchSize = 1 << 20
+
+// decodePatched applies the PATCH body of r to cur and decodes the result into out. The body
+// is a JSON Merge Patch (application/merge-patch+json or application/json) or a JSON Patch
+// (application/json-patch+json). It returns the HTTP status to use on failure.
+func decodePatched(w http.ResponseWriter, r *http.Request, cur, out any) (int, error) {
+	mt := jsonpatch.MergePatchType
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		var err error
+		if mt, _, err = mime.ParseMediaType(ct); err != nil {
+			return http.StatusUnsupportedMediaType, err
+		}
+	}
+	patch, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPatchSize))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return http.StatusRequestEntityTooLarge, fmt.Errorf("patch exceeds %d bytes", tooLarge.Limit)
+		}
+		return http.StatusBadRequest, err
+	}
+	doc, err := json.Marshal(cur)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	var patched []byte
+	switch mt {
+	case jsonpatch.MergePatchType, "application/json":
+		patched, err = jsonpatch.MergePatch(doc, patch)
+	case jsonpatch.JSONPatchType:
+		patched, err = jsonpatch.Apply(doc, patch)
+	default:
+		return http.StatusUnsupportedMediaType, fmt.Errorf("unsupported patch type %q (want %s or %s)", mt, jsonpatch.MergePatchType, jsonpatch.JSONPatchType)
+	}
+	if err != nil {
+		if errors.Is(err, jsonpatch.ErrTestFailed) {
+			return http.StatusConflict, err
+		}
+		return http.StatusUnprocessableEntity, err
+	}
+	if err := json.Unmarshal(patched, out); err != nil {
+		return http.StatusUnprocessableEntity, fmt.Errorf("patched document is invalid: %w", err)
+	}
+	return 0, nil
+}
+
+// writePatchError writes a decodePatched failure with the code matching its status.
+func writePatchError(w http.ResponseWriter, status int, err error) {
+	switch status {
+	case http.StatusUnsupportedMediaType:
+		writeError(w, status, codeUnsupportedMediaType, err.Error())
+	case http.StatusConflict:
+		writeError(w, status, codePatchTestFailed, err.Error())
+	case http.StatusRequestEntityTooLarge:
+		writeError(w, status, codePayloadTooLarge, err.Error())
+	case http.StatusInternalServerError:
+		internalError(w, err)
+	default:
+		writeError(w, status, codeInvalidRequest, err.Error())
+	}
+}
+
+// validateService normalizes svc and checks it the way CreateService checks a new service:
+// a public prefix, a known protocol and the fields that protocol needs.
+func validateService(svc *registry.Service) error {
+	if strings.TrimSpace(svc.PublicPrefix) == "" {
+		return fieldError("required", "public_prefix", "public_prefix required")
+	}
+	svc.PublicPrefix = normalizePrefix(strings.TrimSpace(svc.PublicPrefix))
+	svc.Protocol = strings.ToLower(strings.TrimSpace(svc.Protocol))
+	if svc.Protocol == "" {
+		svc.Protocol = "http"
+	}
+	svc.BaseURL = strings.TrimRight(strings.TrimSpace(svc.BaseURL), "/")
+	svc.GRPCTarget = strings.TrimSpace(svc.GRPCTarget)
+	switch svc.Protocol {
+	case "http":
+		if svc.SwaggerURL == "" {
+			return fieldError("required", "swagger_url", "swagger_url required for protocol=http")
+		}
+		if svc.BaseURL == "" {
+			return fieldError("required", "base_url", "base_url required for protocol=http")
+		}
+	case "grpc-json", "grpc", "grpc-web":
+		if svc.GRPCTarget == "" {
+			return fieldError("required", "grpc_target", "grpc_target required for protocol="+svc.Protocol)
+		}
+		switch svc.DescriptorSource {
+		case "", registry.DescriptorSourceReflection, registry.DescriptorSourceSet:
+		default:
+			return fieldError("invalid", "descriptor_source", "descriptor_source must be reflection or descriptor_set")
+		}
+	default:
+		return fieldError("invalid", "protocol", "unsupported protocol")
+	}
+	return validateStreamingPolicy(svc.Streaming)
+}
+
+// keepServerFields copies the fields clients cannot set (identity, fetched swagger, health and
+// timestamps) from cur into svc.
+func keepServerFields(svc, cur *registry.Service) {
+	svc.ID = cur.ID
+	svc.SwaggerJSON = cur.SwaggerJSON
+	svc.LastRefreshed = cur.LastRefreshed
+	svc.LastHealthAt = cur.LastHealthAt
+	svc.LastStatus = cur.LastStatus
+	svc.CreatedAt = cur.CreatedAt
+}
+
+// routingChanged reports whether an update touched fields the proxy routes or forwards by.
+func routingChanged(a, b *registry.Service) bool {
+	return a.PublicPrefix != b.PublicPrefix || a.Enabled != b.Enabled || a.Protocol != b.Protocol ||
+		a.BaseURL != b.BaseURL || a.GRPCTarget != b.GRPCTarget || a.DescriptorSource != b.DescriptorSource ||
+		!reflect.DeepEqual(a.Metadata, b.Metadata) || !reflect.DeepEqual(a.Streaming, b.Streaming)
+}
+
+// saveService validates and stores next as the new state of cur, then refreshes whatever
+// runtime state depends on the fields that changed. With dry_run=true it only returns the
+// validation report.
+func (h *Handler) saveService(w http.ResponseWriter, r *http.Request, cur, next *registry.Service, version int64) {
+	keepServerFields(next, cur)
+	if err := validateService(next); err != nil {
+		badRequest(w, err)
+		return
+	}
+	opts := parseCheckOptions(r)
+	// overlaps that predate this update don't block unrelated edits
+	opts.allowOverlap = opts.allowOverlap || cur.PublicPrefix == next.PublicPrefix
+	// only probe the upstream when the update points the service somewhere else
+	opts.probe = opts.probe && (cur.Protocol != next.Protocol || cur.BaseURL != next.BaseURL || cur.GRPCTarget != next.GRPCTarget)
+	rep, err := h.checkService(r.Context(), next, opts)
+	if err != nil {
+		internalError(w, err)
+		return
+	}
+	if isDryRun(r) {
+		rep.DryRun = true
+		writeReport(w, rep)
+		return
+	}
+	if !rep.Valid {
+		writeReport(w, rep)
+		return
+	}
+	next.Version = version
+	if err := h.repo.Update(r.Context(), next); err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	if cur.GRPCTarget != next.GRPCTarget || cur.DescriptorSource != next.DescriptorSource {
+		grpcjson.Invalidate(cur.GRPCTarget)
+		grpcjson.Invalidate(next.GRPCTarget)
+	}
+	if cur.BaseURL != next.BaseURL || cur.GRPCTarget != next.GRPCTarget {
+		h.invalidateTLS(next)
+	}
+	if routingChanged(cur, next) {
+		_ = registry.LoadEnabled(h.repo, h.reg)
+	}
+	setETag(w, next.Version)
+	util.JSON(w, next)
+}
+
+// PatchService applies a JSON Merge Patch or JSON Patch to a service.
+// Without If-Match the patch is applied against the version it was computed from, so a
+// concurrent update still yields 412.
+// @Summary Patch service
+// @Tags admin
+// @Accept json
+// @Param id path string true "Service ID"
+// @Param payload body object true "JSON Merge Patch (application/merge-patch+json) or JSON Patch (application/json-patch+json)"
+// @Param If-Match header string false "ETag from a previous read"
+// @Param dry_run query bool false "Validate only and return the report"
+// @Success 200 {object} registry.Service
+// @Failure 400 {object} admin.problem
+// @Failure 404 {object} admin.problem
+// @Failure 409 {object} admin.problem "JSON Patch test failed or prefix conflict"
+// @Failure 412 {object} admin.problem "version conflict"
+// @Failure 413 {object} admin.problem
+// @Failure 415 {object} admin.problem
+// @Failure 422 {object} admin.problem
+// @Security BearerAuth
+// @Router /admin/v1/services/{id} [patch]
+func (h *Handler) PatchService(w http.ResponseWriter, r *http.Request, id string) {
+	cur, err := h.repo.Get(r.Context(), id)
+	if err != nil {
+		lookupError(w, err, "service")
+		return
+	}
+	if !checkIfMatch(w, r, cur.Version) {
+		return
+	}
+	var next registry.Service
+	if code, err := decodePatched(w, r, cur, &next); err != nil {
+		writePatchError(w, code, err)
+		return
+	}
+	h.saveService(w, r, cur, &next, cur.Version)
+}
+
+// patchRoute applies the PATCH body of r to the stored route.
+func (h *Handler) patchRoute(w http.ResponseWriter, r *http.Request, serviceID, routeID string) {
+	cur, err := h.repo.GetRoute(r.Context(), serviceID, routeID)
+	if err != nil {
+		lookupError(w, err, "route")
+		return
+	}
+	if !checkIfMatch(w, r, cur.Version) {
+		return
+	}
+	var next registry.Route
+	if code, err := decodePatched(w, r, cur, &next); err != nil {
+		writePatchError(w, code, err)
+		return
+	}
+	next.CreatedAt = cur.CreatedAt
+	h.saveRoute(w, r, cur, &next, cur.Version)
+}
+
+// saveRoute validates and stores next as the new state of the route cur.
+// An explicit edit detaches annotation-managed and generated routes so later syncs won't
+// overwrite it. The route table is recompiled only if a matching-relevant field changed.
+func (h *Handler) saveRoute(w http.ResponseWriter, r *http.Request, cur, next *registry.Route, version int64) {
+	next.ID = cur.ID
+	next.ServiceID = cur.ServiceID
+	next.Source = registry.RouteSourceManual
+	// a patch can remove fields the Routes POST requires
+	if next.Method == "" || next.Path == "" || next.GRPCMethod == "" {
+		badRequest(w, fieldError("required", "", "method, path, grpc_method required"))
+		return
+	}
+	if !h.validateRoute(w, r, next) {
+		return
+	}
+	next.Version = version
+	if err := h.repo.UpdateRoute(r.Context(), next); err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	if routeChanged(cur, next) {
+		h.reloadRoutes(r.Context(), next.ServiceID)
+	}
+	setETag(w, next.Version)
+	util.JSON(w, next)
+}
+
+// routeChanged compares everything but bookkeeping fields.
+func routeChanged(a, b *registry.Route) bool {
+	if !strings.EqualFold(a.Method, b.Method) {
+		return true
+	}
+	x := *a
+	x.Method, x.Source, x.Version, x.CreatedAt, x.UpdatedAt = b.Method, b.Source, b.Version, b.CreatedAt, b.UpdatedAt
+	return !reflect.DeepEqual(&x, b)
+}
@@ -0,0 +1,70 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"ecomm/api-gateway/internal/registry"
+)
+
+func TestPatchRouteKeepsRequiredFields(t *testing.T) {
+	ctx := context.Background()
+	repo := registry.NewMemoryRepository()
+	svc := &registry.Service{ID: uuid.NewString(), Name: "catalog", PublicPrefix: "/api/catalog", Protocol: "grpc-json", GRPCTarget: "dns:///catalog:9090", Enabled: true}
+	if err := repo.Create(ctx, svc); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	rt := &registry.Route{ID: uuid.NewString(), ServiceID: svc.ID, Method: "GET", Path: "/v1/items/{id}", GRPCMethod: "catalog.v1.Catalog/GetItem"}
+	if err := repo.CreateRoute(ctx, rt); err != nil {
+		t.Fatalf("CreateRoute: %v", err)
+	}
+	h := NewHandler(repo, registry.New())
+
+	for _, field := range []string{"method", "path", "grpc_method"} {
+		req := httptest.NewRequest(http.MethodPatch, "/", strings.NewReader(`{"`+field+`":null}`))
+		req.Header.Set("Content-Type", "application/merge-patch+json")
+		rec := httptest.NewRecorder()
+		h.RouteByID(rec, req, svc.ID, rt.ID)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("PATCH removing %s: got status %d, want 400", field, rec.Code)
+		}
+	}
+	got, err := repo.GetRoute(ctx, svc.ID, rt.ID)
+	if err != nil {
+		t.Fatalf("GetRoute: %v", err)
+	}
+	if got.Version != rt.Version || got.GRPCMethod != rt.GRPCMethod {
+		t.Errorf("rejected patches changed the route: got %+v", got)
+	}
+}
+
+func TestPatchTooLarge(t *testing.T) {
+	ctx := context.Background()
+	repo := registry.NewMemoryRepository()
+	svc := &registry.Service{ID: uuid.NewString(), Name: "web", PublicPrefix: "/api/web/", BaseURL: "http://web:8080", Enabled: true}
+	if err := repo.Create(ctx, svc); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	h := NewHandler(repo, registry.New())
+	body := `{"description":"` + strings.Repeat("x", maxPatchSize) + `"}`
+	req := httptest.NewRequest(http.MethodPatch, "/", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/merge-patch+json")
+	rec := httptest.NewRecorder()
+	h.PatchService(rec, req, svc.ID)
+	var p problem
+	if err := json.NewDecoder(rec.Body).Decode(&p); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusRequestEntityTooLarge || p.Code != codePayloadTooLarge {
+		t.Fatalf("got %d %+v, want 413 payload_too_large", rec.Code, p)
+	}
+	if got, err := repo.Get(ctx, svc.ID); err != nil || got.Version != svc.Version {
+		t.Fatalf("stored service = %+v, %v; want it unchanged", got, err)
+	}
+}
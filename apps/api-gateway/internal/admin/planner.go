@@ -0,0 +1,339 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+	"github.com/jhump/protoreflect/desc"
+
+	"ecomm/api-gateway/internal/registry"
+	"ecomm/api-gateway/internal/util"
+)
+
+// Plan actions.
+const (
+	planCreate    = "create"
+	planUpdate    = "update"
+	planDelete    = "delete"
+	planUnchanged = "unchanged"
+	planConflict  = "conflict"
+	planSkip      = "skip"
+)
+
+// planChange is one entry of a route plan. Route is the proposed route and Current the stored
+// one it replaces or removes.
+type planChange struct {
+	Action     string          `json:"action"`
+	GRPCMethod string          `json:"grpc_method"`
+	Route      *registry.Route `json:"route,omitempty"`
+	Current    *registry.Route `json:"current,omitempty"`
+	Rationale  string          `json:"rationale,omitempty"`
+	Reason     string          `json:"reason,omitempty"`
+	Applied    bool            `json:"applied,omitempty"`
+}
+
+// routePlan is the diff between the routes inferred from a service's gRPC methods and the
+// generated routes currently stored.
+type routePlan struct {
+	ServiceID string         `json:"service_id"`
+	DryRun    bool           `json:"dry_run"`
+	Changes   []*planChange  `json:"changes"`
+	Summary   map[string]int `json:"summary"`
+}
+
+// restVerbs maps method-name prefixes to resource-style routes. item routes address one
+// resource by id; the others address the collection.
+var restVerbs = []struct {
+	prefix string
+	method string
+	item   bool
+	plural bool // the noun following the prefix is already plural
+	body   bool
+}{
+	{"List", "GET", false, true, false},
+	{"Get", "GET", true, false, false},
+	{"Create", "POST", false, false, true},
+	{"Update", "PUT", true, false, true},
+	{"Delete", "DELETE", true, false, false},
+}
+
+// planRESTRoute infers a resource-style route from a method name and its input message:
+// ListProducts → GET /products, GetProduct → GET /products/{id}, CreateProduct → POST
+// /products, UpdateProduct → PUT (PATCH with an update_mask) /products/{product.id} and
+// DeleteOrder → DELETE /orders/{id}. Other methods become POST /kebab-method with the
+// whole body. The returned rationale explains the choice.
+func planRESTRoute(serviceID string, m discoveredMethod) (*registry.Route, string) {
+	rt := &registry.Route{ServiceID: serviceID, GRPCMethod: m.GRPCMethod, Source: registry.RouteSourceGenerated}
+	for _, v := range restVerbs {
+		noun, ok := strings.CutPrefix(m.Method, v.prefix)
+		if !ok || noun == "" || !unicode.IsUpper(rune(noun[0])) {
+			continue
+		}
+		singular, plural := noun, pluralize(noun)
+		if v.plural {
+			singular, plural = singularize(noun), noun
+		}
+		rt.Method = v.method
+		rt.Path = "/" + toKebab(plural)
+		rt.Body = registry.RouteBodyNone
+		why := fmt.Sprintf("%s%s addresses the %s collection", v.prefix, noun, toKebab(plural))
+		resource := toSnake(singular)
+		var resourceField *desc.FieldDescriptor
+		if m.input != nil {
+			if fd := m.input.FindFieldByName(resource); fd != nil && fd.GetMessageType() != nil && !fd.IsRepeated() {
+				resourceField = fd
+			}
+		}
+		if v.body {
+			rt.Body = "*"
+			if resourceField != nil {
+				rt.Body = resource
+			}
+		}
+		if v.item {
+			id, found := idFieldPath(m.input, resource, resourceField)
+			rt.Path += "/{" + id + "}"
+			why = fmt.Sprintf("%s%s addresses one %s by %s", v.prefix, noun, toKebab(singular), id)
+			if !found {
+				why += " (no id field found on the input message; assumed \"id\")"
+			}
+		}
+		if v.prefix == "Update" && m.input != nil && (m.input.FindFieldByName("update_mask") != nil || m.input.FindFieldByName("field_mask") != nil) {
+			rt.Method = "PATCH"
+			why += "; partial update via field mask"
+		}
+		return rt, why
+	}
+	rt.Method = "POST"
+	rt.Path = "/" + toKebab(m.Method)
+	rt.Body = "*"
+	return rt, "no resource verb prefix; mapped as a custom method"
+}
+
+// idFieldPath picks the input field identifying the resource: <resource>_id, id, then id on
+// the resource message itself. It falls back to "id".
+func idFieldPath(input *desc.MessageDescriptor, resource string, resourceField *desc.FieldDescriptor) (string, bool) {
+	if input == nil {
+		return "id", false
+	}
+	for _, name := range []string{resource + "_id", "id"} {
+		if fd := input.FindFieldByName(name); fd != nil && !fd.IsRepeated() && fd.GetMessageType() == nil {
+			return name, true
+		}
+	}
+	if resourceField != nil {
+		if fd := resourceField.GetMessageType().FindFieldByName("id"); fd != nil && !fd.IsRepeated() {
+			return resource + ".id", true
+		}
+	}
+	return "id", false
+}
+
+// planRoutes diffs the routes inferred for methods against the service's stored routes.
+// Methods with google.api.http bindings or a hand-written route are left alone; generated
+// routes whose method disappeared are deleted.
+func (h *Handler) planRoutes(ctx context.Context, serviceID string, methods []discoveredMethod) (*routePlan, error) {
+	existing, err := h.repo.ListRoutes(ctx, serviceID)
+	if err != nil {
+		return nil, err
+	}
+	generated := map[string]*registry.Route{}
+	owned := map[string]*registry.Route{}
+	for _, rt := range existing {
+		if rt.Source == registry.RouteSourceGenerated {
+			generated[rt.GRPCMethod] = rt
+		} else {
+			owned[rt.GRPCMethod] = rt
+		}
+	}
+	plan := &routePlan{ServiceID: serviceID, Changes: []*planChange{}, Summary: map[string]int{}}
+	add := func(c *planChange) {
+		plan.Changes = append(plan.Changes, c)
+		plan.Summary[c.Action]++
+	}
+	// working is the route set as it would look after the changes planned so far, so planned
+	// routes are checked against each other too.
+	working := append([]*registry.Route(nil), existing...)
+	seen := map[string]bool{}
+	for _, m := range methods {
+		seen[m.GRPCMethod] = true
+		cur := generated[m.GRPCMethod]
+		switch {
+		case len(m.HTTPRules) > 0:
+			add(&planChange{Action: planSkip, GRPCMethod: m.GRPCMethod, Current: cur, Reason: "method declares google.api.http bindings; use annotation sync"})
+			continue
+		case owned[m.GRPCMethod] != nil:
+			add(&planChange{Action: planSkip, GRPCMethod: m.GRPCMethod, Current: owned[m.GRPCMethod], Reason: "a hand-written route already maps this method"})
+			continue
+		}
+		rt, why := planRESTRoute(serviceID, m)
+		c := &planChange{GRPCMethod: m.GRPCMethod, Route: rt, Current: cur, Rationale: why}
+		if cur != nil {
+			rt.ID = cur.ID
+			if strings.EqualFold(cur.Method, rt.Method) && cur.Path == rt.Path && cur.Body == rt.Body {
+				c.Action = planUnchanged
+				add(c)
+				continue
+			}
+		} else {
+			rt.ID = uuid.NewString()
+		}
+		if err := checkRoute(rt, working); err != nil {
+			c.Action, c.Reason = planConflict, err.Error()
+			add(c)
+			continue
+		}
+		c.Action = planCreate
+		if cur != nil {
+			c.Action = planUpdate
+			working = withoutRoute(working, cur.ID)
+		}
+		working = append(working, rt)
+		add(c)
+	}
+	for _, rt := range existing {
+		if rt.Source == registry.RouteSourceGenerated && !seen[rt.GRPCMethod] {
+			add(&planChange{Action: planDelete, GRPCMethod: rt.GRPCMethod, Current: rt, Reason: "method no longer exposed by the upstream"})
+		}
+	}
+	return plan, nil
+}
+
+// applyPlan applies the create, update and delete changes accepted by accept (all when nil).
+func (h *Handler) applyPlan(ctx context.Context, plan *routePlan, accept func(*planChange) bool) error {
+	for _, c := range plan.Changes {
+		if accept != nil && !accept(c) {
+			continue
+		}
+		var err error
+		switch c.Action {
+		case planCreate:
+			c.Route.CreatedAt, c.Route.UpdatedAt = time.Now(), time.Now()
+			err = h.repo.CreateRoute(ctx, c.Route)
+		case planUpdate:
+			c.Route.CreatedAt, c.Route.UpdatedAt = c.Current.CreatedAt, time.Now()
+			c.Route.QueryMapping = c.Current.QueryMapping
+			c.Route.ResponseBody = c.Current.ResponseBody
+			c.Route.Version = c.Current.Version
+			err = h.repo.UpdateRoute(ctx, c.Route)
+		case planDelete:
+			err = h.repo.DeleteRoute(ctx, plan.ServiceID, c.Current.ID, c.Current.Version)
+		default:
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("%s %s: %w", c.Action, c.GRPCMethod, err)
+		}
+		c.Applied = true
+	}
+	return nil
+}
+
+func withoutRoute(routes []*registry.Route, id string) []*registry.Route {
+	out := make([]*registry.Route, 0, len(routes))
+	for _, rt := range routes {
+		if rt.ID != id {
+			out = append(out, rt)
+		}
+	}
+	return out
+}
+
+func pluralize(s string) string {
+	lower := strings.ToLower(s)
+	switch {
+	case strings.HasSuffix(lower, "y") && len(s) > 1 && !strings.ContainsRune("aeiou", rune(lower[len(lower)-2])):
+		return s[:len(s)-1] + "ies"
+	case strings.HasSuffix(lower, "s"), strings.HasSuffix(lower, "x"), strings.HasSuffix(lower, "z"),
+		strings.HasSuffix(lower, "ch"), strings.HasSuffix(lower, "sh"):
+		return s + "es"
+	}
+	return s + "s"
+}
+
+func singularize(s string) string {
+	lower := strings.ToLower(s)
+	switch {
+	case strings.HasSuffix(lower, "ies") && len(s) > 3:
+		return s[:len(s)-3] + "y"
+	case strings.HasSuffix(lower, "sses"), strings.HasSuffix(lower, "xes"), strings.HasSuffix(lower, "zes"),
+		strings.HasSuffix(lower, "ches"), strings.HasSuffix(lower, "shes"):
+		return s[:len(s)-2]
+	case strings.HasSuffix(lower, "ss"):
+		return s
+	case strings.HasSuffix(lower, "s") && len(s) > 1:
+		return s[:len(s)-1]
+	}
+	return s
+}
+
+func toSnake(s string) string {
+	return strings.ReplaceAll(toKebab(s), "-", "_")
+}
+
+// RoutePlan infers resource-style routes from the service's gRPC methods.
+// GET (or POST with ?dry_run=true) returns the plan without changing anything. POST applies
+// it; an optional body {"accept": ["pkg.Service/Method", ...]} limits the changes applied to
+// those methods. Applied routes are recorded with source "generated".
+func (h *Handler) RoutePlan(w http.ResponseWriter, r *http.Request, serviceID string) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		methodNotAllowed(w)
+		return
+	}
+	var body struct {
+		Accept []string `json:"accept"`
+	}
+	if r.Method == http.MethodPost && r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil && !errors.Is(err, io.EOF) {
+			badRequest(w, err)
+			return
+		}
+	}
+	svc, err := h.repo.Get(r.Context(), serviceID)
+	if err != nil {
+		lookupError(w, err, "service")
+		return
+	}
+	if strings.ToLower(svc.Protocol) != "grpc-json" || svc.GRPCTarget == "" {
+		invalidField(w, "protocol", "service is not grpc-json or grpc_target missing")
+		return
+	}
+	list, err := discoverGRPCMethods(r.Context(), svc.GRPCTarget)
+	if err != nil {
+		upstreamError(w, svc.GRPCTarget, err)
+		return
+	}
+	plan, err := h.planRoutes(r.Context(), serviceID, list)
+	if err != nil {
+		internalError(w, err)
+		return
+	}
+	var accept func(*planChange) bool
+	if len(body.Accept) > 0 {
+		accepted := map[string]bool{}
+		for _, m := range body.Accept {
+			accepted[strings.TrimPrefix(m, "/")] = true
+		}
+		accept = func(c *planChange) bool { return accepted[c.GRPCMethod] }
+	}
+	plan.DryRun = r.Method == http.MethodGet || r.URL.Query().Get("dry_run") == "true"
+	if plan.DryRun {
+		util.JSON(w, plan)
+		return
+	}
+	err = h.applyPlan(r.Context(), plan, accept)
+	h.reloadRoutes(r.Context(), serviceID)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	util.JSON(w, plan)
+}
@@ -0,0 +1,96 @@
+package admin
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/desc/builder"
+
+	"ecomm/api-gateway/internal/registry"
+)
+
+func TestPlanRESTRoute(t *testing.T) {
+	product := builder.NewMessage("Product").
+		AddField(builder.NewField("id", builder.FieldTypeString())).
+		AddField(builder.NewField("name", builder.FieldTypeString()))
+	mask := builder.NewMessage("FieldMask").AddField(builder.NewField("paths", builder.FieldTypeString()).SetRepeated())
+	input := func(name string, fields ...*builder.FieldBuilder) *desc.MessageDescriptor {
+		mb := builder.NewMessage(name)
+		for _, f := range fields {
+			mb.AddField(f)
+		}
+		md, err := mb.Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return md
+	}
+	tests := []struct {
+		method string
+		input  *desc.MessageDescriptor
+		want   string // "METHOD path body"
+		why    string // substring of the rationale
+	}{
+		{"ListProducts", nil, "GET /products -", "collection"},
+		{"ListCategories", nil, "GET /categories -", "collection"},
+		{"GetProduct", input("GetProductRequest", builder.NewField("id", builder.FieldTypeString())), "GET /products/{id} -", "by id"},
+		{"GetProduct", input("GetProductRequest", builder.NewField("product_id", builder.FieldTypeString())), "GET /products/{product_id} -", "by product_id"},
+		{"GetCategory", nil, "GET /categories/{id} -", "assumed"},
+		{"CreateProduct", input("CreateProductRequest", builder.NewField("product", builder.FieldTypeMessage(product))), "POST /products product", "collection"},
+		{"CreateProduct", nil, "POST /products *", "collection"},
+		{"CreateShippingAddress", nil, "POST /shipping-addresses *", "collection"},
+		{"UpdateProduct", input("UpdateProductRequest", builder.NewField("product", builder.FieldTypeMessage(product))), "PUT /products/{product.id} product", "by product.id"},
+		{"UpdateProduct", input("UpdateProductRequest",
+			builder.NewField("product", builder.FieldTypeMessage(product)),
+			builder.NewField("update_mask", builder.FieldTypeMessage(mask))), "PATCH /products/{product.id} product", "field mask"},
+		{"DeleteOrder", nil, "DELETE /orders/{id} -", "assumed"},
+		{"Ping", nil, "POST /ping *", "custom method"},
+		{"Listing", nil, "POST /listing *", "custom method"},
+		{"ReserveStock", nil, "POST /reserve-stock *", "custom method"},
+	}
+	for _, tt := range tests {
+		m := discoveredMethod{Service: "shop.v1.Shop", Method: tt.method, GRPCMethod: "shop.v1.Shop/" + tt.method, input: tt.input}
+		rt, why := planRESTRoute("svc", m)
+		got := rt.Method + " " + rt.Path + " " + rt.Body
+		if got != tt.want || !strings.Contains(why, tt.why) {
+			t.Errorf("planRESTRoute(%s) = %q (%s), want %q (%s)", tt.method, got, why, tt.want, tt.why)
+		}
+		if rt.Source != registry.RouteSourceGenerated || rt.GRPCMethod != m.GRPCMethod || rt.ServiceID != "svc" {
+			t.Errorf("planRESTRoute(%s): got %+v", tt.method, rt)
+		}
+	}
+}
+
+func TestPluralize(t *testing.T) {
+	for singular, plural := range map[string]string{
+		"Product":  "Products",
+		"Category": "Categories",
+		"Day":      "Days",
+		"Address":  "Addresses",
+		"Box":      "Boxes",
+		"Batch":    "Batches",
+		"Wish":     "Wishes",
+	} {
+		if got := pluralize(singular); got != plural {
+			t.Errorf("pluralize(%q) = %q, want %q", singular, got, plural)
+		}
+	}
+}
+
+func TestSingularize(t *testing.T) {
+	for plural, singular := range map[string]string{
+		"Products":   "Product",
+		"Categories": "Category",
+		"Addresses":  "Address",
+		"Boxes":      "Box",
+		"Batches":    "Batch",
+		"Wishes":     "Wish",
+		"Access":     "Access",
+		"Data":       "Data",
+	} {
+		if got := singularize(plural); got != singular {
+			t.Errorf("singularize(%q) = %q, want %q", plural, got, singular)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package admin
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+
+	"ecomm/api-gateway/internal/registry"
+)
+
+// problemMediaType is the media type of admin API errors (RFC 7807).
+const problemMediaType = "application/problem+json"
+
+// problemTypeBase prefixes the stable error code to form the problem type URI.
+const problemTypeBase = "urn:ecomm:api-gateway:problem:"
+
+// Stable error codes of admin API problems. Clients branch on these, not on titles or details.
+const (
+	codeInvalidRequest       = "invalid_request"
+	codeValidationFailed     = "validation_failed"
+	codeNotFound             = "not_found"
+	codeMethodNotAllowed     = "method_not_allowed"
+	codeUnauthorized         = "unauthorized"
+	codeForbidden            = "forbidden"
+	codeConflict             = "conflict"
+	codeVersionConflict      = "version_conflict"
+	codeReadOnly             = "read_only"
+	codeStaleDraft           = "stale_draft"
+	codePatchTestFailed      = "patch_test_failed"
+	codePayloadTooLarge      = "payload_too_large"
+	codeUnsupportedMediaType = "unsupported_media_type"
+	codeUpstreamError        = "upstream_error"
+	codeUnavailable          = "unavailable"
+	codeInternal             = "internal_error"
+)
+
+var problemTitles = map[string]string{
+	codeInvalidRequest:       "Invalid request",
+	codeValidationFailed:     "Validation failed",
+	codeNotFound:             "Not found",
+	codeMethodNotAllowed:     "Method not allowed",
+	codeUnauthorized:         "Unauthorized",
+	codeForbidden:            "Forbidden",
+	codeConflict:             "Conflict",
+	codeVersionConflict:      "Version conflict",
+	codeReadOnly:             "Read-only registry",
+	codeStaleDraft:           "Stale draft",
+	codePatchTestFailed:      "Patch test failed",
+	codePayloadTooLarge:      "Payload too large",
+	codeUnsupportedMediaType: "Unsupported media type",
+	codeUpstreamError:        "Upstream error",
+	codeUnavailable:          "Service unavailable",
+	codeInternal:             "Internal error",
+}
+
+// problem is an RFC 7807 error body. Code repeats the last segment of Type for convenience;
+// Errors and Warnings carry field-level details of validation failures.
+type problem struct {
+	Type     string            `json:"type"`
+	Title    string            `json:"title"`
+	Status   int               `json:"status"`
+	Detail   string            `json:"detail,omitempty"`
+	Code     string            `json:"code"`
+	Errors   []validationIssue `json:"errors,omitempty"`
+	Warnings []validationIssue `json:"warnings,omitempty"`
+}
+
+func newProblem(status int, code, detail string) *problem {
+	return &problem{Type: problemTypeBase + code, Title: problemTitles[code], Status: status, Detail: detail, Code: code}
+}
+
+func writeProblem(w http.ResponseWriter, p *problem) {
+	w.Header().Set("Content-Type", problemMediaType)
+	w.WriteHeader(p.Status)
+	_ = json.NewEncoder(w).Encode(p)
+}
+
+// writeError writes a problem without field details.
+func writeError(w http.ResponseWriter, status int, code, detail string) {
+	writeProblem(w, newProblem(status, code, detail))
+}
+
+func methodNotAllowed(w http.ResponseWriter) {
+	writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "method not allowed on this resource")
+}
+
+func notFound(w http.ResponseWriter, what string) {
+	writeError(w, http.StatusNotFound, codeNotFound, what+" not found")
+}
+
+// lookupError maps a failed read of what: a missing row is 404, a malformed key 400 and
+// anything else a 500.
+func lookupError(w http.ResponseWriter, err error, what string) {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		notFound(w, what)
+	case errors.Is(err, registry.ErrInvalidValue):
+		badRequest(w, err)
+	default:
+		internalError(w, err)
+	}
+}
+
+// validID answers 400 unless id, taken from the request path, is a UUID as every service,
+// route and draft id is.
+func validID(w http.ResponseWriter, what, id string) bool {
+	if _, err := uuid.Parse(id); err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidRequest, fmt.Sprintf("%s id %q is not a UUID", what, id))
+		return false
+	}
+	return true
+}
+
+// internalError logs err and answers 500 without leaking it to the client.
+func internalError(w http.ResponseWriter, err error) {
+	log.Printf("admin: %v", err)
+	writeError(w, http.StatusInternalServerError, codeInternal, "the request could not be completed; see the gateway log")
+}
+
+// badRequest answers 400. A validationIssue (possibly wrapped) becomes a field-level
+// validation failure; any other error an invalid request.
+func badRequest(w http.ResponseWriter, err error) {
+	var issue validationIssue
+	if errors.As(err, &issue) {
+		p := newProblem(http.StatusBadRequest, codeValidationFailed, issue.Message)
+		p.Errors = []validationIssue{issue}
+		writeProblem(w, p)
+		return
+	}
+	writeError(w, http.StatusBadRequest, codeInvalidRequest, err.Error())
+}
+
+// invalidField answers 400 with a single field-level error.
+func invalidField(w http.ResponseWriter, field, message string) {
+	badRequest(w, fieldError("invalid", field, message))
+}
+
+// upstreamError logs err and answers 502 naming only the configured upstream; the cause can
+// hold addresses, certificate details or response bodies the client shouldn't see.
+func upstreamError(w http.ResponseWriter, upstream string, err error) {
+	log.Printf("admin: upstream %s: %v", upstream, err)
+	writeError(w, http.StatusBadGateway, codeUpstreamError, fmt.Sprintf("upstream %s failed; see the gateway log", upstream))
+}
+
+// writeStoreError maps repository errors from writes: stale versions to 412, missing rows to
+// 404, uniqueness violations to 409, malformed values to 400 and anything else to 500.
+func writeStoreError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, registry.ErrVersionConflict):
+		writeError(w, http.StatusPreconditionFailed, codeVersionConflict, err.Error())
+	case errors.Is(err, registry.ErrDuplicate):
+		writeError(w, http.StatusConflict, codeConflict, err.Error())
+	case errors.Is(err, sql.ErrNoRows):
+		notFound(w, "resource")
+	case errors.Is(err, registry.ErrInvalidValue):
+		badRequest(w, err)
+	default:
+		internalError(w, err)
+	}
+}
+
+// writeReport writes a dry-run validation report as is, or a failed one as a problem whose
+// errors and warnings are the report's.
+func writeReport(w http.ResponseWriter, rep *validationReport) {
+	if rep.DryRun {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(rep)
+		return
+	}
+	status, code := http.StatusBadRequest, codeValidationFailed
+	if rep.conflictOnly() {
+		status, code = http.StatusConflict, codeConflict
+	}
+	p := newProblem(status, code, rep.Errors[0].Message)
+	p.Errors, p.Warnings = rep.Errors, rep.Warnings
+	writeProblem(w, p)
+}
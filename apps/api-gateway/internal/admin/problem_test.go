@@ -0,0 +1,82 @@
+package admin
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"ecomm/api-gateway/internal/registry"
+)
+
+func TestMalformedIDs(t *testing.T) {
+	h := NewHandler(registry.NewMemoryRepository(), registry.New())
+	missing := uuid.NewString()
+	tests := []struct {
+		method, path string
+		want         int
+	}{
+		{http.MethodGet, "/services/not-a-uuid", http.StatusBadRequest},
+		{http.MethodDelete, "/services/not-a-uuid", http.StatusBadRequest},
+		{http.MethodGet, "/services/not-a-uuid/routes", http.StatusBadRequest},
+		{http.MethodGet, "/services/" + missing + "/routes/not-a-uuid", http.StatusBadRequest},
+		{http.MethodGet, "/drafts/not-a-uuid", http.StatusBadRequest},
+		{http.MethodGet, "/services/" + missing, http.StatusNotFound},
+		{http.MethodGet, "/services/" + missing + "/routes/" + uuid.NewString(), http.StatusNotFound},
+		{http.MethodGet, "/drafts/" + missing, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		h.DefaultNamespace(rec, httptest.NewRequest(tt.method, APIBase+tt.path, nil))
+		if rec.Code != tt.want {
+			t.Errorf("%s %s: got status %d, want %d", tt.method, tt.path, rec.Code, tt.want)
+			continue
+		}
+		var p problem
+		if err := json.NewDecoder(rec.Body).Decode(&p); err != nil || p.Status != tt.want {
+			t.Errorf("%s %s: got body %+v (%v), want a problem with status %d", tt.method, tt.path, p, err, tt.want)
+		}
+	}
+}
+
+func TestStoreErrorStatus(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{fmt.Errorf("%w: invalid input syntax for type uuid", registry.ErrInvalidValue), http.StatusBadRequest},
+		{registry.ErrVersionConflict, http.StatusPreconditionFailed},
+		{registry.ErrDuplicate, http.StatusConflict},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		writeStoreError(rec, tt.err)
+		if rec.Code != tt.want {
+			t.Errorf("writeStoreError(%v): got status %d, want %d", tt.err, rec.Code, tt.want)
+		}
+	}
+	rec := httptest.NewRecorder()
+	lookupError(rec, tests[0].err, "service")
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("lookupError(%v): got status %d, want 400", tests[0].err, rec.Code)
+	}
+}
+
+func TestUpstreamErrorHidesCause(t *testing.T) {
+	rec := httptest.NewRecorder()
+	upstreamError(rec, "dns:///orders:9090", fmt.Errorf("reflection: dial 10.0.4.17:9090: x509: certificate signed by unknown authority"))
+	var p problem
+	if err := json.NewDecoder(rec.Body).Decode(&p); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusBadGateway || p.Code != codeUpstreamError {
+		t.Fatalf("got %d %+v, want 502 upstream_error", rec.Code, p)
+	}
+	if !strings.Contains(p.Detail, "dns:///orders:9090") || strings.Contains(p.Detail, "10.0.4.17") || strings.Contains(p.Detail, "x509") {
+		t.Fatalf("detail %q, want the configured upstream without the cause", p.Detail)
+	}
+}
@@ -0,0 +1,191 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+
+	"ecomm/api-gateway/internal/registry"
+	"ecomm/api-gateway/internal/util"
+)
+
+// PromoteRequest is the payload to promote a service to another namespace.
+type PromoteRequest struct {
+	// To is the target namespace.
+	To      string `json:"to" example:"staging"`
+	Message string `json:"message" example:"promote users v2 to staging"`
+	// Upstream overrides where the promoted service points in the target namespace.
+	Upstream *PromoteUpstream `json:"upstream,omitempty"`
+}
+
+// PromoteUpstream holds the upstream addresses of a promoted service. Empty fields are not
+// overridden.
+type PromoteUpstream struct {
+	BaseURL    string `json:"base_url" example:"http://users.staging:8081"`
+	SwaggerURL string `json:"swagger_url" example:"http://users.staging:8081/swagger.json"`
+	GRPCTarget string `json:"grpc_target" example:"users.staging:9090"`
+}
+
+// promotionPlan is the result of a dry-run promotion.
+type promotionPlan struct {
+	Changes []registry.SnapshotChange `json:"changes"`
+	Report  *validationReport         `json:"report"`
+}
+
+// errTargetChanged is returned when the target namespace changes while a promotion is applied.
+var errTargetChanged = errors.New("the target namespace changed during the promotion; retry")
+
+// PromoteService copies the configuration and routes of a service to another namespace and
+// records the change there as a revision. The service is matched in the target namespace by
+// public prefix: an existing one keeps its ID and its upstream addresses (unless upstream
+// overrides them), and its routes are matched by method and path. A new service takes the
+// source's upstream addresses unless overridden. Descriptor sets and upstream TLS settings are
+// not copied. The target is validated like a published draft; with dry_run=true the changes and
+// the report are returned without applying them.
+// @Summary Promote a service to another namespace
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param id path string true "Service ID"
+// @Param payload body admin.PromoteRequest true "Target namespace"
+// @Param dry_run query bool false "Return the changes and validation report only"
+// @Param allow_prefix_overlap query bool false "Accept nested prefixes"
+// @Param skip_upstream_check query bool false "Don't probe the upstream"
+// @Success 200 {object} registry.Revision
+// @Failure 400 {object} admin.problem "validation failed"
+// @Failure 403 {object} admin.problem
+// @Failure 404 {object} admin.problem
+// @Failure 409 {object} admin.problem "conflict"
+// @Security BearerAuth
+// @Router /admin/v1/namespaces/{ns}/services/{id}/promote [post]
+func (h *Handler) PromoteService(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w)
+		return
+	}
+	if !validID(w, "service", id) {
+		return
+	}
+	var body PromoteRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		badRequest(w, err)
+		return
+	}
+	if body.To == "" {
+		badRequest(w, fieldError("required", "to", "to (the target namespace) required"))
+		return
+	}
+	if body.To == h.ns {
+		invalidField(w, "to", "the service is already in namespace "+h.ns)
+		return
+	}
+	ctx := r.Context()
+	target, err := h.repo.GetNamespace(ctx, body.To)
+	if err != nil {
+		lookupError(w, err, "namespace "+body.To)
+		return
+	}
+	if !h.authorize(w, r, target, registry.RoleEditor) {
+		return
+	}
+	svc, err := h.repo.Get(ctx, id)
+	if err != nil {
+		lookupError(w, err, "service")
+		return
+	}
+	routes, err := h.repo.ListRoutes(ctx, id)
+	if err != nil {
+		internalError(w, err)
+		return
+	}
+	th := h.scoped(target.Name)
+	live, err := registry.TakeSnapshot(ctx, th.repo)
+	if err != nil {
+		internalError(w, err)
+		return
+	}
+	desired := promotedSnapshot(live, svc, routes, target.Name, body.Upstream)
+	rep := th.checkSnapshot(ctx, desired, live, parseCheckOptions(r))
+	if isDryRun(r) {
+		rep.DryRun = true
+		util.JSON(w, promotionPlan{Changes: registry.DiffSnapshots(live, desired), Report: rep})
+		return
+	}
+	if !rep.Valid {
+		writeReport(w, rep)
+		return
+	}
+	if body.Message == "" {
+		body.Message = "promote " + svc.PublicPrefix + " from namespace " + h.ns
+	}
+	rev := &registry.Revision{Source: registry.RevisionSourcePromote, Message: body.Message}
+	base := live.Fingerprint()
+	err = th.publish(ctx, desired, rev, func(tx registry.Repository, live *registry.Snapshot) error {
+		if live.Fingerprint() != base {
+			return errTargetChanged
+		}
+		return nil
+	})
+	if errors.Is(err, errTargetChanged) {
+		writeError(w, http.StatusConflict, codeConflict, err.Error())
+		return
+	}
+	if err != nil {
+		writePublishError(w, err)
+		return
+	}
+	rev.Snapshot = nil
+	util.JSON(w, rev)
+}
+
+// promotedSnapshot returns live with svc and its routes copied in, replacing the service with
+// the same public prefix.
+func promotedSnapshot(live *registry.Snapshot, svc *registry.Service, routes []*registry.Route, ns string, up *PromoteUpstream) *registry.Snapshot {
+	out := &registry.Snapshot{Services: []*registry.Service{}, Routes: []*registry.Route{}}
+	var existing *registry.Service
+	for _, s := range live.Services {
+		if s.PublicPrefix == svc.PublicPrefix {
+			existing = s
+			continue
+		}
+		out.Services = append(out.Services, s)
+	}
+	next := *svc
+	next.Namespace = ns
+	next.ID, next.Version = uuid.NewString(), 0
+	next.LastStatus, next.LastHealthAt = "", time.Time{}
+	existingRoutes := map[string]*registry.Route{}
+	if existing != nil {
+		next.ID, next.Version = existing.ID, existing.Version
+		next.BaseURL, next.SwaggerURL, next.GRPCTarget = existing.BaseURL, existing.SwaggerURL, existing.GRPCTarget
+		next.LastStatus, next.LastHealthAt = existing.LastStatus, existing.LastHealthAt
+		next.CreatedAt = existing.CreatedAt
+	}
+	if up != nil {
+		next.BaseURL = firstNonEmpty(up.BaseURL, next.BaseURL)
+		next.SwaggerURL = firstNonEmpty(up.SwaggerURL, next.SwaggerURL)
+		next.GRPCTarget = firstNonEmpty(up.GRPCTarget, next.GRPCTarget)
+	}
+	out.Services = append(out.Services, &next)
+	for _, rt := range live.Routes {
+		if existing != nil && rt.ServiceID == existing.ID {
+			existingRoutes[routeKey(rt.Method, rt.Path)] = rt
+			continue
+		}
+		out.Routes = append(out.Routes, rt)
+	}
+	for _, rt := range routes {
+		c := *rt
+		c.ServiceID, c.Namespace = next.ID, ns
+		if cur := existingRoutes[routeKey(rt.Method, rt.Path)]; cur != nil {
+			c.ID, c.Version = cur.ID, cur.Version
+		} else {
+			c.ID, c.Version = uuid.NewString(), 0
+		}
+		out.Routes = append(out.Routes, &c)
+	}
+	return out
+}
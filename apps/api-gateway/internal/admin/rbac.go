@@ -0,0 +1,129 @@
+package admin
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"ecomm/api-gateway/internal/registry"
+	"ecomm/api-gateway/internal/util"
+)
+
+// RBAC authorizes Admin API requests by the caller's role in the namespace they address (see
+// registry.Member). Global admins hold every role in every namespace and alone create and
+// delete namespaces and assign their host names.
+type RBAC struct {
+	// Admins are the global admins: token subjects, or "group:NAME" for every member of a group.
+	Admins []string
+}
+
+// Authenticate requires a valid HS256 bearer token signed with secret and makes its claims
+// available to the handlers. It is the authentication RBAC relies on.
+func Authenticate(secret string) util.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tok := util.BearerToken(r)
+			if tok == "" {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="gateway-admin"`)
+				writeError(w, http.StatusUnauthorized, codeUnauthorized, "missing bearer token")
+				return
+			}
+			c, err := util.ParseJWT(tok, secret)
+			if err != nil {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="gateway-admin", error="invalid_token"`)
+				writeError(w, http.StatusUnauthorized, codeUnauthorized, err.Error())
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(util.WithClaims(r.Context(), c)))
+		})
+	}
+}
+
+// matches reports whether a subject entry ("alice" or "group:platform") names the caller.
+func matches(subject string, c *util.Claims) bool {
+	if c == nil {
+		return false
+	}
+	if group, ok := strings.CutPrefix(subject, "group:"); ok {
+		for _, g := range c.Groups {
+			if g == group {
+				return true
+			}
+		}
+		return false
+	}
+	return c.Subject != "" && subject == c.Subject
+}
+
+func (a *RBAC) isAdmin(c *util.Claims) bool {
+	for _, s := range a.Admins {
+		if matches(s, c) {
+			return true
+		}
+	}
+	return false
+}
+
+// role returns the highest role the caller holds in ns, or "".
+func (a *RBAC) role(c *util.Claims, ns *registry.Namespace) string {
+	if a.isAdmin(c) {
+		return registry.RoleAdmin
+	}
+	role := ""
+	for _, m := range ns.Members {
+		if matches(m.Subject, c) && registry.RoleRank(m.Role) > registry.RoleRank(role) {
+			role = m.Role
+		}
+	}
+	return role
+}
+
+// can reports whether the caller holds at least role in ns. Without RBAC everyone can.
+func (h *Handler) can(r *http.Request, ns *registry.Namespace, role string) bool {
+	if h.rbac == nil {
+		return true
+	}
+	return registry.RoleRank(h.rbac.role(util.ClaimsFrom(r.Context()), ns)) >= registry.RoleRank(role)
+}
+
+// authorize is can, answering 403 when the caller lacks the role.
+func (h *Handler) authorize(w http.ResponseWriter, r *http.Request, ns *registry.Namespace, role string) bool {
+	if h.can(r, ns, role) {
+		return true
+	}
+	writeError(w, http.StatusForbidden, codeForbidden, fmt.Sprintf("this requires the %s role in namespace %s", role, ns.Name))
+	return false
+}
+
+// authorizeAdmin answers 403 unless the caller is a global admin (or RBAC is off).
+func (h *Handler) authorizeAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if h.rbac == nil || h.rbac.isAdmin(util.ClaimsFrom(r.Context())) {
+		return true
+	}
+	writeError(w, http.StatusForbidden, codeForbidden, "this requires a global admin")
+	return false
+}
+
+// requiredRole is the role a request to a namespace's services, drafts and revisions needs:
+// viewer to read, editor to change anything.
+func requiredRole(r *http.Request) string {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return registry.RoleViewer
+	}
+	return registry.RoleEditor
+}
+
+// validateMembers checks the roles and subjects of namespace members.
+func validateMembers(members []registry.Member) error {
+	for i, m := range members {
+		field := fmt.Sprintf("members[%d]", i)
+		if strings.TrimSpace(m.Subject) == "" || m.Subject == "group:" {
+			return fieldError("required", field+".subject", "member subject required")
+		}
+		if registry.RoleRank(m.Role) == 0 {
+			return fieldError("invalid", field+".role", fmt.Sprintf("unknown role %q (want viewer, editor or admin)", m.Role))
+		}
+	}
+	return nil
+}
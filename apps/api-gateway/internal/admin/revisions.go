@@ -0,0 +1,102 @@
+package admin
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"ecomm/api-gateway/internal/registry"
+	"ecomm/api-gateway/internal/util"
+)
+
+// Revisions lists the revisions of the live registry, newest first, without their snapshots.
+// @Summary List revisions
+// @Tags admin
+// @Produce json
+// @Success 200 {array} registry.Revision
+// @Failure 401 {object} admin.problem
+// @Security BearerAuth
+// @Router /admin/v1/revisions [get]
+func (h *Handler) Revisions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w)
+		return
+	}
+	list, err := h.repo.ListRevisions(r.Context())
+	if err != nil {
+		internalError(w, err)
+		return
+	}
+	util.JSON(w, list)
+}
+
+// RevisionByID dispatches the actions on one revision, addressed by number or "latest":
+//
+//	GET   /admin/v1/revisions/{n}
+//	GET   /admin/v1/revisions/{n}/diff
+//	POST  /admin/v1/revisions/{n}/rollback
+func (h *Handler) RevisionByID(w http.ResponseWriter, r *http.Request) {
+	rest := strings.TrimPrefix(r.URL.Path, APIBase+"/revisions/")
+	ref, action, _ := strings.Cut(rest, "/")
+	var number int64
+	if ref != "latest" {
+		n, err := strconv.ParseInt(ref, 10, 64)
+		if err != nil || n < 1 {
+			notFound(w, "revision")
+			return
+		}
+		number = n
+	}
+	rev, err := h.repo.GetRevision(r.Context(), number)
+	if err != nil {
+		lookupError(w, err, "revision")
+		return
+	}
+	switch {
+	case action == "" && r.Method == http.MethodGet:
+		util.JSON(w, rev)
+	case action == "diff" && r.Method == http.MethodGet:
+		live, err := registry.TakeSnapshot(r.Context(), h.repo)
+		if err != nil {
+			internalError(w, err)
+			return
+		}
+		util.JSON(w, snapshotDiff{Current: true, Changes: registry.DiffSnapshots(live, rev.Snapshot)})
+	case action == "rollback" && r.Method == http.MethodPost:
+		h.rollback(w, r, rev)
+	case action == "", action == "diff", action == "rollback":
+		methodNotAllowed(w)
+	default:
+		notFound(w, "resource")
+	}
+}
+
+// rollback makes the live registry match an earlier revision, in one transaction, and records
+// the result as a new revision. Services deleted since are recreated with their IDs.
+// @Summary Roll back to a revision
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param n path int true "Revision number"
+// @Param payload body admin.PublishRequest false "Revision message"
+// @Success 200 {object} registry.Revision
+// @Failure 404 {object} admin.problem
+// @Failure 409 {object} admin.problem "conflict"
+// @Security BearerAuth
+// @Router /admin/v1/revisions/{n}/rollback [post]
+func (h *Handler) rollback(w http.ResponseWriter, r *http.Request, target *registry.Revision) {
+	body, ok := decodePublishRequest(w, r)
+	if !ok {
+		return
+	}
+	if body.Message == "" {
+		body.Message = "rollback to revision " + strconv.FormatInt(target.Number, 10)
+	}
+	rev := &registry.Revision{Source: registry.RevisionSourceRollback, Message: body.Message, RollbackOf: target.Number}
+	if err := h.publish(r.Context(), target.Snapshot, rev, nil); err != nil {
+		writePublishError(w, err)
+		return
+	}
+	rev.Snapshot = nil
+	util.JSON(w, rev)
+}
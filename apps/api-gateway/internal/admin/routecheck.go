@@ -0,0 +1,54 @@
+package admin
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"ecomm/api-gateway/internal/registry"
+	"ecomm/api-gateway/internal/routing"
+)
+
+var fieldPath = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)*$`)
+
+// routeConflictError explains why a route cannot coexist with an existing one.
+type routeConflictError struct {
+	Route    *registry.Route
+	Existing *registry.Route
+	Reason   string
+}
+
+func (e *routeConflictError) Error() string {
+	return fmt.Sprintf("route %s %s conflicts with route %s (%s %s): %s",
+		strings.ToUpper(e.Route.Method), e.Route.Path, e.Existing.ID, strings.ToUpper(e.Existing.Method), e.Existing.Path, e.Reason)
+}
+
+// checkRoute validates rt's path template and body selectors, and rejects it when it is ambiguous with another
+// route of the same service and method. Overlaps that template precedence resolves (a literal
+// segment beats a variable, a constrained variable beats a plain one, a single-segment variable
+// beats **) are allowed. The returned error is either a template error or *routeConflictError.
+func checkRoute(rt *registry.Route, others []*registry.Route) error {
+	t, err := routing.Parse(rt.Path)
+	if err != nil {
+		return err
+	}
+	switch {
+	case rt.Body != "" && rt.Body != "*" && rt.Body != registry.RouteBodyNone && !fieldPath.MatchString(rt.Body):
+		return fmt.Errorf("body must be \"*\", %q or a field path, got %q", registry.RouteBodyNone, rt.Body)
+	case rt.ResponseBody != "" && rt.ResponseBody != "*" && !fieldPath.MatchString(rt.ResponseBody):
+		return fmt.Errorf("response_body must be a field path, got %q", rt.ResponseBody)
+	}
+	for _, o := range others {
+		if o.ID == rt.ID || !strings.EqualFold(o.Method, rt.Method) {
+			continue
+		}
+		ot, err := routing.Parse(o.Path)
+		if err != nil {
+			continue
+		}
+		if reason, ok := routing.Conflict(t, ot); ok {
+			return &routeConflictError{Route: rt, Existing: o, Reason: reason}
+		}
+	}
+	return nil
+}
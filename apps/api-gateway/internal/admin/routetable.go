@@ -0,0 +1,82 @@
+package admin
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"ecomm/api-gateway/internal/registry"
+	"ecomm/api-gateway/internal/util"
+)
+
+// compiledRouteView is one entry of the compiled route table, in match order.
+type compiledRouteView struct {
+	Precedence int      `json:"precedence"`
+	RouteID    string   `json:"route_id"`
+	Method     string   `json:"method"`
+	Path       string   `json:"path"`
+	Vars       []string `json:"vars,omitempty"`
+	GRPCMethod string   `json:"grpc_method"`
+	Source     string   `json:"source,omitempty"`
+}
+
+// routeTableView is the compiled route table the proxy currently serves for a service.
+type routeTableView struct {
+	ServiceID string                  `json:"service_id"`
+	Loaded    bool                    `json:"loaded"`
+	LoadedAt  *time.Time              `json:"loaded_at,omitempty"`
+	Routes    []compiledRouteView     `json:"routes"`
+	Invalid   []registry.InvalidRoute `json:"invalid,omitempty"`
+}
+
+// RouteTable shows the compiled route table of a service as held in memory by the proxy.
+// Precedence is the position within the method's list; the first matching entry wins.
+func (h *Handler) RouteTable(w http.ResponseWriter, r *http.Request, serviceID string) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w)
+		return
+	}
+	if _, err := h.repo.Get(r.Context(), serviceID); err != nil {
+		lookupError(w, err, "service")
+		return
+	}
+	view := routeTableView{ServiceID: serviceID, Routes: []compiledRouteView{}}
+	if t := h.reg.Routes(serviceID); t != nil {
+		view.Loaded = true
+		view.LoadedAt = &t.LoadedAt
+		view.Invalid = t.Invalid()
+		prec, method := 0, ""
+		for _, c := range t.Routes() {
+			if m := strings.ToUpper(c.Route.Method); m != method {
+				prec, method = 0, m
+			}
+			prec++
+			view.Routes = append(view.Routes, compiledRouteView{
+				Precedence: prec,
+				RouteID:    c.Route.ID,
+				Method:     c.Route.Method,
+				Path:       c.Route.Path,
+				Vars:       c.Template.Vars(),
+				GRPCMethod: c.Route.GRPCMethod,
+				Source:     c.Route.Source,
+			})
+		}
+	}
+	util.JSON(w, view)
+}
+
+// reloadRoutes recompiles the in-memory route table of a loaded service after its routes
+// changed. Services that aren't loaded (disabled ones) get their table on the next LoadEnabled.
+func (h *Handler) reloadRoutes(ctx context.Context, serviceID string) {
+	if h.reg == nil {
+		return
+	}
+	if svc := h.reg.Service(serviceID); svc == nil || !strings.EqualFold(svc.Protocol, "grpc-json") {
+		return
+	}
+	if err := registry.ReloadRoutes(ctx, h.repo, h.reg, serviceID); err != nil {
+		log.Printf("warn: reload routes for %s failed: %v", serviceID, err)
+	}
+}
@@ -0,0 +1,85 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"ecomm/api-gateway/internal/registry"
+)
+
+func TestRouteTableFollowsRouteChanges(t *testing.T) {
+	ctx := context.Background()
+	repo := registry.NewMemoryRepository()
+	svc := &registry.Service{ID: uuid.NewString(), Name: "catalog", PublicPrefix: "/api/catalog/", Protocol: "grpc-json", GRPCTarget: "dns:///catalog:9090", Enabled: true}
+	if err := repo.Create(ctx, svc); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	reg := registry.New()
+	if err := registry.LoadEnabled(repo, reg); err != nil {
+		t.Fatalf("LoadEnabled: %v", err)
+	}
+	h := NewHandler(repo, reg)
+
+	table := func() routeTableView {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		h.RouteTable(rec, httptest.NewRequest(http.MethodGet, "/", nil), svc.ID)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET table: status %d: %s", rec.Code, rec.Body)
+		}
+		var v routeTableView
+		if err := json.Unmarshal(rec.Body.Bytes(), &v); err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	if v := table(); !v.Loaded || len(v.Routes) != 0 {
+		t.Fatalf("initial table = %+v, want loaded and empty", v)
+	}
+
+	var created []string
+	for _, path := range []string{"/v1/items/{id}", "/v1/items/featured"} {
+		rec := httptest.NewRecorder()
+		body := `{"method":"GET","path":"` + path + `","grpc_method":"catalog.v1.Catalog/GetItem"}`
+		h.Routes(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)), svc.ID)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("POST route %s: status %d: %s", path, rec.Code, rec.Body)
+		}
+		var rt registry.Route
+		if err := json.Unmarshal(rec.Body.Bytes(), &rt); err != nil {
+			t.Fatal(err)
+		}
+		created = append(created, rt.ID)
+	}
+	v := table()
+	if len(v.Routes) != 2 || v.Routes[0].Path != "/v1/items/featured" || v.Routes[0].Precedence != 1 || v.Routes[1].Vars[0] != "id" {
+		t.Fatalf("table after create = %+v, want the literal route first", v.Routes)
+	}
+	if rt, _ := reg.Routes(svc.ID).Match("GET", "/v1/items/42"); rt == nil || rt.ID != created[0] {
+		t.Fatalf("proxy table doesn't serve the created route: %v", rt)
+	}
+
+	rec := httptest.NewRecorder()
+	h.RouteByID(rec, httptest.NewRequest(http.MethodDelete, "/", nil), svc.ID, created[1])
+	if rec.Code != http.StatusOK {
+		t.Fatalf("DELETE route: status %d: %s", rec.Code, rec.Body)
+	}
+	if v := table(); len(v.Routes) != 1 || v.Routes[0].RouteID != created[0] {
+		t.Fatalf("table after delete = %+v, want only %s", v.Routes, created[0])
+	}
+	if rt, _ := reg.Routes(svc.ID).Match("GET", "/v1/items/featured"); rt == nil || rt.ID != created[0] {
+		t.Fatalf("deleted route still served: %v", rt)
+	}
+
+	rec = httptest.NewRecorder()
+	h.RouteTable(rec, httptest.NewRequest(http.MethodGet, "/", nil), uuid.NewString())
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("table of a missing service: status %d, want 404", rec.Code)
+	}
+}
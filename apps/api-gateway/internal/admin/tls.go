@@ -0,0 +1,99 @@
+package admin
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"ecomm/api-gateway/internal/registry"
+	"ecomm/api-gateway/internal/secrets"
+	"ecomm/api-gateway/internal/upstream"
+	"ecomm/api-gateway/internal/util"
+)
+
+// UpstreamTLS reads (GET), replaces (PUT) or clears (DELETE) a service's upstream TLS settings.
+// Settings are stored encrypted; GET masks the client private key.
+// @Summary Manage upstream TLS settings
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param id path string true "Service ID"
+// @Param payload body registry.UpstreamTLS false "TLS settings (PUT)"
+// @Success 200 {object} registry.UpstreamTLS
+// @Failure 400 {object} admin.problem
+// @Failure 401 {object} admin.problem
+// @Failure 404 {object} admin.problem
+// @Security BearerAuth
+// @Router /admin/v1/services/{id}/tls [get]
+// @Router /admin/v1/services/{id}/tls [put]
+// @Router /admin/v1/services/{id}/tls [delete]
+func (h *Handler) UpstreamTLS(w http.ResponseWriter, r *http.Request, serviceID string) {
+	svc, err := h.repo.Get(r.Context(), serviceID)
+	if err != nil {
+		lookupError(w, err, "service")
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		t, err := h.repo.GetUpstreamTLS(r.Context(), serviceID)
+		if err != nil {
+			internalError(w, err)
+			return
+		}
+		if t == nil {
+			notFound(w, "tls settings")
+			return
+		}
+		util.JSON(w, t.Redacted())
+	case http.MethodPut:
+		var body registry.UpstreamTLS
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			badRequest(w, err)
+			return
+		}
+		if body.InsecureSkipVerify && !h.allowInsecureTLS {
+			invalidField(w, "insecure_skip_verify", "insecure_skip_verify is only allowed when the gateway runs in dev mode")
+			return
+		}
+		// Validate by building the config: unreadable files or mismatched key pairs fail here
+		// instead of on the first proxied request.
+		if _, _, err := upstream.BuildConfig(&body); err != nil {
+			badRequest(w, err)
+			return
+		}
+		if err := h.repo.SaveUpstreamTLS(r.Context(), serviceID, &body); err != nil {
+			writeTLSStoreError(w, err)
+			return
+		}
+		h.invalidateTLS(svc)
+		util.JSON(w, body.Redacted())
+	case http.MethodDelete:
+		if err := h.repo.SaveUpstreamTLS(r.Context(), serviceID, nil); err != nil {
+			writeTLSStoreError(w, err)
+			return
+		}
+		h.invalidateTLS(svc)
+		util.JSON(w, map[string]string{"deleted": serviceID})
+	default:
+		methodNotAllowed(w)
+	}
+}
+
+func writeTLSStoreError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, secrets.ErrNoKey):
+		writeError(w, http.StatusServiceUnavailable, codeUnavailable, err.Error())
+	case errors.Is(err, sql.ErrNoRows):
+		notFound(w, "service")
+	default:
+		internalError(w, err)
+	}
+}
+
+// invalidateTLS drops cached TLS state for svc so the next request re-reads its settings.
+func (h *Handler) invalidateTLS(svc *registry.Service) {
+	if h.tlsm != nil {
+		h.tlsm.Invalidate(svc)
+	}
+}
@@ -1,5 +1,7 @@
 package admin
 
+import "ecomm/api-gateway/internal/registry"
+
 // CreateServiceRequest is the request payload to create/register a service
 type CreateServiceRequest struct {
 	Name         string `json:"name" example:"User Service"`
@@ -7,9 +9,18 @@ type CreateServiceRequest struct {
 	PublicPrefix string `json:"public_prefix" example:"/api/users/"`
 	SwaggerURL   string `json:"swagger_url" example:"http://user-service:8081/swagger.json"`
 	BaseURL      string `json:"base_url" example:"http://user-service:8081"`
-	// Protocol: "http" (default) uses reverse proxy; "grpc-json" enables HTTP→gRPC transcoding
+	// Protocol: "http" (default) uses reverse proxy; "grpc-json" enables HTTP→gRPC transcoding;
+	// "grpc" and "grpc-web" proxy gRPC and gRPC-Web calls to GRPCTarget
 	Protocol string `json:"protocol" example:"http"`
-	// GRPCTarget is required when Protocol is "grpc-json" (format host:port)
+	// GRPCTarget is required for the grpc* protocols (format host:port)
 	GRPCTarget string `json:"grpc_target" example:"user-service:9090"`
-	Enabled    *bool  `json:"enabled" example:"true"`
+	// DescriptorSource for grpc-json: "reflection" (default, uploaded descriptor set as fallback) or "descriptor_set"
+	DescriptorSource string `json:"descriptor_source" example:"reflection"`
+	// Metadata configures header <-> gRPC metadata mapping for grpc-json services
+	Metadata *registry.MetadataPolicy `json:"metadata"`
+	// Streaming configures WebSocket upgrades, streamed responses and connection limits for http services
+	Streaming *registry.StreamingPolicy `json:"streaming"`
+	// Labels are free-form key/value tags for filtering the service list
+	Labels  map[string]string `json:"labels"`
+	Enabled *bool             `json:"enabled" example:"true"`
 }
@@ -0,0 +1,326 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+
+	"ecomm/api-gateway/internal/registry"
+)
+
+// upstreamProbeTimeout bounds the reachability check of a service's upstream.
+const upstreamProbeTimeout = 3 * time.Second
+
+// validationIssue is one finding of a service validation. Code is stable for clients.
+type validationIssue struct {
+	Code    string `json:"code"`
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+func (i validationIssue) Error() string { return i.Message }
+
+// fieldError returns a validation issue as an error; badRequest reports it field by field.
+func fieldError(code, field, message string) error {
+	return validationIssue{Code: code, Field: field, Message: message}
+}
+
+// validationReport is the outcome of checkService. Errors block onboarding, warnings don't.
+type validationReport struct {
+	Valid    bool              `json:"valid"`
+	DryRun   bool              `json:"dry_run,omitempty"`
+	Errors   []validationIssue `json:"errors"`
+	Warnings []validationIssue `json:"warnings"`
+	// Service is the normalized service as it would be stored, without its swagger document.
+	Service *registry.Service `json:"service,omitempty"`
+}
+
+func (v *validationReport) fail(code, field, format string, args ...any) {
+	v.Errors = append(v.Errors, validationIssue{Code: code, Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validationReport) warn(code, field, format string, args ...any) {
+	v.Warnings = append(v.Warnings, validationIssue{Code: code, Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// conflictOnly reports whether the only errors are prefix conflicts with other services.
+func (v *validationReport) conflictOnly() bool {
+	for _, e := range v.Errors {
+		if e.Code != "prefix_conflict" && e.Code != "prefix_overlap" {
+			return false
+		}
+	}
+	return len(v.Errors) > 0
+}
+
+// checkOptions tune checkService from query parameters.
+type checkOptions struct {
+	// allowOverlap downgrades nested prefixes to a warning (exact duplicates stay errors).
+	allowOverlap bool
+	// probe enables the upstream reachability check.
+	probe bool
+}
+
+func parseCheckOptions(r *http.Request) checkOptions {
+	q := r.URL.Query()
+	return checkOptions{
+		allowOverlap: q.Get("allow_prefix_overlap") == "true",
+		probe:        q.Get("skip_upstream_check") != "true",
+	}
+}
+
+func isDryRun(r *http.Request) bool {
+	return r.URL.Query().Get("dry_run") == "true"
+}
+
+// checkService validates a normalized service beyond its required fields: protocol
+// consistency, prefix conflicts with the other registered services and, if enabled, whether
+// the upstream answers.
+func (h *Handler) checkService(ctx context.Context, svc *registry.Service, opts checkOptions) (*validationReport, error) {
+	shown := *svc
+	shown.SwaggerJSON = nil
+	rep := &validationReport{Errors: []validationIssue{}, Warnings: []validationIssue{}, Service: &shown}
+	checkProtocol(rep, svc)
+	others, err := h.repo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	checkPrefix(rep, svc, others, opts.allowOverlap)
+	if opts.probe && len(rep.Errors) == 0 {
+		h.probeUpstream(ctx, rep, svc)
+	}
+	rep.Valid = len(rep.Errors) == 0
+	return rep, nil
+}
+
+// checkProtocol flags settings that don't fit the service protocol.
+func checkProtocol(rep *validationReport, svc *registry.Service) {
+	switch svc.Protocol {
+	case "http":
+		u, err := url.Parse(svc.BaseURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			rep.fail("invalid_base_url", "base_url", "base_url %q must be an absolute http or https URL", svc.BaseURL)
+		}
+		if svc.GRPCTarget != "" {
+			rep.warn("ignored_field", "grpc_target", "grpc_target is ignored for protocol=http")
+		}
+		if svc.DescriptorSource != "" {
+			rep.warn("ignored_field", "descriptor_source", "descriptor_source is ignored for protocol=http")
+		}
+	default:
+		if strings.Contains(svc.GRPCTarget, "://") && !strings.HasPrefix(svc.GRPCTarget, "dns://") {
+			rep.fail("invalid_grpc_target", "grpc_target", "grpc_target %q must be host:port, not a URL", svc.GRPCTarget)
+		} else if _, port, err := net.SplitHostPort(strings.TrimPrefix(svc.GRPCTarget, "dns:///")); err != nil || port == "" {
+			rep.fail("invalid_grpc_target", "grpc_target", "grpc_target %q must be host:port", svc.GRPCTarget)
+		}
+		if svc.BaseURL != "" {
+			rep.warn("ignored_field", "base_url", "base_url is not used for protocol=%s; requests go to grpc_target", svc.Protocol)
+		}
+		if svc.DescriptorSource != "" && svc.Protocol != "grpc-json" {
+			rep.warn("ignored_field", "descriptor_source", "descriptor_source only applies to protocol=grpc-json")
+		}
+		if isGRPCPassthrough(svc.Protocol) {
+			if r, ok := registry.ReservedConflict(svc.PublicPrefix); ok {
+				rep.fail("reserved_prefix", "public_prefix", "public_prefix %s would intercept the gateway's own %s endpoints", svc.PublicPrefix, r)
+			}
+		}
+		switch {
+		case svc.Streaming == nil || svc.Protocol == "grpc-json":
+		case svc.Protocol == "grpc-web" && streamingOnlyOrigins(svc.Streaming):
+		case svc.Protocol == "grpc-web":
+			rep.warn("ignored_field", "streaming", "only streaming.allowed_origins applies to protocol=grpc-web")
+		default:
+			rep.warn("ignored_field", "streaming", "streaming policy only applies to protocol=http, grpc-json or grpc-web")
+		}
+	}
+}
+
+// checkPrefix reports prefixes that collide with enabled or disabled services. The registry
+// routes by longest prefix, so a nested prefix silently takes over part of the other
+// service's path space; an identical prefix replaces the other service outright.
+func checkPrefix(rep *validationReport, svc *registry.Service, others []*registry.Service, allowOverlap bool) {
+	for _, o := range others {
+		if o.ID == svc.ID {
+			continue
+		}
+		switch {
+		case o.PublicPrefix == svc.PublicPrefix:
+			rep.fail("prefix_conflict", "public_prefix", "public_prefix %s is already used by service %s (%s)", svc.PublicPrefix, o.Name, o.ID)
+		case strings.HasPrefix(svc.PublicPrefix, o.PublicPrefix):
+			msg := "public_prefix %s is nested under %s of service %s (%s) and shadows part of its paths"
+			if allowOverlap {
+				rep.warn("prefix_overlap", "public_prefix", msg, svc.PublicPrefix, o.PublicPrefix, o.Name, o.ID)
+			} else {
+				rep.fail("prefix_overlap", "public_prefix", msg+"; pass allow_prefix_overlap=true if intended", svc.PublicPrefix, o.PublicPrefix, o.Name, o.ID)
+			}
+		case strings.HasPrefix(o.PublicPrefix, svc.PublicPrefix):
+			msg := "public_prefix %s contains %s of service %s (%s), which shadows part of its paths"
+			if allowOverlap {
+				rep.warn("prefix_overlap", "public_prefix", msg, svc.PublicPrefix, o.PublicPrefix, o.Name, o.ID)
+			} else {
+				rep.fail("prefix_overlap", "public_prefix", msg+"; pass allow_prefix_overlap=true if intended", svc.PublicPrefix, o.PublicPrefix, o.Name, o.ID)
+			}
+		}
+	}
+}
+
+// probeUpstream checks that the upstream answers. Any HTTP response, or any gRPC status other
+// than Unavailable/DeadlineExceeded, counts as reachable; a non-serving health status is only
+// a warning.
+func (h *Handler) probeUpstream(ctx context.Context, rep *validationReport, svc *registry.Service) {
+	ctx, cancel := context.WithTimeout(ctx, upstreamProbeTimeout)
+	defer cancel()
+	if svc.Protocol == "http" {
+		client := &http.Client{Timeout: upstreamProbeTimeout}
+		if h.tlsm != nil {
+			if rt, err := h.tlsm.Transport(ctx, svc); err == nil {
+				client.Transport = rt
+			}
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, svc.BaseURL, nil)
+		if err != nil {
+			rep.fail("upstream_unreachable", "base_url", "%v", err)
+			return
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			rep.fail("upstream_unreachable", "base_url", "upstream %s is not reachable: %v", svc.BaseURL, err)
+			return
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 500 {
+			rep.warn("upstream_error", "base_url", "upstream %s answered %d", svc.BaseURL, resp.StatusCode)
+		}
+		return
+	}
+	// A throwaway connection: the target may never be saved, and a rejected one must not linger
+	// in grpcjson.Pool.
+	var creds credentials.TransportCredentials = insecure.NewCredentials()
+	if h.tlsm != nil {
+		if cfg, err := h.tlsm.Config(ctx, svc); err == nil && cfg != nil {
+			creds = credentials.NewTLS(cfg)
+		}
+	}
+	conn, err := grpc.NewClient(svc.GRPCTarget, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		rep.fail("upstream_unreachable", "grpc_target", "cannot dial %s: %v", svc.GRPCTarget, err)
+		return
+	}
+	defer conn.Close()
+	res, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+	switch status.Code(err) {
+	case codes.OK:
+		if res.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+			rep.warn("upstream_not_serving", "grpc_target", "%s reports health status %s", svc.GRPCTarget, res.GetStatus())
+		}
+	case codes.Unavailable, codes.DeadlineExceeded:
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			err = fmt.Errorf("no answer within %s", upstreamProbeTimeout)
+		}
+		rep.fail("upstream_unreachable", "grpc_target", "upstream %s is not reachable: %v", svc.GRPCTarget, err)
+	case codes.Unimplemented:
+		rep.warn("no_health_service", "grpc_target", "%s is reachable but doesn't implement grpc.health.v1", svc.GRPCTarget)
+	}
+}
+
+// checkSnapshot validates a whole registry, as a draft is before it's published: every service
+// like a single write, prefixes against each other, and the routes of each service against its
+// other routes. Prefix overlaps the live registry already has don't block, and only the
+// upstreams of services that are new or changed are probed. Issues are attributed to
+// "services/{id}/{field}" and "routes/{id}/{field}".
+func (h *Handler) checkSnapshot(ctx context.Context, snap, live *registry.Snapshot, opts checkOptions) *validationReport {
+	rep := &validationReport{Errors: []validationIssue{}, Warnings: []validationIssue{}}
+	liveByID := map[string]*registry.Service{}
+	for _, s := range live.Services {
+		liveByID[s.ID] = s
+	}
+	byID := map[string]*registry.Service{}
+	for _, s := range snap.Services {
+		byID[s.ID] = s
+	}
+	for _, s := range snap.Services {
+		svc := *s
+		sub := &validationReport{}
+		if err := validateService(&svc); err != nil {
+			var issue validationIssue
+			if !errors.As(err, &issue) {
+				issue = validationIssue{Code: "invalid", Message: err.Error()}
+			}
+			sub.Errors = append(sub.Errors, issue)
+		} else {
+			cur := liveByID[svc.ID]
+			checkProtocol(sub, &svc)
+			var others []*registry.Service
+			for _, o := range snap.Services {
+				if o.ID != svc.ID {
+					others = append(others, o)
+				}
+			}
+			checkPrefix(sub, &svc, others, opts.allowOverlap || cur != nil && cur.PublicPrefix == svc.PublicPrefix)
+			changed := cur == nil || cur.Protocol != svc.Protocol || cur.BaseURL != svc.BaseURL || cur.GRPCTarget != svc.GRPCTarget
+			if opts.probe && changed && len(sub.Errors) == 0 {
+				h.probeUpstream(ctx, sub, &svc)
+			}
+		}
+		rep.merge(sub, "services/"+svc.ID, "service "+svc.PublicPrefix+": ")
+	}
+	routes := map[string][]*registry.Route{}
+	for _, rt := range snap.Routes {
+		routes[rt.ServiceID] = append(routes[rt.ServiceID], rt)
+	}
+	for _, rt := range snap.Routes {
+		sub := &validationReport{}
+		where := "route " + strings.ToUpper(rt.Method) + " " + rt.Path + ": "
+		svc := byID[rt.ServiceID]
+		switch {
+		case svc == nil:
+			sub.fail("not_found", "service_id", "service %s does not exist", rt.ServiceID)
+		case !strings.EqualFold(svc.Protocol, "grpc-json"):
+			sub.warn("ignored_route", "service_id", "routes only apply to protocol=grpc-json; service %s uses %s", svc.PublicPrefix, svc.Protocol)
+		}
+		if err := checkRoute(rt, routes[rt.ServiceID]); err != nil {
+			sub.fail("invalid_route", "path", "%v", err)
+		}
+		if svc != nil {
+			where = "route " + strings.ToUpper(rt.Method) + " " + svc.PublicPrefix + strings.TrimPrefix(rt.Path, "/") + ": "
+		}
+		rep.merge(sub, "routes/"+rt.ID, where)
+	}
+	rep.Valid = len(rep.Errors) == 0
+	return rep
+}
+
+// merge adds the issues of sub to v, prefixing fields with path and messages with subject.
+func (v *validationReport) merge(sub *validationReport, path, subject string) {
+	for _, i := range sub.Errors {
+		i.Field, i.Message = strings.TrimSuffix(path+"/"+i.Field, "/"), subject+i.Message
+		v.Errors = append(v.Errors, i)
+	}
+	for _, i := range sub.Warnings {
+		i.Field, i.Message = strings.TrimSuffix(path+"/"+i.Field, "/"), subject+i.Message
+		v.Warnings = append(v.Warnings, i)
+	}
+}
+
+// isGRPCPassthrough reports whether services with protocol p are matched ahead of the gateway's
+// own routes (see proxy.GRPC).
+func isGRPCPassthrough(p string) bool {
+	return p == "grpc" || p == "grpc-web"
+}
+
+// streamingOnlyOrigins reports whether sp sets nothing but AllowedOrigins.
+func streamingOnlyOrigins(sp *registry.StreamingPolicy) bool {
+	return sp.WebSocket == nil && sp.IdleTimeoutSeconds == 0 && sp.MaxDurationSeconds == 0 && sp.MaxConnections == 0
+}
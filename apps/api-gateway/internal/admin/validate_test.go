@@ -0,0 +1,49 @@
+package admin
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
+	"ecomm/api-gateway/internal/registry"
+)
+
+func TestProbeUpstreamGRPC(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer()
+	hs := health.NewServer()
+	healthpb.RegisterHealthServer(srv, hs)
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed.Close()
+
+	h := NewHandler(registry.NewMemoryRepository(), registry.New())
+	probe := func(target string) *validationReport {
+		rep := &validationReport{}
+		h.probeUpstream(context.Background(), rep, &registry.Service{Protocol: "grpc-json", GRPCTarget: target})
+		return rep
+	}
+
+	if rep := probe(lis.Addr().String()); len(rep.Errors)+len(rep.Warnings) != 0 {
+		t.Errorf("serving upstream: got %+v, want no findings", rep)
+	}
+	hs.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+	if rep := probe(lis.Addr().String()); len(rep.Warnings) != 1 || rep.Warnings[0].Code != "upstream_not_serving" {
+		t.Errorf("not serving upstream: got %+v, want an upstream_not_serving warning", rep)
+	}
+	if rep := probe(closed.Addr().String()); len(rep.Errors) != 1 || rep.Errors[0].Code != "upstream_unreachable" {
+		t.Errorf("closed port: got %+v, want an upstream_unreachable error", rep)
+	}
+}
@@ -0,0 +1,46 @@
+package admin
+
+import (
+	"net/http"
+	"strings"
+)
+
+// APIBase is the path prefix of the current admin API version.
+const APIBase = "/admin/v1"
+
+// Legacy serves the unversioned /admin/... paths as deprecated aliases of /admin/v1/...: the
+// request is rewritten to its versioned path and the response points at the successor with
+// Deprecation and Link headers.
+func Legacy(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		successor := APIBase + strings.TrimPrefix(r.URL.Path, "/admin")
+		w.Header().Set("Deprecation", "true")
+		w.Header().Add("Link", "<"+successor+`>; rel="successor-version"`)
+		next.ServeHTTP(w, withPath(r, successor))
+	})
+}
+
+// withPath returns a shallow copy of r for another path, so a handler can serve a request
+// addressed to an alias of its path.
+func withPath(r *http.Request, path string) *http.Request {
+	r2 := new(http.Request)
+	*r2 = *r
+	u := *r.URL
+	u.Path, u.RawPath = path, ""
+	r2.URL = &u
+	return r2
+}
+
+// ReadOnly rejects requests that would change the registry, for gateways whose registry is
+// managed from configuration files; reason tells clients where changes go instead.
+func ReadOnly(next http.Handler, reason string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next.ServeHTTP(w, r)
+		default:
+			w.Header().Set("Allow", "GET, HEAD, OPTIONS")
+			writeError(w, http.StatusMethodNotAllowed, codeReadOnly, reason)
+		}
+	})
+}
@@ -1,7 +1,9 @@
 package app
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
 	"strconv"
@@ -10,9 +12,12 @@ import (
 	httpSwagger "github.com/swaggo/http-swagger"
 
 	"ecomm/api-gateway/internal/admin"
+	"ecomm/api-gateway/internal/gitops"
+	"ecomm/api-gateway/internal/grpcjson"
 	hc "ecomm/api-gateway/internal/health"
 	"ecomm/api-gateway/internal/proxy"
 	"ecomm/api-gateway/internal/registry"
+	"ecomm/api-gateway/internal/upstream"
 	"ecomm/api-gateway/internal/util"
 )
 
@@ -23,6 +28,29 @@ type Options struct {
 	Registry       *registry.Registry
 	JWTSecret      string
 	HealthInterval time.Duration
+	// ReloadInterval re-reads services and route tables from Repo this often, so changes made
+	// through another replica's Admin API reach this one. Zero disables it.
+	ReloadInterval time.Duration
+	// AllowInsecureTLS permits insecure_skip_verify in upstream TLS settings. Development only.
+	AllowInsecureTLS bool
+	// ConfigDir, when set, declares services and routes in YAML files (see package gitops). They
+	// are reconciled into Repo at startup and, with WatchConfig, whenever they change.
+	ConfigDir   string
+	WatchConfig bool
+	// PruneConfig deletes services the files don't declare.
+	PruneConfig bool
+	// FileOnly means Repo holds nothing but the configuration files (an in-memory repository):
+	// undeclared services are always pruned and the Admin API is read-only.
+	FileOnly bool
+	// ConfigNamespace is the namespace the configuration files declare (default "default").
+	ConfigNamespace string
+	// NamespaceHeader, when set, names a request header that selects the namespace of proxied
+	// requests instead of the host name (see proxy.NamespaceHeader). Empty disables it.
+	NamespaceHeader string
+	// RBAC requires Admin API callers to present a JWT signed with JWTSecret and authorizes them
+	// by namespace role; Admins are the global admins (subjects or "group:NAME").
+	RBAC   bool
+	Admins []string
 }
 
 // NewServer builds the http.Server with all routes and background jobs wired.
@@ -37,8 +65,40 @@ func NewServer(opts Options) (*http.Server, error) {
 	if opts.HealthInterval <= 0 {
 		opts.HealthInterval = 30 * time.Second
 	}
+	if opts.RBAC && opts.JWTSecret == "" {
+		return nil, fmt.Errorf("RBAC requires a JWT secret")
+	}
+	proxy.NamespaceHeader = opts.NamespaceHeader
 	// Load enabled services into in-memory routing registry
+	var tlsm *upstream.TLSManager
 	if opts.Repo != nil {
+		grpcjson.Descriptors.Loader = admin.SchemaLoader(opts.Repo)
+		tlsm = upstream.NewTLSManager(opts.Repo, opts.Registry)
+		grpcjson.Pool.SetCredentials(tlsm.GRPCCredentials)
+		// Re-dial pooled gRPC connections when a service's TLS material changes.
+		tlsm.OnChange(func(svc *registry.Service) {
+			if svc.GRPCTarget != "" {
+				grpcjson.Pool.Evict(svc.GRPCTarget)
+			}
+		})
+		if opts.ConfigDir != "" {
+			rec := &gitops.Reconciler{
+				Dir:       opts.ConfigDir,
+				Repo:      opts.Repo,
+				Registry:  opts.Registry,
+				TLS:       tlsm,
+				Namespace: opts.ConfigNamespace,
+				Options:   gitops.Options{Prune: opts.PruneConfig || opts.FileOnly},
+			}
+			plan, err := rec.Reconcile(context.Background())
+			rec.Log(plan, err)
+			if err != nil {
+				return nil, fmt.Errorf("registry config: %w", err)
+			}
+			if opts.WatchConfig {
+				go rec.Watch(context.Background())
+			}
+		}
 		if err := registry.LoadEnabled(opts.Repo, opts.Registry); err != nil {
 			log.Printf("warn: load registry failed: %v", err)
 		}
@@ -56,14 +116,40 @@ func NewServer(opts Options) (*http.Server, error) {
 		_ = json.NewEncoder(w).Encode(map[string]any{"status": "ready"})
 	})
 
+	// Prometheus-style proxy connection metrics
+	mux.HandleFunc("/metrics", proxy.MetricsHandler())
+
 	// Public proxy surface
-	mux.HandleFunc("/api/", proxy.Dynamic(opts.Registry, opts.Repo))
+	mux.HandleFunc("/api/", proxy.Dynamic(opts.Registry, tlsm))
 
 	// Admin API with middleware chain
-	adm := admin.NewHandler(opts.Repo, opts.Registry)
+	adm := admin.NewHandler(opts.Repo, opts.Registry).WithUpstreamTLS(tlsm, opts.AllowInsecureTLS)
 	adminChain := util.Chain(util.CORSv2(), util.JWTAuthV2(opts.JWTSecret))
-	mux.Handle("/admin/services", adminChain(http.HandlerFunc(adm.Services)))
-	mux.Handle("/admin/services/", adminChain(http.HandlerFunc(adm.ServiceByID)))
+	if opts.RBAC {
+		adm.WithRBAC(&admin.RBAC{Admins: opts.Admins})
+		adminChain = util.Chain(util.CORSv2(), admin.Authenticate(opts.JWTSecret))
+	}
+	// Services, drafts and revisions directly under /admin/v1 are those of the default namespace.
+	var scoped http.Handler = http.HandlerFunc(adm.DefaultNamespace)
+	var namespaces, namespaceByName http.Handler = http.HandlerFunc(adm.Namespaces), http.HandlerFunc(adm.NamespaceByName)
+	if opts.FileOnly {
+		reason := "the registry is managed by the configuration files in " + opts.ConfigDir + "; change them instead"
+		scoped = admin.ReadOnly(scoped, reason)
+		namespaces, namespaceByName = admin.ReadOnly(namespaces, reason), admin.ReadOnly(namespaceByName, reason)
+	}
+	mux.Handle(admin.APIBase+"/services", adminChain(scoped))
+	mux.Handle(admin.APIBase+"/services/", adminChain(scoped))
+	// Drafts stage changes to services and routes; publishing one records a revision.
+	mux.Handle(admin.APIBase+"/drafts", adminChain(scoped))
+	mux.Handle(admin.APIBase+"/drafts/", adminChain(scoped))
+	mux.Handle(admin.APIBase+"/revisions", adminChain(scoped))
+	mux.Handle(admin.APIBase+"/revisions/", adminChain(scoped))
+	// Namespaces hold isolated route tables, each with its own services, drafts and revisions.
+	mux.Handle(admin.APIBase+"/namespaces", adminChain(namespaces))
+	mux.Handle(admin.APIBase+"/namespaces/", adminChain(namespaceByName))
+	// Unversioned paths predate /admin/v1 and remain as deprecated aliases.
+	mux.Handle("/admin/services", adminChain(admin.Legacy(scoped)))
+	mux.Handle("/admin/services/", adminChain(admin.Legacy(scoped)))
 
 	// Swagger UI generated by swaggo at /swagger/index.html
 	mux.Handle("/swagger/", httpSwagger.WrapHandler)
@@ -76,7 +162,29 @@ func NewServer(opts Options) (*http.Server, error) {
 		}
 		hc.Start(opts.Repo, strconv.Itoa(sec))
 	}
+	// Each replica compiles its own route tables; pick up changes made through the others.
+	if opts.Repo != nil && !opts.FileOnly && opts.ReloadInterval > 0 {
+		go reloadPeriodically(opts.Repo, opts.Registry, opts.ReloadInterval)
+	}
 
-	srv := &http.Server{Addr: ":" + opts.Port, Handler: mux}
+	// Native gRPC clients speak HTTP/2 with prior knowledge (h2c) when TLS isn't used.
+	srv := &http.Server{Addr: ":" + opts.Port, Handler: proxy.GRPC(opts.Registry, tlsm, mux)}
+	srv.Protocols = new(http.Protocols)
+	srv.Protocols.SetHTTP1(true)
+	srv.Protocols.SetHTTP2(true)
+	srv.Protocols.SetUnencryptedHTTP2(true)
 	return srv, nil
 }
+
+// reloadPeriodically reloads reg from repo every interval. Admin API writes update the local
+// registry at once; this bounds how long other replicas serve the previous routes. Cached TLS
+// settings and descriptors are not refreshed; use the refresh endpoint on each replica for those.
+func reloadPeriodically(repo registry.Repository, reg *registry.Registry, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for range t.C {
+		if err := registry.LoadEnabled(repo, reg); err != nil {
+			log.Printf("warn: periodic registry reload failed: %v", err)
+		}
+	}
+}
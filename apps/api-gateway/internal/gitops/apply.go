@@ -0,0 +1,31 @@
+package gitops
+
+import (
+	"context"
+	"fmt"
+
+	"ecomm/api-gateway/internal/registry"
+)
+
+// Apply makes the changes of plan, in order, in one transaction when repo is
+// registry.Transactional: either every change is stored or none is. Without transaction
+// support the changes made before a failure remain. Updates and deletions are conditional on
+// the version the plan was computed from, so a concurrent edit makes Apply fail with
+// registry.ErrVersionConflict rather than being overwritten unseen.
+func Apply(ctx context.Context, repo registry.Repository, plan *Plan) error {
+	if plan.Empty() {
+		return nil
+	}
+	run := func(tx registry.Repository) error {
+		for _, c := range plan.Changes {
+			if err := registry.ApplyChange(ctx, tx, c.Action, c.svc, c.route); err != nil {
+				return fmt.Errorf("%s: %w", c, err)
+			}
+		}
+		return nil
+	}
+	if t, ok := repo.(registry.Transactional); ok {
+		return t.InTx(ctx, run)
+	}
+	return run(repo)
+}
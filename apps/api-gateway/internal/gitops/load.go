@@ -0,0 +1,171 @@
+package gitops
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/invopop/yaml"
+
+	"ecomm/api-gateway/client"
+)
+
+// ErrNoConfig is returned by Load for a directory without configuration files. An empty
+// directory is more likely a missing volume than an intent to delete every service.
+var ErrNoConfig = errors.New("no configuration files found")
+
+// document is one YAML document of a configuration file. A Registry document, the output of
+// `gatewayctl export`, fills Services; a Service document fills the embedded spec.
+type document struct {
+	client.Document
+	client.ServiceSpec
+}
+
+// isConfigFile reports whether Load reads the file: YAML or JSON, not hidden (editors and
+// git keep temporary files there).
+func isConfigFile(name string) bool {
+	if strings.HasPrefix(name, ".") {
+		return false
+	}
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".yaml", ".yml", ".json":
+		return true
+	}
+	return false
+}
+
+// configFiles lists the configuration files under dir, recursively and in lexical order.
+func configFiles(dir string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != dir && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if isConfigFile(d.Name()) {
+			files = append(files, path)
+		}
+		return nil
+	})
+	sort.Strings(files)
+	return files, err
+}
+
+// Load reads every configuration file under dir and returns the declared services, normalized
+// and validated. Errors name the file and document they come from; a public prefix declared
+// twice, even in different files, is an error. A directory without any file yields ErrNoConfig.
+func Load(dir string) (*Config, error) {
+	files, err := configFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("%s: %w", dir, ErrNoConfig)
+	}
+	cfg := &Config{}
+	where := map[string]string{}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		specs, err := parseFile(file, data)
+		if err != nil {
+			return nil, err
+		}
+		for _, spec := range specs {
+			if prev, ok := where[spec.PublicPrefix]; ok {
+				return nil, fmt.Errorf("%s: public_prefix %s is already declared in %s", spec.file, spec.PublicPrefix, prev)
+			}
+			where[spec.PublicPrefix] = spec.file
+			cfg.Services = append(cfg.Services, spec)
+		}
+	}
+	sort.Slice(cfg.Services, func(i, j int) bool { return cfg.Services[i].PublicPrefix < cfg.Services[j].PublicPrefix })
+	return cfg, nil
+}
+
+// parseFile decodes the documents of one file. Unknown fields are rejected so that typos don't
+// silently drop configuration.
+func parseFile(file string, data []byte) ([]ServiceSpec, error) {
+	var specs []ServiceSpec
+	for i, chunk := range splitDocuments(data) {
+		if len(bytes.TrimSpace(chunk)) == 0 {
+			continue
+		}
+		at := file
+		if i > 0 {
+			at = fmt.Sprintf("%s (document %d)", file, i+1)
+		}
+		var doc document
+		strict := func(d *json.Decoder) *json.Decoder { d.DisallowUnknownFields(); return d }
+		if err := yaml.Unmarshal(chunk, &doc, strict); err != nil {
+			return nil, fmt.Errorf("%s: %w", at, err)
+		}
+		if doc.APIVersion != "" && doc.APIVersion != APIVersion {
+			return nil, fmt.Errorf("%s: unsupported apiVersion %q (want %s)", at, doc.APIVersion, APIVersion)
+		}
+		var found []client.ServiceSpec
+		switch doc.Kind {
+		case KindRegistry:
+			if !reflect.DeepEqual(doc.ServiceSpec, client.ServiceSpec{}) {
+				return nil, fmt.Errorf("%s: service fields belong under services: in a %s document", at, KindRegistry)
+			}
+			found = doc.Services
+		case KindService:
+			if doc.Services != nil {
+				return nil, fmt.Errorf("%s: services: is only allowed in a %s document", at, KindRegistry)
+			}
+			found = []client.ServiceSpec{doc.ServiceSpec}
+		case "":
+			return nil, fmt.Errorf("%s: kind required (%s or %s)", at, KindRegistry, KindService)
+		default:
+			return nil, fmt.Errorf("%s: unsupported kind %q (want %s or %s)", at, doc.Kind, KindRegistry, KindService)
+		}
+		for _, s := range found {
+			spec := ServiceSpec{ServiceSpec: s, file: file}
+			spec.normalize()
+			if err := spec.validate(); err != nil {
+				name := spec.PublicPrefix
+				if name == "" {
+					name = spec.Name
+				}
+				return nil, fmt.Errorf("%s: service %s: %w", at, name, err)
+			}
+			specs = append(specs, spec)
+		}
+	}
+	return specs, nil
+}
+
+// splitDocuments splits a multi-document YAML file at "---" separator lines.
+func splitDocuments(data []byte) [][]byte {
+	var docs [][]byte
+	var cur bytes.Buffer
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(make([]byte, 64*1024), len(data)+1)
+	for sc.Scan() {
+		line := sc.Bytes()
+		if bytes.Equal(bytes.TrimRight(line, " \t\r"), []byte("---")) {
+			docs = append(docs, append([]byte(nil), cur.Bytes()...))
+			cur.Reset()
+			continue
+		}
+		cur.Write(line)
+		cur.WriteByte('\n')
+	}
+	return append(docs, cur.Bytes())
+}
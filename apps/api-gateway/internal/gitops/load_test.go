@@ -0,0 +1,119 @@
+package gitops
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/invopop/yaml"
+
+	"ecomm/api-gateway/client"
+)
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// The output of `gatewayctl export` is a configuration directory as is.
+func TestLoadExportedDocument(t *testing.T) {
+	no := false
+	doc := client.Document{APIVersion: client.DocumentAPIVersion, Kind: client.DocumentKind, Services: []client.ServiceSpec{
+		client.SpecOf(&client.Service{Name: "web", PublicPrefix: "/api/web/", BaseURL: "http://web:8080", Protocol: "http", Enabled: true,
+			Metadata:  &client.MetadataPolicy{ForwardHeaders: []string{"X-Request-Id"}},
+			Streaming: &client.StreamingPolicy{WebSocket: &no, AllowedOrigins: []string{"https://shop.example.com"}},
+			Labels:    map[string]string{"team": "web"}}, nil),
+		client.SpecOf(&client.Service{Name: "catalog", PublicPrefix: "/api/catalog/", Protocol: "grpc-json", GRPCTarget: "catalog:9090"}, []client.Route{
+			{Method: "GET", Path: "/v1/items/{id}", GRPCMethod: "catalog.v1.Catalog/GetItem", QueryMapping: map[string]client.QueryMapEntry{"q": {Field: "filter.query"}}},
+			{Method: "post", Path: "/v1/items", GRPCMethod: "catalog.v1.Catalog/CreateItem", Body: "item", ResponseBody: "item"},
+		}),
+	}}
+	data, err := yaml.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Load(writeFiles(t, map[string]string{"registry.yaml": string(data)}))
+	if err != nil {
+		t.Fatalf("Load(exported document): %v", err)
+	}
+	if len(cfg.Services) != 2 {
+		t.Fatalf("Load: got %d services, want 2", len(cfg.Services))
+	}
+	catalog, web := cfg.Services[0], cfg.Services[1]
+	if catalog.PublicPrefix != "/api/catalog/" || web.PublicPrefix != "/api/web/" {
+		t.Fatalf("Load: got services %s and %s, want them sorted by prefix", catalog.PublicPrefix, web.PublicPrefix)
+	}
+	if catalog.Enabled == nil || *catalog.Enabled || len(catalog.Routes) != 2 || catalog.Routes[0].Method != "GET" || catalog.Routes[1].Method != "POST" {
+		t.Errorf("Load: got catalog %+v, want it disabled with both routes", catalog.ServiceSpec)
+	}
+	if !reflect.DeepEqual(web.Streaming, doc.Services[0].Streaming) || !reflect.DeepEqual(web.Metadata, doc.Services[0].Metadata) || web.Labels["team"] != "web" {
+		t.Errorf("Load: got web %+v, want the exported policies and labels", web.ServiceSpec)
+	}
+	if got := cfg.Files(); len(got) != 1 || filepath.Base(got[0]) != "registry.yaml" {
+		t.Errorf("Files: got %v", got)
+	}
+}
+
+func TestLoadServiceDocuments(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"a.yaml":        "apiVersion: gateway.ecomm/v1\nkind: Service\nname: a\npublic_prefix: /api/a\nbase_url: http://a/\n---\nkind: Service\nname: b\npublic_prefix: api/b/\nbase_url: http://b\n",
+		"nested/c.json": `{"kind":"Service","name":"c","public_prefix":"/api/c/","protocol":"GRPC","grpc_target":"c:9090"}`,
+		".hidden.yaml":  "not: [valid",
+		"README.md":     "ignored",
+	})
+	cfg, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	var got []string
+	for _, s := range cfg.Services {
+		got = append(got, s.PublicPrefix+" "+s.Protocol+" "+s.BaseURL)
+	}
+	want := []string{"/api/a/ http http://a", "/api/b/ http http://b", "/api/c/ grpc "}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Load: got %q, want %q", got, want)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{"unknown field", map[string]string{"a.yaml": "kind: Service\nname: a\npublic_prefix: /a/\nbase_url: http://a\nbase_ulr: x\n"}, "unknown field"},
+		{"no kind", map[string]string{"a.yaml": "name: a\npublic_prefix: /a/\n"}, "kind required"},
+		{"wrong apiVersion", map[string]string{"a.yaml": "apiVersion: v2\nkind: Service\n"}, "unsupported apiVersion"},
+		{"service fields in a registry", map[string]string{"a.yaml": "kind: Registry\nname: a\n"}, "belong under services"},
+		{"services in a service", map[string]string{"a.yaml": "kind: Service\nservices: []\n"}, "only allowed"},
+		{"duplicate prefix", map[string]string{
+			"a.yaml": "kind: Service\nname: a\npublic_prefix: /a/\nbase_url: http://a\n",
+			"b.yaml": "kind: Service\nname: b\npublic_prefix: /a\nbase_url: http://b\n",
+		}, "already declared"},
+		{"routes on http", map[string]string{"a.yaml": "kind: Service\nname: a\npublic_prefix: /a/\nbase_url: http://a\nroutes:\n- method: GET\n  path: /x\n  grpc_method: a.A/X\n"}, "only supported for protocol=grpc-json"},
+		{"route twice", map[string]string{"a.yaml": "kind: Service\nname: a\npublic_prefix: /a/\nprotocol: grpc-json\ngrpc_target: a:1\nroutes:\n- {method: GET, path: /x, grpc_method: a.A/X}\n- {method: get, path: /x, grpc_method: a.A/Y}\n"}, "declared twice"},
+		{"bad body", map[string]string{"a.yaml": "kind: Service\nname: a\npublic_prefix: /a/\nprotocol: grpc-json\ngrpc_target: a:1\nroutes:\n- {method: POST, path: /x, grpc_method: a.A/X, body: \"a-b\"}\n"}, "body must be"},
+	}
+	for _, tt := range tests {
+		_, err := Load(writeFiles(t, tt.files))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: got error %v, want one containing %q", tt.name, err, tt.want)
+		}
+	}
+	if _, err := Load(t.TempDir()); !errors.Is(err, ErrNoConfig) {
+		t.Errorf("Load(empty directory): got %v, want ErrNoConfig", err)
+	}
+}
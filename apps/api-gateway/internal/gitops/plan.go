@@ -0,0 +1,230 @@
+package gitops
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"ecomm/api-gateway/internal/registry"
+)
+
+// Plan actions.
+const (
+	ActionCreate = "create"
+	ActionUpdate = "update"
+	ActionDelete = "delete"
+)
+
+// Change is one step of a plan.
+type Change struct {
+	Action string `json:"action"`
+	// Kind is "service" or "route".
+	Kind string `json:"kind"`
+	// Service is the public prefix of the service the change belongs to.
+	Service string `json:"service"`
+	// Route is "METHOD path" for route changes.
+	Route string `json:"route,omitempty"`
+	// Fields lists the declared fields an update changes.
+	Fields []string `json:"fields,omitempty"`
+	// File declares the service; empty for deletions.
+	File string `json:"file,omitempty"`
+
+	svc      *registry.Service // state to write; the stored service for deletions
+	previous *registry.Service // stored state before an update
+	route    *registry.Route
+}
+
+func (c Change) String() string {
+	s := c.Action + " " + c.Kind + " " + c.Service
+	if c.Route != "" {
+		s += " " + c.Route
+	}
+	if len(c.Fields) > 0 {
+		s += " (" + strings.Join(c.Fields, ", ") + ")"
+	}
+	return s
+}
+
+// Plan is the ordered list of changes that makes a repository match a Config. Applying the
+// changes in order never trips uniqueness constraints: a service's route deletions come before
+// its route creations, and services are matched by public prefix.
+type Plan struct {
+	Changes []Change `json:"changes"`
+	// Unchanged counts declared services that already match.
+	Unchanged int `json:"unchanged"`
+}
+
+// Empty reports whether the plan has nothing to do.
+func (p *Plan) Empty() bool { return len(p.Changes) == 0 }
+
+// Summary counts the changes by action, e.g. "1 to create, 2 to update, 0 to delete".
+func (p *Plan) Summary() string {
+	n := map[string]int{}
+	for _, c := range p.Changes {
+		n[c.Action]++
+	}
+	return fmt.Sprintf("%d to create, %d to update, %d to delete", n[ActionCreate], n[ActionUpdate], n[ActionDelete])
+}
+
+// Options tune planning.
+type Options struct {
+	// Prune deletes stored services the configuration doesn't declare. Declared routes that
+	// were removed from a service are always deleted; routes of other sources are left alone.
+	Prune bool
+}
+
+// ComputePlan compares cfg with the services and routes stored in repo.
+func ComputePlan(ctx context.Context, repo registry.Repository, cfg *Config, opts Options) (*Plan, error) {
+	stored, err := repo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	byPrefix := map[string]*registry.Service{}
+	for _, s := range stored {
+		byPrefix[s.PublicPrefix] = s
+	}
+	plan := &Plan{Changes: []Change{}}
+	declared := map[string]bool{}
+	for i := range cfg.Services {
+		spec := &cfg.Services[i]
+		declared[spec.PublicPrefix] = true
+		cur := byPrefix[spec.PublicPrefix]
+		var routes []*registry.Route
+		if cur == nil {
+			now := time.Now()
+			svc := &registry.Service{ID: uuid.NewString(), CreatedAt: now, UpdatedAt: now, LastRefreshed: now}
+			spec.apply(svc)
+			plan.Changes = append(plan.Changes, Change{Action: ActionCreate, Kind: "service", Service: spec.PublicPrefix, File: spec.file, svc: svc})
+			plan.Changes = append(plan.Changes, routeChanges(spec, svc.ID, nil)...)
+			continue
+		}
+		next := *cur
+		spec.apply(&next)
+		fields := changedFields(cur, &next)
+		if len(fields) > 0 {
+			plan.Changes = append(plan.Changes, Change{Action: ActionUpdate, Kind: "service", Service: spec.PublicPrefix, Fields: fields, File: spec.file, svc: &next, previous: cur})
+		}
+		if cur.Protocol == "grpc-json" || len(spec.Routes) > 0 {
+			if routes, err = repo.ListRoutes(ctx, cur.ID); err != nil {
+				return nil, fmt.Errorf("routes of %s: %w", cur.PublicPrefix, err)
+			}
+		}
+		rc := routeChanges(spec, cur.ID, routes)
+		plan.Changes = append(plan.Changes, rc...)
+		if len(fields) == 0 && len(rc) == 0 {
+			plan.Unchanged++
+		}
+	}
+	if opts.Prune {
+		for _, s := range stored {
+			if !declared[s.PublicPrefix] {
+				plan.Changes = append(plan.Changes, Change{Action: ActionDelete, Kind: "service", Service: s.PublicPrefix, svc: s})
+			}
+		}
+	}
+	return plan, nil
+}
+
+// routeChanges reconciles the declared routes of a service with its stored ones. A stored
+// route with the method and path of a declared one is taken over whatever its source; stored
+// declared routes that are no longer declared are deleted.
+func routeChanges(spec *ServiceSpec, serviceID string, stored []*registry.Route) []Change {
+	byKey := map[string]*registry.Route{}
+	for _, rt := range stored {
+		byKey[strings.ToUpper(rt.Method)+" "+rt.Path] = rt
+	}
+	wanted := map[string]bool{}
+	var deletes, updates, creates []Change
+	for i := range spec.Routes {
+		rs := spec.Routes[i]
+		k := routeKey(rs)
+		wanted[k] = true
+		cur := byKey[k]
+		if cur == nil {
+			now := time.Now()
+			rt := &registry.Route{ID: uuid.NewString(), ServiceID: serviceID, CreatedAt: now, UpdatedAt: now}
+			applyRoute(rs, rt)
+			creates = append(creates, Change{Action: ActionCreate, Kind: "route", Service: spec.PublicPrefix, Route: k, File: spec.file, route: rt})
+			continue
+		}
+		next := *cur
+		applyRoute(rs, &next)
+		if fields := changedRouteFields(cur, &next); len(fields) > 0 {
+			updates = append(updates, Change{Action: ActionUpdate, Kind: "route", Service: spec.PublicPrefix, Route: k, Fields: fields, File: spec.file, route: &next})
+		}
+	}
+	for _, rt := range stored {
+		k := strings.ToUpper(rt.Method) + " " + rt.Path
+		if rt.Source == registry.RouteSourceDeclared && !wanted[k] {
+			deletes = append(deletes, Change{Action: ActionDelete, Kind: "route", Service: spec.PublicPrefix, Route: k, route: rt})
+		}
+	}
+	return append(append(deletes, updates...), creates...)
+}
+
+// changedFields names the declared fields that differ between a and b.
+func changedFields(a, b *registry.Service) []string {
+	var fields []string
+	diff := func(name string, x, y any) {
+		if !reflect.DeepEqual(x, y) {
+			fields = append(fields, name)
+		}
+	}
+	diff("name", a.Name, b.Name)
+	diff("description", a.Description, b.Description)
+	diff("base_url", a.BaseURL, b.BaseURL)
+	diff("swagger_url", a.SwaggerURL, b.SwaggerURL)
+	diff("protocol", a.Protocol, b.Protocol)
+	diff("grpc_target", a.GRPCTarget, b.GRPCTarget)
+	diff("descriptor_source", descriptorSource(a), descriptorSource(b))
+	diff("metadata", orEmpty(a.Metadata), orEmpty(b.Metadata))
+	diff("streaming", orEmpty(a.Streaming), orEmpty(b.Streaming))
+	diff("labels", nilIfEmpty(a.Labels), nilIfEmpty(b.Labels))
+	diff("enabled", a.Enabled, b.Enabled)
+	return fields
+}
+
+func changedRouteFields(a, b *registry.Route) []string {
+	var fields []string
+	diff := func(name string, x, y any) {
+		if !reflect.DeepEqual(x, y) {
+			fields = append(fields, name)
+		}
+	}
+	diff("grpc_method", a.GRPCMethod, b.GRPCMethod)
+	diff("query_mapping", nilIfEmpty(a.QueryMapping), nilIfEmpty(b.QueryMapping))
+	diff("body", a.Body, b.Body)
+	diff("response_body", a.ResponseBody, b.ResponseBody)
+	diff("strict_query", a.StrictQuery, b.StrictQuery)
+	diff("params_override_body", a.ParamsOverrideBody, b.ParamsOverrideBody)
+	diff("source", a.Source, b.Source)
+	return fields
+}
+
+func descriptorSource(s *registry.Service) string {
+	if s.DescriptorSource == "" {
+		return registry.DescriptorSourceReflection
+	}
+	return s.DescriptorSource
+}
+
+// orEmpty treats a nil policy like an empty one.
+func orEmpty[T any](p *T) T {
+	var zero T
+	if p == nil {
+		return zero
+	}
+	return *p
+}
+
+// nilIfEmpty treats an empty map like a nil one.
+func nilIfEmpty[M ~map[K]V, K comparable, V any](m M) M {
+	if len(m) == 0 {
+		return nil
+	}
+	return m
+}
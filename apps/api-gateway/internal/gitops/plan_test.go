@@ -0,0 +1,207 @@
+package gitops
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"ecomm/api-gateway/client"
+	"ecomm/api-gateway/internal/registry"
+)
+
+func loadConfig(t *testing.T, files map[string]string) *Config {
+	t.Helper()
+	cfg, err := Load(writeFiles(t, files))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	return cfg
+}
+
+func changeList(p *Plan) []string {
+	out := make([]string, 0, len(p.Changes))
+	for _, c := range p.Changes {
+		out = append(out, c.String())
+	}
+	return out
+}
+
+const catalogSpec = `kind: Service
+name: catalog
+public_prefix: /api/catalog/
+protocol: grpc-json
+grpc_target: catalog:9090
+routes:
+- {method: GET, path: "/v1/items/{id}", grpc_method: catalog.v1.Catalog/GetItem}
+- {method: POST, path: /v1/items, grpc_method: catalog.v1.Catalog/CreateItem, body: "*"}
+`
+
+func TestComputePlan(t *testing.T) {
+	ctx := context.Background()
+	repo := registry.NewMemoryRepository()
+	cfg := loadConfig(t, map[string]string{"catalog.yaml": catalogSpec})
+
+	plan, err := ComputePlan(ctx, repo, cfg, Options{})
+	if err != nil {
+		t.Fatalf("ComputePlan: %v", err)
+	}
+	want := []string{
+		"create service /api/catalog/",
+		"create route /api/catalog/ GET /v1/items/{id}",
+		"create route /api/catalog/ POST /v1/items",
+	}
+	if got := changeList(plan); !reflect.DeepEqual(got, want) {
+		t.Fatalf("ComputePlan(empty repository): got %q, want %q", got, want)
+	}
+	if err := Apply(ctx, repo, plan); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+
+	// applying a plan converges: planning again has nothing to do
+	if plan, err = ComputePlan(ctx, repo, cfg, Options{}); err != nil {
+		t.Fatalf("ComputePlan: %v", err)
+	}
+	if !plan.Empty() || plan.Unchanged != 1 {
+		t.Fatalf("ComputePlan(applied): got %q with %d unchanged, want nothing to do", changeList(plan), plan.Unchanged)
+	}
+
+	list, err := repo.List(ctx)
+	if err != nil || len(list) != 1 {
+		t.Fatalf("List: got %d services, %v", len(list), err)
+	}
+	svc := list[0]
+	routes, err := repo.ListRoutes(ctx, svc.ID)
+	if err != nil {
+		t.Fatalf("ListRoutes: %v", err)
+	}
+	for _, rt := range routes {
+		if rt.Source != registry.RouteSourceDeclared {
+			t.Errorf("route %s %s: got source %q, want declared", rt.Method, rt.Path, rt.Source)
+		}
+	}
+	// an operator's route is left alone; a declared one that's no longer declared goes
+	manual := &registry.Route{ID: uuid.NewString(), ServiceID: svc.ID, Method: "DELETE", Path: "/v1/items/{id}", GRPCMethod: "catalog.v1.Catalog/DeleteItem", Source: registry.RouteSourceManual}
+	if err := repo.CreateRoute(ctx, manual); err != nil {
+		t.Fatalf("CreateRoute: %v", err)
+	}
+	cfg = loadConfig(t, map[string]string{
+		"catalog.yaml": `kind: Service
+name: catalog
+description: products
+public_prefix: /api/catalog/
+protocol: grpc-json
+grpc_target: catalog:9090
+enabled: false
+routes:
+- {method: GET, path: "/v1/items/{id}", grpc_method: catalog.v1.Catalog/FetchItem}
+- {method: PUT, path: "/v1/items/{id}", grpc_method: catalog.v1.Catalog/UpdateItem, body: "*"}
+`,
+		"web.yaml": "kind: Service\nname: web\npublic_prefix: /api/web/\nbase_url: http://web\n",
+	})
+	if plan, err = ComputePlan(ctx, repo, cfg, Options{}); err != nil {
+		t.Fatalf("ComputePlan: %v", err)
+	}
+	want = []string{
+		"update service /api/catalog/ (description, enabled)",
+		"delete route /api/catalog/ POST /v1/items",
+		"update route /api/catalog/ GET /v1/items/{id} (grpc_method)",
+		"create route /api/catalog/ PUT /v1/items/{id}",
+		"create service /api/web/",
+	}
+	if got := changeList(plan); !reflect.DeepEqual(got, want) {
+		t.Fatalf("ComputePlan(changed files): got %q, want %q", got, want)
+	}
+	if err := Apply(ctx, repo, plan); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	if _, err := repo.GetRoute(ctx, svc.ID, manual.ID); err != nil {
+		t.Errorf("manual route: %v, want it kept", err)
+	}
+
+	// without prune undeclared services stay; with it they go
+	cfg = loadConfig(t, map[string]string{"web.yaml": "kind: Service\nname: web\npublic_prefix: /api/web/\nbase_url: http://web\n"})
+	if plan, err = ComputePlan(ctx, repo, cfg, Options{}); err != nil || !plan.Empty() {
+		t.Fatalf("ComputePlan(no prune): got %q, %v, want nothing to do", changeList(plan), err)
+	}
+	if plan, err = ComputePlan(ctx, repo, cfg, Options{Prune: true}); err != nil {
+		t.Fatalf("ComputePlan(prune): %v", err)
+	}
+	if got, want := changeList(plan), []string{"delete service /api/catalog/"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("ComputePlan(prune): got %q, want %q", got, want)
+	}
+	if err := Apply(ctx, repo, plan); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	if list, _ := repo.List(ctx); len(list) != 1 || list[0].PublicPrefix != "/api/web/" {
+		t.Errorf("after prune: got %d services, want only /api/web/", len(list))
+	}
+}
+
+// A deletion planned before a concurrent edit fails instead of discarding the edit.
+func TestApplyDeleteConflict(t *testing.T) {
+	ctx := context.Background()
+	repo := registry.NewMemoryRepository()
+	plan, err := ComputePlan(ctx, repo, loadConfig(t, map[string]string{"catalog.yaml": catalogSpec}), Options{})
+	if err != nil {
+		t.Fatalf("ComputePlan: %v", err)
+	}
+	if err := Apply(ctx, repo, plan); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	web := loadConfig(t, map[string]string{"web.yaml": "kind: Service\nname: web\npublic_prefix: /api/web/\nbase_url: http://web\n"})
+	if plan, err = ComputePlan(ctx, repo, web, Options{Prune: true}); err != nil {
+		t.Fatalf("ComputePlan(prune): %v", err)
+	}
+	list, _ := repo.List(ctx)
+	svc := list[0]
+	svc.Description = "edited meanwhile"
+	if err := repo.Update(ctx, svc); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if err := Apply(ctx, repo, plan); !errors.Is(err, registry.ErrVersionConflict) {
+		t.Fatalf("Apply: got %v, want ErrVersionConflict", err)
+	}
+	if _, err := repo.Get(ctx, svc.ID); err != nil {
+		t.Fatalf("Get: %v, want the edited service kept", err)
+	}
+}
+
+// A declared route takes over a stored route with the same method and path.
+func TestComputePlanTakesOverRoutes(t *testing.T) {
+	ctx := context.Background()
+	repo := registry.NewMemoryRepository()
+	svc := &registry.Service{ID: uuid.NewString(), Name: "catalog", PublicPrefix: "/api/catalog/", Protocol: "grpc-json", GRPCTarget: "catalog:9090", DescriptorSource: registry.DescriptorSourceReflection, Enabled: true}
+	if err := repo.Create(ctx, svc); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	rt := &registry.Route{ID: uuid.NewString(), ServiceID: svc.ID, Method: "GET", Path: "/v1/items/{id}", GRPCMethod: "catalog.v1.Catalog/GetItem", Source: registry.RouteSourceAnnotation}
+	if err := repo.CreateRoute(ctx, rt); err != nil {
+		t.Fatalf("CreateRoute: %v", err)
+	}
+	plan, err := ComputePlan(ctx, repo, loadConfig(t, map[string]string{"catalog.yaml": catalogSpec}), Options{})
+	if err != nil {
+		t.Fatalf("ComputePlan: %v", err)
+	}
+	want := []string{
+		"update route /api/catalog/ GET /v1/items/{id} (source)",
+		"create route /api/catalog/ POST /v1/items",
+	}
+	if got := changeList(plan); !reflect.DeepEqual(got, want) {
+		t.Errorf("ComputePlan: got %q, want %q", got, want)
+	}
+}
+
+func TestApplyRouteQueryMapping(t *testing.T) {
+	var rt registry.Route
+	applyRoute(client.RouteSpec{Method: "GET", Path: "/x", GRPCMethod: "a.A/X", QueryMapping: map[string]client.QueryMapEntry{"q": {Field: "query", Type: "string"}}}, &rt)
+	if want := (registry.RouteQueryMapping{"q": {Field: "query", Type: "string"}}); !reflect.DeepEqual(rt.QueryMapping, want) || rt.Source != registry.RouteSourceDeclared {
+		t.Errorf("applyRoute: got %+v, want the query mapping of the spec", rt)
+	}
+	applyRoute(client.RouteSpec{Method: "GET", Path: "/x", GRPCMethod: "a.A/X"}, &rt)
+	if rt.QueryMapping != nil {
+		t.Errorf("applyRoute(no mapping): got %v, want nil", rt.QueryMapping)
+	}
+}
@@ -0,0 +1,196 @@
+package gitops
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"ecomm/api-gateway/internal/grpcjson"
+	"ecomm/api-gateway/internal/registry"
+	"ecomm/api-gateway/internal/upstream"
+)
+
+// defaultInterval is how often Watch checks the directory for changes.
+const defaultInterval = 2 * time.Second
+
+// Reconciler keeps a repository, and the runtime registry loaded from it, in line with a
+// configuration directory.
+type Reconciler struct {
+	Dir  string
+	Repo registry.Repository
+	// Namespace is the namespace the files declare; "" means registry.DefaultNamespace. It is
+	// created if missing. Services of other namespaces are never touched, pruning included.
+	Namespace string
+	// Registry, when set, is reloaded after every applied plan.
+	Registry *registry.Registry
+	// TLS, when set, drops the cached TLS state of services whose upstream changed.
+	TLS     *upstream.TLSManager
+	Options Options
+	// DryRun only logs the plans.
+	DryRun bool
+	// Interval between checks of the directory in Watch; defaults to 2s.
+	Interval time.Duration
+
+	mu   sync.Mutex
+	last string // fingerprint of the files last reconciled
+}
+
+// Reconcile loads the directory, plans against the repository and applies the plan, then
+// refreshes runtime state. It returns the plan, applied unless DryRun is set.
+func (r *Reconciler) Reconcile(ctx context.Context) (*Plan, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.last = fingerprint(r.Dir)
+	cfg, err := Load(r.Dir)
+	if err != nil {
+		return nil, err
+	}
+	repo, err := r.scoped(ctx)
+	if err != nil {
+		return nil, err
+	}
+	plan, err := ComputePlan(ctx, repo, cfg, r.Options)
+	if err != nil {
+		return nil, err
+	}
+	if plan.Empty() || r.DryRun {
+		return plan, nil
+	}
+	if err := Apply(ctx, repo, plan); err != nil {
+		return plan, err
+	}
+	r.refresh(plan)
+	return plan, nil
+}
+
+// scoped returns the repository limited to the namespace of the files, creating the namespace
+// if needed.
+func (r *Reconciler) scoped(ctx context.Context) (registry.Repository, error) {
+	ns := r.Namespace
+	if ns == "" {
+		ns = registry.DefaultNamespace
+	}
+	if !registry.ValidNamespaceName(ns) {
+		return nil, fmt.Errorf("invalid namespace %q", ns)
+	}
+	if _, err := r.Repo.GetNamespace(ctx, ns); errors.Is(err, sql.ErrNoRows) && !r.DryRun {
+		if err := r.Repo.CreateNamespace(ctx, &registry.Namespace{Name: ns, Description: "declared by " + r.Dir}); err != nil && !errors.Is(err, registry.ErrDuplicate) {
+			return nil, fmt.Errorf("create namespace %s: %w", ns, err)
+		}
+		log.Printf("gitops: created namespace %s", ns)
+	} else if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	return registry.InNamespace(r.Repo, ns), nil
+}
+
+// refresh brings runtime state in line with an applied plan, as the Admin API does after
+// writes.
+func (r *Reconciler) refresh(plan *Plan) {
+	for _, c := range plan.Changes {
+		if c.Kind != "service" {
+			continue
+		}
+		prev, next := c.previous, c.svc
+		switch c.Action {
+		case ActionUpdate:
+			if prev.GRPCTarget != next.GRPCTarget || prev.DescriptorSource != next.DescriptorSource {
+				grpcjson.Invalidate(prev.GRPCTarget)
+				grpcjson.Invalidate(next.GRPCTarget)
+			}
+			if r.TLS != nil && (prev.BaseURL != next.BaseURL || prev.GRPCTarget != next.GRPCTarget) {
+				r.TLS.Invalidate(next)
+			}
+		case ActionDelete:
+			if r.TLS != nil {
+				r.TLS.Invalidate(next)
+			}
+		}
+	}
+	if r.Registry != nil {
+		if err := registry.LoadEnabled(r.Repo, r.Registry); err != nil {
+			log.Printf("warn: gitops: load registry failed: %v", err)
+		}
+	}
+}
+
+// Watch reconciles whenever the files of the directory change, until ctx is done. A change is
+// acted on once the files have stayed the same for one interval, so editors and git checkouts
+// can finish writing. Failures are logged; the previous state stays in place and the files are
+// retried when they change again.
+func (r *Reconciler) Watch(ctx context.Context) {
+	interval := r.Interval
+	if interval <= 0 {
+		interval = defaultInterval
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	pending := ""
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		fp := fingerprint(r.Dir)
+		r.mu.Lock()
+		last := r.last
+		r.mu.Unlock()
+		if fp == last {
+			pending = ""
+			continue
+		}
+		if fp != pending {
+			pending = fp
+			continue
+		}
+		pending = ""
+		r.Log(r.Reconcile(ctx))
+	}
+}
+
+// Log reports the outcome of Reconcile.
+func (r *Reconciler) Log(plan *Plan, err error) {
+	verb := "applied"
+	if r.DryRun {
+		verb = "planned"
+	}
+	switch {
+	case err != nil && plan != nil:
+		log.Printf("gitops: %s: apply failed: %v", r.Dir, err)
+	case err != nil:
+		log.Printf("gitops: %s: %v", r.Dir, err)
+	case plan.Empty():
+		log.Printf("gitops: %s: registry is up to date", r.Dir)
+	default:
+		for _, c := range plan.Changes {
+			log.Printf("gitops: %s %s", verb, c)
+		}
+		log.Printf("gitops: %s: %s %s", r.Dir, verb, plan.Summary())
+	}
+}
+
+// fingerprint identifies the current contents of dir by the names, sizes and modification
+// times of its configuration files.
+func fingerprint(dir string) string {
+	files, err := configFiles(dir)
+	if err != nil {
+		return "error: " + err.Error()
+	}
+	h := sha256.New()
+	for _, f := range files {
+		fi, err := os.Stat(f)
+		if err != nil {
+			continue
+		}
+		fmt.Fprintf(h, "%s\x00%d\x00%d\n", f, fi.Size(), fi.ModTime().UnixNano())
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,217 @@
+// Package gitops manages the service registry declaratively: services and routes are defined
+// in a directory of YAML (or JSON) files, compared with the contents of a registry.Repository
+// to produce a plan of creates, updates and deletes, and the plan is applied in one
+// transaction. A Reconciler repeats this whenever the files change, either against Postgres or,
+// in file-only mode, against an in-memory repository.
+//
+// Files use the format gatewayctl exports: a "Registry" document lists services, a "Service"
+// document holds one service with the service fields at the top level. A file may contain
+// several documents separated by "---".
+//
+//	apiVersion: gateway.ecomm/v1
+//	kind: Service
+//	name: catalog
+//	public_prefix: /api/catalog/
+//	protocol: grpc-json
+//	grpc_target: catalog:9090
+//	routes:
+//	  - method: GET
+//	    path: /products/{id}
+//	    grpc_method: catalog.v1.CatalogService/GetProduct
+package gitops
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"ecomm/api-gateway/client"
+	"ecomm/api-gateway/internal/registry"
+	"ecomm/api-gateway/internal/routing"
+)
+
+// Document identification, as gatewayctl exports it.
+const (
+	APIVersion   = client.DocumentAPIVersion
+	KindRegistry = client.DocumentKind
+	KindService  = "Service"
+)
+
+// ServiceSpec is the declared configuration of one service: the client.ServiceSpec that
+// `gatewayctl export` writes, so an exported document is a valid configuration file. Services
+// are identified by PublicPrefix; server-managed state (IDs, health, fetched swagger, versions)
+// is not declared.
+type ServiceSpec struct {
+	client.ServiceSpec
+
+	// file is where the spec was declared, for messages.
+	file string
+}
+
+// routeKey identifies a declared route by method and path.
+func routeKey(r client.RouteSpec) string { return strings.ToUpper(r.Method) + " " + r.Path }
+
+// Config is the declared registry: every service of a directory, sorted by public prefix.
+type Config struct {
+	Services []ServiceSpec
+}
+
+// Files returns the files the services were loaded from.
+func (c *Config) Files() []string {
+	seen := map[string]bool{}
+	var files []string
+	for _, s := range c.Services {
+		if s.file != "" && !seen[s.file] {
+			seen[s.file] = true
+			files = append(files, s.file)
+		}
+	}
+	sort.Strings(files)
+	return files
+}
+
+// normalize applies the defaults and canonical forms the Admin API applies on write, so that
+// a spec compares equal to the service it produced.
+func (s *ServiceSpec) normalize() {
+	s.PublicPrefix = strings.TrimSpace(s.PublicPrefix)
+	if s.PublicPrefix != "" {
+		if !strings.HasPrefix(s.PublicPrefix, "/") {
+			s.PublicPrefix = "/" + s.PublicPrefix
+		}
+		if !strings.HasSuffix(s.PublicPrefix, "/") {
+			s.PublicPrefix += "/"
+		}
+	}
+	s.Protocol = strings.ToLower(strings.TrimSpace(s.Protocol))
+	if s.Protocol == "" {
+		s.Protocol = "http"
+	}
+	s.BaseURL = strings.TrimRight(strings.TrimSpace(s.BaseURL), "/")
+	s.GRPCTarget = strings.TrimSpace(s.GRPCTarget)
+	// stored services always carry a descriptor source, whatever their protocol
+	if s.DescriptorSource == "" {
+		s.DescriptorSource = registry.DescriptorSourceReflection
+	}
+	for i := range s.Routes {
+		s.Routes[i].Method = strings.ToUpper(strings.TrimSpace(s.Routes[i].Method))
+		s.Routes[i].Path = strings.TrimSpace(s.Routes[i].Path)
+	}
+	sort.SliceStable(s.Routes, func(i, j int) bool { return routeKey(s.Routes[i]) < routeKey(s.Routes[j]) })
+}
+
+var fieldPath = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)*$`)
+
+// validate checks a normalized spec with the rules of the Admin API. swagger_url is optional
+// here: declared http services are routed without their OpenAPI document.
+func (s *ServiceSpec) validate() error {
+	if s.PublicPrefix == "" {
+		return fmt.Errorf("public_prefix required")
+	}
+	if s.Name == "" {
+		return fmt.Errorf("name required")
+	}
+	switch s.Protocol {
+	case "http":
+		if s.BaseURL == "" {
+			return fmt.Errorf("base_url required for protocol=http")
+		}
+		if len(s.Routes) > 0 {
+			return fmt.Errorf("routes are only supported for protocol=grpc-json")
+		}
+	case "grpc-json", "grpc", "grpc-web":
+		if s.GRPCTarget == "" {
+			return fmt.Errorf("grpc_target required for protocol=%s", s.Protocol)
+		}
+		switch s.DescriptorSource {
+		case registry.DescriptorSourceReflection, registry.DescriptorSourceSet:
+		default:
+			return fmt.Errorf("descriptor_source must be reflection or descriptor_set")
+		}
+		if s.Protocol != "grpc-json" && len(s.Routes) > 0 {
+			return fmt.Errorf("routes are only supported for protocol=grpc-json")
+		}
+		if r, ok := registry.ReservedConflict(s.PublicPrefix); ok && s.Protocol != "grpc-json" {
+			return fmt.Errorf("public_prefix %s would intercept the gateway's own %s endpoints", s.PublicPrefix, r)
+		}
+	default:
+		return fmt.Errorf("unsupported protocol %q", s.Protocol)
+	}
+	if sp := s.Streaming; sp != nil && (sp.IdleTimeoutSeconds < 0 || sp.MaxDurationSeconds < 0 || sp.MaxConnections < 0) {
+		return fmt.Errorf("streaming limits and timeouts must not be negative")
+	}
+	type declared struct {
+		path string
+		t    *routing.Template
+	}
+	templates := map[string][]declared{}
+	seen := map[string]bool{}
+	for _, rt := range s.Routes {
+		if err := validateRoute(rt); err != nil {
+			return fmt.Errorf("route %s: %w", routeKey(rt), err)
+		}
+		if seen[routeKey(rt)] {
+			return fmt.Errorf("route %s is declared twice", routeKey(rt))
+		}
+		seen[routeKey(rt)] = true
+		t, _ := routing.Parse(rt.Path)
+		for _, o := range templates[rt.Method] {
+			if reason, ok := routing.Conflict(t, o.t); ok {
+				return fmt.Errorf("route %s conflicts with %s %s: %s", routeKey(rt), rt.Method, o.path, reason)
+			}
+		}
+		templates[rt.Method] = append(templates[rt.Method], declared{rt.Path, t})
+	}
+	return nil
+}
+
+func validateRoute(r client.RouteSpec) error {
+	switch {
+	case r.Method == "":
+		return fmt.Errorf("method required")
+	case r.GRPCMethod == "":
+		return fmt.Errorf("grpc_method required")
+	case r.Body != "" && r.Body != "*" && r.Body != registry.RouteBodyNone && !fieldPath.MatchString(r.Body):
+		return fmt.Errorf("body must be \"*\", %q or a field path, got %q", registry.RouteBodyNone, r.Body)
+	case r.ResponseBody != "" && r.ResponseBody != "*" && !fieldPath.MatchString(r.ResponseBody):
+		return fmt.Errorf("response_body must be a field path, got %q", r.ResponseBody)
+	}
+	_, err := routing.Parse(r.Path)
+	return err
+}
+
+// apply copies the declared fields onto svc, leaving identity and server-managed state alone.
+func (s *ServiceSpec) apply(svc *registry.Service) {
+	svc.Name = s.Name
+	svc.Description = s.Description
+	svc.PublicPrefix = s.PublicPrefix
+	svc.BaseURL = s.BaseURL
+	svc.SwaggerURL = s.SwaggerURL
+	svc.Protocol = s.Protocol
+	svc.GRPCTarget = s.GRPCTarget
+	svc.DescriptorSource = s.DescriptorSource
+	// the client's policies mirror the registry's field for field
+	svc.Metadata = (*registry.MetadataPolicy)(s.Metadata)
+	svc.Streaming = (*registry.StreamingPolicy)(s.Streaming)
+	svc.Labels = s.Labels
+	svc.Enabled = s.Enabled == nil || *s.Enabled
+}
+
+// applyRoute copies the declared fields of r onto rt and marks it as declared.
+func applyRoute(r client.RouteSpec, rt *registry.Route) {
+	rt.Method = r.Method
+	rt.Path = r.Path
+	rt.GRPCMethod = r.GRPCMethod
+	rt.QueryMapping = nil
+	for param, e := range r.QueryMapping {
+		if rt.QueryMapping == nil {
+			rt.QueryMapping = registry.RouteQueryMapping{}
+		}
+		rt.QueryMapping[param] = registry.RouteQueryMapEntry(e)
+	}
+	rt.Body = r.Body
+	rt.ResponseBody = r.ResponseBody
+	rt.StrictQuery = r.StrictQuery
+	rt.ParamsOverrideBody = r.ParamsOverrideBody
+	rt.Source = registry.RouteSourceDeclared
+}
@@ -0,0 +1,187 @@
+package grpcjson
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/grpcreflect"
+	"google.golang.org/grpc"
+)
+
+// Schema describes where descriptors for an upstream target come from.
+type Schema struct {
+	// Files is the operator-uploaded FileDescriptorSet, if any.
+	Files []*desc.FileDescriptor
+	// DisableReflection resolves exclusively from Files. Otherwise reflection is tried
+	// first and Files act as a fallback when the upstream doesn't serve reflection.
+	DisableReflection bool
+}
+
+// SchemaLoader returns the Schema for target. It is consulted once per target until the
+// target is invalidated.
+type SchemaLoader func(ctx context.Context, target string) (*Schema, error)
+
+type targetEntry struct {
+	schema   *Schema
+	services map[string]*desc.ServiceDescriptor
+}
+
+// DescriptorCache memoizes service descriptors resolved via server reflection or an uploaded
+// FileDescriptorSet, keyed by upstream target and fully-qualified service name. Entries live
+// until invalidated (route discovery, descriptor upload, or an admin refresh of the owning service).
+type DescriptorCache struct {
+	// Loader supplies uploaded descriptor sets. When nil, only reflection is used.
+	Loader SchemaLoader
+
+	mu       sync.RWMutex
+	byTarget map[string]*targetEntry
+}
+
+func NewDescriptorCache() *DescriptorCache {
+	return &DescriptorCache{byTarget: map[string]*targetEntry{}}
+}
+
+// ResolveMethod returns the method descriptor for service/method on target, asking the
+// upstream's reflection service over conn only on a cache miss.
+func (c *DescriptorCache) ResolveMethod(ctx context.Context, target string, conn grpc.ClientConnInterface, service, method string) (*desc.MethodDescriptor, error) {
+	sd, err := c.ResolveService(ctx, target, conn, service)
+	if err != nil {
+		return nil, err
+	}
+	md := sd.FindMethodByName(method)
+	if md == nil {
+		return nil, fmt.Errorf("method %s not found on %s", method, service)
+	}
+	return md, nil
+}
+
+// ResolveService returns the (possibly cached) descriptor for service on target.
+func (c *DescriptorCache) ResolveService(ctx context.Context, target string, conn grpc.ClientConnInterface, service string) (*desc.ServiceDescriptor, error) {
+	c.mu.RLock()
+	var sd *desc.ServiceDescriptor
+	if e := c.byTarget[target]; e != nil {
+		sd = e.services[service]
+	}
+	c.mu.RUnlock()
+	if sd != nil {
+		return sd, nil
+	}
+	schema, err := c.schema(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	if !schema.DisableReflection {
+		sd, err = reflectService(ctx, conn, service)
+	}
+	if sd == nil {
+		if fsd := findService(schema.Files, service); fsd != nil {
+			sd, err = fsd, nil
+		} else if err == nil {
+			err = fmt.Errorf("service %s not found in uploaded descriptor set", service)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	if e := c.byTarget[target]; e != nil {
+		e.services[service] = sd
+	}
+	c.mu.Unlock()
+	return sd, nil
+}
+
+// ListServices returns every service exposed by target (excluding the reflection services),
+// sorted by name. Reflection is preferred; the uploaded set is used when it is disabled or fails.
+func (c *DescriptorCache) ListServices(ctx context.Context, target string, conn grpc.ClientConnInterface) ([]*desc.ServiceDescriptor, error) {
+	schema, err := c.schema(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	if !schema.DisableReflection {
+		rc := grpcreflect.NewClientAuto(ctx, conn)
+		names, err = rc.ListServices()
+		rc.Reset()
+	}
+	if names == nil {
+		if len(schema.Files) == 0 {
+			if err == nil {
+				err = fmt.Errorf("reflection disabled and no descriptor set uploaded for %s", target)
+			}
+			return nil, err
+		}
+		names = ServiceNames(schema.Files)
+	}
+	sort.Strings(names)
+	var out []*desc.ServiceDescriptor
+	for _, name := range names {
+		if isReflectionService(name) {
+			continue
+		}
+		sd, err := c.ResolveService(ctx, target, conn, name)
+		if err != nil {
+			continue
+		}
+		out = append(out, sd)
+	}
+	return out, nil
+}
+
+// Invalidate drops every cached descriptor and the loaded schema for target.
+func (c *DescriptorCache) Invalidate(target string) {
+	c.mu.Lock()
+	delete(c.byTarget, target)
+	c.mu.Unlock()
+}
+
+func (c *DescriptorCache) schema(ctx context.Context, target string) (*Schema, error) {
+	c.mu.RLock()
+	e := c.byTarget[target]
+	c.mu.RUnlock()
+	if e != nil {
+		return e.schema, nil
+	}
+	schema := &Schema{}
+	if c.Loader != nil {
+		loaded, err := c.Loader(ctx, target)
+		if err != nil {
+			return nil, err
+		}
+		if loaded != nil {
+			schema = loaded
+		}
+	}
+	c.mu.Lock()
+	if cur := c.byTarget[target]; cur != nil {
+		schema = cur.schema
+	} else {
+		c.byTarget[target] = &targetEntry{schema: schema, services: map[string]*desc.ServiceDescriptor{}}
+	}
+	c.mu.Unlock()
+	return schema, nil
+}
+
+// reflectService resolves service via grpc.reflection.v1, falling back to v1alpha.
+func reflectService(ctx context.Context, conn grpc.ClientConnInterface, service string) (*desc.ServiceDescriptor, error) {
+	rc := grpcreflect.NewClientAuto(ctx, conn)
+	defer rc.Reset()
+	return rc.ResolveService(service)
+}
+
+func findService(files []*desc.FileDescriptor, service string) *desc.ServiceDescriptor {
+	for _, fd := range files {
+		if sd := fd.FindService(service); sd != nil {
+			return sd
+		}
+	}
+	return nil
+}
+
+func isReflectionService(name string) bool {
+	return strings.HasPrefix(name, "grpc.reflection.")
+}
@@ -0,0 +1,192 @@
+package grpcjson
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/jhump/protoreflect/desc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	v1alphareflectiongrpc "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// echoFile is test/v1/echo.proto: service test.v1.Echo { rpc Ping(PingRequest) returns (PingResponse) }.
+func echoFile() *descriptorpb.FileDescriptorProto {
+	text := &descriptorpb.FieldDescriptorProto{
+		Name:   proto.String("text"),
+		Number: proto.Int32(1),
+		Type:   descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(),
+		Label:  descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+	}
+	return &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("test/v1/echo.proto"),
+		Package: proto.String("test.v1"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{
+			{Name: proto.String("PingRequest"), Field: []*descriptorpb.FieldDescriptorProto{text}},
+			{Name: proto.String("PingResponse"), Field: []*descriptorpb.FieldDescriptorProto{text}},
+		},
+		Service: []*descriptorpb.ServiceDescriptorProto{{
+			Name: proto.String("Echo"),
+			Method: []*descriptorpb.MethodDescriptorProto{{
+				Name:       proto.String("Ping"),
+				InputType:  proto.String(".test.v1.PingRequest"),
+				OutputType: proto.String(".test.v1.PingResponse"),
+			}},
+		}},
+	}
+}
+
+func echoFiles(t *testing.T) []*desc.FileDescriptor {
+	t.Helper()
+	raw, err := proto.Marshal(&descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{echoFile()}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := ParseDescriptorSet(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func TestParseDescriptorSet(t *testing.T) {
+	files := echoFiles(t)
+	if got := ServiceNames(files); len(got) != 1 || got[0] != "test.v1.Echo" {
+		t.Fatalf("ServiceNames = %v, want [test.v1.Echo]", got)
+	}
+
+	importing := echoFile()
+	importing.Dependency = []string{"google/protobuf/empty.proto"}
+	tests := map[string][]byte{
+		"garbage":        []byte("not a descriptor set"),
+		"empty set":      nil,
+		"missing import": mustMarshal(t, &descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{importing}}),
+	}
+	for name, raw := range tests {
+		if _, err := ParseDescriptorSet(raw); err == nil {
+			t.Errorf("%s: parsed without error", name)
+		}
+	}
+}
+
+func mustMarshal(t *testing.T, m proto.Message) []byte {
+	t.Helper()
+	raw, err := proto.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+// dialTest returns a client for target that is closed with the test.
+func dialTest(t *testing.T, target string) *grpc.ClientConn {
+	t.Helper()
+	conn, err := grpc.NewClient(target, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestDescriptorCacheReflectionFirst(t *testing.T) {
+	target := startHealthServer(t)
+	conn := dialTest(t, target)
+	var loads int
+	c := NewDescriptorCache()
+	c.Loader = func(ctx context.Context, tg string) (*Schema, error) {
+		loads++
+		return &Schema{Files: echoFiles(t)}, nil
+	}
+	ctx := context.Background()
+
+	// Served by reflection.
+	if _, err := c.ResolveMethod(ctx, target, conn, "grpc.health.v1.Health", "Check"); err != nil {
+		t.Fatalf("reflection: %v", err)
+	}
+	// Not served by the upstream: the uploaded set fills in.
+	md, err := c.ResolveMethod(ctx, target, conn, "test.v1.Echo", "Ping")
+	if err != nil {
+		t.Fatalf("fallback: %v", err)
+	}
+	if md.GetInputType().GetFullyQualifiedName() != "test.v1.PingRequest" {
+		t.Fatalf("input type %s", md.GetInputType().GetFullyQualifiedName())
+	}
+	if _, err := c.ResolveMethod(ctx, target, conn, "test.v1.Echo", "Pong"); err == nil {
+		t.Fatal("resolved a method the service doesn't define")
+	}
+	services, err := c.ListServices(ctx, target, conn)
+	if err != nil || len(services) != 1 || services[0].GetFullyQualifiedName() != "grpc.health.v1.Health" {
+		t.Fatalf("ListServices = %v, %v; want the reflected Health service only", services, err)
+	}
+	if loads != 1 {
+		t.Fatalf("schema loaded %d times, want once per target", loads)
+	}
+	c.Invalidate(target)
+	if _, err := c.ResolveMethod(ctx, target, conn, "test.v1.Echo", "Ping"); err != nil || loads != 2 {
+		t.Fatalf("after Invalidate: err %v, loads %d; want the schema reloaded", err, loads)
+	}
+}
+
+func TestDescriptorCacheReflectionDisabled(t *testing.T) {
+	// Nothing listens here: with reflection disabled the upstream is never asked.
+	target := "127.0.0.1:1"
+	conn := dialTest(t, target)
+	c := NewDescriptorCache()
+	c.Loader = func(ctx context.Context, tg string) (*Schema, error) {
+		return &Schema{Files: echoFiles(t), DisableReflection: true}, nil
+	}
+	ctx := context.Background()
+	if _, err := c.ResolveMethod(ctx, target, conn, "test.v1.Echo", "Ping"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.ResolveService(ctx, target, conn, "grpc.health.v1.Health"); err == nil || !strings.Contains(err.Error(), "not found in uploaded descriptor set") {
+		t.Fatalf("err = %v, want not found in the uploaded set", err)
+	}
+	services, err := c.ListServices(ctx, target, conn)
+	if err != nil || len(services) != 1 || services[0].GetFullyQualifiedName() != "test.v1.Echo" {
+		t.Fatalf("ListServices = %v, %v; want the uploaded Echo service", services, err)
+	}
+
+	empty := NewDescriptorCache()
+	empty.Loader = func(context.Context, string) (*Schema, error) { return &Schema{DisableReflection: true}, nil }
+	if _, err := empty.ListServices(ctx, target, conn); err == nil {
+		t.Fatal("listed services with reflection disabled and no set uploaded")
+	}
+}
+
+func TestReflectionVersions(t *testing.T) {
+	register := map[string]func(*grpc.Server){
+		"v1 only": func(s *grpc.Server) { reflection.RegisterV1(s) },
+		"v1alpha only": func(s *grpc.Server) {
+			v1alphareflectiongrpc.RegisterServerReflectionServer(s, reflection.NewServer(reflection.ServerOptions{Services: s}))
+		},
+	}
+	for name, reg := range register {
+		t.Run(name, func(t *testing.T) {
+			lis, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			srv := grpc.NewServer()
+			healthpb.RegisterHealthServer(srv, health.NewServer())
+			reg(srv)
+			go func() { _ = srv.Serve(lis) }()
+			t.Cleanup(srv.Stop)
+			target := lis.Addr().String()
+
+			c := NewDescriptorCache()
+			if _, err := c.ResolveMethod(context.Background(), target, dialTest(t, target), "grpc.health.v1.Health", "Watch"); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
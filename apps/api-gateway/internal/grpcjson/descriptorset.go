@@ -0,0 +1,50 @@
+package grpcjson
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/jhump/protoreflect/desc"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// ParseDescriptorSet decodes a binary FileDescriptorSet (as written by
+// `protoc --include_imports --descriptor_set_out`) and links its files. Every import must be
+// present in the set.
+func ParseDescriptorSet(raw []byte) ([]*desc.FileDescriptor, error) {
+	var set descriptorpb.FileDescriptorSet
+	if err := proto.Unmarshal(raw, &set); err != nil {
+		return nil, fmt.Errorf("decode FileDescriptorSet: %w", err)
+	}
+	return FilesFromSet(&set)
+}
+
+// FilesFromSet links the files of set, returning them sorted by name.
+func FilesFromSet(set *descriptorpb.FileDescriptorSet) ([]*desc.FileDescriptor, error) {
+	if len(set.GetFile()) == 0 {
+		return nil, fmt.Errorf("descriptor set contains no files")
+	}
+	byName, err := desc.CreateFileDescriptorsFromSet(set)
+	if err != nil {
+		return nil, fmt.Errorf("link descriptor set (was it built with --include_imports?): %w", err)
+	}
+	files := make([]*desc.FileDescriptor, 0, len(byName))
+	for _, fd := range byName {
+		files = append(files, fd)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].GetName() < files[j].GetName() })
+	return files, nil
+}
+
+// ServiceNames lists the fully-qualified names of the services defined in files.
+func ServiceNames(files []*desc.FileDescriptor) []string {
+	var out []string
+	for _, fd := range files {
+		for _, sd := range fd.GetServices() {
+			out = append(out, sd.GetFullyQualifiedName())
+		}
+	}
+	sort.Strings(out)
+	return out
+}
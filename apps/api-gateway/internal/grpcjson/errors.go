@@ -0,0 +1,91 @@
+package grpcjson
+
+import (
+	"encoding/json"
+	"net/http"
+
+	// Registers google.rpc error detail types (BadRequest, RetryInfo, ...) so they decode from Any.
+	_ "google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// HTTPStatusFromCode maps a gRPC status code to the HTTP status a REST client expects,
+// following the grpc-gateway conventions.
+func HTTPStatusFromCode(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.Canceled:
+		return 499 // Client Closed Request
+	case codes.Unknown:
+		return http.StatusInternalServerError
+	case codes.InvalidArgument:
+		return http.StatusBadRequest
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists:
+		return http.StatusConflict
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.FailedPrecondition:
+		// Not 412: that status is reserved for HTTP conditional request failures.
+		return http.StatusBadRequest
+	case codes.Aborted:
+		return http.StatusConflict
+	case codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Internal:
+		return http.StatusInternalServerError
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	case codes.DataLoss:
+		return http.StatusInternalServerError
+	}
+	return http.StatusInternalServerError
+}
+
+// WriteError writes st as a JSON google.rpc.Status envelope:
+//
+//	{"code": 5, "message": "order not found", "details": [{"@type": "type.googleapis.com/google.rpc.ResourceInfo", ...}]}
+//
+// with the HTTP status derived from the gRPC code. The same envelope is used for errors
+// raised by the gateway itself so clients only have to handle one shape.
+func WriteError(w http.ResponseWriter, st *status.Status) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(HTTPStatusFromCode(st.Code()))
+	_, _ = w.Write(errorBody(st))
+}
+
+// Errorf is a shorthand for WriteError(w, status.Newf(code, format, args...)).
+func Errorf(w http.ResponseWriter, code codes.Code, format string, args ...any) {
+	WriteError(w, status.Newf(code, format, args...))
+}
+
+// errorBody renders st as protojson. Details whose types the gateway does not know cannot be
+// expanded, so on failure they are reported by type URL only rather than dropped silently.
+func errorBody(st *status.Status) []byte {
+	if bs, err := protojson.Marshal(st.Proto()); err == nil {
+		return bs
+	}
+	details := make([]map[string]string, 0, len(st.Proto().GetDetails()))
+	for _, d := range st.Proto().GetDetails() {
+		details = append(details, map[string]string{"@type": d.GetTypeUrl()})
+	}
+	bs, _ := json.Marshal(map[string]any{
+		"code":    int32(st.Code()),
+		"message": st.Message(),
+		"details": details,
+	})
+	return bs
+}
@@ -0,0 +1,101 @@
+package grpcjson
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/jhump/protoreflect/desc"
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// HTTPBinding is one REST mapping declared by a method's google.api.http option
+// (the primary rule or one of its additional_bindings).
+type HTTPBinding struct {
+	Method       string `json:"method"`
+	Path         string `json:"path"`
+	Body         string `json:"body,omitempty"`
+	ResponseBody string `json:"response_body,omitempty"`
+}
+
+// HTTPBindings returns the google.api.http bindings declared on md, or nil when the
+// method carries no annotation.
+func HTTPBindings(md *desc.MethodDescriptor) []HTTPBinding {
+	rule := httpRule(md)
+	if rule == nil {
+		return nil
+	}
+	var out []HTTPBinding
+	if b, ok := bindingFromRule(rule); ok {
+		out = append(out, b)
+	}
+	// additional_bindings may not nest further, so one level is enough.
+	for _, extra := range rule.GetAdditionalBindings() {
+		if b, ok := bindingFromRule(extra); ok {
+			out = append(out, b)
+		}
+	}
+	return out
+}
+
+// httpRule extracts the google.api.http extension from md's options. Descriptors obtained
+// via reflection may carry the extension as unknown fields, so options are re-parsed with
+// the annotations package registered.
+func httpRule(md *desc.MethodDescriptor) *annotations.HttpRule {
+	opts := md.GetMethodOptions()
+	if opts == nil {
+		return nil
+	}
+	raw, err := proto.Marshal(opts)
+	if err != nil {
+		return nil
+	}
+	parsed := &descriptorpb.MethodOptions{}
+	if err := proto.Unmarshal(raw, parsed); err != nil {
+		return nil
+	}
+	if !proto.HasExtension(parsed, annotations.E_Http) {
+		return nil
+	}
+	rule, _ := proto.GetExtension(parsed, annotations.E_Http).(*annotations.HttpRule)
+	return rule
+}
+
+func bindingFromRule(rule *annotations.HttpRule) (HTTPBinding, bool) {
+	var method, path string
+	switch p := rule.GetPattern().(type) {
+	case *annotations.HttpRule_Get:
+		method, path = "GET", p.Get
+	case *annotations.HttpRule_Put:
+		method, path = "PUT", p.Put
+	case *annotations.HttpRule_Post:
+		method, path = "POST", p.Post
+	case *annotations.HttpRule_Delete:
+		method, path = "DELETE", p.Delete
+	case *annotations.HttpRule_Patch:
+		method, path = "PATCH", p.Patch
+	case *annotations.HttpRule_Custom:
+		method, path = strings.ToUpper(p.Custom.GetKind()), p.Custom.GetPath()
+	}
+	if method == "" || path == "" {
+		return HTTPBinding{}, false
+	}
+	return HTTPBinding{
+		Method:       method,
+		Path:         normalizeTemplate(path),
+		Body:         rule.GetBody(),
+		ResponseBody: rule.GetResponseBody(),
+	}, true
+}
+
+var singleSegmentVar = regexp.MustCompile(`\{([A-Za-z_][A-Za-z0-9_.]*)=\*\}`)
+
+// normalizeTemplate rewrites the `{field=*}` shorthand to `{field}`, the gateway's native
+// single-segment form. Other template forms are kept verbatim.
+func normalizeTemplate(path string) string {
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return singleSegmentVar.ReplaceAllString(path, "{$1}")
+}
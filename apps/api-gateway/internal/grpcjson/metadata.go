@@ -0,0 +1,198 @@
+package grpcjson
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// MetadataHeaderPrefix marks HTTP headers that carry gRPC metadata, in both directions.
+	MetadataHeaderPrefix = "Grpc-Metadata-"
+	// maxTimeout caps client-requested deadlines.
+	maxTimeout = 5 * time.Minute
+)
+
+// MetadataOptions controls how HTTP headers map to gRPC metadata for a service.
+//
+// Requests forward Authorization, every ForwardHeaders entry and all Grpc-Metadata-* headers
+// (prefix stripped). Upstream response headers and trailers come back as Grpc-Metadata-* headers.
+// Keys are compared case-insensitively; Deny wins over Allow, and a non-empty Allow list
+// restricts both directions to the listed keys.
+type MetadataOptions struct {
+	ForwardHeaders []string
+	Allow          []string
+	Deny           []string
+}
+
+// reservedMetadata never crosses the HTTP/gRPC boundary: it is either transport-level or
+// managed by grpc-go itself.
+var reservedMetadata = map[string]bool{
+	"content-type": true, "te": true, "user-agent": true, "host": true, "connection": true,
+	"keep-alive": true, "proxy-connection": true, "transfer-encoding": true, "upgrade": true,
+}
+
+func (o MetadataOptions) permits(key string) bool {
+	key = strings.ToLower(key)
+	if reservedMetadata[key] || strings.HasPrefix(key, "grpc-") || strings.HasPrefix(key, ":") {
+		return false
+	}
+	for _, d := range o.Deny {
+		if strings.EqualFold(d, key) {
+			return false
+		}
+	}
+	if len(o.Allow) == 0 {
+		return true
+	}
+	for _, a := range o.Allow {
+		if strings.EqualFold(a, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// outgoingContext derives the upstream call context from r: request headers become outgoing
+// metadata per mo, and a Grpc-Timeout or X-Request-Timeout header sets the deadline.
+// The returned cancel func must always be called.
+func outgoingContext(r *http.Request, mo MetadataOptions) (context.Context, context.CancelFunc, error) {
+	md := metadata.MD{}
+	add := func(key, val string) {
+		key = strings.ToLower(key)
+		if !mo.permits(key) {
+			return
+		}
+		if strings.HasSuffix(key, "-bin") {
+			// Binary metadata travels base64-encoded over HTTP; grpc-go re-encodes it on the wire.
+			b, err := base64.StdEncoding.DecodeString(val)
+			if err != nil {
+				return
+			}
+			val = string(b)
+		}
+		md.Append(key, val)
+	}
+	for _, h := range append([]string{"Authorization"}, mo.ForwardHeaders...) {
+		for _, v := range r.Header.Values(h) {
+			add(h, v)
+		}
+	}
+	for k, vs := range r.Header {
+		if !strings.HasPrefix(k, MetadataHeaderPrefix) {
+			continue
+		}
+		for _, v := range vs {
+			add(strings.TrimPrefix(k, MetadataHeaderPrefix), v)
+		}
+	}
+
+	ctx := metadata.NewOutgoingContext(r.Context(), md)
+	timeout, err := requestTimeout(r)
+	if err != nil {
+		return ctx, func() {}, err
+	}
+	if timeout > 0 {
+		tctx, cancel := context.WithTimeout(ctx, timeout)
+		return tctx, cancel, nil
+	}
+	return ctx, func() {}, nil
+}
+
+// requestTimeout reads the client's deadline. Grpc-Timeout uses the gRPC wire format
+// (e.g. "500m", "2S"); X-Request-Timeout accepts a Go duration ("1.5s") or plain seconds.
+func requestTimeout(r *http.Request) (time.Duration, error) {
+	var d time.Duration
+	var err error
+	if v := r.Header.Get("Grpc-Timeout"); v != "" {
+		d, err = parseGRPCTimeout(v)
+	} else if v := r.Header.Get("X-Request-Timeout"); v != "" {
+		if secs, perr := strconv.ParseFloat(v, 64); perr == nil {
+			// Clamp before converting: float-to-int overflow is undefined.
+			d = time.Duration(min(secs, maxTimeout.Seconds()) * float64(time.Second))
+		} else {
+			d, err = time.ParseDuration(v)
+		}
+	}
+	if err != nil {
+		return 0, err
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("negative timeout")
+	}
+	if d > maxTimeout {
+		d = maxTimeout
+	}
+	return d, nil
+}
+
+// parseGRPCTimeout parses the gRPC wire format: at most eight digits and a unit. Values above
+// maxTimeout return maxTimeout.
+func parseGRPCTimeout(v string) (time.Duration, error) {
+	if len(v) < 2 || len(v) > 9 {
+		return 0, fmt.Errorf("invalid Grpc-Timeout %q", v)
+	}
+	n, err := strconv.ParseInt(v[:len(v)-1], 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid Grpc-Timeout %q", v)
+	}
+	var unit time.Duration
+	switch v[len(v)-1] {
+	case 'H':
+		unit = time.Hour
+	case 'M':
+		unit = time.Minute
+	case 'S':
+		unit = time.Second
+	case 'm':
+		unit = time.Millisecond
+	case 'u':
+		unit = time.Microsecond
+	case 'n':
+		unit = time.Nanosecond
+	default:
+		return 0, fmt.Errorf("invalid Grpc-Timeout unit in %q", v)
+	}
+	// Eight digits of hours overflow a Duration; anything past maxTimeout is clamped anyway.
+	if n > int64(maxTimeout/unit) {
+		return maxTimeout, nil
+	}
+	return time.Duration(n) * unit, nil
+}
+
+// writeResponseMetadata copies permitted upstream metadata onto w as Grpc-Metadata-* headers.
+// It must run before the response status is written.
+func writeResponseMetadata(w http.ResponseWriter, mo MetadataOptions, mds ...metadata.MD) {
+	for _, md := range mds {
+		for k, vs := range md {
+			if !mo.permits(k) {
+				continue
+			}
+			name := MetadataHeaderPrefix + textproto.CanonicalMIMEHeaderKey(k)
+			for _, v := range vs {
+				if strings.HasSuffix(k, "-bin") {
+					v = base64.StdEncoding.EncodeToString([]byte(v))
+				}
+				w.Header().Add(name, v)
+			}
+		}
+	}
+}
+
+// filterMetadata returns the permitted subset of md, for embedding in stream status events.
+func filterMetadata(md metadata.MD, mo MetadataOptions) metadata.MD {
+	out := metadata.MD{}
+	for k, vs := range md {
+		if mo.permits(k) {
+			out[k] = vs
+		}
+	}
+	return out
+}
@@ -0,0 +1,162 @@
+package grpcjson
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/metadata"
+)
+
+func TestParseGRPCTimeout(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"0H", 0, false},
+		{"1H", maxTimeout, false},
+		{"2M", 2 * time.Minute, false},
+		{"3S", 3 * time.Second, false},
+		{"500m", 500 * time.Millisecond, false},
+		{"250u", 250 * time.Microsecond, false},
+		{"99999999n", 99999999 * time.Nanosecond, false},
+		{"0S", 0, false},
+		// Spec-valid, but past what a Duration holds: clamped instead of wrapping negative.
+		{"3000000H", maxTimeout, false},
+		{"99999999H", maxTimeout, false},
+		{"99999999M", maxTimeout, false},
+		{"", 0, true},
+		{"S", 0, true},
+		{"123456789S", 0, true},
+		{"-1S", 0, true},
+		{"1.5S", 0, true},
+		{"10s", 0, true},
+		{"10", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseGRPCTimeout(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseGRPCTimeout(%q) = %v, %v; want %v (error %v)", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestMetadataPermits(t *testing.T) {
+	tests := []struct {
+		name string
+		opts MetadataOptions
+		key  string
+		want bool
+	}{
+		{"plain key", MetadataOptions{}, "x-tenant", true},
+		{"case-insensitive deny", MetadataOptions{Deny: []string{"X-Tenant"}}, "x-tenant", false},
+		{"allow list", MetadataOptions{Allow: []string{"X-Tenant"}}, "x-tenant", true},
+		{"outside allow list", MetadataOptions{Allow: []string{"x-tenant"}}, "x-user", false},
+		{"deny wins over allow", MetadataOptions{Allow: []string{"x-tenant"}, Deny: []string{"x-tenant"}}, "X-Tenant", false},
+		{"reserved", MetadataOptions{}, "content-type", false},
+		{"reserved even when allowed", MetadataOptions{Allow: []string{"te"}}, "te", false},
+		{"grpc- prefix", MetadataOptions{}, "grpc-timeout", false},
+		{"pseudo header", MetadataOptions{}, ":authority", false},
+	}
+	for _, tt := range tests {
+		if got := tt.opts.permits(tt.key); got != tt.want {
+			t.Errorf("%s: permits(%q) = %v, want %v", tt.name, tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestOutgoingContext(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     MetadataOptions
+		header   http.Header
+		want     metadata.MD
+		deadline time.Duration // 0: none
+		wantErr  bool
+	}{
+		{
+			name:   "authorization and prefixed headers",
+			header: http.Header{"Authorization": {"Bearer t"}, "Grpc-Metadata-X-Tenant": {"acme", "beta"}, "X-Other": {"dropped"}},
+			want:   metadata.MD{"authorization": {"Bearer t"}, "x-tenant": {"acme", "beta"}},
+		},
+		{
+			name:   "forwarded headers",
+			opts:   MetadataOptions{ForwardHeaders: []string{"X-Request-Id"}},
+			header: http.Header{"X-Request-Id": {"r1"}},
+			want:   metadata.MD{"x-request-id": {"r1"}},
+		},
+		{
+			name:   "allow and deny",
+			opts:   MetadataOptions{Allow: []string{"x-tenant", "authorization"}, Deny: []string{"authorization"}},
+			header: http.Header{"Authorization": {"Bearer t"}, "Grpc-Metadata-X-Tenant": {"acme"}, "Grpc-Metadata-X-User": {"u"}},
+			want:   metadata.MD{"x-tenant": {"acme"}},
+		},
+		{
+			name: "binary metadata",
+			header: http.Header{
+				"Grpc-Metadata-Trace-Bin": {base64.StdEncoding.EncodeToString([]byte{0, 1, 2})},
+				"Grpc-Metadata-Bad-Bin":   {"not base64!"},
+			},
+			want: metadata.MD{"trace-bin": {"\x00\x01\x02"}},
+		},
+		{
+			name:   "reserved keys",
+			header: http.Header{"Grpc-Metadata-Content-Type": {"text/plain"}, "Grpc-Metadata-Grpc-Status": {"0"}},
+			want:   metadata.MD{},
+		},
+		{name: "grpc-timeout", header: http.Header{"Grpc-Timeout": {"2S"}}, want: metadata.MD{}, deadline: 2 * time.Second},
+		{name: "grpc-timeout clamped", header: http.Header{"Grpc-Timeout": {"3000000H"}}, want: metadata.MD{}, deadline: maxTimeout},
+		{name: "request timeout seconds", header: http.Header{"X-Request-Timeout": {"1.5"}}, want: metadata.MD{}, deadline: 1500 * time.Millisecond},
+		{name: "request timeout duration", header: http.Header{"X-Request-Timeout": {"3s"}}, want: metadata.MD{}, deadline: 3 * time.Second},
+		{name: "request timeout clamped", header: http.Header{"X-Request-Timeout": {"1e30"}}, want: metadata.MD{}, deadline: maxTimeout},
+		{name: "grpc-timeout wins", header: http.Header{"Grpc-Timeout": {"1S"}, "X-Request-Timeout": {"9s"}}, want: metadata.MD{}, deadline: time.Second},
+		{name: "negative request timeout", header: http.Header{"X-Request-Timeout": {"-1s"}}, wantErr: true},
+		{name: "malformed grpc-timeout", header: http.Header{"Grpc-Timeout": {"soon"}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/", nil)
+		r.Header = tt.header
+		start := time.Now()
+		ctx, cancel, err := outgoingContext(r, tt.opts)
+		cancel()
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: outgoingContext succeeded", tt.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		md, _ := metadata.FromOutgoingContext(ctx)
+		if !reflect.DeepEqual(md, tt.want) {
+			t.Errorf("%s: metadata %v, want %v", tt.name, md, tt.want)
+		}
+		dl, ok := ctx.Deadline()
+		switch {
+		case tt.deadline == 0 && ok:
+			t.Errorf("%s: unexpected deadline in %v", tt.name, time.Until(dl))
+		case tt.deadline != 0 && (!ok || dl.Sub(start) < tt.deadline || dl.Sub(start) > tt.deadline+time.Second):
+			t.Errorf("%s: deadline in %v, want %v", tt.name, dl.Sub(start), tt.deadline)
+		}
+	}
+}
+
+func TestWriteResponseMetadata(t *testing.T) {
+	rec := httptest.NewRecorder()
+	writeResponseMetadata(rec, MetadataOptions{Deny: []string{"x-secret"}},
+		metadata.MD{"x-tenant": {"acme"}, "x-secret": {"s"}, "content-type": {"application/grpc"}},
+		metadata.MD{"trace-bin": {"\x00\x01"}})
+	want := http.Header{
+		"Grpc-Metadata-X-Tenant":  {"acme"},
+		"Grpc-Metadata-Trace-Bin": {base64.StdEncoding.EncodeToString([]byte{0, 1})},
+	}
+	if !reflect.DeepEqual(rec.Header(), want) {
+		t.Errorf("headers %v, want %v", rec.Header(), want)
+	}
+}
@@ -0,0 +1,243 @@
+package grpcjson
+
+import (
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/backoff"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/keepalive"
+)
+
+// PoolOptions tunes the upstream connection pool. Zero values fall back to sane defaults.
+type PoolOptions struct {
+	// IdleTimeout closes connections that have not been used for this long.
+	IdleTimeout time.Duration
+	// KeepaliveTime is the interval between client keepalive pings on an idle transport.
+	KeepaliveTime time.Duration
+	// KeepaliveTimeout is how long to wait for a ping ack before the transport is considered dead.
+	KeepaliveTimeout time.Duration
+	// MaxBackoff caps the reconnect backoff delay.
+	MaxBackoff time.Duration
+	// Credentials picks transport credentials per target at dial time. Nil dials in plaintext.
+	Credentials func(target string) (credentials.TransportCredentials, error)
+}
+
+func (o PoolOptions) withDefaults() PoolOptions {
+	if o.IdleTimeout <= 0 {
+		o.IdleTimeout = 5 * time.Minute
+	}
+	if o.KeepaliveTime <= 0 {
+		o.KeepaliveTime = 30 * time.Second
+	}
+	if o.KeepaliveTimeout <= 0 {
+		o.KeepaliveTimeout = 10 * time.Second
+	}
+	if o.MaxBackoff <= 0 {
+		o.MaxBackoff = 30 * time.Second
+	}
+	return o
+}
+
+type pooledConn struct {
+	conn     *grpc.ClientConn
+	refs     int
+	lastUsed time.Time
+	// evicted connections are out of the pool; the last release closes them.
+	evicted bool
+}
+
+// ConnPool keeps one long-lived grpc.ClientConn per upstream target.
+// gRPC multiplexes concurrent RPCs over a single HTTP/2 connection, so sharing
+// a ClientConn per target is both cheaper and faster than dialing per request.
+// Connections unused for IdleTimeout are closed by a background janitor.
+type ConnPool struct {
+	opts  PoolOptions
+	creds func(target string) (credentials.TransportCredentials, error)
+	mu    sync.Mutex
+	conns map[string]*pooledConn
+	// dialing holds the dial in progress per target; concurrent Gets for the target wait for
+	// it instead of dialing again, and Gets for other targets don't wait at all.
+	dialing map[string]*dialCall
+	stop    chan struct{}
+	once    sync.Once
+}
+
+type dialCall struct {
+	done chan struct{}
+	err  error
+	// evicted is set when the target is evicted mid-dial: the connection may carry stale
+	// credentials, so it is closed instead of pooled.
+	evicted bool
+}
+
+// NewConnPool creates a pool and starts its idle eviction loop.
+func NewConnPool(opts PoolOptions) *ConnPool {
+	p := &ConnPool{
+		opts:    opts.withDefaults(),
+		creds:   opts.Credentials,
+		conns:   map[string]*pooledConn{},
+		dialing: map[string]*dialCall{},
+		stop:    make(chan struct{}),
+	}
+	go p.janitor()
+	return p
+}
+
+// SetCredentials installs the per-target credentials hook (see PoolOptions.Credentials).
+// Only connections dialed afterwards are affected; Evict existing ones to re-dial.
+func (p *ConnPool) SetCredentials(fn func(target string) (credentials.TransportCredentials, error)) {
+	p.mu.Lock()
+	p.creds = fn
+	p.mu.Unlock()
+}
+
+// Get returns the shared connection for target, dialing it on first use.
+// Callers must invoke the returned release func once the RPC completes.
+func (p *ConnPool) Get(target string) (*grpc.ClientConn, func(), error) {
+	for {
+		p.mu.Lock()
+		if pc, ok := p.conns[target]; ok {
+			defer p.mu.Unlock()
+			return pc.conn, p.acquire(pc), nil
+		}
+		if call, ok := p.dialing[target]; ok {
+			p.mu.Unlock()
+			<-call.done
+			if call.err != nil {
+				return nil, nil, call.err
+			}
+			continue // pick up the connection just pooled
+		}
+		call := &dialCall{done: make(chan struct{})}
+		p.dialing[target] = call
+		credsFor := p.creds
+		p.mu.Unlock()
+
+		// Neither the credentials lookup (possibly a repository read) nor the dial holds p.mu.
+		conn, err := p.dial(target, credsFor)
+		p.mu.Lock()
+		delete(p.dialing, target)
+		call.err = err
+		var release func()
+		if err == nil && !call.evicted {
+			pc := &pooledConn{conn: conn}
+			p.conns[target] = pc
+			release = p.acquire(pc)
+		}
+		p.mu.Unlock()
+		close(call.done)
+		if err != nil {
+			return nil, nil, err
+		}
+		if call.evicted {
+			_ = conn.Close()
+			continue // dial again with the current credentials
+		}
+		return conn, release, nil
+	}
+}
+
+// acquire counts a user of pc and returns its release func. Callers hold p.mu.
+func (p *ConnPool) acquire(pc *pooledConn) func() {
+	pc.refs++
+	pc.lastUsed = time.Now()
+	var released sync.Once
+	return func() {
+		released.Do(func() {
+			p.mu.Lock()
+			pc.refs--
+			pc.lastUsed = time.Now()
+			closing := pc.evicted && pc.refs == 0
+			p.mu.Unlock()
+			if closing {
+				_ = pc.conn.Close()
+			}
+		})
+	}
+}
+
+// dial connects to target with the credentials credsFor picks for it; without a hook,
+// targets dial in plaintext.
+func (p *ConnPool) dial(target string, credsFor func(target string) (credentials.TransportCredentials, error)) (*grpc.ClientConn, error) {
+	var creds credentials.TransportCredentials = insecure.NewCredentials()
+	if credsFor != nil {
+		c, err := credsFor(target)
+		if err != nil {
+			return nil, err
+		}
+		creds = c
+	}
+	bo := backoff.DefaultConfig
+	bo.MaxDelay = p.opts.MaxBackoff
+	return grpc.NewClient(target,
+		grpc.WithTransportCredentials(creds),
+		grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:                p.opts.KeepaliveTime,
+			Timeout:             p.opts.KeepaliveTimeout,
+			PermitWithoutStream: true,
+		}),
+		grpc.WithConnectParams(grpc.ConnectParams{Backoff: bo, MinConnectTimeout: 5 * time.Second}),
+	)
+}
+
+// Evict closes and forgets the connection for target once it is no longer in use, and
+// discards the result of a dial in progress. A subsequent Get dials a fresh connection.
+func (p *ConnPool) Evict(target string) {
+	p.mu.Lock()
+	if call, ok := p.dialing[target]; ok {
+		call.evicted = true
+	}
+	pc, ok := p.conns[target]
+	if ok {
+		delete(p.conns, target)
+		pc.evicted = true
+	}
+	idle := ok && pc.refs == 0
+	p.mu.Unlock()
+	if idle {
+		_ = pc.conn.Close()
+	}
+}
+
+// Close stops the janitor and closes every pooled connection.
+func (p *ConnPool) Close() error {
+	p.once.Do(func() { close(p.stop) })
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for target, pc := range p.conns {
+		_ = pc.conn.Close()
+		delete(p.conns, target)
+	}
+	return nil
+}
+
+func (p *ConnPool) janitor() {
+	interval := p.opts.IdleTimeout / 2
+	if interval < time.Second {
+		interval = time.Second
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-p.stop:
+			return
+		case now := <-t.C:
+			p.evictIdle(now)
+		}
+	}
+}
+
+func (p *ConnPool) evictIdle(now time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for target, pc := range p.conns {
+		if pc.refs == 0 && now.Sub(pc.lastUsed) >= p.opts.IdleTimeout {
+			_ = pc.conn.Close()
+			delete(p.conns, target)
+		}
+	}
+}
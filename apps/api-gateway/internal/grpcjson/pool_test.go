@@ -0,0 +1,161 @@
+package grpcjson
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+func TestConnPoolGetDialsOncePerTarget(t *testing.T) {
+	var dials atomic.Int32
+	p := NewConnPool(PoolOptions{Credentials: func(string) (credentials.TransportCredentials, error) {
+		dials.Add(1)
+		time.Sleep(20 * time.Millisecond) // keep the dial in flight while the others arrive
+		return insecure.NewCredentials(), nil
+	}})
+	defer p.Close()
+
+	const n = 8
+	conns := make([]*grpc.ClientConn, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			conn, release, err := p.Get("127.0.0.1:1")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer release()
+			conns[i] = conn
+		}(i)
+	}
+	wg.Wait()
+	if got := dials.Load(); got != 1 {
+		t.Fatalf("dialed %d times, want 1", got)
+	}
+	for _, c := range conns[1:] {
+		if c != conns[0] {
+			t.Fatal("Get returned different connections for one target")
+		}
+	}
+}
+
+func TestConnPoolGetDoesNotBlockOtherTargets(t *testing.T) {
+	slow := make(chan struct{})
+	p := NewConnPool(PoolOptions{Credentials: func(target string) (credentials.TransportCredentials, error) {
+		if target == "slow:1" {
+			<-slow
+		}
+		return insecure.NewCredentials(), nil
+	}})
+	defer p.Close()
+	defer close(slow)
+
+	go func() { _, _, _ = p.Get("slow:1") }()
+	time.Sleep(10 * time.Millisecond)
+	done := make(chan error, 1)
+	go func() {
+		_, release, err := p.Get("fast:1")
+		if err == nil {
+			release()
+		}
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Get for one target waited on another target's dial")
+	}
+}
+
+func TestConnPoolEvictClosesOnLastRelease(t *testing.T) {
+	p := NewConnPool(PoolOptions{})
+	defer p.Close()
+	conn, release1, err := p.Get("127.0.0.1:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, release2, err := p.Get("127.0.0.1:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p.Evict("127.0.0.1:1")
+	fresh, release3, err := p.Get("127.0.0.1:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release3()
+	if fresh == conn {
+		t.Fatal("Get returned the evicted connection")
+	}
+	release1()
+	release1() // a second release must not count twice
+	if conn.GetState() == connectivity.Shutdown {
+		t.Fatal("evicted connection closed while an RPC still uses it")
+	}
+	release2()
+	if conn.GetState() != connectivity.Shutdown {
+		t.Fatal("evicted connection still open after its last release")
+	}
+
+	// Unused connections close right away.
+	release3()
+	p.Evict("127.0.0.1:1")
+	if fresh.GetState() != connectivity.Shutdown {
+		t.Fatal("idle evicted connection still open")
+	}
+}
+
+func TestConnPoolEvictDiscardsDialInFlight(t *testing.T) {
+	var dials atomic.Int32
+	dialing, proceed := make(chan struct{}), make(chan struct{})
+	p := NewConnPool(PoolOptions{Credentials: func(string) (credentials.TransportCredentials, error) {
+		if dials.Add(1) == 1 {
+			close(dialing)
+			<-proceed // credentials rotate while the first dial is in flight
+		}
+		return insecure.NewCredentials(), nil
+	}})
+	defer p.Close()
+
+	type result struct {
+		conn *grpc.ClientConn
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		conn, release, err := p.Get("127.0.0.1:1")
+		if err == nil {
+			release()
+		}
+		done <- result{conn, err}
+	}()
+	<-dialing
+	p.Evict("127.0.0.1:1")
+	close(proceed)
+	res := <-done
+	if res.err != nil {
+		t.Fatal(res.err)
+	}
+	if got := dials.Load(); got != 2 {
+		t.Fatalf("dialed %d times, want a second dial after the eviction", got)
+	}
+	p.mu.Lock()
+	pooled := p.conns["127.0.0.1:1"]
+	p.mu.Unlock()
+	if pooled == nil || pooled.conn != res.conn {
+		t.Fatal("Get didn't return the connection dialed after the eviction")
+	}
+}
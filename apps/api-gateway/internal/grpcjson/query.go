@@ -0,0 +1,208 @@
+package grpcjson
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jhump/protoreflect/desc"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// queryParams maps query parameters onto fields of msg. Keys are field paths using proto or
+// JSON names ("page_size", "filter.minPrice"); repeated fields collect every value; enums accept
+// names or numbers; Timestamp, Duration, FieldMask and the wrapper types take their JSON string
+// forms (Durations also accept Go syntax such as "1m30s").
+//
+// Parameters that don't resolve to a settable field, or whose values don't parse, are dropped,
+// unless strict is set, in which case they yield an InvalidArgument status with BadRequest details.
+func queryParams(msg *desc.MessageDescriptor, q url.Values, strict bool) (map[string]any, *status.Status) {
+	out := map[string]any{}
+	var violations []*errdetails.BadRequest_FieldViolation
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		vals := q[key]
+		path, fd, err := resolveFieldPath(msg, key)
+		if err == nil {
+			var v any
+			if v, err = queryFieldValue(fd, vals, strict); err == nil {
+				out[path] = v
+				continue
+			}
+		}
+		violations = append(violations, &errdetails.BadRequest_FieldViolation{Field: key, Description: err.Error()})
+	}
+	if strict && len(violations) > 0 {
+		st := status.New(codes.InvalidArgument, "invalid query parameters")
+		if withDetails, err := st.WithDetails(&errdetails.BadRequest{FieldViolations: violations}); err == nil {
+			st = withDetails
+		}
+		return nil, st
+	}
+	return out, nil
+}
+
+// resolveFieldPath walks the dotted key through msg and returns the canonical proto-name path
+// and the final field.
+func resolveFieldPath(msg *desc.MessageDescriptor, key string) (string, *desc.FieldDescriptor, error) {
+	parts := strings.Split(key, ".")
+	names := make([]string, len(parts))
+	cur := msg
+	var fd *desc.FieldDescriptor
+	for i, p := range parts {
+		if cur == nil {
+			return "", nil, fmt.Errorf("%s is not a message field", strings.Join(parts[:i], "."))
+		}
+		fd = cur.FindFieldByName(p)
+		if fd == nil {
+			fd = cur.FindFieldByJSONName(p)
+		}
+		if fd == nil {
+			return "", nil, fmt.Errorf("unknown field %q on %s", p, cur.GetFullyQualifiedName())
+		}
+		names[i] = fd.GetName()
+		cur = nil
+		if i < len(parts)-1 {
+			if fd.IsRepeated() || fd.IsMap() {
+				return "", nil, fmt.Errorf("cannot address into repeated field %s", fd.GetName())
+			}
+			cur = fd.GetMessageType()
+		}
+	}
+	return strings.Join(names, "."), fd, nil
+}
+
+func queryFieldValue(fd *desc.FieldDescriptor, vals []string, strict bool) (any, error) {
+	if fd.IsMap() {
+		return nil, fmt.Errorf("map field %s cannot be set from a query parameter", fd.GetName())
+	}
+	if fd.IsRepeated() {
+		list := make([]any, 0, len(vals))
+		for _, v := range vals {
+			x, err := scalarValue(fd, v)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, x)
+		}
+		return list, nil
+	}
+	if len(vals) > 1 && strict {
+		return nil, fmt.Errorf("field %s is not repeated but was given %d values", fd.GetName(), len(vals))
+	}
+	return scalarValue(fd, vals[0])
+}
+
+// scalarValue converts one query value into the JSON value protojson expects for fd.
+func scalarValue(fd *desc.FieldDescriptor, v string) (any, error) {
+	bad := func(kind string) error { return fmt.Errorf("invalid %s value %q for %s", kind, v, fd.GetName()) }
+	switch fd.GetType() {
+	case descriptorpb.FieldDescriptorProto_TYPE_STRING, descriptorpb.FieldDescriptorProto_TYPE_BYTES:
+		return v, nil
+	case descriptorpb.FieldDescriptorProto_TYPE_BOOL:
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, bad("bool")
+		}
+		return b, nil
+	case descriptorpb.FieldDescriptorProto_TYPE_INT32, descriptorpb.FieldDescriptorProto_TYPE_SINT32, descriptorpb.FieldDescriptorProto_TYPE_SFIXED32:
+		if _, err := strconv.ParseInt(v, 10, 32); err != nil {
+			return nil, bad("int32")
+		}
+		return json.Number(v), nil
+	case descriptorpb.FieldDescriptorProto_TYPE_INT64, descriptorpb.FieldDescriptorProto_TYPE_SINT64, descriptorpb.FieldDescriptorProto_TYPE_SFIXED64:
+		if _, err := strconv.ParseInt(v, 10, 64); err != nil {
+			return nil, bad("int64")
+		}
+		return json.Number(v), nil
+	case descriptorpb.FieldDescriptorProto_TYPE_UINT32, descriptorpb.FieldDescriptorProto_TYPE_FIXED32:
+		if _, err := strconv.ParseUint(v, 10, 32); err != nil {
+			return nil, bad("uint32")
+		}
+		return json.Number(v), nil
+	case descriptorpb.FieldDescriptorProto_TYPE_UINT64, descriptorpb.FieldDescriptorProto_TYPE_FIXED64:
+		if _, err := strconv.ParseUint(v, 10, 64); err != nil {
+			return nil, bad("uint64")
+		}
+		return json.Number(v), nil
+	case descriptorpb.FieldDescriptorProto_TYPE_FLOAT, descriptorpb.FieldDescriptorProto_TYPE_DOUBLE:
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return nil, bad("number")
+		}
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			// protojson spells these as strings.
+			return nonFiniteName(f), nil
+		}
+		return json.Number(strconv.FormatFloat(f, 'g', -1, 64)), nil
+	case descriptorpb.FieldDescriptorProto_TYPE_ENUM:
+		et := fd.GetEnumType()
+		if ev := et.FindValueByName(v); ev != nil {
+			return ev.GetName(), nil
+		}
+		if n, err := strconv.ParseInt(v, 10, 32); err == nil {
+			if ev := et.FindValueByNumber(int32(n)); ev != nil {
+				return ev.GetName(), nil
+			}
+		}
+		return nil, fmt.Errorf("invalid value %q for enum %s", v, et.GetFullyQualifiedName())
+	case descriptorpb.FieldDescriptorProto_TYPE_MESSAGE:
+		return wellKnownValue(fd, v)
+	}
+	return nil, fmt.Errorf("field %s cannot be set from a query parameter", fd.GetName())
+}
+
+func nonFiniteName(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "Infinity"
+	case math.IsInf(f, -1):
+		return "-Infinity"
+	}
+	return "NaN"
+}
+
+// wellKnownValue handles message fields whose JSON form is a scalar.
+func wellKnownValue(fd *desc.FieldDescriptor, v string) (any, error) {
+	mt := fd.GetMessageType()
+	switch name := mt.GetFullyQualifiedName(); name {
+	case "google.protobuf.Timestamp":
+		t, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timestamp %q for %s (want RFC 3339)", v, fd.GetName())
+		}
+		return t.UTC().Format(time.RFC3339Nano), nil
+	case "google.protobuf.Duration":
+		if secs, ok := strings.CutSuffix(v, "s"); ok {
+			if _, err := strconv.ParseFloat(secs, 64); err == nil {
+				return v, nil
+			}
+		}
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid duration %q for %s", v, fd.GetName())
+		}
+		return strconv.FormatFloat(d.Seconds(), 'f', -1, 64) + "s", nil
+	case "google.protobuf.FieldMask":
+		return v, nil
+	case "google.protobuf.DoubleValue", "google.protobuf.FloatValue",
+		"google.protobuf.Int64Value", "google.protobuf.UInt64Value",
+		"google.protobuf.Int32Value", "google.protobuf.UInt32Value",
+		"google.protobuf.BoolValue", "google.protobuf.StringValue", "google.protobuf.BytesValue":
+		return scalarValue(mt.FindFieldByName("value"), v)
+	default:
+		return nil, fmt.Errorf("message field %s (%s) cannot be set from a query parameter", fd.GetName(), name)
+	}
+}
@@ -0,0 +1,203 @@
+package grpcjson
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/jhump/protoreflect/desc"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// listRequest builds test.v1.ListRequest, a message with every kind of field query
+// parameters can address.
+func listRequest(t *testing.T) *desc.MessageDescriptor {
+	t.Helper()
+	var deps []*desc.FileDescriptor
+	for _, f := range []string{"google/protobuf/timestamp.proto", "google/protobuf/duration.proto", "google/protobuf/wrappers.proto"} {
+		fd, err := desc.LoadFileDescriptor(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		deps = append(deps, fd)
+	}
+	field := func(name string, num int32, typ descriptorpb.FieldDescriptorProto_Type, typeName string, repeated bool) *descriptorpb.FieldDescriptorProto {
+		label := descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL
+		if repeated {
+			label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED
+		}
+		f := &descriptorpb.FieldDescriptorProto{Name: proto.String(name), Number: proto.Int32(num), Type: typ.Enum(), Label: label.Enum()}
+		if typeName != "" {
+			f.TypeName = proto.String(typeName)
+		}
+		return f
+	}
+	const (
+		str  = descriptorpb.FieldDescriptorProto_TYPE_STRING
+		i32  = descriptorpb.FieldDescriptorProto_TYPE_INT32
+		i64  = descriptorpb.FieldDescriptorProto_TYPE_INT64
+		dbl  = descriptorpb.FieldDescriptorProto_TYPE_DOUBLE
+		enum = descriptorpb.FieldDescriptorProto_TYPE_ENUM
+		msg  = descriptorpb.FieldDescriptorProto_TYPE_MESSAGE
+	)
+	fdp := &descriptorpb.FileDescriptorProto{
+		Name:       proto.String("test/v1/list.proto"),
+		Package:    proto.String("test.v1"),
+		Syntax:     proto.String("proto3"),
+		Dependency: []string{"google/protobuf/timestamp.proto", "google/protobuf/duration.proto", "google/protobuf/wrappers.proto"},
+		EnumType: []*descriptorpb.EnumDescriptorProto{{
+			Name: proto.String("State"),
+			Value: []*descriptorpb.EnumValueDescriptorProto{
+				{Name: proto.String("STATE_UNSPECIFIED"), Number: proto.Int32(0)},
+				{Name: proto.String("ACTIVE"), Number: proto.Int32(1)},
+				{Name: proto.String("ARCHIVED"), Number: proto.Int32(2)},
+			},
+		}},
+		MessageType: []*descriptorpb.DescriptorProto{
+			{
+				Name: proto.String("Filter"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("min_price", 1, dbl, "", false),
+					field("states", 2, enum, ".test.v1.State", true),
+				},
+			},
+			{
+				Name: proto.String("ListRequest"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("page_size", 1, i32, "", false),
+					field("tags", 2, str, "", true),
+					field("filter", 3, msg, ".test.v1.Filter", false),
+					field("state", 4, enum, ".test.v1.State", false),
+					field("since", 5, msg, ".google.protobuf.Timestamp", false),
+					field("ttl", 6, msg, ".google.protobuf.Duration", false),
+					field("limit", 7, msg, ".google.protobuf.Int64Value", false),
+					field("only_active", 8, msg, ".google.protobuf.BoolValue", false),
+					field("ids", 9, i64, "", true),
+					field("labels", 10, msg, ".test.v1.ListRequest.LabelsEntry", true),
+					field("filters", 11, msg, ".test.v1.Filter", true),
+				},
+				NestedType: []*descriptorpb.DescriptorProto{{
+					Name:    proto.String("LabelsEntry"),
+					Options: &descriptorpb.MessageOptions{MapEntry: proto.Bool(true)},
+					Field: []*descriptorpb.FieldDescriptorProto{
+						field("key", 1, str, "", false),
+						field("value", 2, str, "", false),
+					},
+				}},
+			},
+		},
+	}
+	fd, err := desc.CreateFileDescriptor(fdp, deps...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fd.FindMessage("test.v1.ListRequest")
+}
+
+func TestQueryParams(t *testing.T) {
+	md := listRequest(t)
+	tests := []struct {
+		name   string
+		query  string
+		strict bool
+		want   string
+		// violations are the fields a strict request reports as BadRequest violations.
+		violations []string
+	}{
+		{name: "scalar by proto name", query: "page_size=10", want: `{"page_size":"10"}`},
+		{name: "scalar by JSON name", query: "pageSize=20", want: `{"page_size":"20"}`},
+		{name: "repeated values", query: "tags=a&tags=b&ids=1&ids=2", want: `{"ids":["1","2"],"tags":["a","b"]}`},
+		{name: "dotted path", query: "filter.minPrice=9.5&filter.states=ACTIVE&filter.states=2", want: `{"filter.min_price":"9.5","filter.states":["ACTIVE","ARCHIVED"]}`},
+		{name: "enum by name or number", query: "state=1", want: `{"state":"ACTIVE"}`},
+		{name: "timestamp", query: "since=2024-05-01T10:00:00%2B02:00", want: `{"since":"2024-05-01T08:00:00Z"}`},
+		{name: "duration in JSON or Go syntax", query: "ttl=1m30s", want: `{"ttl":"90s"}`},
+		{name: "duration seconds", query: "ttl=1.5s", want: `{"ttl":"1.5s"}`},
+		{name: "wrappers", query: "limit=50&only_active=true", want: `{"limit":"50","only_active":true}`},
+		{name: "lenient drops bad values", query: "state=PAUSED&page_size=x&nope=1&tags=kept", want: `{"tags":["kept"]}`},
+		{name: "lenient keeps the first of several values", query: "state=ACTIVE&state=ARCHIVED", want: `{"state":"ACTIVE"}`},
+		{name: "strict unknown field", query: "nope=1", strict: true, violations: []string{"nope"}},
+		{name: "strict bad values", query: "page_size=x&since=yesterday&ttl=forever&state=PAUSED", strict: true, violations: []string{"page_size", "since", "state", "ttl"}},
+		{name: "strict repeated scalar", query: "state=ACTIVE&state=ARCHIVED", strict: true, violations: []string{"state"}},
+		{name: "strict map and repeated message paths", query: "labels=x&filters.min_price=1", strict: true, violations: []string{"filters.min_price", "labels"}},
+		{name: "strict path through a scalar", query: "page_size.x=1", strict: true, violations: []string{"page_size.x"}},
+		{name: "strict valid", query: "pageSize=5&filter.states=ACTIVE", strict: true, want: `{"filter.states":["ACTIVE"],"page_size":"5"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, st := queryParams(md, q, tt.strict)
+			if tt.violations != nil {
+				if st == nil || st.Code() != codes.InvalidArgument {
+					t.Fatalf("status = %v, want InvalidArgument", st)
+				}
+				var fields []string
+				for _, d := range st.Details() {
+					if br, ok := d.(*errdetails.BadRequest); ok {
+						for _, v := range br.GetFieldViolations() {
+							if v.GetDescription() == "" {
+								t.Errorf("violation of %s has no description", v.GetField())
+							}
+							fields = append(fields, v.GetField())
+						}
+					}
+				}
+				if gotF, wantF := jsonString(t, fields), jsonString(t, tt.violations); gotF != wantF {
+					t.Fatalf("violations = %s, want %s", gotF, wantF)
+				}
+				rec := httptest.NewRecorder()
+				WriteError(rec, st)
+				if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "google.rpc.BadRequest") {
+					t.Fatalf("response %d %s, want 400 with BadRequest details", rec.Code, rec.Body)
+				}
+				return
+			}
+			if st != nil {
+				t.Fatalf("unexpected status %v", st.Err())
+			}
+			if s := jsonString(t, got); s != tt.want {
+				t.Fatalf("params = %s, want %s", s, tt.want)
+			}
+		})
+	}
+}
+
+// jsonString renders v with numbers as strings, so json.Number and plain numbers compare alike.
+func jsonString(t *testing.T, v any) string {
+	t.Helper()
+	if m, ok := v.(map[string]any); ok {
+		norm := make(map[string]any, len(m))
+		for k, x := range m {
+			norm[k] = numbersAsStrings(x)
+		}
+		v = norm
+	}
+	bs, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(bs)
+}
+
+func numbersAsStrings(v any) any {
+	switch x := v.(type) {
+	case json.Number:
+		return x.String()
+	case []any:
+		out := make([]any, len(x))
+		for i, e := range x {
+			out[i] = numbersAsStrings(e)
+		}
+		return out
+	}
+	return v
+}
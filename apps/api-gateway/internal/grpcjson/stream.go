@@ -0,0 +1,165 @@
+package grpcjson
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/dynamic"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// streamWriter frames server-streamed messages for the HTTP client.
+type streamWriter interface {
+	contentType() string
+	message(w io.Writer, msg json.RawMessage) error
+	final(w io.Writer, st streamStatus) error
+}
+
+// streamStatus is the final event of a stream: the gRPC status plus upstream trailers.
+type streamStatus struct {
+	Code     int                 `json:"code"`
+	Message  string              `json:"message,omitempty"`
+	Trailers map[string][]string `json:"trailers,omitempty"`
+}
+
+// ndjsonWriter emits one JSON object per line: {"result": ...} per message and
+// {"status": ...} as the last line.
+type ndjsonWriter struct{}
+
+func (ndjsonWriter) contentType() string { return "application/x-ndjson" }
+
+func (ndjsonWriter) message(w io.Writer, msg json.RawMessage) error {
+	return writeLine(w, map[string]any{"result": msg})
+}
+
+func (ndjsonWriter) final(w io.Writer, st streamStatus) error {
+	return writeLine(w, map[string]any{"status": st})
+}
+
+func writeLine(w io.Writer, v any) error {
+	bs, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(bs, '\n'))
+	return err
+}
+
+// sseWriter emits Server-Sent Events: "message" per upstream message, then a single
+// "end" (OK) or "error" (non-OK) event carrying the status.
+type sseWriter struct{}
+
+func (sseWriter) contentType() string { return "text/event-stream" }
+
+func (sseWriter) message(w io.Writer, msg json.RawMessage) error {
+	return writeEvent(w, "message", msg)
+}
+
+func (sseWriter) final(w io.Writer, st streamStatus) error {
+	bs, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+	event := "end"
+	if st.Code != 0 {
+		event = "error"
+	}
+	return writeEvent(w, event, bs)
+}
+
+func writeEvent(w io.Writer, event string, data []byte) error {
+	_, err := io.WriteString(w, "event: "+event+"\ndata: "+string(data)+"\n\n")
+	return err
+}
+
+// negotiateStream picks SSE when the client accepts text/event-stream, otherwise NDJSON.
+func negotiateStream(r *http.Request) streamWriter {
+	if strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+		return sseWriter{}
+	}
+	return ndjsonWriter{}
+}
+
+// serveServerStream invokes a server-streaming RPC and relays each response message to
+// the client as soon as it arrives. The upstream call is bound to the request context, so
+// a client disconnect cancels the RPC.
+func serveServerStream(ctx context.Context, conn grpc.ClientConnInterface, fullMethod string, md *desc.MethodDescriptor, inMsg *dynamic.Message, opts Options, w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	sd := &grpc.StreamDesc{StreamName: md.GetName(), ServerStreams: true}
+	cs, err := conn.NewStream(ctx, sd, fullMethod)
+	if err == nil {
+		err = cs.SendMsg(inMsg)
+	}
+	if err == nil {
+		err = cs.CloseSend()
+	}
+	if err != nil && !errors.Is(err, io.EOF) {
+		WriteError(w, status.Convert(err))
+		return
+	}
+
+	// Header blocks until the upstream sends response headers (or fails).
+	if hdr, err := cs.Header(); err == nil {
+		writeResponseMetadata(w, opts.Metadata, hdr)
+	}
+	sw := negotiateStream(r)
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", sw.contentType())
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	_ = rc.Flush()
+
+	for {
+		outMsg := dynamic.NewMessage(md.GetOutputType())
+		err := cs.RecvMsg(outMsg)
+		if err != nil {
+			if r.Context().Err() != nil {
+				// Client went away; nobody is left to receive the final event.
+				return
+			}
+			st := streamStatus{Trailers: trailerMap(filterMetadata(cs.Trailer(), opts.Metadata))}
+			if !errors.Is(err, io.EOF) {
+				s := status.Convert(err)
+				st.Code = int(s.Code())
+				st.Message = s.Message()
+			}
+			_ = sw.final(w, st)
+			_ = rc.Flush()
+			return
+		}
+		bs, err := marshalOutput(outMsg, opts.ResponseBody)
+		if err != nil {
+			_ = sw.final(w, streamStatus{Code: int(codes.Internal), Message: "marshal: " + err.Error()})
+			_ = rc.Flush()
+			return
+		}
+		if err := sw.message(w, bs); err != nil {
+			return
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func trailerMap(md metadata.MD) map[string][]string {
+	if len(md) == 0 {
+		return nil
+	}
+	out := make(map[string][]string, len(md))
+	for k, v := range md {
+		out[k] = v
+	}
+	return out
+}
@@ -0,0 +1,216 @@
+package grpcjson
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+)
+
+// watchServer is a scripted grpc.health.v1.Health: Watch sends every status received on
+// send, then returns end (nil for OK) with an x-watch trailer once send is closed.
+type watchServer struct {
+	healthpb.UnimplementedHealthServer
+	send chan healthpb.HealthCheckResponse_ServingStatus
+	end  error
+	// done is closed when Watch returns, with the reason.
+	done chan error
+}
+
+func (s *watchServer) Watch(_ *healthpb.HealthCheckRequest, stream healthpb.Health_WatchServer) error {
+	err := s.watch(stream)
+	s.done <- err
+	close(s.done)
+	return err
+}
+
+func (s *watchServer) watch(stream healthpb.Health_WatchServer) error {
+	// Send headers right away so the client sees the response before the first message.
+	if err := stream.SendHeader(metadata.MD{}); err != nil {
+		return err
+	}
+	stream.SetTrailer(metadata.Pairs("x-watch", "done"))
+	for {
+		select {
+		case st, ok := <-s.send:
+			if !ok {
+				return s.end
+			}
+			if err := stream.Send(&healthpb.HealthCheckResponse{Status: st}); err != nil {
+				return err
+			}
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		}
+	}
+}
+
+// startWatch serves ws with reflection and returns an HTTP server transcoding Health/Watch.
+func startWatch(t *testing.T, ws *watchServer) *httptest.Server {
+	t.Helper()
+	ws.send = make(chan healthpb.HealthCheckResponse_ServingStatus)
+	ws.done = make(chan error, 1)
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer()
+	healthpb.RegisterHealthServer(srv, ws)
+	reflection.Register(srv)
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+	target := lis.Addr().String()
+	hs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ServeWithOptions(target, "/grpc.health.v1.Health/Watch", Options{}, w, r)
+	}))
+	t.Cleanup(hs.Close)
+	return hs
+}
+
+func watch(t *testing.T, ctx context.Context, hs *httptest.Server, accept string) *http.Response {
+	t.Helper()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, hs.URL, strings.NewReader(`{}`))
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	res, err := hs.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+func TestServerStreamFraming(t *testing.T) {
+	tests := []struct {
+		name   string
+		accept string
+		end    error
+		ctype  string
+		want   string
+	}{
+		{
+			name:  "ndjson by default",
+			ctype: "application/x-ndjson",
+			want: `{"result":{"status":"SERVING"}}` + "\n" +
+				`{"result":{"status":"NOT_SERVING"}}` + "\n" +
+				`{"status":{"code":0,"trailers":{"x-watch":["done"]}}}` + "\n",
+		},
+		{
+			name:   "ndjson error status",
+			accept: "application/json",
+			end:    status.Error(codes.Aborted, "watcher evicted"),
+			ctype:  "application/x-ndjson",
+			want: `{"result":{"status":"SERVING"}}` + "\n" +
+				`{"result":{"status":"NOT_SERVING"}}` + "\n" +
+				`{"status":{"code":10,"message":"watcher evicted","trailers":{"x-watch":["done"]}}}` + "\n",
+		},
+		{
+			name:   "sse",
+			accept: "application/json, text/event-stream",
+			ctype:  "text/event-stream",
+			want: "event: message\ndata: {\"status\":\"SERVING\"}\n\n" +
+				"event: message\ndata: {\"status\":\"NOT_SERVING\"}\n\n" +
+				"event: end\ndata: {\"code\":0,\"trailers\":{\"x-watch\":[\"done\"]}}\n\n",
+		},
+		{
+			name:   "sse error event",
+			accept: "text/event-stream",
+			end:    status.Error(codes.Aborted, "watcher evicted"),
+			ctype:  "text/event-stream",
+			want: "event: message\ndata: {\"status\":\"SERVING\"}\n\n" +
+				"event: message\ndata: {\"status\":\"NOT_SERVING\"}\n\n" +
+				"event: error\ndata: {\"code\":10,\"message\":\"watcher evicted\",\"trailers\":{\"x-watch\":[\"done\"]}}\n\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ws := &watchServer{end: tt.end}
+			hs := startWatch(t, ws)
+			go func() {
+				ws.send <- healthpb.HealthCheckResponse_SERVING
+				ws.send <- healthpb.HealthCheckResponse_NOT_SERVING
+				close(ws.send)
+			}()
+			res := watch(t, context.Background(), hs, tt.accept)
+			defer res.Body.Close()
+			body, err := io.ReadAll(res.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.StatusCode != http.StatusOK || res.Header.Get("Content-Type") != tt.ctype {
+				t.Fatalf("got %d %s, want 200 %s", res.StatusCode, res.Header.Get("Content-Type"), tt.ctype)
+			}
+			if string(body) != tt.want {
+				t.Fatalf("body:\n%s\nwant:\n%s", body, tt.want)
+			}
+		})
+	}
+}
+
+func TestServerStreamFlushesEachMessage(t *testing.T) {
+	ws := &watchServer{}
+	hs := startWatch(t, ws)
+	res := watch(t, context.Background(), hs, "")
+	defer res.Body.Close()
+	lines := make(chan string)
+	go func() {
+		sc := bufio.NewScanner(res.Body)
+		for sc.Scan() {
+			lines <- sc.Text()
+		}
+		close(lines)
+	}()
+
+	// Each message must reach the client while the upstream stream is still open.
+	for _, st := range []healthpb.HealthCheckResponse_ServingStatus{healthpb.HealthCheckResponse_SERVING, healthpb.HealthCheckResponse_NOT_SERVING} {
+		ws.send <- st
+		select {
+		case line := <-lines:
+			if want := `{"result":{"status":"` + st.String() + `"}}`; line != want {
+				t.Fatalf("got %s, want %s", line, want)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("%s not flushed to the client", st)
+		}
+	}
+	close(ws.send)
+	if line := <-lines; !strings.HasPrefix(line, `{"status":{"code":0`) {
+		t.Fatalf("final line %s, want the OK status", line)
+	}
+}
+
+func TestServerStreamCancelsOnDisconnect(t *testing.T) {
+	ws := &watchServer{}
+	hs := startWatch(t, ws)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	res := watch(t, ctx, hs, "text/event-stream")
+	defer res.Body.Close()
+	ws.send <- healthpb.HealthCheckResponse_SERVING
+	if _, err := bufio.NewReader(res.Body).ReadString('\n'); err != nil {
+		t.Fatal(err)
+	}
+
+	cancel()
+	select {
+	case err := <-ws.done:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("upstream Watch ended with %v, want it canceled", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("upstream stream still open after the client disconnected")
+	}
+}
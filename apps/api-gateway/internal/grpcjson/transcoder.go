@@ -1,21 +1,44 @@
 package grpcjson
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"strings"
 
+	"github.com/gorilla/websocket"
+	"github.com/jhump/protoreflect/desc"
 	"github.com/jhump/protoreflect/dynamic"
-	"github.com/jhump/protoreflect/grpcreflect"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
-	reflectpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
 )
 
-// Serve performs a minimal JSON→gRPC transcoding for unary RPCs using server reflection.
+var (
+	// Pool holds the shared upstream connections used by the transcoder.
+	Pool = NewConnPool(PoolOptions{})
+	// Descriptors caches reflection results for upstream services.
+	Descriptors = NewDescriptorCache()
+)
+
+// Invalidate drops cached descriptors for target so the next request re-resolves them
+// via reflection. Call it after route discovery or an admin refresh.
+func Invalidate(target string) {
+	Descriptors.Invalidate(target)
+}
+
+// Serve performs a minimal JSON→gRPC transcoding for unary and server-streaming RPCs using server reflection.
+// Server-streaming responses are relayed as NDJSON, or as Server-Sent Events when the client accepts text/event-stream.
+// Client- and bidi-streaming methods are served over a WebSocket upgrade (see serveWebSocket).
 // Path remainder must be "/<package>.<Service>/<Method>". Request body should be JSON for the method's input message.
 // NOTE: This is a minimal dynamic approach for exploration; production use should adopt google.api.http annotations
 // and/or generated grpc-gateway handlers for robust REST shapes.
@@ -26,82 +49,263 @@ func Serve(grpcTarget, methodPath string, w http.ResponseWriter, r *http.Request
 // ServeWithParams is like Serve, but merges provided params into the JSON input object
 // before invoking the gRPC method. Params win only for missing keys (body overrides).
 func ServeWithParams(grpcTarget, methodPath string, params map[string]any, w http.ResponseWriter, r *http.Request) {
+	ServeWithOptions(grpcTarget, methodPath, Options{Params: params}, w, r)
+}
+
+// BodyNone as Options.Body ignores the request body: the input message is built from params
+// only, like a google.api.http rule without a body.
+const BodyNone = "-"
+
+// Options carries per-route transcoding settings.
+type Options struct {
+	// Params are path/query values merged into the input message. Keys may be dotted field
+	// paths ("user.id") addressing nested messages. By default the body wins for fields it sets.
+	Params map[string]any
+	// ParamsOverrideBody makes Params win over values in the body.
+	ParamsOverrideBody bool
+	// Query holds query parameters keyed by input field path; they are resolved against the
+	// method's input type and fill fields that Params doesn't set (see queryParams).
+	Query url.Values
+	// StrictQuery rejects unknown or mistyped query parameters with InvalidArgument.
+	StrictQuery bool
+	// Body selects where the request body goes: "" or "*" for the whole input message,
+	// BodyNone to ignore it, otherwise the (possibly dotted) path of the input field that receives it.
+	Body string
+	// ResponseBody, when set, is the (possibly dotted) path of the output field returned
+	// instead of the whole message.
+	ResponseBody string
+	// Metadata controls header <-> gRPC metadata mapping.
+	Metadata MetadataOptions
+	// AllowedOrigins lists browser origins that may open WebSockets to streaming methods;
+	// empty allows same-origin pages only.
+	AllowedOrigins []string
+}
+
+// ServeWithOptions is like ServeWithParams with google.api.http style body and
+// response_body selection.
+func ServeWithOptions(grpcTarget, methodPath string, opts Options, w http.ResponseWriter, r *http.Request) {
 	full := strings.TrimPrefix(methodPath, "/")
 	if full == "" || !strings.Contains(full, "/") {
-		http.Error(w, "invalid gRPC method path; expected /package.Service/Method", http.StatusBadRequest)
+		Errorf(w, codes.InvalidArgument, "invalid gRPC method path; expected /package.Service/Method")
 		return
 	}
-	// Dial upstream
-	conn, err := grpc.DialContext(r.Context(), grpcTarget, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	// Borrow the pooled upstream connection
+	conn, release, err := Pool.Get(grpcTarget)
 	if err != nil {
-		http.Error(w, "upstream dial failed: "+err.Error(), http.StatusBadGateway)
+		Errorf(w, codes.Unavailable, "upstream dial failed: %v", err)
 		return
 	}
-	defer conn.Close()
-
-	// Setup reflection client
-	rc := grpcreflect.NewClient(r.Context(), reflectpb.NewServerReflectionClient(conn))
-	defer rc.Reset()
+	defer release()
 
 	service := full[:strings.LastIndex(full, "/")]
 	method := full[strings.LastIndex(full, "/")+1:]
-	desc, err := rc.ResolveService(service)
+	md, err := Descriptors.ResolveMethod(r.Context(), grpcTarget, conn, service, method)
 	if err != nil {
-		http.Error(w, "service not found: "+err.Error(), http.StatusBadRequest)
-		return
-	}
-	md := desc.FindMethodByName(method)
-	if md == nil {
-		http.Error(w, "method not found", http.StatusBadRequest)
+		st, ok := status.FromError(err)
+		if !ok {
+			st = status.Newf(codes.NotFound, "method not found: %v", err)
+		}
+		WriteError(w, st)
 		return
 	}
-	// Build dynamic input message from JSON body
-	inMsg := dynamic.NewMessage(md.GetInputType())
-	body, _ := io.ReadAll(r.Body)
-	if len(body) == 0 {
-		if params != nil {
-			if merged, err := json.Marshal(params); err == nil {
-				body = merged
-			}
+	if len(opts.Query) > 0 {
+		qp, st := queryParams(md.GetInputType(), opts.Query, opts.StrictQuery)
+		if st != nil {
+			WriteError(w, st)
+			return
 		}
-		if len(body) == 0 {
-			body = []byte("{}")
+		// Path params win over query params for the same field.
+		params := make(map[string]any, len(opts.Params)+len(qp))
+		for k, v := range qp {
+			params[k] = v
 		}
-	} else if params != nil && len(params) > 0 {
-		var obj map[string]any
-		if err := json.Unmarshal(body, &obj); err == nil && obj != nil {
-			for k, v := range params {
-				if _, exists := obj[k]; !exists {
-					obj[k] = v
-				}
-			}
-			if merged, err := json.Marshal(obj); err == nil {
-				body = merged
-			}
+		for k, v := range opts.Params {
+			params[k] = v
 		}
+		opts.Params = params
 	}
-	if err := inMsg.UnmarshalJSON(body); err != nil {
-		http.Error(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
+	ctx, cancel, err := outgoingContext(r, opts.Metadata)
+	defer cancel()
+	if err != nil {
+		Errorf(w, codes.InvalidArgument, "%v", err)
+		return
+	}
+	fullMethod := "/" + service + "/" + method
+	if md.IsClientStreaming() {
+		if !websocket.IsWebSocketUpgrade(r) {
+			Errorf(w, codes.InvalidArgument, "client-streaming methods require a WebSocket upgrade")
+			return
+		}
+		serveWebSocket(ctx, conn, fullMethod, md, opts, w, r)
 		return
 	}
-	// Propagate Authorization header as metadata if present
-	ctx := r.Context()
-	if auth := r.Header.Get("Authorization"); auth != "" {
-		ctx = metadata.AppendToOutgoingContext(ctx, "authorization", auth)
+	inMsg, err := decodeInput(md, opts, r)
+	if err != nil {
+		Errorf(w, codes.InvalidArgument, "invalid JSON: %v", err)
+		return
+	}
+	if md.IsServerStreaming() {
+		serveServerStream(ctx, conn, fullMethod, md, inMsg, opts, w, r)
+		return
 	}
 	// Invoke unary RPC
 	outMsg := dynamic.NewMessage(md.GetOutputType())
-	err = conn.Invoke(ctx, "/"+service+"/"+method, inMsg, outMsg)
+	var header, trailer metadata.MD
+	err = conn.Invoke(ctx, fullMethod, inMsg, outMsg, grpc.Header(&header), grpc.Trailer(&trailer))
+	writeResponseMetadata(w, opts.Metadata, header, trailer)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("grpc error: %v", err), http.StatusBadGateway)
+		WriteError(w, status.Convert(err))
 		return
 	}
 	// Write JSON response
 	w.Header().Set("Content-Type", "application/json")
-	bs, err := outMsg.MarshalJSON()
+	bs, err := marshalOutput(outMsg, opts.ResponseBody)
 	if err != nil {
-		http.Error(w, "marshal: "+err.Error(), http.StatusInternalServerError)
+		Errorf(w, codes.Internal, "marshal: %v", err)
 		return
 	}
 	_, _ = w.Write(bs)
 }
+
+// decodeInput builds the dynamic input message for md from the JSON request body and params.
+func decodeInput(md *desc.MethodDescriptor, opts Options, r *http.Request) (*dynamic.Message, error) {
+	body, _ := io.ReadAll(r.Body)
+	body, err := selectBody(body, opts.Body)
+	if err != nil {
+		return nil, err
+	}
+	return decodeJSON(md, body, opts)
+}
+
+// selectBody places body where the route maps it: the whole input message, nowhere (BodyNone)
+// or nested under a field path such as "product" or "order.item".
+func selectBody(body []byte, field string) ([]byte, error) {
+	switch {
+	case field == BodyNone:
+		return nil, nil
+	case field == "" || field == "*" || len(body) == 0:
+		return body, nil
+	case !json.Valid(body):
+		return nil, errors.New("request body is not valid JSON")
+	}
+	wrapped := json.RawMessage(body)
+	parts := strings.Split(field, ".")
+	for i := len(parts) - 1; i >= 0; i-- {
+		b, err := json.Marshal(map[string]json.RawMessage{parts[i]: wrapped})
+		if err != nil {
+			return nil, err
+		}
+		wrapped = b
+	}
+	return wrapped, nil
+}
+
+// marshalOutput renders msg as JSON, or only the field at the dotted path responseBody when set.
+// Both go through the protobuf JSON mapping, so int64s, enums and well-known types look the same
+// whether a field is returned on its own or as part of the whole message.
+func marshalOutput(msg *dynamic.Message, responseBody string) ([]byte, error) {
+	if responseBody == "" || responseBody == "*" {
+		return msg.MarshalJSON()
+	}
+	raw, err := msg.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	pm := dynamicpb.NewMessage(msg.GetMessageDescriptor().UnwrapMessage())
+	if err := proto.Unmarshal(raw, pm); err != nil {
+		return nil, err
+	}
+	var cur protoreflect.Message = pm
+	parts := strings.Split(responseBody, ".")
+	for i, name := range parts {
+		fd := cur.Descriptor().Fields().ByName(protoreflect.Name(name))
+		if fd == nil {
+			return nil, fmt.Errorf("response_body field %q not found on %s", responseBody, cur.Descriptor().FullName())
+		}
+		if i == len(parts)-1 {
+			return marshalField(cur, fd)
+		}
+		if fd.Message() == nil || fd.IsList() || fd.IsMap() {
+			return nil, fmt.Errorf("response_body %q: %s is not a message field", responseBody, name)
+		}
+		if !cur.Has(fd) {
+			return []byte("null"), nil
+		}
+		cur = cur.Get(fd).Message()
+	}
+	return msg.MarshalJSON()
+}
+
+// marshalField renders the value of fd in m with protojson by marshaling a copy of m that holds
+// only that field. Unset fields render as their zero value ("UNKNOWN", "0", [], ...), or null
+// for fields with explicit presence.
+func marshalField(m protoreflect.Message, fd protoreflect.FieldDescriptor) ([]byte, error) {
+	only := m.Type().New()
+	set := m.Has(fd)
+	if set {
+		only.Set(fd, m.Get(fd))
+	}
+	b, err := protojson.MarshalOptions{EmitUnpopulated: !set}.Marshal(only.Interface())
+	if err != nil {
+		return nil, err
+	}
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(b, &obj); err != nil {
+		// Well-known types such as Duration have a JSON form without fields to pick from.
+		return nil, fmt.Errorf("response_body cannot select a field of %s", m.Descriptor().FullName())
+	}
+	return obj[fd.JSONName()], nil
+}
+
+// decodeJSON unmarshals body into a new instance of md's input type after merging
+// opts.Params into it. An empty body yields just the params.
+func decodeJSON(md *desc.MethodDescriptor, body []byte, opts Options) (*dynamic.Message, error) {
+	inMsg := dynamic.NewMessage(md.GetInputType())
+	if len(opts.Params) > 0 {
+		obj := map[string]any{}
+		if len(body) > 0 {
+			dec := json.NewDecoder(bytes.NewReader(body))
+			// Keep numbers verbatim so int64 values survive the round trip.
+			dec.UseNumber()
+			if err := dec.Decode(&obj); err != nil {
+				return nil, err
+			}
+		}
+		for k, v := range opts.Params {
+			setFieldPath(obj, strings.Split(k, "."), v, opts.ParamsOverrideBody)
+		}
+		merged, err := json.Marshal(obj)
+		if err != nil {
+			return nil, err
+		}
+		body = merged
+	}
+	if len(body) == 0 {
+		body = []byte("{}")
+	}
+	if err := inMsg.UnmarshalJSON(body); err != nil {
+		return nil, err
+	}
+	return inMsg, nil
+}
+
+// setFieldPath sets obj[path...] = v, creating intermediate objects. Existing values are only
+// replaced when override is set.
+func setFieldPath(obj map[string]any, path []string, v any, override bool) {
+	for _, p := range path[:len(path)-1] {
+		next, ok := obj[p].(map[string]any)
+		if !ok {
+			if cur, exists := obj[p]; exists && cur != nil && !override {
+				return
+			}
+			next = map[string]any{}
+			obj[p] = next
+		}
+		obj = next
+	}
+	last := path[len(path)-1]
+	if _, exists := obj[last]; exists && !override {
+		return
+	}
+	obj[last] = v
+}
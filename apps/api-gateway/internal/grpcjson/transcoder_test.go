@@ -0,0 +1,163 @@
+package grpcjson
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/protoadapt"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/known/apipb"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/sourcecontextpb"
+	"google.golang.org/protobuf/types/known/typepb"
+
+	"github.com/jhump/protoreflect/dynamic"
+)
+
+// startHealthServer serves grpc.health.v1.Health with reflection on a loopback port.
+func startHealthServer(tb testing.TB) string {
+	tb.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	srv := grpc.NewServer()
+	healthpb.RegisterHealthServer(srv, health.NewServer())
+	reflection.Register(srv)
+	go func() { _ = srv.Serve(lis) }()
+	tb.Cleanup(srv.Stop)
+	return lis.Addr().String()
+}
+
+func transcodeOnce(tb testing.TB, target string) {
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{}`))
+	rec := httptest.NewRecorder()
+	ServeWithOptions(target, "/grpc.health.v1.Health/Check", Options{}, rec, req)
+	if rec.Code != http.StatusOK {
+		tb.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestServeWithOptionsUnary(t *testing.T) {
+	target := startHealthServer(t)
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{}`))
+	rec := httptest.NewRecorder()
+	ServeWithOptions(target, "/grpc.health.v1.Health/Check", Options{}, rec, req)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"SERVING"`) {
+		t.Fatalf("got %d %s", rec.Code, rec.Body)
+	}
+}
+
+// BenchmarkTranscode compares the shared pool and descriptor cache against dialing and
+// reflecting on every request, which is what the transcoder did before both existed.
+func BenchmarkTranscode(b *testing.B) {
+	target := startHealthServer(b)
+	pool, descs := Pool, Descriptors
+	b.Cleanup(func() { Pool, Descriptors = pool, descs })
+
+	b.Run("pooled", func(b *testing.B) {
+		Pool, Descriptors = NewConnPool(PoolOptions{}), NewDescriptorCache()
+		defer Pool.Close()
+		transcodeOnce(b, target) // warm the connection and descriptors
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			transcodeOnce(b, target)
+		}
+	})
+	b.Run("unpooled", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			Pool, Descriptors = NewConnPool(PoolOptions{}), NewDescriptorCache()
+			transcodeOnce(b, target)
+			_ = Pool.Close()
+		}
+	})
+}
+
+func TestMarshalOutputResponseBody(t *testing.T) {
+	api := &apipb.Api{
+		Name:          "catalog",
+		Version:       "v1",
+		SourceContext: &sourcecontextpb.SourceContext{FileName: "catalog.proto"},
+		Syntax:        typepb.Syntax_SYNTAX_EDITIONS,
+		Methods:       []*apipb.Method{{Name: "Get", ResponseStreaming: true}},
+	}
+	opt := &descriptorpb.UninterpretedOption{NegativeIntValue: proto.Int64(-5), StringValue: []byte("x")}
+	tests := []struct {
+		name    string
+		msg     protoadapt.MessageV2
+		path    string
+		want    string
+		wantErr bool
+	}{
+		{"whole message", opt, "", `{"negativeIntValue":"-5","stringValue":"eA=="}`, false},
+		{"int64 as string", opt, "negative_int_value", `"-5"`, false},
+		{"bytes as base64", opt, "string_value", `"eA=="`, false},
+		{"unset optional", &descriptorpb.UninterpretedOption{}, "negative_int_value", `null`, false},
+		{"inside well-known type", &durationpb.Duration{Seconds: 5}, "seconds", ``, true},
+		{"enum by name", api, "syntax", `"SYNTAX_EDITIONS"`, false},
+		{"unset enum", &apipb.Api{}, "syntax", `"SYNTAX_PROTO2"`, false},
+		{"nested field", api, "source_context.file_name", `"catalog.proto"`, false},
+		{"unset parent", &apipb.Api{}, "source_context.file_name", `null`, false},
+		{"repeated", api, "methods", `[{"name":"Get","responseStreaming":true}]`, false},
+		{"unset repeated", &apipb.Api{}, "methods", `[]`, false},
+		{"unset message", &apipb.Api{}, "source_context", `null`, false},
+		{"unknown field", api, "nope", ``, true},
+		{"scalar parent", api, "name.x", ``, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, err := dynamic.AsDynamicMessage(protoadapt.MessageV1Of(tt.msg))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := marshalOutput(msg, tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			var compact bytes.Buffer
+			if err := json.Compact(&compact, got); err != nil {
+				t.Fatalf("invalid JSON %q: %v", got, err)
+			}
+			if compact.String() != tt.want {
+				t.Fatalf("marshalOutput(%q) = %s, want %s", tt.path, compact.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestSelectBody(t *testing.T) {
+	tests := []struct {
+		field, body, want string
+	}{
+		{"", `{"a":1}`, `{"a":1}`},
+		{"*", `{"a":1}`, `{"a":1}`},
+		{BodyNone, `{"a":1}`, ``},
+		{"none", `{"a":1}`, `{"none":{"a":1}}`}, // a real field, not the sentinel
+		{"order.item", `{"sku":"x"}`, `{"order":{"item":{"sku":"x"}}}`},
+		{"product", ``, ``},
+	}
+	for _, tt := range tests {
+		got, err := selectBody([]byte(tt.body), tt.field)
+		if err != nil || string(got) != tt.want {
+			t.Errorf("selectBody(%q, %q) = %s, %v; want %s", tt.body, tt.field, got, err, tt.want)
+		}
+	}
+	if _, err := selectBody([]byte(`{`), "product"); err == nil {
+		t.Error("selectBody accepted invalid JSON")
+	}
+}
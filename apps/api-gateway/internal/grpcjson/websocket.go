@@ -0,0 +1,160 @@
+package grpcjson
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gorilla/websocket"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/dynamic"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"ecomm/api-gateway/internal/util"
+)
+
+// wsCloseBase is added to the gRPC status code to form the WebSocket close code for
+// non-OK statuses (4000-4999 is the application-private range). OK closes with 1000.
+const wsCloseBase = 4000
+
+// newUpgrader accepts browser origins listed in allowed, or same-origin pages when it is empty
+// (see util.OriginAllowed). Cookies ride along on WebSocket handshakes, so an unchecked origin
+// would let any site drive streaming RPCs with the user's session.
+func newUpgrader(allowed []string) *websocket.Upgrader {
+	return &websocket.Upgrader{
+		ReadBufferSize:  4096,
+		WriteBufferSize: 4096,
+		CheckOrigin:     func(r *http.Request) bool { return util.OriginAllowed(r, allowed) },
+	}
+}
+
+// serveWebSocket bridges a client- or bidi-streaming RPC over a WebSocket connection.
+//
+// Each inbound text frame is a JSON request message (path params fill in missing keys);
+// an empty text frame half-closes the upstream send side. Each upstream response message
+// is sent as a text frame. When the RPC ends, the gateway sends a close frame whose code
+// is 1000 for OK or 4000+<grpc code> otherwise, with the status message as the reason.
+func serveWebSocket(ctx context.Context, conn grpc.ClientConnInterface, fullMethod string, md *desc.MethodDescriptor, opts Options, w http.ResponseWriter, r *http.Request) {
+	ws, err := newUpgrader(opts.AllowedOrigins).Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade has already replied with an HTTP error.
+		return
+	}
+	defer ws.Close()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	sd := &grpc.StreamDesc{StreamName: md.GetName(), ClientStreams: true, ServerStreams: md.IsServerStreaming()}
+	cs, err := conn.NewStream(ctx, sd, fullMethod)
+	if err != nil {
+		closeWithStatus(ws, status.Convert(err))
+		return
+	}
+
+	// Reader: client frames -> upstream. A protocol error or abrupt disconnect cancels the RPC.
+	readErr := make(chan *status.Status, 1)
+	go func() {
+		halfClosed := false
+		for {
+			mt, data, err := ws.ReadMessage()
+			if err != nil {
+				if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+					cancel()
+				}
+				_ = cs.CloseSend()
+				return
+			}
+			if mt != websocket.TextMessage {
+				readErr <- status.New(codes.InvalidArgument, "only text frames are supported")
+				cancel()
+				return
+			}
+			if len(data) == 0 {
+				halfClosed = true
+				_ = cs.CloseSend()
+				continue
+			}
+			if halfClosed {
+				readErr <- status.New(codes.InvalidArgument, "message sent after end of client stream")
+				cancel()
+				return
+			}
+			body, err := selectBody(data, opts.Body)
+			if err != nil {
+				readErr <- status.New(codes.InvalidArgument, "invalid JSON: "+err.Error())
+				cancel()
+				return
+			}
+			inMsg, err := decodeJSON(md, body, opts)
+			if err != nil {
+				readErr <- status.New(codes.InvalidArgument, "invalid JSON: "+err.Error())
+				cancel()
+				return
+			}
+			if err := cs.SendMsg(inMsg); err != nil {
+				// io.EOF means the server ended the RPC; the real status surfaces on RecvMsg.
+				if !errors.Is(err, io.EOF) {
+					cancel()
+				}
+				return
+			}
+		}
+	}()
+
+	// Writer: upstream messages -> client frames, then the final status as a close frame.
+	for {
+		outMsg := dynamic.NewMessage(md.GetOutputType())
+		err := cs.RecvMsg(outMsg)
+		if err != nil {
+			st := status.New(codes.OK, "")
+			if !errors.Is(err, io.EOF) {
+				st = status.Convert(err)
+			}
+			select {
+			case rs := <-readErr:
+				st = rs
+			default:
+			}
+			closeWithStatus(ws, st)
+			return
+		}
+		bs, err := marshalOutput(outMsg, opts.ResponseBody)
+		if err != nil {
+			closeWithStatus(ws, status.New(codes.Internal, "marshal: "+err.Error()))
+			return
+		}
+		if err := ws.WriteMessage(websocket.TextMessage, bs); err != nil {
+			return
+		}
+	}
+}
+
+// closeWithStatus conveys st to the client in a close frame.
+func closeWithStatus(ws *websocket.Conn, st *status.Status) {
+	code := websocket.CloseNormalClosure
+	if st.Code() != codes.OK {
+		code = wsCloseBase + int(st.Code())
+	}
+	msg := websocket.FormatCloseMessage(code, closeReason(st.Message()))
+	_ = ws.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+}
+
+// closeReason fits msg into a close frame: payloads are limited to 125 bytes, two of which carry
+// the code. The reason must stay valid UTF-8, so it is cut before the rune crossing the limit.
+func closeReason(msg string) string {
+	const max = 123
+	if len(msg) <= max {
+		return msg
+	}
+	n := max
+	for n > 0 && !utf8.RuneStart(msg[n]) {
+		n--
+	}
+	return msg[:n]
+}
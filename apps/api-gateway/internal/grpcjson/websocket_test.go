@@ -0,0 +1,30 @@
+package grpcjson
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestCloseReason(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  string
+		want int // byte length of the result
+	}{
+		{"short", "deadline exceeded", 17},
+		{"exact", strings.Repeat("a", 123), 123},
+		{"ascii", strings.Repeat("a", 200), 123},
+		// 122 ASCII bytes then a 3-byte rune straddling the limit: the rune is dropped whole.
+		{"rune at limit", strings.Repeat("a", 122) + "€€", 122},
+		{"all multibyte", strings.Repeat("€", 60), 123},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := closeReason(tt.msg)
+			if len(got) != tt.want || !utf8.ValidString(got) || !strings.HasPrefix(tt.msg, got) {
+				t.Fatalf("closeReason = %q (%d bytes), want a valid %d-byte prefix", got, len(got), tt.want)
+			}
+		})
+	}
+}
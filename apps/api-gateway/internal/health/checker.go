@@ -39,10 +39,8 @@ func Start(repo registry.Repository, intervalSec string) {
 				if err == nil && resp.StatusCode/100 == 2 {
 					status = "Healthy"
 				}
-				// update fields
-				s.LastStatus = status
-				s.LastHealthAt = time.Now()
-				_ = repo.Update(context.Background(), s)
+				// health is recorded separately so probes don't bump the service version
+				_ = repo.UpdateHealth(context.Background(), s.ID, status, time.Now())
 			}
 		}
 	}()
@@ -0,0 +1,331 @@
+// Package jsonpatch applies JSON Merge Patch (RFC 7386) and JSON Patch (RFC 6902) documents.
+package jsonpatch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Media types of the two patch formats.
+const (
+	MergePatchType = "application/merge-patch+json"
+	JSONPatchType  = "application/json-patch+json"
+)
+
+// ErrTestFailed is returned (wrapped) when a JSON Patch test operation doesn't match.
+var ErrTestFailed = errors.New("test failed")
+
+// MergePatch applies a JSON Merge Patch to doc: objects are merged recursively, null removes a
+// member and any other value replaces the target.
+func MergePatch(doc, patch []byte) ([]byte, error) {
+	var d, p any
+	if err := decode(doc, &d); err != nil {
+		return nil, fmt.Errorf("document: %w", err)
+	}
+	if err := decode(patch, &p); err != nil {
+		return nil, fmt.Errorf("merge patch: %w", err)
+	}
+	return json.Marshal(merge(d, p))
+}
+
+func merge(target, patch any) any {
+	p, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]any)
+	if !ok {
+		t = map[string]any{}
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(t, k)
+			continue
+		}
+		t[k] = merge(t[k], v)
+	}
+	return t
+}
+
+// Operation is one JSON Patch operation.
+type Operation struct {
+	Op   string `json:"op"`
+	Path string `json:"path"`
+	From string `json:"from,omitempty"`
+	// Value is absent (nil) or the raw JSON value, including "null".
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// Apply applies a JSON Patch (an array of operations) to doc. Operations are applied in order
+// and the patch is atomic: any failing operation, including a failed test, aborts it.
+func Apply(doc, patch []byte) ([]byte, error) {
+	var d any
+	if err := decode(doc, &d); err != nil {
+		return nil, fmt.Errorf("document: %w", err)
+	}
+	var ops []Operation
+	if err := json.Unmarshal(patch, &ops); err != nil {
+		return nil, fmt.Errorf("json patch must be an array of operations: %w", err)
+	}
+	for i, op := range ops {
+		var err error
+		if d, err = applyOp(d, op); err != nil {
+			return nil, fmt.Errorf("operation %d (%s %s): %w", i, op.Op, op.Path, err)
+		}
+	}
+	return json.Marshal(d)
+}
+
+func applyOp(doc any, op Operation) (any, error) {
+	value := func() (any, error) {
+		if op.Value == nil {
+			return nil, errors.New("missing value")
+		}
+		var v any
+		return v, decode(op.Value, &v)
+	}
+	switch op.Op {
+	case "add":
+		v, err := value()
+		if err != nil {
+			return nil, err
+		}
+		return add(doc, op.Path, v)
+	case "remove":
+		doc, _, err := remove(doc, op.Path)
+		return doc, err
+	case "replace":
+		v, err := value()
+		if err != nil {
+			return nil, err
+		}
+		if doc, _, err = remove(doc, op.Path); err != nil {
+			return nil, err
+		}
+		return add(doc, op.Path, v)
+	case "move":
+		if strings.HasPrefix(op.Path, op.From+"/") {
+			return nil, errors.New("cannot move a value into one of its children")
+		}
+		doc, v, err := remove(doc, op.From)
+		if err != nil {
+			return nil, err
+		}
+		return add(doc, op.Path, v)
+	case "copy":
+		v, err := get(doc, op.From)
+		if err != nil {
+			return nil, err
+		}
+		return add(doc, op.Path, deepCopy(v))
+	case "test":
+		want, err := value()
+		if err != nil {
+			return nil, err
+		}
+		got, err := get(doc, op.Path)
+		if err != nil {
+			return nil, err
+		}
+		if !reflect.DeepEqual(normalize(got), normalize(want)) {
+			return nil, ErrTestFailed
+		}
+		return doc, nil
+	}
+	return nil, fmt.Errorf("unknown op %q", op.Op)
+}
+
+// parsePointer splits a JSON Pointer (RFC 6901) into unescaped reference tokens.
+func parsePointer(p string) ([]string, error) {
+	if p == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(p, "/") {
+		return nil, fmt.Errorf("invalid pointer %q", p)
+	}
+	parts := strings.Split(p[1:], "/")
+	for i, s := range parts {
+		parts[i] = strings.ReplaceAll(strings.ReplaceAll(s, "~1", "/"), "~0", "~")
+	}
+	return parts, nil
+}
+
+func get(doc any, path string) (any, error) {
+	tokens, err := parsePointer(path)
+	if err != nil {
+		return nil, err
+	}
+	cur := doc
+	for _, tok := range tokens {
+		switch c := cur.(type) {
+		case map[string]any:
+			v, ok := c[tok]
+			if !ok {
+				return nil, fmt.Errorf("path %q does not exist", path)
+			}
+			cur = v
+		case []any:
+			i, err := index(tok, len(c), false)
+			if err != nil {
+				return nil, err
+			}
+			cur = c[i]
+		default:
+			return nil, fmt.Errorf("path %q does not exist", path)
+		}
+	}
+	return cur, nil
+}
+
+// add inserts v at path and returns the (possibly replaced) document root.
+func add(doc any, path string, v any) (any, error) {
+	tokens, err := parsePointer(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return v, nil
+	}
+	return update(doc, tokens, func(parent any, last string) (any, error) {
+		switch c := parent.(type) {
+		case map[string]any:
+			c[last] = v
+			return c, nil
+		case []any:
+			i, err := index(last, len(c), true)
+			if err != nil {
+				return nil, err
+			}
+			c = append(c, nil)
+			copy(c[i+1:], c[i:])
+			c[i] = v
+			return c, nil
+		}
+		return nil, fmt.Errorf("parent of %q is not a container", path)
+	})
+}
+
+// remove deletes the value at path and returns the new root and the removed value.
+func remove(doc any, path string) (any, any, error) {
+	tokens, err := parsePointer(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, doc, nil
+	}
+	var removed any
+	doc, err = update(doc, tokens, func(parent any, last string) (any, error) {
+		switch c := parent.(type) {
+		case map[string]any:
+			v, ok := c[last]
+			if !ok {
+				return nil, fmt.Errorf("path %q does not exist", path)
+			}
+			removed = v
+			delete(c, last)
+			return c, nil
+		case []any:
+			i, err := index(last, len(c), false)
+			if err != nil {
+				return nil, err
+			}
+			removed = c[i]
+			return append(c[:i], c[i+1:]...), nil
+		}
+		return nil, fmt.Errorf("path %q does not exist", path)
+	})
+	return doc, removed, err
+}
+
+// update walks to the parent of the last token, lets fn modify it and stores the result back,
+// so slices that grow or shrink are re-linked into their parents.
+func update(node any, tokens []string, fn func(parent any, last string) (any, error)) (any, error) {
+	if len(tokens) == 1 {
+		return fn(node, tokens[0])
+	}
+	switch c := node.(type) {
+	case map[string]any:
+		child, ok := c[tokens[0]]
+		if !ok {
+			return nil, fmt.Errorf("path segment %q does not exist", tokens[0])
+		}
+		nc, err := update(child, tokens[1:], fn)
+		if err != nil {
+			return nil, err
+		}
+		c[tokens[0]] = nc
+		return c, nil
+	case []any:
+		i, err := index(tokens[0], len(c), false)
+		if err != nil {
+			return nil, err
+		}
+		nc, err := update(c[i], tokens[1:], fn)
+		if err != nil {
+			return nil, err
+		}
+		c[i] = nc
+		return c, nil
+	}
+	return nil, fmt.Errorf("path segment %q does not exist", tokens[0])
+}
+
+// index parses an array index token; "-" (append) is only valid when inserting.
+func index(tok string, n int, insert bool) (int, error) {
+	if tok == "-" && insert {
+		return n, nil
+	}
+	i, err := strconv.Atoi(tok)
+	if err != nil || i < 0 || (tok != "0" && strings.HasPrefix(tok, "0")) {
+		return 0, fmt.Errorf("invalid array index %q", tok)
+	}
+	last := n - 1
+	if insert {
+		last = n
+	}
+	if i > last {
+		return 0, fmt.Errorf("array index %d out of range", i)
+	}
+	return i, nil
+}
+
+func decode(b []byte, v any) error {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
+
+func deepCopy(v any) any {
+	b, _ := json.Marshal(v)
+	var out any
+	_ = decode(b, &out)
+	return out
+}
+
+// normalize makes numbers comparable regardless of their textual form (1 vs 1.0).
+func normalize(v any) any {
+	switch x := v.(type) {
+	case json.Number:
+		f, _ := x.Float64()
+		return f
+	case map[string]any:
+		out := make(map[string]any, len(x))
+		for k, e := range x {
+			out[k] = normalize(e)
+		}
+		return out
+	case []any:
+		out := make([]any, len(x))
+		for i, e := range x {
+			out[i] = normalize(e)
+		}
+		return out
+	}
+	return v
+}
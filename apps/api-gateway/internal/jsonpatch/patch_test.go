@@ -0,0 +1,123 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func jsonEqual(t *testing.T, got []byte, want string) bool {
+	t.Helper()
+	var g, w any
+	if err := json.Unmarshal(got, &g); err != nil {
+		t.Fatalf("result %s: %v", got, err)
+	}
+	if err := json.Unmarshal([]byte(want), &w); err != nil {
+		t.Fatalf("expectation %s: %v", want, err)
+	}
+	return reflect.DeepEqual(g, w)
+}
+
+// Cases from RFC 7386 appendix A.
+func TestMergePatch(t *testing.T) {
+	tests := []struct{ doc, patch, want string }{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+		{`{"n":12345678901234567890}`, `{}`, `{"n":12345678901234567890}`},
+	}
+	for _, tt := range tests {
+		got, err := MergePatch([]byte(tt.doc), []byte(tt.patch))
+		if err != nil {
+			t.Errorf("MergePatch(%s, %s): %v", tt.doc, tt.patch, err)
+			continue
+		}
+		if !jsonEqual(t, got, tt.want) {
+			t.Errorf("MergePatch(%s, %s) = %s, want %s", tt.doc, tt.patch, got, tt.want)
+		}
+	}
+	if _, err := MergePatch([]byte(`{}`), []byte(`{`)); err == nil {
+		t.Error("MergePatch(malformed patch): got no error")
+	}
+}
+
+// Cases mostly from RFC 6902 appendix A.
+func TestApply(t *testing.T) {
+	tests := []struct {
+		name, doc, patch, want string
+	}{
+		{"add member", `{"foo":"bar"}`, `[{"op":"add","path":"/baz","value":"qux"}]`, `{"baz":"qux","foo":"bar"}`},
+		{"add element", `{"foo":["bar","baz"]}`, `[{"op":"add","path":"/foo/1","value":"qux"}]`, `{"foo":["bar","qux","baz"]}`},
+		{"append element", `{"foo":["bar"]}`, `[{"op":"add","path":"/foo/-","value":"baz"}]`, `{"foo":["bar","baz"]}`},
+		{"add nested", `{"foo":"bar"}`, `[{"op":"add","path":"/child","value":{"grandchild":{}}}]`, `{"foo":"bar","child":{"grandchild":{}}}`},
+		{"replace root", `{"foo":"bar"}`, `[{"op":"add","path":"","value":[1]}]`, `[1]`},
+		{"remove member", `{"baz":"qux","foo":"bar"}`, `[{"op":"remove","path":"/baz"}]`, `{"foo":"bar"}`},
+		{"remove element", `{"foo":["bar","qux","baz"]}`, `[{"op":"remove","path":"/foo/1"}]`, `{"foo":["bar","baz"]}`},
+		{"replace", `{"baz":"qux","foo":"bar"}`, `[{"op":"replace","path":"/baz","value":"boo"}]`, `{"baz":"boo","foo":"bar"}`},
+		{"move member", `{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`, `[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`, `{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`},
+		{"move element", `{"foo":["all","grass","cows","eat"]}`, `[{"op":"move","from":"/foo/1","path":"/foo/3"}]`, `{"foo":["all","cows","eat","grass"]}`},
+		{"copy", `{"a":{"b":[1]}}`, `[{"op":"copy","from":"/a","path":"/c"},{"op":"add","path":"/c/b/-","value":2}]`, `{"a":{"b":[1]},"c":{"b":[1,2]}}`},
+		{"test then replace", `{"v":1}`, `[{"op":"test","path":"/v","value":1.0},{"op":"replace","path":"/v","value":2}]`, `{"v":2}`},
+		{"escaped pointer", `{"a/b":1,"m~n":2}`, `[{"op":"remove","path":"/a~1b"},{"op":"replace","path":"/m~0n","value":3}]`, `{"m~n":3}`},
+		{"add null", `{}`, `[{"op":"add","path":"/n","value":null}]`, `{"n":null}`},
+	}
+	for _, tt := range tests {
+		got, err := Apply([]byte(tt.doc), []byte(tt.patch))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !jsonEqual(t, got, tt.want) {
+			t.Errorf("%s: got %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestApplyErrors(t *testing.T) {
+	tests := []struct {
+		name, doc, patch string
+	}{
+		{"not an array", `{}`, `{"op":"add","path":"/a","value":1}`},
+		{"unknown op", `{}`, `[{"op":"frob","path":"/a"}]`},
+		{"missing value", `{}`, `[{"op":"add","path":"/a"}]`},
+		{"missing parent", `{"foo":"bar"}`, `[{"op":"add","path":"/baz/bat","value":"qux"}]`},
+		{"remove missing", `{"foo":"bar"}`, `[{"op":"remove","path":"/baz"}]`},
+		{"replace missing", `{"foo":"bar"}`, `[{"op":"replace","path":"/baz","value":1}]`},
+		{"index out of range", `{"foo":[1]}`, `[{"op":"add","path":"/foo/2","value":1}]`},
+		{"leading zero index", `{"foo":[1,2]}`, `[{"op":"remove","path":"/foo/01"}]`},
+		{"append outside add", `{"foo":[1]}`, `[{"op":"remove","path":"/foo/-"}]`},
+		{"move into child", `{"a":{"b":{}}}`, `[{"op":"move","from":"/a","path":"/a/b/c"}]`},
+		{"pointer without slash", `{"a":1}`, `[{"op":"remove","path":"a"}]`},
+	}
+	for _, tt := range tests {
+		if got, err := Apply([]byte(tt.doc), []byte(tt.patch)); err == nil {
+			t.Errorf("%s: got %s, want an error", tt.name, got)
+		} else if errors.Is(err, ErrTestFailed) {
+			t.Errorf("%s: got ErrTestFailed, want another error", tt.name)
+		}
+	}
+}
+
+func TestApplyTestFailed(t *testing.T) {
+	doc := `{"baz":"qux","foo":["a",2,"c"]}`
+	_, err := Apply([]byte(doc), []byte(`[{"op":"test","path":"/baz","value":"qux"},{"op":"test","path":"/foo/1","value":"2"}]`))
+	if !errors.Is(err, ErrTestFailed) {
+		t.Errorf("Apply(failing test): got %v, want ErrTestFailed", err)
+	}
+	if _, err := Apply([]byte(doc), []byte(`[{"op":"test","path":"/missing","value":1}]`)); err == nil {
+		t.Error("Apply(test of a missing path): got no error")
+	}
+}
@@ -0,0 +1,344 @@
+// Package migrate versions the gateway's Postgres schema. Migrations are SQL files embedded in
+// the binary, named NNNN_name.up.sql with an optional NNNN_name.down.sql, and {{schema}} in them
+// stands for the configured schema. Applied versions are recorded with the checksum of their up
+// file in gateway_migrations, and every change runs under an advisory lock so replicas starting
+// together apply each migration once.
+package migrate
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed migrations/*.sql
+var files embed.FS
+
+// Migration is one embedded schema change.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string // empty when the migration cannot be reverted
+	// Checksum is the SHA-256 of the up file, hex encoded.
+	Checksum string
+}
+
+// Status is a migration's state in the database. Migrations applied by a newer build, which
+// this one doesn't embed, are listed with an empty Up.
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+	// Modified reports that the embedded up file differs from the one that was applied.
+	Modified bool
+}
+
+// ErrChecksum is returned when an applied migration's file was edited afterwards; migrations
+// are immutable once released, changes belong in a new one.
+var ErrChecksum = errors.New("migrate: applied migration was modified")
+
+var (
+	fileName   = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+	schemaName = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+)
+
+// Migrator applies and reverts the embedded migrations in one schema.
+type Migrator struct {
+	db         *sql.DB
+	schema     string
+	migrations []Migration
+	// Logf, when set, reports each migration applied or reverted.
+	Logf func(format string, args ...any)
+}
+
+// New returns a Migrator for schema; an empty schema means "public", like
+// registry.NewSQLRepository. Only [a-z_][a-z0-9_]* are allowed.
+func New(db *sql.DB, schema string) (*Migrator, error) {
+	if schema == "" {
+		schema = "public"
+	}
+	if !schemaName.MatchString(schema) {
+		return nil, fmt.Errorf("migrate: invalid schema name %q", schema)
+	}
+	list, err := load(files)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, schema: schema, migrations: list}, nil
+}
+
+// Run applies every pending migration, as the gateway does on startup.
+func Run(db *sql.DB, schema string) error {
+	m, err := New(db, schema)
+	if err != nil {
+		return err
+	}
+	_, err = m.Up(context.Background(), 0)
+	return err
+}
+
+// Migrations returns the embedded migrations, oldest first.
+func (m *Migrator) Migrations() []Migration {
+	return append([]Migration(nil), m.migrations...)
+}
+
+// load reads and pairs the migration files of fsys.
+func load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	byVersion := map[int64]*Migration{}
+	for _, e := range entries {
+		match := fileName.FindStringSubmatch(e.Name())
+		if match == nil {
+			return nil, fmt.Errorf("migrate: unexpected file %s", e.Name())
+		}
+		version, _ := strconv.ParseInt(match[1], 10, 64)
+		data, err := fs.ReadFile(fsys, "migrations/"+e.Name())
+		if err != nil {
+			return nil, err
+		}
+		mig := byVersion[version]
+		if mig == nil {
+			mig = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = mig
+		} else if mig.Name != match[2] {
+			return nil, fmt.Errorf("migrate: version %d is both %s and %s", version, mig.Name, match[2])
+		}
+		if match[3] == "up" {
+			sum := sha256.Sum256(data)
+			mig.Up, mig.Checksum = string(data), hex.EncodeToString(sum[:])
+		} else {
+			mig.Down = string(data)
+		}
+	}
+	list := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.Up == "" {
+			return nil, fmt.Errorf("migrate: %04d_%s has no up file", mig.Version, mig.Name)
+		}
+		list = append(list, *mig)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	return list, nil
+}
+
+// applied is a row of gateway_migrations.
+type applied struct {
+	name      string
+	checksum  string
+	appliedAt time.Time
+}
+
+func (m *Migrator) table() string { return m.schema + ".gateway_migrations" }
+
+// lockKey identifies the schema's advisory lock; other schemas migrate independently.
+func (m *Migrator) lockKey() int64 {
+	h := fnv.New64a()
+	h.Write([]byte("api-gateway/migrate:" + m.schema))
+	return int64(h.Sum64())
+}
+
+// locked runs fn on one connection holding the schema's advisory lock, creating the schema
+// and the migrations table first. Session locks belong to a connection, so everything fn does
+// must go through conn.
+func (m *Migrator) locked(ctx context.Context, fn func(conn *sql.Conn, done map[int64]applied) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, m.lockKey()); err != nil {
+		return fmt.Errorf("migrate: lock: %w", err)
+	}
+	defer conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, m.lockKey())
+	if _, err := conn.ExecContext(ctx, fmt.Sprintf(`CREATE SCHEMA IF NOT EXISTS %s;
+	CREATE TABLE IF NOT EXISTS %s (
+	  version BIGINT PRIMARY KEY,
+	  name TEXT NOT NULL,
+	  checksum TEXT NOT NULL,
+	  applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	);`, m.schema, m.table())); err != nil {
+		return err
+	}
+	done, err := m.applied(ctx, conn)
+	if err != nil {
+		return err
+	}
+	return fn(conn, done)
+}
+
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+func (m *Migrator) applied(ctx context.Context, q queryer) (map[int64]applied, error) {
+	rows, err := q.QueryContext(ctx, fmt.Sprintf(`SELECT version, name, checksum, applied_at FROM %s`, m.table()))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	done := map[int64]applied{}
+	for rows.Next() {
+		var v int64
+		var a applied
+		if err := rows.Scan(&v, &a.name, &a.checksum, &a.appliedAt); err != nil {
+			return nil, err
+		}
+		done[v] = a
+	}
+	return done, rows.Err()
+}
+
+// verify fails with ErrChecksum when an applied migration no longer matches its file.
+func (m *Migrator) verify(done map[int64]applied) error {
+	for _, mig := range m.migrations {
+		if a, ok := done[mig.Version]; ok && a.checksum != mig.Checksum {
+			return fmt.Errorf("%w: %04d_%s", ErrChecksum, mig.Version, mig.Name)
+		}
+	}
+	return nil
+}
+
+// Up applies the pending migrations up to and including version target, or all of them when
+// target is 0, each in its own transaction. It returns the migrations applied.
+func (m *Migrator) Up(ctx context.Context, target int64) ([]Migration, error) {
+	var out []Migration
+	err := m.locked(ctx, func(conn *sql.Conn, done map[int64]applied) error {
+		if err := m.verify(done); err != nil {
+			return err
+		}
+		for _, mig := range m.migrations {
+			if target > 0 && mig.Version > target {
+				break
+			}
+			if _, ok := done[mig.Version]; ok {
+				continue
+			}
+			err := m.exec(ctx, conn, mig.Up, fmt.Sprintf(`INSERT INTO %s (version, name, checksum) VALUES ($1, $2, $3)`, m.table()), mig.Version, mig.Name, mig.Checksum)
+			if err != nil {
+				return fmt.Errorf("migrate: apply %04d_%s: %w", mig.Version, mig.Name, err)
+			}
+			m.logf("migrate: applied %04d_%s", mig.Version, mig.Name)
+			out = append(out, mig)
+		}
+		return nil
+	})
+	return out, err
+}
+
+// Down reverts applied migrations, newest first, until only those at or below version target
+// remain, and stops at the first one without a down file. It returns the migrations reverted.
+func (m *Migrator) Down(ctx context.Context, target int64) ([]Migration, error) {
+	var out []Migration
+	err := m.locked(ctx, func(conn *sql.Conn, done map[int64]applied) error {
+		if err := m.verify(done); err != nil {
+			return err
+		}
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			mig := m.migrations[i]
+			if mig.Version <= target {
+				break
+			}
+			if _, ok := done[mig.Version]; !ok {
+				continue
+			}
+			if mig.Down == "" {
+				return fmt.Errorf("migrate: %04d_%s cannot be reverted", mig.Version, mig.Name)
+			}
+			err := m.exec(ctx, conn, mig.Down, fmt.Sprintf(`DELETE FROM %s WHERE version = $1`, m.table()), mig.Version)
+			if err != nil {
+				return fmt.Errorf("migrate: revert %04d_%s: %w", mig.Version, mig.Name, err)
+			}
+			m.logf("migrate: reverted %04d_%s", mig.Version, mig.Name)
+			out = append(out, mig)
+		}
+		return nil
+	})
+	return out, err
+}
+
+// Previous returns the version below the newest applied one, the target that makes Down
+// revert a single migration.
+func (m *Migrator) Previous(ctx context.Context) (int64, error) {
+	list, err := m.Status(ctx)
+	if err != nil {
+		return 0, err
+	}
+	var versions []int64
+	for _, s := range list {
+		if s.Applied {
+			versions = append(versions, s.Version)
+		}
+	}
+	if len(versions) < 2 {
+		return 0, nil
+	}
+	return versions[len(versions)-2], nil
+}
+
+// exec runs one migration's SQL and its bookkeeping statement in a transaction.
+func (m *Migrator) exec(ctx context.Context, conn *sql.Conn, script, record string, args ...any) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, strings.ReplaceAll(script, "{{schema}}", m.schema)); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, record, args...); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// Status lists every embedded migration, and every applied one this build doesn't know, by
+// version. It doesn't take the lock and doesn't create anything.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	var exists bool
+	if err := m.db.QueryRowContext(ctx, `SELECT to_regclass($1) IS NOT NULL`, m.table()).Scan(&exists); err != nil {
+		return nil, err
+	}
+	done := map[int64]applied{}
+	if exists {
+		var err error
+		if done, err = m.applied(ctx, m.db); err != nil {
+			return nil, err
+		}
+	}
+	var out []Status
+	for _, mig := range m.migrations {
+		s := Status{Migration: mig}
+		if a, ok := done[mig.Version]; ok {
+			s.Applied, s.AppliedAt, s.Modified = true, a.appliedAt, a.checksum != mig.Checksum
+			delete(done, mig.Version)
+		}
+		out = append(out, s)
+	}
+	for v, a := range done {
+		out = append(out, Status{Migration: Migration{Version: v, Name: a.name, Checksum: a.checksum}, Applied: true, AppliedAt: a.appliedAt})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Version < out[j].Version })
+	return out, nil
+}
+
+func (m *Migrator) logf(format string, args ...any) {
+	if m.Logf != nil {
+		m.Logf(format, args...)
+	}
+}
@@ -0,0 +1,93 @@
+package migrate
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestEmbeddedMigrations(t *testing.T) {
+	list, err := load(files)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if len(list) == 0 {
+		t.Fatal("no embedded migrations")
+	}
+	for i, mig := range list {
+		if mig.Version != int64(i+1) {
+			t.Errorf("%04d_%s: want version %d, versions must be contiguous", mig.Version, mig.Name, i+1)
+		}
+		if mig.Down == "" {
+			t.Errorf("%04d_%s has no down file", mig.Version, mig.Name)
+		}
+		if !strings.Contains(mig.Up, "{{schema}}.") {
+			t.Errorf("%04d_%s doesn't qualify its tables with {{schema}}", mig.Version, mig.Name)
+		}
+		for _, line := range strings.Split(mig.Up, "\n") {
+			if !strings.HasPrefix(line, "--") && strings.Contains(line, "schema_migrations") {
+				t.Errorf("%04d_%s touches the legacy schema_migrations table", mig.Version, mig.Name)
+			}
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	file := func(s string) *fstest.MapFile { return &fstest.MapFile{Data: []byte(s)} }
+	list, err := load(fstest.MapFS{
+		"migrations/0002_b.up.sql":   file("B"),
+		"migrations/0001_a.up.sql":   file("A"),
+		"migrations/0001_a.down.sql": file("-A"),
+	})
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if len(list) != 2 || list[0].Name != "a" || list[1].Name != "b" {
+		t.Fatalf("load: got %+v, want a then b", list)
+	}
+	if list[0].Down != "-A" || list[1].Down != "" {
+		t.Errorf("load: down files %q, %q", list[0].Down, list[1].Down)
+	}
+	// sha256("A")
+	if want := "559aead08264d5795d3909718cdd05abd49572e84fe55590eef31a88a08fdffd"; list[0].Checksum != want {
+		t.Errorf("checksum = %s, want %s", list[0].Checksum, want)
+	}
+
+	for name, fsys := range map[string]fstest.MapFS{
+		"unexpected file": {"migrations/README.md": file("")},
+		"no up file":      {"migrations/0001_a.down.sql": file("")},
+		"renamed":         {"migrations/0001_a.up.sql": file(""), "migrations/0001_b.down.sql": file("")},
+	} {
+		if _, err := load(fsys); err == nil {
+			t.Errorf("%s: load succeeded", name)
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	m := &Migrator{migrations: []Migration{{Version: 1, Name: "a", Checksum: "x"}, {Version: 2, Name: "b", Checksum: "y"}}}
+	if err := m.verify(map[int64]applied{1: {name: "a", checksum: "x", appliedAt: time.Now()}}); err != nil {
+		t.Errorf("verify: %v", err)
+	}
+	// Migrations applied by a newer build aren't this one's to check.
+	if err := m.verify(map[int64]applied{3: {name: "c", checksum: "z"}}); err != nil {
+		t.Errorf("verify: %v", err)
+	}
+	if err := m.verify(map[int64]applied{2: {name: "b", checksum: "edited"}}); !errors.Is(err, ErrChecksum) {
+		t.Errorf("verify: got %v, want ErrChecksum", err)
+	}
+}
+
+func TestNewSchemaName(t *testing.T) {
+	for schema, ok := range map[string]bool{"": true, "gateway": true, "gw_2": true, "Gateway": false, "gw;drop": false, "2gw": false} {
+		m, err := New(nil, schema)
+		if (err == nil) != ok {
+			t.Errorf("New(%q): %v", schema, err)
+		}
+		if schema == "" && m != nil && m.schema != "public" {
+			t.Errorf(`New(""): schema %q, want public`, m.schema)
+		}
+	}
+}
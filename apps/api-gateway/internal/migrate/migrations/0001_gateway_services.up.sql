@@ -0,0 +1,19 @@
+-- Services onboarded through the Admin API. gateway_migrations supersedes the schema_migrations
+-- table of the former inline migrations, which is left alone so an older build rolled back to
+-- still finds its history.
+
+CREATE TABLE IF NOT EXISTS {{schema}}.gateway_services (
+  id UUID PRIMARY KEY,
+  name TEXT NOT NULL,
+  description TEXT,
+  public_prefix TEXT NOT NULL UNIQUE,
+  base_url TEXT NOT NULL,
+  swagger_url TEXT NOT NULL,
+  enabled BOOLEAN NOT NULL DEFAULT TRUE,
+  swagger_json JSONB,
+  last_refreshed_at TIMESTAMPTZ,
+  last_health_at TIMESTAMPTZ,
+  last_status TEXT,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS {{schema}}.gateway_routes;
+ALTER TABLE {{schema}}.gateway_services DROP COLUMN IF EXISTS grpc_target;
+ALTER TABLE {{schema}}.gateway_services DROP COLUMN IF EXISTS protocol;
@@ -0,0 +1,15 @@
+-- gRPC upstreams and the HTTP routes of grpc-json services.
+ALTER TABLE {{schema}}.gateway_services ADD COLUMN IF NOT EXISTS protocol TEXT NOT NULL DEFAULT 'http';
+ALTER TABLE {{schema}}.gateway_services ADD COLUMN IF NOT EXISTS grpc_target TEXT;
+
+CREATE TABLE IF NOT EXISTS {{schema}}.gateway_routes (
+  id UUID PRIMARY KEY,
+  service_id UUID NOT NULL REFERENCES {{schema}}.gateway_services(id) ON DELETE CASCADE,
+  method TEXT NOT NULL,
+  path_pattern TEXT NOT NULL,
+  grpc_method TEXT NOT NULL,
+  query_mapping JSONB,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  UNIQUE(service_id, method, path_pattern)
+);
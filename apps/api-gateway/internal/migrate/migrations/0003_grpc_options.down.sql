@@ -0,0 +1,12 @@
+DROP TABLE IF EXISTS {{schema}}.gateway_descriptor_sets;
+
+ALTER TABLE {{schema}}.gateway_routes DROP COLUMN IF EXISTS strict_query;
+ALTER TABLE {{schema}}.gateway_routes DROP COLUMN IF EXISTS params_override_body;
+ALTER TABLE {{schema}}.gateway_routes DROP COLUMN IF EXISTS source;
+ALTER TABLE {{schema}}.gateway_routes DROP COLUMN IF EXISTS response_body;
+ALTER TABLE {{schema}}.gateway_routes DROP COLUMN IF EXISTS body;
+
+ALTER TABLE {{schema}}.gateway_services DROP COLUMN IF EXISTS streaming_policy;
+ALTER TABLE {{schema}}.gateway_services DROP COLUMN IF EXISTS upstream_tls;
+ALTER TABLE {{schema}}.gateway_services DROP COLUMN IF EXISTS metadata_policy;
+ALTER TABLE {{schema}}.gateway_services DROP COLUMN IF EXISTS descriptor_source;
@@ -0,0 +1,22 @@
+-- Descriptor sources, metadata and streaming policies, encrypted upstream TLS settings,
+-- google.api.http style route options and uploaded FileDescriptorSets.
+ALTER TABLE {{schema}}.gateway_services ADD COLUMN IF NOT EXISTS descriptor_source TEXT NOT NULL DEFAULT 'reflection';
+ALTER TABLE {{schema}}.gateway_services ADD COLUMN IF NOT EXISTS metadata_policy JSONB;
+ALTER TABLE {{schema}}.gateway_services ADD COLUMN IF NOT EXISTS upstream_tls BYTEA;
+ALTER TABLE {{schema}}.gateway_services ADD COLUMN IF NOT EXISTS streaming_policy JSONB;
+
+ALTER TABLE {{schema}}.gateway_routes ADD COLUMN IF NOT EXISTS body TEXT NOT NULL DEFAULT '';
+ALTER TABLE {{schema}}.gateway_routes ADD COLUMN IF NOT EXISTS response_body TEXT NOT NULL DEFAULT '';
+ALTER TABLE {{schema}}.gateway_routes ADD COLUMN IF NOT EXISTS source TEXT NOT NULL DEFAULT 'manual';
+ALTER TABLE {{schema}}.gateway_routes ADD COLUMN IF NOT EXISTS params_override_body BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE {{schema}}.gateway_routes ADD COLUMN IF NOT EXISTS strict_query BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE TABLE IF NOT EXISTS {{schema}}.gateway_descriptor_sets (
+  service_id UUID NOT NULL REFERENCES {{schema}}.gateway_services(id) ON DELETE CASCADE,
+  version INTEGER NOT NULL,
+  sha256 TEXT NOT NULL,
+  services JSONB,
+  data BYTEA NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  PRIMARY KEY(service_id, version)
+);
@@ -0,0 +1,3 @@
+ALTER TABLE {{schema}}.gateway_routes DROP COLUMN IF EXISTS version;
+ALTER TABLE {{schema}}.gateway_services DROP COLUMN IF EXISTS version;
+ALTER TABLE {{schema}}.gateway_services DROP COLUMN IF EXISTS labels;
@@ -0,0 +1,4 @@
+-- Service labels and the optimistic-locking versions of services and routes.
+ALTER TABLE {{schema}}.gateway_services ADD COLUMN IF NOT EXISTS labels JSONB;
+ALTER TABLE {{schema}}.gateway_services ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 1;
+ALTER TABLE {{schema}}.gateway_routes ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 1;
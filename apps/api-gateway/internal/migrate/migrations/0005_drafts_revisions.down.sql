@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS {{schema}}.gateway_revisions;
+DROP TABLE IF EXISTS {{schema}}.gateway_drafts;
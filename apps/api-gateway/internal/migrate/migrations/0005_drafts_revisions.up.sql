@@ -0,0 +1,23 @@
+-- Drafts of staged changes and published revisions of the registry.
+CREATE TABLE IF NOT EXISTS {{schema}}.gateway_drafts (
+  id UUID PRIMARY KEY,
+  name TEXT NOT NULL,
+  description TEXT NOT NULL DEFAULT '',
+  base TEXT NOT NULL,
+  snapshot JSONB NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  version BIGINT NOT NULL DEFAULT 1
+);
+
+CREATE TABLE IF NOT EXISTS {{schema}}.gateway_revisions (
+  number BIGINT PRIMARY KEY,
+  source TEXT NOT NULL,
+  message TEXT NOT NULL DEFAULT '',
+  draft_id TEXT NOT NULL DEFAULT '',
+  rollback_of BIGINT NOT NULL DEFAULT 0,
+  fingerprint TEXT NOT NULL,
+  changes JSONB NOT NULL,
+  snapshot JSONB NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
@@ -0,0 +1,10 @@
+-- Fails while two namespaces share a prefix; delete or move those services first.
+ALTER TABLE {{schema}}.gateway_revisions DROP COLUMN IF EXISTS namespace;
+ALTER TABLE {{schema}}.gateway_drafts DROP COLUMN IF EXISTS namespace;
+ALTER TABLE {{schema}}.gateway_routes DROP COLUMN IF EXISTS namespace;
+
+DROP INDEX IF EXISTS {{schema}}.gateway_services_namespace_public_prefix_key;
+ALTER TABLE {{schema}}.gateway_services DROP COLUMN IF EXISTS namespace;
+ALTER TABLE {{schema}}.gateway_services ADD CONSTRAINT gateway_services_public_prefix_key UNIQUE (public_prefix);
+
+DROP TABLE IF EXISTS {{schema}}.gateway_namespaces;
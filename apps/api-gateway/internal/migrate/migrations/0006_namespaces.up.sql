@@ -0,0 +1,19 @@
+-- Namespaces; the default one always exists. Prefixes are unique per namespace.
+CREATE TABLE IF NOT EXISTS {{schema}}.gateway_namespaces (
+  name TEXT PRIMARY KEY,
+  description TEXT NOT NULL DEFAULT '',
+  hosts JSONB NOT NULL DEFAULT '[]',
+  members JSONB NOT NULL DEFAULT '[]',
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  version BIGINT NOT NULL DEFAULT 1
+);
+INSERT INTO {{schema}}.gateway_namespaces (name) VALUES ('default') ON CONFLICT DO NOTHING;
+
+ALTER TABLE {{schema}}.gateway_services ADD COLUMN IF NOT EXISTS namespace TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE {{schema}}.gateway_services DROP CONSTRAINT IF EXISTS gateway_services_public_prefix_key;
+CREATE UNIQUE INDEX IF NOT EXISTS gateway_services_namespace_public_prefix_key ON {{schema}}.gateway_services (namespace, public_prefix);
+
+ALTER TABLE {{schema}}.gateway_routes ADD COLUMN IF NOT EXISTS namespace TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE {{schema}}.gateway_drafts ADD COLUMN IF NOT EXISTS namespace TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE {{schema}}.gateway_revisions ADD COLUMN IF NOT EXISTS namespace TEXT NOT NULL DEFAULT 'default';
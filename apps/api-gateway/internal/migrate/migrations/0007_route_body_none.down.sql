@@ -0,0 +1,11 @@
+UPDATE {{schema}}.gateway_routes SET body = 'none' WHERE body = '-';
+
+UPDATE {{schema}}.gateway_drafts SET snapshot = jsonb_set(snapshot, '{routes}', (
+  SELECT jsonb_agg(CASE WHEN rt->>'body' = '-' THEN jsonb_set(rt, '{body}', '"none"') ELSE rt END ORDER BY i)
+  FROM jsonb_array_elements(snapshot->'routes') WITH ORDINALITY AS r(rt, i)
+)) WHERE snapshot->'routes' @> '[{"body": "-"}]';
+
+UPDATE {{schema}}.gateway_revisions SET snapshot = jsonb_set(snapshot, '{routes}', (
+  SELECT jsonb_agg(CASE WHEN rt->>'body' = '-' THEN jsonb_set(rt, '{body}', '"none"') ELSE rt END ORDER BY i)
+  FROM jsonb_array_elements(snapshot->'routes') WITH ORDINALITY AS r(rt, i)
+)) WHERE snapshot->'routes' @> '[{"body": "-"}]';
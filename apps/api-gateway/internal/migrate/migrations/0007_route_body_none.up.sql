@@ -0,0 +1,14 @@
+-- Routes that ignore the request body used to store body 'none', which is also a valid field
+-- name. They now store '-'. Draft and revision snapshots are rewritten too, so publishing or
+-- rolling back to them keeps the old meaning.
+UPDATE {{schema}}.gateway_routes SET body = '-' WHERE body = 'none';
+
+UPDATE {{schema}}.gateway_drafts SET snapshot = jsonb_set(snapshot, '{routes}', (
+  SELECT jsonb_agg(CASE WHEN rt->>'body' = 'none' THEN jsonb_set(rt, '{body}', '"-"') ELSE rt END ORDER BY i)
+  FROM jsonb_array_elements(snapshot->'routes') WITH ORDINALITY AS r(rt, i)
+)) WHERE snapshot->'routes' @> '[{"body": "none"}]';
+
+UPDATE {{schema}}.gateway_revisions SET snapshot = jsonb_set(snapshot, '{routes}', (
+  SELECT jsonb_agg(CASE WHEN rt->>'body' = 'none' THEN jsonb_set(rt, '{body}', '"-"') ELSE rt END ORDER BY i)
+  FROM jsonb_array_elements(snapshot->'routes') WITH ORDINALITY AS r(rt, i)
+)) WHERE snapshot->'routes' @> '[{"body": "none"}]';
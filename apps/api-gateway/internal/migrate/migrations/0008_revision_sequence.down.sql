@@ -0,0 +1,2 @@
+ALTER TABLE {{schema}}.gateway_revisions ALTER COLUMN number DROP DEFAULT;
+DROP SEQUENCE IF EXISTS {{schema}}.gateway_revisions_number_seq;
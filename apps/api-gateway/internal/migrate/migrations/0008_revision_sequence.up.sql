@@ -0,0 +1,5 @@
+-- Revision numbers come from a sequence instead of MAX(number) + 1, so concurrent publishes,
+-- in the same or different namespaces, never pick the same number.
+CREATE SEQUENCE IF NOT EXISTS {{schema}}.gateway_revisions_number_seq OWNED BY {{schema}}.gateway_revisions.number;
+SELECT setval('{{schema}}.gateway_revisions_number_seq', COALESCE((SELECT MAX(number) FROM {{schema}}.gateway_revisions), 0) + 1, false);
+ALTER TABLE {{schema}}.gateway_revisions ALTER COLUMN number SET DEFAULT nextval('{{schema}}.gateway_revisions_number_seq');
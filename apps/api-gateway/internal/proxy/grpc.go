@@ -0,0 +1,448 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+
+	"ecomm/api-gateway/internal/registry"
+	"ecomm/api-gateway/internal/upstream"
+	"ecomm/api-gateway/internal/util"
+)
+
+const (
+	grpcContentType        = "application/grpc"
+	grpcWebContentType     = "application/grpc-web"
+	grpcWebTextContentType = "application/grpc-web-text"
+	// maxGRPCWebTextBytes bounds base64 grpc-web-text request bodies, which must be buffered to decode.
+	maxGRPCWebTextBytes = 8 << 20
+)
+
+// isGRPCProtocol reports whether services with protocol p are proxied as gRPC frames.
+func isGRPCProtocol(p string) bool {
+	p = strings.ToLower(p)
+	return p == "grpc" || p == "grpc-web"
+}
+
+// GRPC intercepts gRPC (HTTP/2, h2c or TLS) and gRPC-Web requests for services with protocol
+// "grpc" or "grpc-web" and passes everything else to next.
+//
+// The service is found by longest-prefix match on the request path within the request's
+// namespace, as for HTTP services, and the last two path segments are forwarded as the full
+// method name. Native clients, which can't add a path prefix, use a public_prefix like
+// `/catalog.v1.CatalogService/`; gRPC-Web clients may also point at a prefixed host such as
+// `https://gateway/api/catalog`.
+func GRPC(reg *registry.Registry, tlsm *upstream.TLSManager, next http.Handler) http.Handler {
+	p := &grpcProxy{tlsm: tlsm, transports: map[string]*grpcTransport{}, h2c: newH2Transport(true)}
+	reg.OnLoad(func() { p.prune(reg) })
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The gateway's own endpoints are never handed to a service, whatever its prefix.
+		if !isGRPCRequest(r) || registry.IsReservedPath(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		svc, _, ok := match(reg, r)
+		if !ok || svc == nil || !svc.Enabled || !isGRPCProtocol(svc.Protocol) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		p.serve(w, withoutNamespaceHeader(r), svc)
+	})
+}
+
+// isGRPCRequest recognizes gRPC and gRPC-Web calls and gRPC-Web CORS preflights.
+func isGRPCRequest(r *http.Request) bool {
+	if r.Method == http.MethodOptions {
+		return strings.Contains(strings.ToLower(r.Header.Get("Access-Control-Request-Headers")), "x-grpc-web")
+	}
+	return strings.HasPrefix(r.Header.Get("Content-Type"), grpcContentType)
+}
+
+type grpcTransport struct {
+	cfg *tls.Config
+	t   *http.Transport
+}
+
+type grpcProxy struct {
+	tlsm *upstream.TLSManager
+	h2c  *http.Transport
+
+	mu         sync.Mutex
+	transports map[string]*grpcTransport // by service ID, for TLS upstreams
+}
+
+func (p *grpcProxy) serve(w http.ResponseWriter, r *http.Request, svc *registry.Service) {
+	web := strings.ToLower(svc.Protocol) == "grpc-web"
+	ct := r.Header.Get("Content-Type")
+	isWeb := strings.HasPrefix(ct, grpcWebContentType)
+	if r.Method == http.MethodOptions {
+		if !web {
+			writeGRPCError(w, grpcWebContentType, codes.Unimplemented, "gRPC-Web requires protocol=grpc-web")
+			return
+		}
+		grpcWebPreflight(w, r, svc)
+		return
+	}
+	if isWeb && !web {
+		writeGRPCError(w, ct, codes.Unimplemented, "gRPC-Web requires protocol=grpc-web")
+		return
+	}
+	respCT := ct
+	if isWeb {
+		respCT = grpcWebContentType + contentSubtype(ct)
+		if strings.HasPrefix(ct, grpcWebTextContentType) {
+			respCT = grpcWebTextContentType + contentSubtype(ct)
+		}
+		if !setGRPCWebCORS(w, r, svc) {
+			writeGRPCError(w, respCT, codes.PermissionDenied, "origin not allowed")
+			return
+		}
+	}
+	if r.Method != http.MethodPost {
+		writeGRPCError(w, respCT, codes.Unimplemented, "gRPC requires POST")
+		return
+	}
+	method, ok := grpcMethodPath(r.URL.Path)
+	if !ok {
+		writeGRPCError(w, respCT, codes.Unimplemented, "malformed method name "+r.URL.Path)
+		return
+	}
+	if svc.GRPCTarget == "" {
+		writeGRPCError(w, respCT, codes.Unavailable, "service has no grpc_target configured")
+		return
+	}
+	rt, scheme, err := p.transport(r.Context(), svc)
+	if err != nil {
+		// The cause can hold repository errors and certificate paths; keep it in the log.
+		log.Printf("proxy: upstream tls for service %s: %v", svc.ID, err)
+		writeGRPCError(w, respCT, codes.Unavailable, "upstream tls is not available")
+		return
+	}
+	host := strings.TrimPrefix(svc.GRPCTarget, "dns:///")
+	if isWeb {
+		p.serveWeb(w, r, rt, scheme+"://"+host+method, respCT)
+		return
+	}
+	rp := &httputil.ReverseProxy{
+		Director: func(req *http.Request) {
+			req.URL.Scheme = scheme
+			req.URL.Host = host
+			req.URL.Path = method
+			req.URL.RawPath = ""
+			req.Host = host
+		},
+		Transport: rt,
+		// Stream frames as they arrive instead of buffering.
+		FlushInterval: -1,
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			log.Printf("proxy: grpc upstream %s: %v", host, err)
+			writeGRPCError(w, ct, codes.Unavailable, "upstream unavailable")
+		},
+	}
+	rp.ServeHTTP(w, r)
+}
+
+// serveWeb translates one gRPC-Web call into an upstream gRPC call. The message framing is
+// identical; only the content type, the optional base64 "-text" encoding and the trailers
+// (which gRPC-Web sends as a final body frame) differ.
+func (p *grpcProxy) serveWeb(w http.ResponseWriter, r *http.Request, rt http.RoundTripper, target, respCT string) {
+	text := strings.HasPrefix(respCT, grpcWebTextContentType)
+	var body io.Reader = r.Body
+	if text {
+		raw, err := io.ReadAll(io.LimitReader(r.Body, maxGRPCWebTextBytes+1))
+		if err != nil || len(raw) > maxGRPCWebTextBytes {
+			writeGRPCError(w, respCT, codes.ResourceExhausted, "grpc-web-text request too large")
+			return
+		}
+		dec, err := decodeWebText(raw)
+		if err != nil {
+			writeGRPCError(w, respCT, codes.InvalidArgument, "invalid grpc-web-text body: "+err.Error())
+			return
+		}
+		body = bytes.NewReader(dec)
+	}
+	out, err := http.NewRequestWithContext(r.Context(), http.MethodPost, target, body)
+	if err != nil {
+		log.Printf("proxy: grpc-web upstream %s: %v", target, err)
+		writeGRPCError(w, respCT, codes.Internal, "invalid upstream target")
+		return
+	}
+	for k, vs := range r.Header {
+		if grpcWebDropHeaders[k] {
+			continue
+		}
+		out.Header[k] = vs
+	}
+	out.Header.Set("Content-Type", grpcContentType+contentSubtype(respCT))
+	out.Header.Set("Te", "trailers")
+
+	resp, err := rt.RoundTrip(out)
+	if err != nil {
+		log.Printf("proxy: grpc-web upstream %s: %v", target, err)
+		writeGRPCError(w, respCT, codes.Unavailable, "upstream unavailable")
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		writeGRPCError(w, respCT, codes.Unavailable, fmt.Sprintf("upstream returned HTTP %d", resp.StatusCode))
+		return
+	}
+
+	h := w.Header()
+	exposed := []string{"grpc-status", "grpc-message", "grpc-status-details-bin"}
+	for k, vs := range resp.Header {
+		if grpcWebDropHeaders[k] || k == "Trailer" {
+			continue
+		}
+		h[k] = vs
+		exposed = append(exposed, strings.ToLower(k))
+	}
+	h.Set("Content-Type", respCT)
+	if h.Get("Access-Control-Allow-Origin") != "" {
+		h.Set("Access-Control-Expose-Headers", strings.Join(exposed, ", "))
+	}
+	w.WriteHeader(http.StatusOK)
+
+	rc := http.NewResponseController(w)
+	write := func(b []byte) {
+		if text {
+			b = []byte(base64.StdEncoding.EncodeToString(b))
+		}
+		_, _ = w.Write(b)
+		_ = rc.Flush()
+	}
+	buf := make([]byte, 32<<10)
+	for {
+		n, err := resp.Body.Read(buf)
+		if n > 0 {
+			write(buf[:n])
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log.Printf("proxy: grpc-web upstream %s: %v", target, err)
+			write(webTrailerFrame(http.Header{
+				"Grpc-Status":  {strconv.Itoa(int(codes.Unavailable))},
+				"Grpc-Message": {"upstream stream broken"},
+			}))
+			return
+		}
+	}
+	if len(resp.Trailer) == 0 && resp.Header.Get("Grpc-Status") != "" {
+		// Trailers-only response: the status already went out with the headers.
+		return
+	}
+	tr := resp.Trailer
+	if tr.Get("Grpc-Status") == "" {
+		tr = http.Header{
+			"Grpc-Status":  {strconv.Itoa(int(codes.Internal))},
+			"Grpc-Message": {"upstream closed the stream without a status"},
+		}
+	}
+	write(webTrailerFrame(tr))
+}
+
+// transport returns an HTTP/2-only RoundTripper for svc and the URL scheme to use with it:
+// h2c for plaintext upstreams, TLS with the service's upstream settings otherwise.
+func (p *grpcProxy) transport(ctx context.Context, svc *registry.Service) (http.RoundTripper, string, error) {
+	var cfg *tls.Config
+	if p.tlsm != nil {
+		var err error
+		if cfg, err = p.tlsm.Config(ctx, svc); err != nil {
+			return nil, "", err
+		}
+	}
+	if cfg == nil {
+		return p.h2c, "http", nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	gt := p.transports[svc.ID]
+	if gt != nil && gt.cfg == cfg {
+		return gt.t, "https", nil
+	}
+	// First use, or the TLS material was replaced or reloaded since.
+	if gt != nil {
+		gt.t.CloseIdleConnections()
+	}
+	t := newH2Transport(false)
+	t.TLSClientConfig = cfg.Clone()
+	p.transports[svc.ID] = &grpcTransport{cfg: cfg, t: t}
+	return t, "https", nil
+}
+
+// prune closes and drops the transports of services reg no longer serves.
+func (p *grpcProxy) prune(reg *registry.Registry) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for id, gt := range p.transports {
+		if reg.Service(id) == nil {
+			gt.t.CloseIdleConnections()
+			delete(p.transports, id)
+		}
+	}
+}
+
+func newH2Transport(unencrypted bool) *http.Transport {
+	t := &http.Transport{
+		Proxy:               nil,
+		ForceAttemptHTTP2:   true,
+		IdleConnTimeout:     5 * time.Minute,
+		TLSHandshakeTimeout: 10 * time.Second,
+		Protocols:           new(http.Protocols),
+	}
+	if unencrypted {
+		t.Protocols.SetUnencryptedHTTP2(true)
+	} else {
+		t.Protocols.SetHTTP2(true)
+	}
+	return t
+}
+
+// grpcMethodPath returns "/<service>/<method>" from the last two segments of path.
+func grpcMethodPath(path string) (string, bool) {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) < 2 {
+		return "", false
+	}
+	svc, method := parts[len(parts)-2], parts[len(parts)-1]
+	if svc == "" || method == "" {
+		return "", false
+	}
+	return "/" + svc + "/" + method, true
+}
+
+// contentSubtype returns the codec suffix of a gRPC content type, e.g. "+proto".
+func contentSubtype(ct string) string {
+	if i := strings.IndexByte(ct, '+'); i >= 0 {
+		return ct[i:]
+	}
+	return ""
+}
+
+// grpcWebDropHeaders are not forwarded between the browser and the upstream in either direction.
+var grpcWebDropHeaders = map[string]bool{
+	"Content-Type": true, "Content-Length": true, "Connection": true, "Keep-Alive": true,
+	"Te": true, "Transfer-Encoding": true, "Upgrade": true, "Host": true,
+	"X-Grpc-Web": true, "X-User-Agent": true, "Origin": true, "Referer": true,
+	"Accept": true, "Accept-Encoding": true,
+}
+
+// writeGRPCError sends a trailers-only response carrying code and msg.
+func writeGRPCError(w http.ResponseWriter, contentType string, code codes.Code, msg string) {
+	h := w.Header()
+	h.Set("Content-Type", contentType)
+	h.Set("Grpc-Status", strconv.Itoa(int(code)))
+	h.Set("Grpc-Message", encodeGRPCMessage(msg))
+	w.WriteHeader(http.StatusOK)
+}
+
+// encodeGRPCMessage percent-encodes msg as required for the grpc-message header.
+func encodeGRPCMessage(msg string) string {
+	var b strings.Builder
+	for i := 0; i < len(msg); i++ {
+		c := msg[i]
+		if c >= 0x20 && c <= 0x7e && c != '%' {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
+
+// webTrailerFrame encodes trailers as the final gRPC-Web body frame: flag 0x80, a 4-byte
+// length and HTTP/1-style "key: value" lines.
+func webTrailerFrame(tr http.Header) []byte {
+	keys := make([]string, 0, len(tr))
+	for k := range tr {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b bytes.Buffer
+	for _, k := range keys {
+		for _, v := range tr[k] {
+			b.WriteString(strings.ToLower(k) + ": " + v + "\r\n")
+		}
+	}
+	frame := make([]byte, 5, 5+b.Len())
+	frame[0] = 0x80
+	binary.BigEndian.PutUint32(frame[1:], uint32(b.Len()))
+	return append(frame, b.Bytes()...)
+}
+
+// decodeWebText decodes a grpc-web-text body. Clients may send several independently padded
+// base64 chunks back to back, so decoding restarts after each padding run.
+func decodeWebText(b []byte) ([]byte, error) {
+	b = bytes.Join(bytes.Fields(b), nil)
+	var out []byte
+	for len(b) > 0 {
+		n := len(b)
+		if i := bytes.IndexByte(b, '='); i >= 0 {
+			n = i
+			for n < len(b) && b[n] == '=' {
+				n++
+			}
+		}
+		seg, err := base64.StdEncoding.DecodeString(string(b[:n]))
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, seg...)
+		b = b[n:]
+	}
+	return out, nil
+}
+
+// setGRPCWebCORS allows browser clients on the origins in the service's
+// streaming.allowed_origins, or same-origin pages when none are listed (see util.OriginAllowed).
+// It reports whether the request's origin is allowed.
+func setGRPCWebCORS(w http.ResponseWriter, r *http.Request, svc *registry.Service) bool {
+	w.Header().Add("Vary", "Origin")
+	var allowed []string
+	if svc.Streaming != nil {
+		allowed = svc.Streaming.AllowedOrigins
+	}
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if !util.OriginAllowed(r, allowed) {
+		return false
+	}
+	w.Header().Set("Access-Control-Allow-Origin", origin)
+	return true
+}
+
+// grpcWebPreflight answers CORS preflights; disallowed origins get no CORS headers, which the
+// browser treats as a refusal.
+func grpcWebPreflight(w http.ResponseWriter, r *http.Request, svc *registry.Service) {
+	if !setGRPCWebCORS(w, r, svc) {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	h := w.Header()
+	h.Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+	allow := r.Header.Get("Access-Control-Request-Headers")
+	if allow == "" {
+		allow = "content-type, x-grpc-web, x-user-agent, grpc-timeout, authorization"
+	}
+	h.Set("Access-Control-Allow-Headers", allow)
+	h.Set("Access-Control-Max-Age", "600")
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,119 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+
+	"ecomm/api-gateway/internal/registry"
+	"ecomm/api-gateway/internal/upstream"
+)
+
+func TestGRPCLeavesReservedPathsAlone(t *testing.T) {
+	reg := registry.New()
+	// A row predating the onboarding check: its prefix covers every path.
+	reg.Set([]*registry.Service{{ID: "s1", PublicPrefix: "/", Protocol: "grpc", GRPCTarget: "127.0.0.1:1", Enabled: true}})
+	var reached bool
+	h := GRPC(reg, nil, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { reached = true }))
+	req := httptest.NewRequest(http.MethodPost, "/admin/v1/services", nil)
+	req.Header.Set("Content-Type", "application/grpc")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	if !reached {
+		t.Fatal("a gRPC service intercepted an admin path")
+	}
+}
+
+func TestGRPCWebPreflightOrigins(t *testing.T) {
+	reg := registry.New()
+	reg.Set([]*registry.Service{{
+		ID: "s1", PublicPrefix: "/catalog.v1.Catalog/", Protocol: "grpc-web", GRPCTarget: "127.0.0.1:1", Enabled: true,
+		Streaming: &registry.StreamingPolicy{AllowedOrigins: []string{"https://shop.example.com"}},
+	}})
+	h := GRPC(reg, nil, http.NotFoundHandler())
+	for origin, want := range map[string]string{
+		"https://shop.example.com": "https://shop.example.com",
+		"https://evil.example":     "",
+	} {
+		req := httptest.NewRequest(http.MethodOptions, "/catalog.v1.Catalog/Get", nil)
+		req.Header.Set("Origin", origin)
+		req.Header.Set("Access-Control-Request-Headers", "content-type,x-grpc-web")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if got := rec.Header().Get("Access-Control-Allow-Origin"); got != want {
+			t.Errorf("preflight from %s: Access-Control-Allow-Origin = %q, want %q", origin, got, want)
+		}
+	}
+}
+
+func TestGRPCProxyPrune(t *testing.T) {
+	reg := registry.New()
+	reg.Set([]*registry.Service{{ID: "s1", PublicPrefix: "/a.v1.A/", Protocol: "grpc", GRPCTarget: "127.0.0.1:1", Enabled: true}})
+	p := &grpcProxy{transports: map[string]*grpcTransport{
+		"s1": {t: &http.Transport{}},
+		"s2": {t: &http.Transport{}},
+	}}
+	p.prune(reg)
+	if p.transports["s1"] == nil || p.transports["s2"] != nil {
+		t.Fatalf("transports = %v, want only s1", p.transports)
+	}
+}
+
+// Upstream failures reach clients as gRPC statuses without the underlying cause.
+func TestGRPCErrorsHideCause(t *testing.T) {
+	ctx := context.Background()
+	repo := registry.NewMemoryRepository()
+	reg := registry.New()
+	services := []*registry.Service{
+		{ID: uuid.NewString(), Name: "plain", PublicPrefix: "/plain.v1.Plain/", Protocol: "grpc", GRPCTarget: "127.0.0.1:1", Enabled: true},
+		{ID: uuid.NewString(), Name: "web", PublicPrefix: "/web.v1.Web/", Protocol: "grpc-web", GRPCTarget: "127.0.0.1:1", Enabled: true},
+		{ID: uuid.NewString(), Name: "tls", PublicPrefix: "/tls.v1.Tls/", Protocol: "grpc", GRPCTarget: "127.0.0.1:1", Enabled: true},
+	}
+	for _, svc := range services {
+		if err := repo.Create(ctx, svc); err != nil {
+			t.Fatal(err)
+		}
+	}
+	reg.Set(services)
+	if err := repo.SaveUpstreamTLS(ctx, services[2].ID, &registry.UpstreamTLS{CAFile: "/etc/gateway/secret/missing-ca.pem"}); err != nil {
+		t.Fatal(err)
+	}
+	tlsm := upstream.NewTLSManager(repo, reg)
+	defer tlsm.Close()
+	h := GRPC(reg, tlsm, http.NotFoundHandler())
+
+	tests := []struct {
+		name, method, path, ctype string
+		code                      codes.Code
+		message                   string
+	}{
+		{"grpc-web preflight to a grpc service", http.MethodOptions, "/plain.v1.Plain/Get", "", codes.Unimplemented, "gRPC-Web requires protocol=grpc-web"},
+		{"grpc-web call to a grpc service", http.MethodPost, "/plain.v1.Plain/Get", grpcWebContentType, codes.Unimplemented, "gRPC-Web requires protocol=grpc-web"},
+		{"upstream tls", http.MethodPost, "/tls.v1.Tls/Get", grpcContentType, codes.Unavailable, "upstream tls is not available"},
+		{"grpc upstream down", http.MethodPost, "/plain.v1.Plain/Get", grpcContentType, codes.Unavailable, "upstream unavailable"},
+		{"grpc-web upstream down", http.MethodPost, "/web.v1.Web/Get", grpcWebContentType, codes.Unavailable, "upstream unavailable"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(""))
+			if tt.method == http.MethodOptions {
+				req.Header.Set("Access-Control-Request-Headers", "content-type,x-grpc-web")
+			} else {
+				req.Header.Set("Content-Type", tt.ctype)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if got := rec.Header().Get("Grpc-Status"); got != strconv.Itoa(int(tt.code)) {
+				t.Fatalf("grpc-status %q, want %d (body %q)", got, tt.code, rec.Body)
+			}
+			if got := rec.Header().Get("Grpc-Message"); got != tt.message {
+				t.Fatalf("grpc-message %q, want %q", got, tt.message)
+			}
+		})
+	}
+}
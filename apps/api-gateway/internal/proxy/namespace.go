@@ -0,0 +1,39 @@
+package proxy
+
+import (
+	"net/http"
+
+	"ecomm/api-gateway/internal/registry"
+)
+
+// NamespaceHeader names the request header that selects a namespace, overriding the host name
+// (see registry.Registry.NamespaceFor). It lets clients reach a sandbox without a DNS entry of
+// its own. It is off ("") by default: with it any client can route into any namespace.
+var NamespaceHeader = ""
+
+// match finds the service for r in the namespace selected by the namespace header or the
+// request host.
+func match(reg *registry.Registry, r *http.Request) (*registry.Service, string, bool) {
+	return reg.MatchIn(namespaceOf(reg, r), r.URL.Path)
+}
+
+func namespaceOf(reg *registry.Registry, r *http.Request) string {
+	if NamespaceHeader != "" {
+		if ns := r.Header.Get(NamespaceHeader); ns != "" {
+			return ns
+		}
+	}
+	return reg.NamespaceFor(r.Host)
+}
+
+// withoutNamespaceHeader returns r without NamespaceHeader, which is meant for the gateway and
+// must not reach upstreams.
+func withoutNamespaceHeader(r *http.Request) *http.Request {
+	if NamespaceHeader == "" || len(r.Header.Values(NamespaceHeader)) == 0 {
+		return r
+	}
+	r2 := r.WithContext(r.Context())
+	r2.Header = r.Header.Clone()
+	r2.Header.Del(NamespaceHeader)
+	return r2
+}
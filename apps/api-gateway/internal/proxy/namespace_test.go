@@ -0,0 +1,54 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"ecomm/api-gateway/internal/registry"
+)
+
+func TestNamespaceHeader(t *testing.T) {
+	var upstreamHeader []string
+	hit := map[string]int{}
+	upstream := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			hit[name]++
+			upstreamHeader = r.Header.Values("X-Gateway-Namespace")
+		}))
+	}
+	prod, sandbox := upstream("prod"), upstream("sandbox")
+	defer prod.Close()
+	defer sandbox.Close()
+	reg := registry.New()
+	reg.Set([]*registry.Service{
+		{ID: "p", PublicPrefix: "/api/users/", BaseURL: prod.URL, Enabled: true},
+		{ID: "s", Namespace: "sandbox", PublicPrefix: "/api/users/", BaseURL: sandbox.URL, Enabled: true},
+	})
+	serve := func() {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/api/users/1", nil)
+		req.Header.Set("X-Gateway-Namespace", "sandbox")
+		rec := httptest.NewRecorder()
+		Dynamic(reg, nil)(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+		}
+	}
+
+	// Off by default: clients can't pick a namespace the host doesn't select.
+	serve()
+	if hit["prod"] != 1 || hit["sandbox"] != 0 {
+		t.Fatalf("header disabled: upstream hits %v, want prod only", hit)
+	}
+
+	defer func(h string) { NamespaceHeader = h }(NamespaceHeader)
+	NamespaceHeader = "X-Gateway-Namespace"
+	serve()
+	if hit["sandbox"] != 1 {
+		t.Fatalf("header enabled: upstream hits %v, want sandbox", hit)
+	}
+	if len(upstreamHeader) != 0 {
+		t.Errorf("upstream received %s: %q", NamespaceHeader, upstreamHeader)
+	}
+}
@@ -1,45 +1,66 @@
 package proxy
 
 import (
+	"context"
+	"log"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
-	"strconv"
 	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
 
 	"ecomm/api-gateway/internal/grpcjson"
 	"ecomm/api-gateway/internal/registry"
+	"ecomm/api-gateway/internal/upstream"
 )
 
-// Dynamic returns an http.HandlerFunc that proxies requests based on the registry
-func Dynamic(reg *registry.Registry, repo registry.Repository) http.HandlerFunc {
+// Dynamic returns an http.HandlerFunc that proxies requests based on the registry, within the
+// namespace selected by the request host or NamespaceHeader. grpc-json routes come from the
+// registry's compiled route tables; the repository is never consulted. tlsm supplies
+// per-service upstream TLS; when nil, the default transport is used.
+func Dynamic(reg *registry.Registry, tlsm *upstream.TLSManager) http.HandlerFunc {
+	reg.OnLoad(func() { forgetRemoved(reg) })
 	return func(w http.ResponseWriter, r *http.Request) {
-		svc, remainder, ok := reg.Match(r.URL.Path)
+		svc, remainder, ok := match(reg, r)
 		if !ok || svc == nil || !svc.Enabled {
 			http.NotFound(w, r)
 			return
 		}
+		r = withoutNamespaceHeader(r)
+		// gRPC and gRPC-Web calls are intercepted by the GRPC middleware before reaching here.
+		if isGRPCProtocol(svc.Protocol) {
+			http.Error(w, "protocol="+svc.Protocol+" services only accept gRPC requests", http.StatusUnsupportedMediaType)
+			return
+		}
 		// If service requests HTTP→gRPC transcoding, route via JSON transcoder
 		if strings.ToLower(svc.Protocol) == "grpc-json" {
 			if svc.GRPCTarget == "" {
-				http.Error(w, "grpc target missing", http.StatusBadGateway)
+				grpcjson.Errorf(w, codes.Unavailable, "service has no grpc_target configured")
 				return
 			}
 			methodPath := strings.TrimPrefix(remainder, "/")
-			params := map[string]any{}
+			opts := grpcjson.Options{Params: map[string]any{}, Query: r.URL.Query()}
+			if mp := svc.Metadata; mp != nil {
+				opts.Metadata = grpcjson.MetadataOptions{ForwardHeaders: mp.ForwardHeaders, Allow: mp.Allow, Deny: mp.Deny}
+			}
+			if sp := svc.Streaming; sp != nil {
+				opts.AllowedOrigins = sp.AllowedOrigins
+			}
 			// If remainder isn't a direct gRPC method, consult route mappings with templating
 			if !strings.Contains(methodPath, "/") || !strings.Contains(methodPath, ".") {
-				if repo != nil {
-					if routes, err := repo.ListRoutes(r.Context(), svc.ID); err == nil {
-						if rt, pm := matchTemplatedRoute(routes, r.Method, remainder); rt != nil {
-							methodPath = strings.TrimPrefix(rt.GRPCMethod, "/")
-							params = pm
-							mergeQueryParams(params, r.URL, rt)
-						}
-					}
+				if rt, pm := reg.Routes(svc.ID).Match(r.Method, remainder); rt != nil {
+					methodPath = strings.TrimPrefix(rt.GRPCMethod, "/")
+					opts.Params = pm
+					opts.Body = rt.Body
+					opts.ResponseBody = rt.ResponseBody
+					opts.ParamsOverrideBody = rt.ParamsOverrideBody
+					opts.Query = mapQuery(r.URL.Query(), rt.QueryMapping)
+					opts.StrictQuery = rt.StrictQuery
 				}
 			}
-			grpcjson.ServeWithParams(svc.GRPCTarget, methodPath, params, w, r)
+			grpcjson.ServeWithOptions(svc.GRPCTarget, methodPath, opts, w, r)
 			return
 		}
 
@@ -48,6 +69,38 @@ func Dynamic(reg *registry.Registry, repo registry.Repository) http.HandlerFunc
 			http.Error(w, "bad upstream", http.StatusBadGateway)
 			return
 		}
+		sp := svc.Streaming
+		if sp == nil {
+			sp = &registry.StreamingPolicy{}
+		}
+		kind := connHTTP
+		if isUpgrade(r) {
+			if !sp.WebSocketAllowed() {
+				http.Error(w, "websocket upgrades are disabled for this service", http.StatusBadRequest)
+				return
+			}
+			kind = connWebSocket
+		}
+		conn, ok := Connections.open(svc.ID, kind, sp.MaxConnections)
+		if !ok {
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, "too many open connections to service", http.StatusServiceUnavailable)
+			return
+		}
+		defer conn.close()
+		if sp.MaxDurationSeconds > 0 {
+			// Cancelling the request context also tears down upgraded connections.
+			ctx, cancel := context.WithTimeout(r.Context(), time.Duration(sp.MaxDurationSeconds)*time.Second)
+			defer cancel()
+			r = r.WithContext(ctx)
+		}
+		tr, err := streamTransports.transport(r.Context(), svc, tlsm)
+		if err != nil {
+			// The cause can hold repository errors and certificate paths; keep it in the log.
+			log.Printf("proxy: upstream tls for service %s: %v", svc.ID, err)
+			http.Error(w, "bad upstream", http.StatusBadGateway)
+			return
+		}
 		director := func(req *http.Request) {
 			req.URL.Scheme = target.Scheme
 			req.URL.Host = target.Host
@@ -57,109 +110,37 @@ func Dynamic(reg *registry.Registry, repo registry.Repository) http.HandlerFunc
 			req.URL.RawPath = upPath
 			req.Host = target.Host
 		}
-		rp := &httputil.ReverseProxy{Director: director}
-		rp.ServeHTTP(w, r)
-	}
-}
-
-// matchTemplatedRoute tries to match method+path against a set of routes supporting
-// simple templates like /users/{id}. Returns the matched route and extracted params.
-func matchTemplatedRoute(routes []*registry.Route, method, path string) (*registry.Route, map[string]any) {
-	method = strings.ToUpper(method)
-	// Prefer longer patterns (more specific)
-	var best *registry.Route
-	var bestParams map[string]any
-	for _, rt := range routes {
-		if strings.ToUpper(rt.Method) != method {
-			continue
-		}
-		if pm, ok := matchPattern(rt.Path, path); ok {
-			if best == nil || len(rt.Path) > len(best.Path) {
-				best = rt
-				bestParams = pm
-			}
+		rp := &httputil.ReverseProxy{
+			Director:  director,
+			Transport: tr,
+			// Flush every write so SSE and chunked streams reach the client without buffering.
+			FlushInterval: -1,
+			ModifyResponse: func(res *http.Response) error {
+				switch {
+				case res.StatusCode == http.StatusSwitchingProtocols:
+					conn.setKind(connWebSocket)
+				case isStreamedResponse(res):
+					conn.setKind(connStream)
+				default:
+					conn.setKind(connHTTP)
+				}
+				return nil
+			},
 		}
+		rp.ServeHTTP(w, r)
 	}
-	return best, bestParams
 }
 
-// matchPattern checks if request path matches a template pattern and extracts params.
-// Pattern and path are absolute paths starting with '/'. Template segments like {name} match
-// a single path segment and are returned in params.
-func matchPattern(pattern, path string) (map[string]any, bool) {
-	p := strings.TrimSuffix(pattern, "/")
-	u := strings.TrimSuffix(path, "/")
-	if p == "" {
-		p = "/"
-	}
-	if u == "" {
-		u = "/"
-	}
-	ps := strings.Split(strings.TrimPrefix(p, "/"), "/")
-	us := strings.Split(strings.TrimPrefix(u, "/"), "/")
-	if len(ps) != len(us) {
-		return nil, false
-	}
-	params := map[string]any{}
-	for i := 0; i < len(ps); i++ {
-		segP := ps[i]
-		segU := us[i]
-		if len(segP) >= 2 && segP[0] == '{' && segP[len(segP)-1] == '}' {
-			spec := segP[1 : len(segP)-1]
-			key := spec
-			typ := "string"
-			if i := strings.Index(spec, ":"); i > 0 {
-				key = spec[:i]
-				typ = spec[i+1:]
-			}
-			if key == "" {
-				return nil, false
-			}
-			params[key] = coerceType(segU, typ)
+// mapQuery renames query parameters listed in mapping to their target field paths. Other
+// parameters keep their names, which are resolved as field paths by the transcoder.
+func mapQuery(q url.Values, mapping registry.RouteQueryMapping) url.Values {
+	for qp, entry := range mapping {
+		vals, ok := q[qp]
+		if !ok || entry.Field == "" || entry.Field == qp {
 			continue
 		}
-		if segP != segU {
-			return nil, false
-		}
-	}
-	return params, true
-}
-
-// mergeQueryParams maps query values to rpc fields using route.QueryMapping with type coercion
-func mergeQueryParams(params map[string]any, u *url.URL, rt *registry.Route) {
-	if rt == nil || rt.QueryMapping == nil || u == nil {
-		return
-	}
-	q := u.Query()
-	for qp, entry := range rt.QueryMapping {
-		if vals, ok := q[qp]; ok && len(vals) > 0 {
-			v := vals[0]
-			if entry.Field == "" {
-				continue
-			}
-			params[entry.Field] = coerceType(v, entry.Type)
-		}
-	}
-}
-
-func coerceType(v string, typ string) any {
-	switch strings.ToLower(typ) {
-	case "int", "integer":
-		if i, err := strconv.ParseInt(v, 10, 64); err == nil {
-			return i
-		}
-		return v
-	case "float", "double", "number":
-		if f, err := strconv.ParseFloat(v, 64); err == nil {
-			return f
-		}
-		return v
-	case "bool", "boolean":
-		if b, err := strconv.ParseBool(v); err == nil {
-			return b
-		}
-		return v
-	default:
-		return v
+		delete(q, qp)
+		q[entry.Field] = append(q[entry.Field], vals...)
 	}
+	return q
 }
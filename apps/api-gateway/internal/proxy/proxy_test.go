@@ -0,0 +1,20 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"ecomm/api-gateway/internal/registry"
+)
+
+func TestDynamicGRPCJSONWithoutTarget(t *testing.T) {
+	reg := registry.New()
+	reg.Set([]*registry.Service{{ID: "s1", PublicPrefix: "/api/users/", Protocol: "grpc-json", Enabled: true}})
+	rec := httptest.NewRecorder()
+	Dynamic(reg, nil)(rec, httptest.NewRequest(http.MethodGet, "/api/users/1", nil))
+	// A service without an upstream is a gateway misconfiguration, not a client error.
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusServiceUnavailable, rec.Body)
+	}
+}
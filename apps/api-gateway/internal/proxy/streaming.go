@@ -0,0 +1,285 @@
+package proxy
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"ecomm/api-gateway/internal/registry"
+	"ecomm/api-gateway/internal/upstream"
+)
+
+// Connection kinds reported by the open-connection metrics.
+const (
+	connHTTP      = "http"
+	connStream    = "stream"
+	connWebSocket = "websocket"
+)
+
+// Connections tracks proxied connections to http services.
+var Connections = newConnTracker()
+
+type serviceConns struct {
+	open     map[string]int
+	total    map[string]int64
+	rejected int64
+	// removed marks a service the registry no longer serves; it is dropped once its last
+	// connection closes.
+	removed bool
+}
+
+func (sc *serviceConns) openCount() int {
+	n := 0
+	for _, c := range sc.open {
+		n += c
+	}
+	return n
+}
+
+type connTracker struct {
+	mu  sync.Mutex
+	svc map[string]*serviceConns
+}
+
+func newConnTracker() *connTracker {
+	return &connTracker{svc: map[string]*serviceConns{}}
+}
+
+// trackedConn is one proxied request as seen by the tracker. Its kind is provisional until the
+// upstream response settles it (setKind): a plain request may turn out to be streamed, and an
+// upgrade the upstream refuses is plain http. The open gauge follows the kind; the total counter
+// counts each connection once, under the kind it settled on (or the provisional one if it
+// failed before a response), so it never goes down.
+type trackedConn struct {
+	t       *connTracker
+	svc     string
+	sc      *serviceConns
+	kind    string
+	counted bool
+	once    sync.Once
+}
+
+// open registers a connection of kind to serviceID. It fails when limit (>0) connections to
+// the service are already open.
+func (t *connTracker) open(serviceID, kind string, limit int) (*trackedConn, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	sc := t.svc[serviceID]
+	if sc == nil {
+		sc = &serviceConns{open: map[string]int{}, total: map[string]int64{}}
+		t.svc[serviceID] = sc
+	}
+	sc.removed = false
+	if limit > 0 && sc.openCount() >= limit {
+		sc.rejected++
+		return nil, false
+	}
+	sc.open[kind]++
+	return &trackedConn{t: t, svc: serviceID, sc: sc, kind: kind}, true
+}
+
+// prune drops the metrics of services keep rejects. Those with open connections are dropped
+// when the last one closes.
+func (t *connTracker) prune(keep func(serviceID string) bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for id, sc := range t.svc {
+		switch {
+		case keep(id):
+			sc.removed = false
+		case sc.openCount() == 0:
+			delete(t.svc, id)
+		default:
+			sc.removed = true
+		}
+	}
+}
+
+// setKind settles the connection's kind once the upstream response is known.
+func (c *trackedConn) setKind(kind string) {
+	c.t.mu.Lock()
+	defer c.t.mu.Unlock()
+	sc := c.sc
+	if c.kind != kind {
+		sc.open[c.kind]--
+		sc.open[kind]++
+		c.kind = kind
+	}
+	c.count(sc)
+}
+
+// count adds c to the total counter the first time it's called. Callers hold t.mu.
+func (c *trackedConn) count(sc *serviceConns) {
+	if !c.counted {
+		sc.total[c.kind]++
+		c.counted = true
+	}
+}
+
+func (c *trackedConn) close() {
+	c.once.Do(func() {
+		c.t.mu.Lock()
+		sc := c.sc
+		c.count(sc)
+		sc.open[c.kind]--
+		if sc.removed && sc.openCount() == 0 && c.t.svc[c.svc] == sc {
+			delete(c.t.svc, c.svc)
+		}
+		c.t.mu.Unlock()
+	})
+}
+
+// MetricsHandler serves connection metrics in the Prometheus text exposition format.
+func MetricsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		Connections.mu.Lock()
+		defer Connections.mu.Unlock()
+		ids := make([]string, 0, len(Connections.svc))
+		for id := range Connections.svc {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		kinds := []string{connHTTP, connStream, connWebSocket}
+		fmt.Fprintln(w, "# HELP gateway_open_connections Proxied connections currently open, by service and kind.")
+		fmt.Fprintln(w, "# TYPE gateway_open_connections gauge")
+		for _, id := range ids {
+			for _, k := range kinds {
+				fmt.Fprintf(w, "gateway_open_connections{service=%q,kind=%q} %d\n", id, k, Connections.svc[id].open[k])
+			}
+		}
+		fmt.Fprintln(w, "# HELP gateway_connections_total Proxied connections opened, by service and the kind they settled on.")
+		fmt.Fprintln(w, "# TYPE gateway_connections_total counter")
+		for _, id := range ids {
+			for _, k := range kinds {
+				fmt.Fprintf(w, "gateway_connections_total{service=%q,kind=%q} %d\n", id, k, Connections.svc[id].total[k])
+			}
+		}
+		fmt.Fprintln(w, "# HELP gateway_connections_rejected_total Connections refused by the per-service limit.")
+		fmt.Fprintln(w, "# TYPE gateway_connections_rejected_total counter")
+		for _, id := range ids {
+			fmt.Fprintf(w, "gateway_connections_rejected_total{service=%q} %d\n", id, Connections.svc[id].rejected)
+		}
+	}
+}
+
+// isUpgrade reports whether r asks to switch protocols (e.g. to WebSocket).
+func isUpgrade(r *http.Request) bool {
+	for _, v := range r.Header.Values("Connection") {
+		for _, tok := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(tok), "upgrade") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isStreamedResponse reports whether res is delivered incrementally (SSE or unknown length).
+func isStreamedResponse(res *http.Response) bool {
+	ct := res.Header.Get("Content-Type")
+	return strings.HasPrefix(ct, "text/event-stream") || res.ContentLength == -1
+}
+
+// idleConn extends its deadline on every read and write, so it fails only after idle
+// passes with no traffic in either direction.
+type idleConn struct {
+	net.Conn
+	idle time.Duration
+}
+
+func (c *idleConn) Read(b []byte) (int, error) {
+	_ = c.Conn.SetDeadline(time.Now().Add(c.idle))
+	return c.Conn.Read(b)
+}
+
+func (c *idleConn) Write(b []byte) (int, error) {
+	_ = c.Conn.SetDeadline(time.Now().Add(c.idle))
+	return c.Conn.Write(b)
+}
+
+type idleTransport struct {
+	cfg  *tls.Config
+	idle time.Duration
+	t    *http.Transport
+}
+
+// httpTransports caches transports for http services with an idle timeout; others use the
+// TLS manager's transport directly.
+type httpTransports struct {
+	mu   sync.Mutex
+	byID map[string]*idleTransport
+}
+
+var streamTransports = &httpTransports{byID: map[string]*idleTransport{}}
+
+// prune closes and drops the transports of services keep rejects.
+func (h *httpTransports) prune(keep func(serviceID string) bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for id, it := range h.byID {
+		if !keep(id) {
+			it.t.CloseIdleConnections()
+			delete(h.byID, id)
+		}
+	}
+}
+
+// forgetRemoved releases the connection metrics and transports of services reg no longer
+// serves, whether deleted, disabled or gone on a reload.
+func forgetRemoved(reg *registry.Registry) {
+	keep := func(id string) bool { return reg.Service(id) != nil }
+	Connections.prune(keep)
+	streamTransports.prune(keep)
+}
+
+// transport returns the RoundTripper for svc, honouring its upstream TLS settings and its
+// streaming idle timeout.
+func (h *httpTransports) transport(ctx context.Context, svc *registry.Service, tlsm *upstream.TLSManager) (http.RoundTripper, error) {
+	var idle time.Duration
+	if sp := svc.Streaming; sp != nil && sp.IdleTimeoutSeconds > 0 {
+		idle = time.Duration(sp.IdleTimeoutSeconds) * time.Second
+	}
+	if idle == 0 {
+		if tlsm == nil {
+			return http.DefaultTransport, nil
+		}
+		return tlsm.Transport(ctx, svc)
+	}
+	var cfg *tls.Config
+	if tlsm != nil {
+		var err error
+		if cfg, err = tlsm.Config(ctx, svc); err != nil {
+			return nil, err
+		}
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	it := h.byID[svc.ID]
+	if it != nil && it.cfg == cfg && it.idle == idle {
+		return it.t, nil
+	}
+	if it != nil {
+		it.t.CloseIdleConnections()
+	}
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg != nil {
+		t.TLSClientConfig = cfg
+	}
+	d := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	t.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		c, err := d.DialContext(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		return &idleConn{Conn: c, idle: idle}, nil
+	}
+	h.byID[svc.ID] = &idleTransport{cfg: cfg, idle: idle, t: t}
+	return t, nil
+}
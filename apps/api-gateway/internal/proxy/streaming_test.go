@@ -0,0 +1,130 @@
+package proxy
+
+import (
+	"context"
+	"testing"
+
+	"ecomm/api-gateway/internal/registry"
+)
+
+func TestConnTrackerCountsSettledKind(t *testing.T) {
+	tr := newConnTracker()
+	streamed, _ := tr.open("svc", connHTTP, 0)
+	streamed.setKind(connStream)
+	plain, _ := tr.open("svc", connHTTP, 0)
+	plain.setKind(connHTTP)
+	failed, _ := tr.open("svc", connWebSocket, 0) // upstream unreachable: no response
+
+	sc := tr.svc["svc"]
+	if sc.open[connHTTP] != 1 || sc.open[connStream] != 1 || sc.open[connWebSocket] != 1 {
+		t.Fatalf("open = %v, want one of each kind", sc.open)
+	}
+	for _, c := range []*trackedConn{streamed, plain, failed, failed} {
+		c.close()
+	}
+	want := map[string]int64{connHTTP: 1, connStream: 1, connWebSocket: 1}
+	for k, n := range want {
+		if sc.total[k] != n || sc.open[k] != 0 {
+			t.Errorf("%s: total %d open %d, want total %d open 0", k, sc.total[k], sc.open[k], n)
+		}
+	}
+}
+
+func TestConnTrackerLimit(t *testing.T) {
+	tr := newConnTracker()
+	c, ok := tr.open("svc", connHTTP, 1)
+	if !ok {
+		t.Fatal("first connection refused")
+	}
+	if _, ok := tr.open("svc", connWebSocket, 1); ok {
+		t.Fatal("second connection accepted over the limit")
+	}
+	c.close()
+	if _, ok := tr.open("svc", connWebSocket, 1); !ok {
+		t.Fatal("connection refused after the previous one closed")
+	}
+	if got := tr.svc["svc"].rejected; got != 1 {
+		t.Fatalf("rejected = %d, want 1", got)
+	}
+}
+
+func TestConnTrackerPrune(t *testing.T) {
+	tr := newConnTracker()
+	idle, _ := tr.open("idle", connHTTP, 0)
+	idle.close()
+	busy, _ := tr.open("busy", connStream, 0)
+	kept, _ := tr.open("kept", connHTTP, 0)
+	defer kept.close()
+
+	tr.prune(func(id string) bool { return id == "kept" })
+	if tr.svc["idle"] != nil {
+		t.Error("metrics of a removed idle service kept")
+	}
+	if tr.svc["busy"] == nil || tr.svc["kept"] == nil {
+		t.Fatal("metrics dropped while connections are open")
+	}
+	busy.setKind(connHTTP)
+	busy.close()
+	if tr.svc["busy"] != nil {
+		t.Error("metrics of a removed service kept after its last connection closed")
+	}
+
+	// A service re-added before its connections close keeps its metrics.
+	again, _ := tr.open("again", connHTTP, 0)
+	tr.prune(func(string) bool { return false })
+	c, _ := tr.open("again", connHTTP, 0)
+	again.close()
+	c.close()
+	if sc := tr.svc["again"]; sc == nil || sc.total[connHTTP] != 2 {
+		t.Fatalf("again = %+v, want its two connections counted", sc)
+	}
+}
+
+func TestRegistryLoadForgetsRemovedServices(t *testing.T) {
+	reg := registry.New()
+	gone := &registry.Service{ID: "prune-gone", PublicPrefix: "/gone/", BaseURL: "http://127.0.0.1:1", Enabled: true,
+		Streaming: &registry.StreamingPolicy{IdleTimeoutSeconds: 5}}
+	stays := &registry.Service{ID: "prune-stays", PublicPrefix: "/stays/", BaseURL: "http://127.0.0.1:1", Enabled: true,
+		Streaming: &registry.StreamingPolicy{IdleTimeoutSeconds: 5}}
+	reg.Set([]*registry.Service{gone, stays})
+	Dynamic(reg, nil)
+
+	for _, svc := range []*registry.Service{gone, stays} {
+		if _, err := streamTransports.transport(context.Background(), svc, nil); err != nil {
+			t.Fatal(err)
+		}
+		c, _ := Connections.open(svc.ID, connHTTP, 0)
+		c.close()
+	}
+	reg.Set([]*registry.Service{stays})
+
+	Connections.mu.Lock()
+	goneConns, staysConns := Connections.svc[gone.ID], Connections.svc[stays.ID]
+	Connections.mu.Unlock()
+	if goneConns != nil || staysConns == nil {
+		t.Errorf("connection metrics: gone %v stays %v, want only the remaining service", goneConns, staysConns)
+	}
+	streamTransports.mu.Lock()
+	goneTr, staysTr := streamTransports.byID[gone.ID], streamTransports.byID[stays.ID]
+	streamTransports.mu.Unlock()
+	if goneTr != nil || staysTr == nil {
+		t.Errorf("stream transports: gone %v stays %v, want only the remaining service", goneTr, staysTr)
+	}
+}
+
+func TestWebSocketAllowed(t *testing.T) {
+	no, yes := false, true
+	for _, tt := range []struct {
+		sp   *registry.StreamingPolicy
+		want bool
+	}{
+		{nil, true},
+		{&registry.StreamingPolicy{MaxConnections: 5}, true},
+		{&registry.StreamingPolicy{WebSocket: &yes}, true},
+		{&registry.StreamingPolicy{WebSocket: &no}, false},
+	} {
+		if got := tt.sp.WebSocketAllowed(); got != tt.want {
+			t.Errorf("WebSocketAllowed(%+v) = %v, want %v", tt.sp, got, tt.want)
+		}
+	}
+}
@@ -28,8 +28,6 @@ func NewCachingRepository(inner Repository, rdb *redis.Client, ttl time.Duration
 	return &CachingRepository{inner: inner, rdb: rdb, ttl: ttl}
 }
 
-func (c *CachingRepository) Init() error { return c.inner.Init() }
-
 // Route methods are delegated without caching for now
 func (c *CachingRepository) ListRoutes(ctx context.Context, serviceID string) ([]*Route, error) {
 	return c.inner.ListRoutes(ctx, serviceID)
@@ -43,13 +41,80 @@ func (c *CachingRepository) CreateRoute(ctx context.Context, r *Route) error {
 func (c *CachingRepository) UpdateRoute(ctx context.Context, r *Route) error {
 	return c.inner.UpdateRoute(ctx, r)
 }
-func (c *CachingRepository) DeleteRoute(ctx context.Context, serviceID, routeID string) error {
-	return c.inner.DeleteRoute(ctx, serviceID, routeID)
+func (c *CachingRepository) DeleteRoute(ctx context.Context, serviceID, routeID string, version int64) error {
+	return c.inner.DeleteRoute(ctx, serviceID, routeID, version)
 }
 func (c *CachingRepository) FindRoute(ctx context.Context, serviceID, method, path string) (*Route, error) {
 	return c.inner.FindRoute(ctx, serviceID, method, path)
 }
 
+// Descriptor set methods are delegated without caching; the transcoder caches parsed descriptors itself.
+func (c *CachingRepository) SaveDescriptorSet(ctx context.Context, ds *DescriptorSet) error {
+	return c.inner.SaveDescriptorSet(ctx, ds)
+}
+func (c *CachingRepository) GetDescriptorSet(ctx context.Context, serviceID string, version int) (*DescriptorSet, error) {
+	return c.inner.GetDescriptorSet(ctx, serviceID, version)
+}
+func (c *CachingRepository) ListDescriptorSets(ctx context.Context, serviceID string) ([]*DescriptorSet, error) {
+	return c.inner.ListDescriptorSets(ctx, serviceID)
+}
+
+// Upstream TLS settings are secrets and never written to Redis. Saving them touches the
+// service's UpdatedAt, so its entries are dropped.
+func (c *CachingRepository) GetUpstreamTLS(ctx context.Context, serviceID string) (*UpstreamTLS, error) {
+	return c.inner.GetUpstreamTLS(ctx, serviceID)
+}
+func (c *CachingRepository) SaveUpstreamTLS(ctx context.Context, serviceID string, t *UpstreamTLS) error {
+	if err := c.inner.SaveUpstreamTLS(ctx, serviceID, t); err != nil {
+		return err
+	}
+	c.invalidate(ctx, serviceID)
+	return nil
+}
+
+// Drafts and revisions are delegated without caching; they are only read by the Admin API.
+func (c *CachingRepository) CreateDraft(ctx context.Context, d *Draft) error {
+	return c.inner.CreateDraft(ctx, d)
+}
+func (c *CachingRepository) GetDraft(ctx context.Context, id string) (*Draft, error) {
+	return c.inner.GetDraft(ctx, id)
+}
+func (c *CachingRepository) ListDrafts(ctx context.Context) ([]*Draft, error) {
+	return c.inner.ListDrafts(ctx)
+}
+func (c *CachingRepository) UpdateDraft(ctx context.Context, d *Draft) error {
+	return c.inner.UpdateDraft(ctx, d)
+}
+func (c *CachingRepository) DeleteDraft(ctx context.Context, id string) error {
+	return c.inner.DeleteDraft(ctx, id)
+}
+func (c *CachingRepository) CreateRevision(ctx context.Context, rev *Revision) error {
+	return c.inner.CreateRevision(ctx, rev)
+}
+func (c *CachingRepository) GetRevision(ctx context.Context, number int64) (*Revision, error) {
+	return c.inner.GetRevision(ctx, number)
+}
+func (c *CachingRepository) ListRevisions(ctx context.Context) ([]*Revision, error) {
+	return c.inner.ListRevisions(ctx)
+}
+
+// Namespaces are small and read once per registry reload; they aren't cached either.
+func (c *CachingRepository) ListNamespaces(ctx context.Context) ([]*Namespace, error) {
+	return c.inner.ListNamespaces(ctx)
+}
+func (c *CachingRepository) GetNamespace(ctx context.Context, name string) (*Namespace, error) {
+	return c.inner.GetNamespace(ctx, name)
+}
+func (c *CachingRepository) CreateNamespace(ctx context.Context, ns *Namespace) error {
+	return c.inner.CreateNamespace(ctx, ns)
+}
+func (c *CachingRepository) UpdateNamespace(ctx context.Context, ns *Namespace) error {
+	return c.inner.UpdateNamespace(ctx, ns)
+}
+func (c *CachingRepository) DeleteNamespace(ctx context.Context, name string) error {
+	return c.inner.DeleteNamespace(ctx, name)
+}
+
 func (c *CachingRepository) LoadEnabled(ctx context.Context) ([]*Service, error) {
 	key := "gateway:services:enabled"
 	if bs, err := c.rdb.Get(ctx, key).Bytes(); err == nil {
@@ -120,14 +185,44 @@ func (c *CachingRepository) Update(ctx context.Context, s *Service) error {
 	return nil
 }
 
-func (c *CachingRepository) Delete(ctx context.Context, id string) error {
-	if err := c.inner.Delete(ctx, id); err != nil {
+// QueryServices is not cached; filtered pages are too varied to be worth it.
+func (c *CachingRepository) QueryServices(ctx context.Context, q ServiceQuery) (*ServicePage, error) {
+	return c.inner.QueryServices(ctx, q)
+}
+
+func (c *CachingRepository) UpdateHealth(ctx context.Context, id, status string, at time.Time) error {
+	if err := c.inner.UpdateHealth(ctx, id, status, at); err != nil {
 		return err
 	}
 	c.invalidate(ctx, id)
 	return nil
 }
 
+func (c *CachingRepository) Delete(ctx context.Context, id string, version int64) error {
+	if err := c.inner.Delete(ctx, id, version); err != nil {
+		return err
+	}
+	c.invalidate(ctx, id)
+	return nil
+}
+
+// InTx runs fn in a transaction of the inner repository, bypassing the cache so fn neither
+// reads stale entries nor caches uncommitted ones; afterwards every service entry is dropped.
+// If the inner repository isn't Transactional, fn runs against the cache without atomicity.
+func (c *CachingRepository) InTx(ctx context.Context, fn func(tx Repository) error) error {
+	t, ok := c.inner.(Transactional)
+	if !ok {
+		return fn(c)
+	}
+	err := t.InTx(ctx, fn)
+	c.invalidate(ctx, "")
+	iter := c.rdb.Scan(ctx, 0, "gateway:service:*", 100).Iterator()
+	for iter.Next(ctx) {
+		_ = c.rdb.Del(ctx, iter.Val()).Err()
+	}
+	return err
+}
+
 func (c *CachingRepository) invalidate(ctx context.Context, id string) {
 	_ = c.rdb.Del(ctx, "gateway:services:enabled").Err()
 	_ = c.rdb.Del(ctx, "gateway:services:list").Err()
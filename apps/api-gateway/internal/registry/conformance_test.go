@@ -0,0 +1,63 @@
+package registry_test
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/google/uuid"
+	_ "github.com/lib/pq"
+	"github.com/redis/go-redis/v9"
+
+	"ecomm/api-gateway/internal/migrate"
+	"ecomm/api-gateway/internal/registry"
+	"ecomm/api-gateway/internal/registry/registrytest"
+	"ecomm/api-gateway/internal/secrets"
+)
+
+func TestMemoryRepository(t *testing.T) {
+	registrytest.Run(t, func(t *testing.T) registry.Repository { return registry.NewMemoryRepository() })
+}
+
+// The cache must stay invisible to callers: every read after a write sees the write.
+func TestCachingRepository(t *testing.T) {
+	registrytest.Run(t, func(t *testing.T) registry.Repository {
+		rdb := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+		t.Cleanup(func() { rdb.Close() })
+		return registry.NewCachingRepository(registry.NewMemoryRepository(), rdb, time.Minute)
+	})
+}
+
+// TestSQLRepository runs against the Postgres of DATABASE_URL, each check in a schema of its own.
+func TestSQLRepository(t *testing.T) {
+	dsn := os.Getenv("DATABASE_URL")
+	if dsn == "" {
+		t.Skip("DATABASE_URL is not set")
+	}
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.Ping(); err != nil {
+		t.Fatalf("DATABASE_URL: %v", err)
+	}
+	key := make([]byte, 32)
+	rand.Read(key)
+	box, err := secrets.NewBox(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	registrytest.Run(t, func(t *testing.T) registry.Repository {
+		schema := "gateway_test_" + uuid.NewString()[:8]
+		t.Cleanup(func() { db.Exec(fmt.Sprintf("DROP SCHEMA IF EXISTS %s CASCADE", schema)) })
+		if err := migrate.Run(db, schema); err != nil {
+			t.Fatalf("migrate: %v", err)
+		}
+		return registry.NewSQLRepository(db, schema).WithSecretBox(box)
+	})
+}
@@ -0,0 +1,24 @@
+package registry
+
+import "time"
+
+// Descriptor sources for grpc-json services.
+const (
+	// DescriptorSourceReflection resolves descriptors via server reflection and falls back to the
+	// latest uploaded descriptor set when reflection is unavailable. This is the default.
+	DescriptorSourceReflection = "reflection"
+	// DescriptorSourceSet resolves descriptors only from the latest uploaded descriptor set.
+	DescriptorSourceSet = "descriptor_set"
+)
+
+// DescriptorSet is a versioned, operator-uploaded FileDescriptorSet for a grpc-json service.
+// Versions start at 1 and increase with every upload; the highest version is the active one.
+type DescriptorSet struct {
+	ServiceID string    `json:"service_id"`
+	Version   int       `json:"version"`
+	SHA256    string    `json:"sha256"`
+	Size      int       `json:"size"`
+	Services  []string  `json:"services"`
+	Data      []byte    `json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+}
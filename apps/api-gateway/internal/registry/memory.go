@@ -2,53 +2,767 @@ package registry
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 )
 
-// MemoryRepository is a non-persistent in-memory repo for dev
+// MemoryRepository is a non-persistent in-memory repository for development and file-only
+// mode. It mirrors SQLRepository's semantics: missing rows are sql.ErrNoRows, duplicate prefixes
+// and routes are ErrDuplicate, versions are checked and bumped, and deleting a service cascades
+// to its routes, descriptor sets and TLS settings. Values are copied in and out, so callers
+// never share state with the store, and it is safe for concurrent use. Package registrytest
+// checks all of this.
 type MemoryRepository struct {
-	items map[string]*Service
+	mu   sync.RWMutex
+	data memoryData
+	// txMu is held by InTx for its whole run and shared by plain writes, so writes wait for
+	// a transaction to commit instead of being lost when its copy is swapped in.
+	txMu sync.RWMutex
 }
 
-func NewMemoryRepository() *MemoryRepository { return &MemoryRepository{items: map[string]*Service{}} }
+type memoryData struct {
+	services    map[string]*Service
+	routes      map[string][]*Route // by service id
+	descriptors map[string][]*DescriptorSet
+	tls         map[string]*UpstreamTLS
+	drafts      map[string]*Draft
+	revisions   []*Revision // in number order
+	// lastRevision is the number of the latest revision, deleted or not.
+	lastRevision int64
+	namespaces   map[string]*Namespace
+}
 
-func (m *MemoryRepository) Init() error { return nil }
-func (m *MemoryRepository) LoadEnabled(ctx context.Context) ([]*Service, error) {
-	var list []*Service
-	for _, s := range m.items {
-		if s.Enabled {
-			list = append(list, s)
+func newMemoryData() memoryData {
+	now := time.Now()
+	return memoryData{
+		services:    map[string]*Service{},
+		routes:      map[string][]*Route{},
+		descriptors: map[string][]*DescriptorSet{},
+		tls:         map[string]*UpstreamTLS{},
+		drafts:      map[string]*Draft{},
+		namespaces:  map[string]*Namespace{DefaultNamespace: {Name: DefaultNamespace, CreatedAt: now, UpdatedAt: now, Version: 1}},
+	}
+}
+
+func (d memoryData) clone() memoryData {
+	c := newMemoryData()
+	for id, s := range d.services {
+		c.services[id] = copyService(s)
+	}
+	for id, list := range d.routes {
+		for _, rt := range list {
+			c.routes[id] = append(c.routes[id], copyRoute(rt))
 		}
 	}
-	return list, nil
+	for id, list := range d.descriptors {
+		for _, ds := range list {
+			cp := *ds
+			c.descriptors[id] = append(c.descriptors[id], &cp)
+		}
+	}
+	for id, t := range d.tls {
+		cp := *t
+		c.tls[id] = &cp
+	}
+	for id, dr := range d.drafts {
+		c.drafts[id] = copyDraft(dr)
+	}
+	for _, rev := range d.revisions {
+		c.revisions = append(c.revisions, copyRevision(rev))
+	}
+	c.lastRevision = d.lastRevision
+	for name, ns := range d.namespaces {
+		c.namespaces[name] = copyNamespace(ns)
+	}
+	return c
+}
+
+func NewMemoryRepository() *MemoryRepository { return &MemoryRepository{data: newMemoryData()} }
+
+// NewMemoryRepositoryFrom returns a repository holding the services and routes of snap as they
+// are, versions and timestamps included. Drafts are edited this way.
+func NewMemoryRepositoryFrom(snap *Snapshot) *MemoryRepository {
+	m := NewMemoryRepository()
+	for _, s := range snap.Services {
+		c := copyService(s)
+		if c.Namespace == "" { // snapshots taken before namespaces
+			c.Namespace = DefaultNamespace
+		}
+		m.data.services[s.ID] = c
+	}
+	for _, rt := range snap.Routes {
+		c := copyRoute(rt)
+		if svc := m.data.services[rt.ServiceID]; svc != nil {
+			c.Namespace = svc.Namespace
+		}
+		m.data.routes[rt.ServiceID] = append(m.data.routes[rt.ServiceID], c)
+	}
+	return m
+}
+
+// InTx runs fn against a copy of the store and swaps the copy in if fn succeeds. Writes to m
+// wait until then and reads see the state before fn, so fn must only use tx: writing to m from
+// fn deadlocks.
+func (m *MemoryRepository) InTx(ctx context.Context, fn func(tx Repository) error) error {
+	m.txMu.Lock()
+	defer m.txMu.Unlock()
+	m.mu.RLock()
+	tx := &MemoryRepository{data: m.data.clone()}
+	m.mu.RUnlock()
+	if err := fn(tx); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	m.data = tx.data
+	m.mu.Unlock()
+	return nil
+}
+
+// lock takes the locks of a plain write and returns their unlock.
+func (m *MemoryRepository) lock() func() {
+	m.txMu.RLock()
+	m.mu.Lock()
+	return func() {
+		m.mu.Unlock()
+		m.txMu.RUnlock()
+	}
 }
+
+// copyService deep-copies the fields callers may mutate in place.
+func copyService(s *Service) *Service {
+	c := *s
+	if s.Labels != nil {
+		c.Labels = make(map[string]string, len(s.Labels))
+		for k, v := range s.Labels {
+			c.Labels[k] = v
+		}
+	}
+	if s.Metadata != nil {
+		mp := *s.Metadata
+		c.Metadata = &mp
+	}
+	if s.Streaming != nil {
+		sp := *s.Streaming
+		sp.AllowedOrigins = slices.Clone(sp.AllowedOrigins)
+		if sp.WebSocket != nil {
+			ws := *sp.WebSocket
+			sp.WebSocket = &ws
+		}
+		c.Streaming = &sp
+	}
+	if s.SwaggerJSON != nil {
+		// round-trip like the JSONB column, so stored documents are never aliased
+		if b, err := json.Marshal(s.SwaggerJSON); err == nil {
+			var v any
+			_ = json.Unmarshal(b, &v)
+			c.SwaggerJSON = v
+		}
+	}
+	return &c
+}
+
+func copyRoute(rt *Route) *Route {
+	c := *rt
+	if rt.QueryMapping != nil {
+		c.QueryMapping = make(RouteQueryMapping, len(rt.QueryMapping))
+		for k, v := range rt.QueryMapping {
+			c.QueryMapping[k] = v
+		}
+	}
+	return &c
+}
+
+func copySnapshot(snap *Snapshot) *Snapshot {
+	if snap == nil {
+		return nil
+	}
+	c := &Snapshot{Services: make([]*Service, 0, len(snap.Services)), Routes: make([]*Route, 0, len(snap.Routes))}
+	for _, s := range snap.Services {
+		c.Services = append(c.Services, copyService(s))
+	}
+	for _, rt := range snap.Routes {
+		c.Routes = append(c.Routes, copyRoute(rt))
+	}
+	return c
+}
+
+func copyDraft(d *Draft) *Draft {
+	c := *d
+	c.Snapshot = copySnapshot(d.Snapshot)
+	return &c
+}
+
+func copyRevision(rev *Revision) *Revision {
+	c := *rev
+	c.Changes = append([]SnapshotChange{}, rev.Changes...)
+	c.Snapshot = copySnapshot(rev.Snapshot)
+	return &c
+}
+
+func (m *MemoryRepository) LoadEnabled(ctx context.Context) ([]*Service, error) {
+	page, err := m.QueryServices(ctx, ServiceQuery{Enabled: &[]bool{true}[0]})
+	if err != nil {
+		return nil, err
+	}
+	return page.Items, nil
+}
+
 func (m *MemoryRepository) List(ctx context.Context) ([]*Service, error) {
-	var list []*Service
-	for _, s := range m.items {
-		list = append(list, s)
+	page, err := m.QueryServices(ctx, ServiceQuery{})
+	if err != nil {
+		return nil, err
 	}
-	return list, nil
+	return page.Items, nil
+}
+
+// QueryServices filters, sorts and pages like SQLRepository.QueryServices, including its
+// cursors.
+func (m *MemoryRepository) QueryServices(ctx context.Context, q ServiceQuery) (*ServicePage, error) {
+	if err := q.Validate(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var after *pageCursor
+	if q.Cursor != "" {
+		c, _ := decodeCursor(q.Cursor)
+		after = &c
+	}
+	less := func(a, b *Service) bool {
+		va, vb := sortValue(a, q.Sort), sortValue(b, q.Sort)
+		if va != vb {
+			return va < vb
+		}
+		return a.ID < b.ID
+	}
+	var items []*Service
+	for _, s := range m.data.services {
+		if !matchesQuery(s, q) {
+			continue
+		}
+		if after != nil {
+			pos := &Service{ID: after.ID}
+			switch q.Sort {
+			case "name":
+				pos.Name = after.Value
+			case "public_prefix":
+				pos.PublicPrefix = after.Value
+			case "updated_at":
+				pos.UpdatedAt, _ = time.Parse(time.RFC3339Nano, after.Value)
+			default:
+				pos.CreatedAt, _ = time.Parse(time.RFC3339Nano, after.Value)
+			}
+			if (!q.Desc && !less(pos, s)) || (q.Desc && !less(s, pos)) {
+				continue
+			}
+		}
+		items = append(items, copyService(s))
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if q.Desc {
+			return less(items[j], items[i])
+		}
+		return less(items[i], items[j])
+	})
+	page := &ServicePage{Items: items}
+	if page.Items == nil {
+		page.Items = []*Service{}
+	}
+	if q.Limit > 0 && len(page.Items) > q.Limit {
+		page.Items = page.Items[:q.Limit]
+		last := page.Items[q.Limit-1]
+		page.NextCursor = encodeCursor(pageCursor{Sort: q.Sort, Desc: q.Desc, Value: sortValue(last, q.Sort), ID: last.ID})
+	}
+	return page, nil
 }
+
+func matchesQuery(s *Service, q ServiceQuery) bool {
+	if q.Namespace != "" && s.Namespace != q.Namespace {
+		return false
+	}
+	if q.Enabled != nil && s.Enabled != *q.Enabled {
+		return false
+	}
+	if q.Protocol != "" && s.Protocol != strings.ToLower(q.Protocol) {
+		return false
+	}
+	if q.GRPCTarget != "" && s.GRPCTarget != q.GRPCTarget {
+		return false
+	}
+	if q.Status != "" && !strings.EqualFold(s.LastStatus, q.Status) {
+		return false
+	}
+	if q.Search != "" {
+		needle := strings.ToLower(q.Search)
+		if !strings.Contains(strings.ToLower(s.Name), needle) && !strings.Contains(strings.ToLower(s.PublicPrefix), needle) {
+			return false
+		}
+	}
+	for k, v := range q.Labels {
+		if got, ok := s.Labels[k]; !ok || got != v {
+			return false
+		}
+	}
+	return true
+}
+
 func (m *MemoryRepository) Get(ctx context.Context, id string) (*Service, error) {
-	if s, ok := m.items[id]; ok {
-		return s, nil
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	s, ok := m.data.services[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return copyService(s), nil
+}
+
+// prefixTaken reports whether another service than s in its namespace uses its prefix.
+// Callers hold mu.
+func (m *MemoryRepository) prefixTaken(s *Service) bool {
+	for _, o := range m.data.services {
+		if o.ID != s.ID && o.Namespace == s.Namespace && o.PublicPrefix == s.PublicPrefix {
+			return true
+		}
 	}
-	return nil, sqlErrNotFound
+	return false
 }
+
 func (m *MemoryRepository) Create(ctx context.Context, s *Service) error {
-	m.items[s.ID] = s
+	defer m.lock()()
+	if _, ok := m.data.services[s.ID]; ok {
+		return ErrDuplicate
+	}
+	if s.Namespace == "" {
+		s.Namespace = DefaultNamespace
+	}
+	if m.prefixTaken(s) {
+		return fmt.Errorf("%w: public_prefix is already used by another service", ErrDuplicate)
+	}
+	now := time.Now()
+	s.CreatedAt, s.UpdatedAt, s.Version = now, now, 1
+	if s.Protocol == "" {
+		s.Protocol = "http"
+	}
+	m.data.services[s.ID] = copyService(s)
 	return nil
 }
+
 func (m *MemoryRepository) Update(ctx context.Context, s *Service) error {
-	m.items[s.ID] = s
+	defer m.lock()()
+	cur, ok := m.data.services[s.ID]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	if s.Version != 0 && s.Version != cur.Version {
+		return ErrVersionConflict
+	}
+	// the namespace is fixed at creation
+	s.Namespace = cur.Namespace
+	if m.prefixTaken(s) {
+		return fmt.Errorf("%w: public_prefix is already used by another service", ErrDuplicate)
+	}
+	next := copyService(s)
+	// like the UPDATE statement, leave health, refresh and creation state alone
+	next.CreatedAt, next.LastHealthAt, next.LastStatus, next.LastRefreshed = cur.CreatedAt, cur.LastHealthAt, cur.LastStatus, cur.LastRefreshed
+	next.UpdatedAt, next.Version = time.Now(), cur.Version+1
+	m.data.services[s.ID] = next
+	s.Version, s.UpdatedAt = next.Version, next.UpdatedAt
+	return nil
+}
+
+func (m *MemoryRepository) UpdateHealth(ctx context.Context, id, status string, at time.Time) error {
+	defer m.lock()()
+	if s, ok := m.data.services[id]; ok {
+		s.LastStatus, s.LastHealthAt = status, at
+	}
+	return nil
+}
+
+func (m *MemoryRepository) Delete(ctx context.Context, id string, version int64) error {
+	defer m.lock()()
+	if version != 0 {
+		s, ok := m.data.services[id]
+		if !ok {
+			return sql.ErrNoRows
+		}
+		if s.Version != version {
+			return ErrVersionConflict
+		}
+	}
+	delete(m.data.services, id)
+	delete(m.data.routes, id)
+	delete(m.data.descriptors, id)
+	delete(m.data.tls, id)
+	return nil
+}
+
+// --- Route methods ---
+
+func (m *MemoryRepository) ListRoutes(ctx context.Context, serviceID string) ([]*Route, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var list []*Route
+	for _, rt := range m.data.routes[serviceID] {
+		list = append(list, copyRoute(rt))
+	}
+	sort.SliceStable(list, func(i, j int) bool { return list[i].Path < list[j].Path })
+	return list, nil
+}
+
+func (m *MemoryRepository) GetRoute(ctx context.Context, serviceID, routeID string) (*Route, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if i := m.routeIndex(serviceID, routeID); i >= 0 {
+		return copyRoute(m.data.routes[serviceID][i]), nil
+	}
+	return nil, sql.ErrNoRows
+}
+
+// routeIndex returns the position of routeID among the service's routes, or -1. Callers hold mu.
+func (m *MemoryRepository) routeIndex(serviceID, routeID string) int {
+	for i, rt := range m.data.routes[serviceID] {
+		if rt.ID == routeID {
+			return i
+		}
+	}
+	return -1
+}
+
+// routeTaken reports whether another route than id of the service has method and path.
+// Callers hold mu.
+func (m *MemoryRepository) routeTaken(rt *Route) bool {
+	for _, o := range m.data.routes[rt.ServiceID] {
+		if o.ID != rt.ID && o.Method == strings.ToUpper(rt.Method) && o.Path == rt.Path {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *MemoryRepository) CreateRoute(ctx context.Context, rt *Route) error {
+	defer m.lock()()
+	svc, ok := m.data.services[rt.ServiceID]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	rt.Namespace = svc.Namespace
+	if m.routeIndex(rt.ServiceID, rt.ID) >= 0 {
+		return ErrDuplicate
+	}
+	if m.routeTaken(rt) {
+		return fmt.Errorf("%w: the service already has a route with this method and path", ErrDuplicate)
+	}
+	now := time.Now()
+	rt.Method, rt.Source = strings.ToUpper(rt.Method), routeSource(rt)
+	rt.CreatedAt, rt.UpdatedAt, rt.Version = now, now, 1
+	m.data.routes[rt.ServiceID] = append(m.data.routes[rt.ServiceID], copyRoute(rt))
+	return nil
+}
+
+func (m *MemoryRepository) UpdateRoute(ctx context.Context, rt *Route) error {
+	defer m.lock()()
+	i := m.routeIndex(rt.ServiceID, rt.ID)
+	if i < 0 {
+		return sql.ErrNoRows
+	}
+	cur := m.data.routes[rt.ServiceID][i]
+	if rt.Version != 0 && rt.Version != cur.Version {
+		return ErrVersionConflict
+	}
+	if m.routeTaken(rt) {
+		return fmt.Errorf("%w: the service already has a route with this method and path", ErrDuplicate)
+	}
+	rt.Namespace = cur.Namespace
+	next := copyRoute(rt)
+	next.Method, next.Source = strings.ToUpper(rt.Method), routeSource(rt)
+	next.CreatedAt, next.UpdatedAt, next.Version = cur.CreatedAt, time.Now(), cur.Version+1
+	m.data.routes[rt.ServiceID][i] = next
+	rt.Version, rt.UpdatedAt = next.Version, next.UpdatedAt
+	return nil
+}
+
+func (m *MemoryRepository) DeleteRoute(ctx context.Context, serviceID, routeID string, version int64) error {
+	defer m.lock()()
+	i := m.routeIndex(serviceID, routeID)
+	switch {
+	case i < 0 && version != 0:
+		return sql.ErrNoRows
+	case i < 0:
+		return nil
+	case version != 0 && m.data.routes[serviceID][i].Version != version:
+		return ErrVersionConflict
+	}
+	list := m.data.routes[serviceID]
+	m.data.routes[serviceID] = append(list[:i:i], list[i+1:]...)
+	return nil
+}
+
+func (m *MemoryRepository) FindRoute(ctx context.Context, serviceID, method, path string) (*Route, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, rt := range m.data.routes[serviceID] {
+		if rt.Method == strings.ToUpper(method) && rt.Path == path {
+			return copyRoute(rt), nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+// --- Descriptor set methods ---
+
+func (m *MemoryRepository) SaveDescriptorSet(ctx context.Context, ds *DescriptorSet) error {
+	defer m.lock()()
+	if _, ok := m.data.services[ds.ServiceID]; !ok {
+		return sql.ErrNoRows
+	}
+	list := m.data.descriptors[ds.ServiceID]
+	ds.Version, ds.CreatedAt = len(list)+1, time.Now()
+	cp := *ds
+	cp.Size = len(cp.Data)
+	m.data.descriptors[ds.ServiceID] = append(list, &cp)
+	return nil
+}
+
+func (m *MemoryRepository) GetDescriptorSet(ctx context.Context, serviceID string, version int) (*DescriptorSet, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	list := m.data.descriptors[serviceID]
+	for i := len(list) - 1; i >= 0; i-- {
+		if version == 0 || list[i].Version == version {
+			cp := *list[i]
+			return &cp, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (m *MemoryRepository) ListDescriptorSets(ctx context.Context, serviceID string) ([]*DescriptorSet, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	list := m.data.descriptors[serviceID]
+	out := make([]*DescriptorSet, 0, len(list))
+	for i := len(list) - 1; i >= 0; i-- {
+		cp := *list[i]
+		cp.Data = nil
+		out = append(out, &cp)
+	}
+	return out, nil
+}
+
+// --- Upstream TLS ---
+
+func (m *MemoryRepository) GetUpstreamTLS(ctx context.Context, serviceID string) (*UpstreamTLS, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if _, ok := m.data.services[serviceID]; !ok {
+		return nil, sql.ErrNoRows
+	}
+	t, ok := m.data.tls[serviceID]
+	if !ok {
+		return nil, nil
+	}
+	cp := *t
+	return &cp, nil
+}
+
+func (m *MemoryRepository) SaveUpstreamTLS(ctx context.Context, serviceID string, t *UpstreamTLS) error {
+	defer m.lock()()
+	s, ok := m.data.services[serviceID]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	if t == nil {
+		delete(m.data.tls, serviceID)
+	} else {
+		cp := *t
+		m.data.tls[serviceID] = &cp
+	}
+	s.UpdatedAt = time.Now()
+	return nil
+}
+
+// --- Drafts and revisions ---
+
+func (m *MemoryRepository) CreateDraft(ctx context.Context, d *Draft) error {
+	defer m.lock()()
+	if _, ok := m.data.drafts[d.ID]; ok {
+		return ErrDuplicate
+	}
+	if d.Namespace == "" {
+		d.Namespace = DefaultNamespace
+	}
+	now := time.Now()
+	d.CreatedAt, d.UpdatedAt, d.Version = now, now, 1
+	m.data.drafts[d.ID] = copyDraft(d)
 	return nil
 }
-func (m *MemoryRepository) Delete(ctx context.Context, id string) error {
-	delete(m.items, id)
+
+func (m *MemoryRepository) GetDraft(ctx context.Context, id string) (*Draft, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	d, ok := m.data.drafts[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return copyDraft(d), nil
+}
+
+func (m *MemoryRepository) ListDrafts(ctx context.Context) ([]*Draft, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	list := make([]*Draft, 0, len(m.data.drafts))
+	for _, d := range m.data.drafts {
+		c := *d
+		c.Snapshot = nil
+		list = append(list, &c)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if !list[i].CreatedAt.Equal(list[j].CreatedAt) {
+			return list[i].CreatedAt.Before(list[j].CreatedAt)
+		}
+		return list[i].ID < list[j].ID
+	})
+	return list, nil
+}
+
+func (m *MemoryRepository) UpdateDraft(ctx context.Context, d *Draft) error {
+	defer m.lock()()
+	cur, ok := m.data.drafts[d.ID]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	if d.Version != 0 && d.Version != cur.Version {
+		return ErrVersionConflict
+	}
+	d.Namespace = cur.Namespace
+	next := copyDraft(d)
+	next.CreatedAt, next.UpdatedAt, next.Version = cur.CreatedAt, time.Now(), cur.Version+1
+	m.data.drafts[d.ID] = next
+	d.Version, d.UpdatedAt = next.Version, next.UpdatedAt
+	return nil
+}
+
+func (m *MemoryRepository) DeleteDraft(ctx context.Context, id string) error {
+	defer m.lock()()
+	delete(m.data.drafts, id)
 	return nil
 }
 
-var sqlErrNotFound = &notFound{}
+func (m *MemoryRepository) CreateRevision(ctx context.Context, rev *Revision) error {
+	defer m.lock()()
+	if rev.Namespace == "" {
+		rev.Namespace = DefaultNamespace
+	}
+	m.data.lastRevision++
+	rev.Number, rev.CreatedAt = m.data.lastRevision, time.Now()
+	m.data.revisions = append(m.data.revisions, copyRevision(rev))
+	return nil
+}
+
+func (m *MemoryRepository) GetRevision(ctx context.Context, number int64) (*Revision, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	list := m.data.revisions
+	if number == 0 && len(list) > 0 {
+		return copyRevision(list[len(list)-1]), nil
+	}
+	i := sort.Search(len(list), func(i int) bool { return list[i].Number >= number })
+	if i == len(list) || list[i].Number != number {
+		return nil, sql.ErrNoRows
+	}
+	return copyRevision(list[i]), nil
+}
+
+func (m *MemoryRepository) ListRevisions(ctx context.Context) ([]*Revision, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	list := make([]*Revision, 0, len(m.data.revisions))
+	for i := len(m.data.revisions) - 1; i >= 0; i-- {
+		c := copyRevision(m.data.revisions[i])
+		c.Snapshot = nil
+		list = append(list, c)
+	}
+	return list, nil
+}
+
+// --- Namespaces ---
+
+func (m *MemoryRepository) ListNamespaces(ctx context.Context) ([]*Namespace, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	list := make([]*Namespace, 0, len(m.data.namespaces))
+	for _, ns := range m.data.namespaces {
+		list = append(list, copyNamespace(ns))
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list, nil
+}
+
+func (m *MemoryRepository) GetNamespace(ctx context.Context, name string) (*Namespace, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	ns, ok := m.data.namespaces[name]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	return copyNamespace(ns), nil
+}
 
-type notFound struct{}
+func (m *MemoryRepository) CreateNamespace(ctx context.Context, ns *Namespace) error {
+	defer m.lock()()
+	if _, ok := m.data.namespaces[ns.Name]; ok {
+		return fmt.Errorf("%w: namespace %s already exists", ErrDuplicate, ns.Name)
+	}
+	now := time.Now()
+	ns.CreatedAt, ns.UpdatedAt, ns.Version = now, now, 1
+	m.data.namespaces[ns.Name] = copyNamespace(ns)
+	return nil
+}
 
-func (n *notFound) Error() string { return "not found" }
+func (m *MemoryRepository) UpdateNamespace(ctx context.Context, ns *Namespace) error {
+	defer m.lock()()
+	cur, ok := m.data.namespaces[ns.Name]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	if ns.Version != 0 && ns.Version != cur.Version {
+		return ErrVersionConflict
+	}
+	next := copyNamespace(ns)
+	next.CreatedAt, next.UpdatedAt, next.Version = cur.CreatedAt, time.Now(), cur.Version+1
+	m.data.namespaces[ns.Name] = next
+	ns.Version, ns.UpdatedAt = next.Version, next.UpdatedAt
+	return nil
+}
+
+func (m *MemoryRepository) DeleteNamespace(ctx context.Context, name string) error {
+	if name == DefaultNamespace {
+		return errDeleteDefaultNamespace
+	}
+	defer m.lock()()
+	for _, s := range m.data.services {
+		if s.Namespace == name {
+			return ErrNamespaceNotEmpty
+		}
+	}
+	for id, d := range m.data.drafts {
+		if d.Namespace == name {
+			delete(m.data.drafts, id)
+		}
+	}
+	revisions := m.data.revisions[:0:0]
+	for _, rev := range m.data.revisions {
+		if rev.Namespace != name {
+			revisions = append(revisions, rev)
+		}
+	}
+	m.data.revisions = revisions
+	delete(m.data.namespaces, name)
+	return nil
+}
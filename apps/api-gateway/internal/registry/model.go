@@ -11,29 +11,84 @@ import "time"
 // `LastHealthAt` so operators can see operational state in the Admin UI.
 //
 // Field notes:
-// - `PublicPrefix`: used by runtime routing (longest-prefix match). Trailing slashes are normalized.
-// - `BaseURL`: runtime target used by the reverse proxy. If omitted at create time, the gateway
-//    attempts to infer it from the OpenAPI `servers` definition when onboarding.
-// - `SwaggerURL` / `SwaggerJSON`: the persisted OpenAPI document used for validation and documentation.
-// - `Enabled`: controls whether a service receives proxied traffic.
-// - Timestamps: `CreatedAt`, `UpdatedAt`, `LastRefreshed`, and `LastHealthAt` help operators track
-//    lifecycle and health events.
+//   - `Namespace`: the isolated route table the service belongs to (see Namespace); fixed at creation.
+//   - `PublicPrefix`: used by runtime routing (longest-prefix match within the namespace). Trailing
+//     slashes are normalized.
+//   - `BaseURL`: runtime target used by the reverse proxy. If omitted at create time, the gateway
+//     attempts to infer it from the OpenAPI `servers` definition when onboarding.
+//   - `SwaggerURL` / `SwaggerJSON`: the persisted OpenAPI document used for validation and documentation.
+//   - `Enabled`: controls whether a service receives proxied traffic.
+//   - `Version`: optimistic concurrency token; health probes don't change it.
+//   - Timestamps: `CreatedAt`, `UpdatedAt`, `LastRefreshed`, and `LastHealthAt` help operators track
+//     lifecycle and health events.
 type Service struct {
 	ID           string `json:"id" example:"3d1a7e94-0a2f-4a49-9a9b-8f9f2d0c6f67"`
+	Namespace    string `json:"namespace" example:"default"`
 	Name         string `json:"name" example:"User Service"`
 	Description  string `json:"description,omitempty" example:"Manages users"`
 	PublicPrefix string `json:"public_prefix" example:"/api/users/"`
 	BaseURL      string `json:"base_url" example:"http://user-service:8081"`
 	SwaggerURL   string `json:"swagger_url" example:"http://user-service:8081/swagger.json"`
-	// Protocol decides how the gateway forwards requests: "http" (default) or "grpc-json" (HTTP→gRPC transcoding).
+	// Protocol decides how the gateway forwards requests: "http" (default), "grpc-json" (HTTP→gRPC
+	// transcoding), "grpc" (native gRPC passthrough) or "grpc-web" (gRPC-Web and native gRPC).
+	// For grpc and grpc-web, PublicPrefix is matched against the gRPC method path, e.g.
+	// `/catalog.v1.CatalogService/`.
 	Protocol string `json:"protocol,omitempty" example:"http"`
-	// GRPCTarget is host:port of the upstream gRPC service when Protocol is "grpc-json".
-	GRPCTarget    string    `json:"grpc_target,omitempty" example:"user-service:9090"`
-	Enabled       bool      `json:"enabled" example:"true"`
-	SwaggerJSON   any       `json:"swagger_json,omitempty"`
-	LastRefreshed time.Time `json:"last_refreshed_at,omitempty" example:"2025-11-22T10:20:30Z"`
-	LastHealthAt  time.Time `json:"last_health_at,omitempty" example:"2025-11-22T10:20:00Z"`
-	LastStatus    string    `json:"last_status,omitempty" example:"Healthy"`
-	CreatedAt     time.Time `json:"created_at" example:"2025-11-22T10:00:00Z"`
-	UpdatedAt     time.Time `json:"updated_at" example:"2025-11-22T10:10:00Z"`
+	// GRPCTarget is host:port of the upstream gRPC service for the grpc* protocols.
+	GRPCTarget string `json:"grpc_target,omitempty" example:"user-service:9090"`
+	// DescriptorSource is "reflection" (default; uploaded descriptor set as fallback) or
+	// "descriptor_set" (uploaded set only) for grpc-json services.
+	DescriptorSource string `json:"descriptor_source,omitempty" example:"reflection"`
+	// Metadata controls how HTTP headers map to gRPC metadata for grpc-json services.
+	Metadata *MetadataPolicy `json:"metadata,omitempty"`
+	// Streaming governs WebSocket upgrades and streamed responses for http services.
+	Streaming *StreamingPolicy `json:"streaming,omitempty"`
+	// Labels are free-form key/value tags operators can filter services by.
+	Labels        map[string]string `json:"labels,omitempty" example:"team:catalog"`
+	Enabled       bool              `json:"enabled" example:"true"`
+	SwaggerJSON   any               `json:"swagger_json,omitempty"`
+	LastRefreshed time.Time         `json:"last_refreshed_at,omitempty" example:"2025-11-22T10:20:30Z"`
+	LastHealthAt  time.Time         `json:"last_health_at,omitempty" example:"2025-11-22T10:20:00Z"`
+	LastStatus    string            `json:"last_status,omitempty" example:"Healthy"`
+	CreatedAt     time.Time         `json:"created_at" example:"2025-11-22T10:00:00Z"`
+	UpdatedAt     time.Time         `json:"updated_at" example:"2025-11-22T10:10:00Z"`
+	// Version increases on every configuration change and is exposed as the ETag. Updates
+	// carrying a non-zero Version only apply if it is still current.
+	Version int64 `json:"version" example:"3"`
+}
+
+// MetadataPolicy configures header <-> gRPC metadata mapping for a grpc-json service.
+//
+// Authorization and all `Grpc-Metadata-*` request headers are always candidates for forwarding;
+// ForwardHeaders adds further request headers. Upstream response headers and trailers are
+// returned as `Grpc-Metadata-*` headers. Allow (when non-empty) and Deny filter metadata keys
+// in both directions; Deny wins.
+type MetadataPolicy struct {
+	ForwardHeaders []string `json:"forward_headers,omitempty" example:"X-Request-Id"`
+	Allow          []string `json:"allow,omitempty"`
+	Deny           []string `json:"deny,omitempty" example:"cookie"`
+}
+
+// StreamingPolicy configures long-lived connections to an http service: WebSocket upgrades and
+// streamed responses (SSE, chunked). Streamed responses are always flushed to the client as
+// they arrive. WebSocket upgrades are passed through unless WebSocket is explicitly false.
+//
+// AllowedOrigins lists the browser origins ("https://app.example.com", or "*" for any) that may
+// open WebSockets to grpc-json streaming methods or call a grpc-web service cross-origin; when
+// empty only same-origin pages may. It is the only setting that applies to grpc-web.
+//
+// IdleTimeoutSeconds closes upstream connections with no traffic in either direction for that
+// long, MaxDurationSeconds caps the lifetime of any proxied request, and MaxConnections limits
+// concurrent proxied requests (long-lived or not) to the service. Zero means unlimited.
+type StreamingPolicy struct {
+	WebSocket          *bool    `json:"websocket,omitempty" example:"true"`
+	IdleTimeoutSeconds int      `json:"idle_timeout_seconds,omitempty" example:"60"`
+	MaxDurationSeconds int      `json:"max_duration_seconds,omitempty" example:"3600"`
+	MaxConnections     int      `json:"max_connections,omitempty" example:"500"`
+	AllowedOrigins     []string `json:"allowed_origins,omitempty" example:"https://shop.example.com"`
+}
+
+// WebSocketAllowed reports whether WebSocket upgrades may be proxied; nil policies allow them.
+func (sp *StreamingPolicy) WebSocketAllowed() bool {
+	return sp == nil || sp.WebSocket == nil || *sp.WebSocket
 }
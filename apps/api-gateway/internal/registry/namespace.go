@@ -0,0 +1,415 @@
+package registry
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// DefaultNamespace holds the services of gateways that don't use namespaces, and receives
+// requests no host or header assigns to another namespace. It always exists.
+const DefaultNamespace = "default"
+
+// Namespace roles, from least to most privileged. Viewers read a namespace's services, routes,
+// drafts and revisions; editors also change them and promote services into the namespace;
+// admins also manage the namespace's members.
+const (
+	RoleViewer = "viewer"
+	RoleEditor = "editor"
+	RoleAdmin  = "admin"
+)
+
+// RoleRank orders roles; unknown roles rank 0.
+func RoleRank(role string) int {
+	switch role {
+	case RoleViewer:
+		return 1
+	case RoleEditor:
+		return 2
+	case RoleAdmin:
+		return 3
+	}
+	return 0
+}
+
+// Namespace is an isolated route table: its services' prefixes only have to be unique within
+// it, and requests reach it by host name or by the namespace header (see Registry.NamespaceFor).
+// Environments (dev, staging) and per-developer sandboxes share one gateway this way.
+type Namespace struct {
+	Name        string `json:"name" example:"staging"`
+	Description string `json:"description,omitempty" example:"Pre-production"`
+	// Hosts are the request host names routed to the namespace, without port. A leading "*."
+	// matches any subdomain, e.g. "*.sandbox.example.com".
+	Hosts []string `json:"hosts,omitempty" example:"staging.api.example.com"`
+	// Members grant roles in the namespace.
+	Members   []Member  `json:"members,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// Version increases on every change, like Service.Version.
+	Version int64 `json:"version"`
+}
+
+// Member grants Role to Subject: a token subject ("alice") or, prefixed with "group:", every
+// subject of a group claim ("group:platform").
+type Member struct {
+	Subject string `json:"subject" example:"group:platform"`
+	Role    string `json:"role" example:"editor"`
+}
+
+var namespaceName = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]{0,61}[a-z0-9])?$`)
+
+// ValidNamespaceName reports whether name is a DNS label: lowercase letters, digits and
+// hyphens, at most 63 characters.
+func ValidNamespaceName(name string) bool { return namespaceName.MatchString(name) }
+
+// ErrNamespaceNotEmpty is returned when deleting a namespace that still has services.
+var ErrNamespaceNotEmpty = errors.New("the namespace still has services")
+
+var errDeleteDefaultNamespace = errors.New("registry: the default namespace cannot be deleted")
+
+func copyNamespace(ns *Namespace) *Namespace {
+	c := *ns
+	c.Hosts = append([]string(nil), ns.Hosts...)
+	c.Members = append([]Member(nil), ns.Members...)
+	return &c
+}
+
+// InNamespace returns a view of repo limited to one namespace: listings only contain its
+// services, services of other namespaces read as missing (sql.ErrNoRows), and services created
+// through it belong to it. Routes, descriptor sets and TLS settings are reachable only through
+// the namespace's services, and drafts and revisions are those of the namespace. Namespaces
+// themselves are not scoped. Every Admin API handler works on such a view.
+func InNamespace(repo Repository, ns string) Repository {
+	if v, ok := repo.(*namespaceRepo); ok {
+		repo = v.inner
+	}
+	return &namespaceRepo{inner: repo, ns: ns}
+}
+
+// Unscoped returns the repository a namespace view was made from, or repo itself.
+func Unscoped(repo Repository) Repository {
+	if v, ok := repo.(*namespaceRepo); ok {
+		return v.inner
+	}
+	return repo
+}
+
+type namespaceRepo struct {
+	inner Repository
+	ns    string
+}
+
+func (v *namespaceRepo) InTx(ctx context.Context, fn func(tx Repository) error) error {
+	t, ok := v.inner.(Transactional)
+	if !ok {
+		return fn(v)
+	}
+	return t.InTx(ctx, func(tx Repository) error { return fn(&namespaceRepo{inner: tx, ns: v.ns}) })
+}
+
+// owns returns sql.ErrNoRows unless the service exists in the namespace.
+func (v *namespaceRepo) owns(ctx context.Context, serviceID string) error {
+	_, err := v.Get(ctx, serviceID)
+	return err
+}
+
+func (v *namespaceRepo) filter(list []*Service) []*Service {
+	out := make([]*Service, 0, len(list))
+	for _, s := range list {
+		if s.Namespace == v.ns {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func (v *namespaceRepo) LoadEnabled(ctx context.Context) ([]*Service, error) {
+	list, err := v.inner.LoadEnabled(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return v.filter(list), nil
+}
+
+func (v *namespaceRepo) List(ctx context.Context) ([]*Service, error) {
+	list, err := v.inner.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return v.filter(list), nil
+}
+
+func (v *namespaceRepo) QueryServices(ctx context.Context, q ServiceQuery) (*ServicePage, error) {
+	q.Namespace = v.ns
+	return v.inner.QueryServices(ctx, q)
+}
+
+func (v *namespaceRepo) Get(ctx context.Context, id string) (*Service, error) {
+	s, err := v.inner.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if s.Namespace != v.ns {
+		return nil, sql.ErrNoRows
+	}
+	return s, nil
+}
+
+func (v *namespaceRepo) Create(ctx context.Context, s *Service) error {
+	s.Namespace = v.ns
+	return v.inner.Create(ctx, s)
+}
+
+func (v *namespaceRepo) Update(ctx context.Context, s *Service) error {
+	if err := v.owns(ctx, s.ID); err != nil {
+		return err
+	}
+	s.Namespace = v.ns
+	return v.inner.Update(ctx, s)
+}
+
+func (v *namespaceRepo) UpdateHealth(ctx context.Context, id, status string, at time.Time) error {
+	if err := v.owns(ctx, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil // probes of deleted services are ignored, as in the repositories
+		}
+		return err
+	}
+	return v.inner.UpdateHealth(ctx, id, status, at)
+}
+
+func (v *namespaceRepo) Delete(ctx context.Context, id string, version int64) error {
+	if err := v.owns(ctx, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) && version == 0 {
+			return nil // deleting a missing service is a no-op, as in the repositories
+		}
+		return err
+	}
+	return v.inner.Delete(ctx, id, version)
+}
+
+func (v *namespaceRepo) ListRoutes(ctx context.Context, serviceID string) ([]*Route, error) {
+	if err := v.owns(ctx, serviceID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil // a missing service has no routes, as in the repositories
+		}
+		return nil, err
+	}
+	return v.inner.ListRoutes(ctx, serviceID)
+}
+
+func (v *namespaceRepo) GetRoute(ctx context.Context, serviceID, routeID string) (*Route, error) {
+	if err := v.owns(ctx, serviceID); err != nil {
+		return nil, err
+	}
+	return v.inner.GetRoute(ctx, serviceID, routeID)
+}
+
+func (v *namespaceRepo) CreateRoute(ctx context.Context, r *Route) error {
+	if err := v.owns(ctx, r.ServiceID); err != nil {
+		return err
+	}
+	r.Namespace = v.ns
+	return v.inner.CreateRoute(ctx, r)
+}
+
+func (v *namespaceRepo) UpdateRoute(ctx context.Context, r *Route) error {
+	if err := v.owns(ctx, r.ServiceID); err != nil {
+		return err
+	}
+	r.Namespace = v.ns
+	return v.inner.UpdateRoute(ctx, r)
+}
+
+func (v *namespaceRepo) DeleteRoute(ctx context.Context, serviceID, routeID string, version int64) error {
+	if err := v.owns(ctx, serviceID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) && version == 0 {
+			return nil
+		}
+		return err
+	}
+	return v.inner.DeleteRoute(ctx, serviceID, routeID, version)
+}
+
+func (v *namespaceRepo) FindRoute(ctx context.Context, serviceID, method, path string) (*Route, error) {
+	if err := v.owns(ctx, serviceID); err != nil {
+		return nil, err
+	}
+	return v.inner.FindRoute(ctx, serviceID, method, path)
+}
+
+func (v *namespaceRepo) SaveDescriptorSet(ctx context.Context, ds *DescriptorSet) error {
+	if err := v.owns(ctx, ds.ServiceID); err != nil {
+		return err
+	}
+	return v.inner.SaveDescriptorSet(ctx, ds)
+}
+
+func (v *namespaceRepo) GetDescriptorSet(ctx context.Context, serviceID string, version int) (*DescriptorSet, error) {
+	if err := v.owns(ctx, serviceID); err != nil {
+		return nil, err
+	}
+	return v.inner.GetDescriptorSet(ctx, serviceID, version)
+}
+
+func (v *namespaceRepo) ListDescriptorSets(ctx context.Context, serviceID string) ([]*DescriptorSet, error) {
+	if err := v.owns(ctx, serviceID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return v.inner.ListDescriptorSets(ctx, serviceID)
+}
+
+func (v *namespaceRepo) GetUpstreamTLS(ctx context.Context, serviceID string) (*UpstreamTLS, error) {
+	if err := v.owns(ctx, serviceID); err != nil {
+		return nil, err
+	}
+	return v.inner.GetUpstreamTLS(ctx, serviceID)
+}
+
+func (v *namespaceRepo) SaveUpstreamTLS(ctx context.Context, serviceID string, t *UpstreamTLS) error {
+	if err := v.owns(ctx, serviceID); err != nil {
+		return err
+	}
+	return v.inner.SaveUpstreamTLS(ctx, serviceID, t)
+}
+
+func (v *namespaceRepo) CreateDraft(ctx context.Context, d *Draft) error {
+	d.Namespace = v.ns
+	return v.inner.CreateDraft(ctx, d)
+}
+
+func (v *namespaceRepo) GetDraft(ctx context.Context, id string) (*Draft, error) {
+	d, err := v.inner.GetDraft(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if d.Namespace != v.ns {
+		return nil, sql.ErrNoRows
+	}
+	return d, nil
+}
+
+func (v *namespaceRepo) ListDrafts(ctx context.Context) ([]*Draft, error) {
+	list, err := v.inner.ListDrafts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*Draft, 0, len(list))
+	for _, d := range list {
+		if d.Namespace == v.ns {
+			out = append(out, d)
+		}
+	}
+	return out, nil
+}
+
+func (v *namespaceRepo) UpdateDraft(ctx context.Context, d *Draft) error {
+	if _, err := v.GetDraft(ctx, d.ID); err != nil {
+		return err
+	}
+	d.Namespace = v.ns
+	return v.inner.UpdateDraft(ctx, d)
+}
+
+func (v *namespaceRepo) DeleteDraft(ctx context.Context, id string) error {
+	if _, err := v.GetDraft(ctx, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return err
+	}
+	return v.inner.DeleteDraft(ctx, id)
+}
+
+// Revision numbers are shared by all namespaces, so a namespace's revisions are numbered with
+// gaps.
+func (v *namespaceRepo) CreateRevision(ctx context.Context, rev *Revision) error {
+	rev.Namespace = v.ns
+	return v.inner.CreateRevision(ctx, rev)
+}
+
+func (v *namespaceRepo) GetRevision(ctx context.Context, number int64) (*Revision, error) {
+	if number == 0 {
+		list, err := v.ListRevisions(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if len(list) == 0 {
+			return nil, sql.ErrNoRows
+		}
+		number = list[0].Number
+	}
+	rev, err := v.inner.GetRevision(ctx, number)
+	if err != nil {
+		return nil, err
+	}
+	if rev.Namespace != v.ns {
+		return nil, sql.ErrNoRows
+	}
+	return rev, nil
+}
+
+func (v *namespaceRepo) ListRevisions(ctx context.Context) ([]*Revision, error) {
+	list, err := v.inner.ListRevisions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*Revision, 0, len(list))
+	for _, rev := range list {
+		if rev.Namespace == v.ns {
+			out = append(out, rev)
+		}
+	}
+	return out, nil
+}
+
+func (v *namespaceRepo) ListNamespaces(ctx context.Context) ([]*Namespace, error) {
+	return v.inner.ListNamespaces(ctx)
+}
+
+func (v *namespaceRepo) GetNamespace(ctx context.Context, name string) (*Namespace, error) {
+	return v.inner.GetNamespace(ctx, name)
+}
+
+func (v *namespaceRepo) CreateNamespace(ctx context.Context, ns *Namespace) error {
+	return v.inner.CreateNamespace(ctx, ns)
+}
+
+func (v *namespaceRepo) UpdateNamespace(ctx context.Context, ns *Namespace) error {
+	return v.inner.UpdateNamespace(ctx, ns)
+}
+
+func (v *namespaceRepo) DeleteNamespace(ctx context.Context, name string) error {
+	return v.inner.DeleteNamespace(ctx, name)
+}
+
+// normalizeHost lowercases a request host and strips its port.
+func normalizeHost(host string) string {
+	host = strings.ToLower(host)
+	if i := strings.LastIndexByte(host, ':'); i >= 0 && !strings.Contains(host[i:], "]") {
+		host = host[:i]
+	}
+	return strings.TrimSuffix(strings.Trim(host, "[]"), ".")
+}
+
+// ValidateHost checks a Namespace.Hosts entry: a lowercase host name without port, optionally
+// starting with "*.".
+func ValidateHost(h string) error {
+	name := strings.TrimPrefix(h, "*.")
+	if name == "" || name != strings.ToLower(name) || strings.ContainsAny(name, ":/*") {
+		return fmt.Errorf("%q is not a lowercase host name (optionally starting with *.) without port", h)
+	}
+	for _, label := range strings.Split(name, ".") {
+		if !ValidNamespaceName(label) {
+			return fmt.Errorf("%q is not a valid host name", h)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package registry
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ErrVersionConflict is returned by updates whose expected Version is no longer current.
+var ErrVersionConflict = errors.New("version conflict: the resource was modified concurrently")
+
+// ErrInvalidValue is returned (wrapped) when the store rejects a value of the wrong form, such
+// as an id that isn't a UUID.
+var ErrInvalidValue = errors.New("invalid value")
+
+// ErrDuplicate is returned (possibly wrapped) when a write collides with an existing resource,
+// such as a second service with the same public prefix.
+var ErrDuplicate = errors.New("resource already exists")
+
+// Sort keys accepted by ServiceQuery.Sort.
+var serviceSortColumns = map[string]string{
+	"name":          "name",
+	"public_prefix": "public_prefix",
+	"created_at":    "created_at",
+	"updated_at":    "updated_at",
+}
+
+// ServiceQuery filters, sorts and pages a service listing. Zero values mean "no filter".
+type ServiceQuery struct {
+	// Namespace limits the listing to one namespace; empty lists all of them.
+	Namespace string
+	Enabled   *bool
+	Protocol  string
+	// GRPCTarget matches the upstream gRPC target exactly.
+	GRPCTarget string
+	// Status matches LastStatus case-insensitively ("healthy", "unhealthy").
+	Status string
+	// Search matches name or public prefix, case-insensitively, as a substring.
+	Search string
+	// Labels must all be present with the given values.
+	Labels map[string]string
+	// Sort is one of name, public_prefix, created_at (default) or updated_at; Desc reverses it.
+	Sort string
+	Desc bool
+	// Limit caps the page size; 0 returns everything after Cursor.
+	Limit int
+	// Cursor is the NextCursor of the previous page.
+	Cursor string
+}
+
+// ServicePage is one page of a service listing. NextCursor is empty on the last page.
+type ServicePage struct {
+	Items      []*Service
+	NextCursor string
+}
+
+// Validate normalizes Sort and rejects unknown sort keys or malformed cursors.
+func (q *ServiceQuery) Validate() error {
+	if q.Sort == "" {
+		q.Sort = "created_at"
+	}
+	if _, ok := serviceSortColumns[q.Sort]; !ok {
+		keys := make([]string, 0, len(serviceSortColumns))
+		for k := range serviceSortColumns {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		return fmt.Errorf("unknown sort %q (want one of %s)", q.Sort, strings.Join(keys, ", "))
+	}
+	if q.Limit < 0 {
+		return errors.New("limit must not be negative")
+	}
+	if q.Cursor != "" {
+		c, err := decodeCursor(q.Cursor)
+		if err != nil {
+			return err
+		}
+		if c.Sort != q.Sort || c.Desc != q.Desc {
+			return errors.New("cursor was issued for a different sort order")
+		}
+	}
+	return nil
+}
+
+// pageCursor is the keyset position after the last item of a page: its sort value and id.
+type pageCursor struct {
+	Sort  string `json:"s"`
+	Desc  bool   `json:"d,omitempty"`
+	Value string `json:"v"`
+	ID    string `json:"id"`
+}
+
+func encodeCursor(c pageCursor) string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(s string) (pageCursor, error) {
+	var c pageCursor
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || json.Unmarshal(b, &c) != nil || c.ID == "" {
+		return c, errors.New("malformed cursor")
+	}
+	return c, nil
+}
@@ -0,0 +1,92 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestServiceQueryValidate(t *testing.T) {
+	byName := encodeCursor(pageCursor{Sort: "name", Value: "a", ID: "1"})
+	tests := []struct {
+		name    string
+		q       ServiceQuery
+		wantErr bool
+	}{
+		{"defaults", ServiceQuery{}, false},
+		{"known sort", ServiceQuery{Sort: "updated_at", Desc: true}, false},
+		{"unknown sort", ServiceQuery{Sort: "version"}, true},
+		{"negative limit", ServiceQuery{Limit: -1}, true},
+		{"cursor", ServiceQuery{Sort: "name", Cursor: byName}, false},
+		{"cursor of another sort", ServiceQuery{Sort: "created_at", Cursor: byName}, true},
+		{"cursor of another direction", ServiceQuery{Sort: "name", Desc: true, Cursor: byName}, true},
+		{"cursor not base64", ServiceQuery{Cursor: "%%%"}, true},
+		{"cursor not json", ServiceQuery{Cursor: "bm9wZQ"}, true},
+		{"cursor without id", ServiceQuery{Sort: "name", Cursor: encodeCursor(pageCursor{Sort: "name", Value: "a"})}, true},
+	}
+	for _, tt := range tests {
+		q := tt.q
+		if err := q.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: Validate() = %v, want error %v", tt.name, err, tt.wantErr)
+		}
+	}
+	q := ServiceQuery{}
+	if err := q.Validate(); err != nil || q.Sort != "created_at" {
+		t.Errorf("Validate: got sort %q (%v), want created_at by default", q.Sort, err)
+	}
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	in := pageCursor{Sort: "public_prefix", Desc: true, Value: "/api/x", ID: uuid.NewString()}
+	out, err := decodeCursor(encodeCursor(in))
+	if err != nil {
+		t.Fatalf("decodeCursor: %v", err)
+	}
+	if out != in {
+		t.Errorf("decodeCursor: got %+v, want %+v", out, in)
+	}
+}
+
+// Pages must neither skip nor repeat services that share a sort value: the id breaks ties.
+func TestQueryServicesCursorTies(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+	for i := 0; i < 7; i++ {
+		name := "same"
+		if i%3 == 0 {
+			name = "other"
+		}
+		s := &Service{ID: uuid.NewString(), Name: name, BaseURL: "http://svc", PublicPrefix: fmt.Sprintf("/api/svc%d", i), Enabled: true}
+		if err := repo.Create(ctx, s); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+	}
+	for _, desc := range []bool{false, true} {
+		seen := map[string]bool{}
+		q := ServiceQuery{Sort: "name", Desc: desc, Limit: 2}
+		for pages := 0; ; pages++ {
+			if pages > 4 {
+				t.Fatalf("desc=%v: cursor never ends", desc)
+			}
+			page, err := repo.QueryServices(ctx, q)
+			if err != nil {
+				t.Fatalf("QueryServices: %v", err)
+			}
+			for _, s := range page.Items {
+				if seen[s.ID] {
+					t.Errorf("desc=%v: %s returned twice", desc, s.PublicPrefix)
+				}
+				seen[s.ID] = true
+			}
+			if page.NextCursor == "" {
+				break
+			}
+			q.Cursor = page.NextCursor
+		}
+		if len(seen) != 7 {
+			t.Errorf("desc=%v: paged through %d services, want 7", desc, len(seen))
+		}
+	}
+}
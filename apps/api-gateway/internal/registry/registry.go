@@ -6,46 +6,217 @@ import (
 	"sync"
 )
 
-// Registry holds enabled services and performs prefix matching
+// Registry holds enabled services and performs prefix matching within each namespace. It also
+// holds each service's compiled route table so grpc-json requests are routed without touching
+// the repository, and the host names that select namespaces.
 type Registry struct {
-	mu       sync.RWMutex
+	mu     sync.RWMutex
+	spaces map[string]*prefixTable // by namespace
+	routes map[string]*RouteTable
+	hosts  map[string]string // exact host -> namespace
+	// wildcards are "*." host suffixes (with the leading dot), longest first.
+	wildcards []hostSuffix
+	listeners []func()
+}
+
+// prefixTable is the enabled services of one namespace.
+type prefixTable struct {
 	byPrefix map[string]*Service
 	order    []string // prefixes sorted by length desc
 }
 
+type hostSuffix struct {
+	suffix    string
+	namespace string
+}
+
 func New() *Registry {
-	return &Registry{byPrefix: map[string]*Service{}}
+	return &Registry{spaces: map[string]*prefixTable{}, routes: map[string]*RouteTable{}, hosts: map[string]string{}}
 }
 
 // Set replaces the current registry content with provided services (enabled ones only)
 func (r *Registry) Set(services []*Service) {
 	r.mu.Lock()
-	defer r.mu.Unlock()
-	r.byPrefix = map[string]*Service{}
+	r.set(services)
+	r.mu.Unlock()
+	r.notify()
+}
+
+// Load replaces services and route tables together, so requests never see services paired
+// with another generation's routes.
+func (r *Registry) Load(services []*Service, tables map[string]*RouteTable) {
+	r.mu.Lock()
+	r.set(services)
+	r.routes = tables
+	r.mu.Unlock()
+	r.notify()
+}
+
+// OnLoad registers fn to be called after every Set or Load, e.g. to release what was kept for
+// services that are gone.
+func (r *Registry) OnLoad(fn func()) {
+	r.mu.Lock()
+	r.listeners = append(r.listeners, fn)
+	r.mu.Unlock()
+}
+
+func (r *Registry) notify() {
+	r.mu.RLock()
+	listeners := r.listeners
+	r.mu.RUnlock()
+	for _, fn := range listeners {
+		fn()
+	}
+}
+
+func (r *Registry) set(services []*Service) {
+	r.spaces = map[string]*prefixTable{}
 	for _, s := range services {
-		if s.Enabled {
-			r.byPrefix[s.PublicPrefix] = s
+		if !s.Enabled {
+			continue
 		}
+		ns := s.Namespace
+		if ns == "" {
+			ns = DefaultNamespace
+		}
+		t := r.spaces[ns]
+		if t == nil {
+			t = &prefixTable{byPrefix: map[string]*Service{}}
+			r.spaces[ns] = t
+		}
+		t.byPrefix[s.PublicPrefix] = s
+	}
+	for _, t := range r.spaces {
+		t.order = make([]string, 0, len(t.byPrefix))
+		for p := range t.byPrefix {
+			t.order = append(t.order, p)
+		}
+		sort.Slice(t.order, func(i, j int) bool { return len(t.order[i]) > len(t.order[j]) })
 	}
-	r.order = make([]string, 0, len(r.byPrefix))
-	for p := range r.byPrefix {
-		r.order = append(r.order, p)
+}
+
+// SetNamespaces replaces the host names that select namespaces.
+func (r *Registry) SetNamespaces(namespaces []*Namespace) {
+	hosts := map[string]string{}
+	var wildcards []hostSuffix
+	for _, ns := range namespaces {
+		for _, h := range ns.Hosts {
+			if suffix, ok := strings.CutPrefix(h, "*"); ok {
+				wildcards = append(wildcards, hostSuffix{suffix: suffix, namespace: ns.Name})
+			} else {
+				hosts[h] = ns.Name
+			}
+		}
 	}
-	sort.Slice(r.order, func(i, j int) bool { return len(r.order[i]) > len(r.order[j]) })
+	sort.Slice(wildcards, func(i, j int) bool { return len(wildcards[i].suffix) > len(wildcards[j].suffix) })
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.hosts, r.wildcards = hosts, wildcards
 }
 
-// Match finds the service by longest matching prefix and returns remainder path
+// NamespaceFor returns the namespace a request host selects: an exact host name wins over the
+// longest matching wildcard, and unknown hosts select DefaultNamespace.
+func (r *Registry) NamespaceFor(host string) string {
+	host = normalizeHost(host)
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if ns, ok := r.hosts[host]; ok {
+		return ns
+	}
+	for _, w := range r.wildcards {
+		if strings.HasSuffix(host, w.suffix) {
+			return w.namespace
+		}
+	}
+	return DefaultNamespace
+}
+
+// Match finds the service of DefaultNamespace by longest matching prefix and returns the
+// remainder path.
 func (r *Registry) Match(path string) (*Service, string, bool) {
+	return r.MatchIn(DefaultNamespace, path)
+}
+
+// MatchIn finds the service of namespace ns by longest matching prefix and returns the
+// remainder path.
+func (r *Registry) MatchIn(ns, path string) (*Service, string, bool) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
-	for _, p := range r.order {
+	t := r.spaces[ns]
+	if t == nil {
+		return nil, "", false
+	}
+	for _, p := range t.order {
 		if strings.HasPrefix(path, p) {
 			remainder := strings.TrimPrefix(path, p)
 			if !strings.HasPrefix(remainder, "/") {
 				remainder = "/" + remainder
 			}
-			return r.byPrefix[p], remainder, true
+			return t.byPrefix[p], remainder, true
 		}
 	}
 	return nil, "", false
 }
+
+// Services returns the enabled services currently loaded, of every namespace.
+func (r *Registry) Services() []*Service {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.spaces))
+	for ns := range r.spaces {
+		names = append(names, ns)
+	}
+	sort.Strings(names)
+	var out []*Service
+	for _, ns := range names {
+		t := r.spaces[ns]
+		for _, p := range t.order {
+			out = append(out, t.byPrefix[p])
+		}
+	}
+	return out
+}
+
+// Service returns the loaded service with the given id, or nil.
+func (r *Registry) Service(id string) *Service {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, t := range r.spaces {
+		for _, s := range t.byPrefix {
+			if s.ID == id {
+				return s
+			}
+		}
+	}
+	return nil
+}
+
+// Routes returns the compiled route table of a service, or nil when none is loaded.
+func (r *Registry) Routes(serviceID string) *RouteTable {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.routes[serviceID]
+}
+
+// RouteTables returns all loaded route tables keyed by service id.
+func (r *Registry) RouteTables() map[string]*RouteTable {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make(map[string]*RouteTable, len(r.routes))
+	for id, t := range r.routes {
+		out[id] = t
+	}
+	return out
+}
+
+// SetRoutes swaps in the route table of one service.
+func (r *Registry) SetRoutes(t *RouteTable) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	routes := make(map[string]*RouteTable, len(r.routes)+1)
+	for id, cur := range r.routes {
+		routes[id] = cur
+	}
+	routes[t.ServiceID] = t
+	r.routes = routes
+}
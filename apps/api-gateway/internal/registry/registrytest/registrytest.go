@@ -0,0 +1,769 @@
+// Package registrytest is the conformance suite of registry.Repository. Every implementation
+// (SQLRepository, CachingRepository and MemoryRepository) must pass it, so the gateway behaves
+// the same in file-only mode as against Postgres. Namespace views are checked through the
+// repository they wrap.
+//
+//	func TestMemoryRepository(t *testing.T) {
+//		registrytest.Run(t, func(t *testing.T) registry.Repository { return registry.NewMemoryRepository() })
+//	}
+//
+// A SQL repository needs a freshly migrated schema per call, and a secrets.Box for the upstream
+// TLS checks.
+package registrytest
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"ecomm/api-gateway/internal/registry"
+)
+
+// Factory returns an empty repository: no services, no drafts, no revisions and only the default
+// namespace. It is called once per check, which may run in parallel with others.
+type Factory func(t *testing.T) registry.Repository
+
+// Run checks every behavior callers of registry.Repository rely on.
+func Run(t *testing.T, newRepo Factory) {
+	checks := []struct {
+		name string
+		fn   func(t *testing.T, repo registry.Repository)
+	}{
+		{"Services", testServices},
+		{"UpdateService", testUpdateService},
+		{"DeleteService", testDeleteService},
+		{"QueryServices", testQueryServices},
+		{"Routes", testRoutes},
+		{"DescriptorSets", testDescriptorSets},
+		{"UpstreamTLS", testUpstreamTLS},
+		{"Drafts", testDrafts},
+		{"Revisions", testRevisions},
+		{"Namespaces", testNamespaces},
+		{"NamespaceView", testNamespaceView},
+		{"Copies", testCopies},
+		{"Transactions", testTransactions},
+		{"Concurrency", testConcurrency},
+	}
+	for _, c := range checks {
+		t.Run(c.name, func(t *testing.T) {
+			c.fn(t, newRepo(t))
+		})
+	}
+}
+
+func newID() string { return uuid.NewString() }
+
+func newService(name, prefix string) *registry.Service {
+	return &registry.Service{
+		ID:           newID(),
+		Name:         name,
+		PublicPrefix: prefix,
+		BaseURL:      "http://" + name + ".internal:8080",
+		SwaggerURL:   "http://" + name + ".internal:8080/swagger.json",
+		Enabled:      true,
+	}
+}
+
+func mustCreate(t *testing.T, repo registry.Repository, s *registry.Service) *registry.Service {
+	t.Helper()
+	if err := repo.Create(context.Background(), s); err != nil {
+		t.Fatalf("Create(%s): %v", s.Name, err)
+	}
+	return s
+}
+
+func mustCreateRoute(t *testing.T, repo registry.Repository, rt *registry.Route) *registry.Route {
+	t.Helper()
+	if err := repo.CreateRoute(context.Background(), rt); err != nil {
+		t.Fatalf("CreateRoute(%s %s): %v", rt.Method, rt.Path, err)
+	}
+	return rt
+}
+
+// wantErr fails unless err is target, and tells which call it was.
+func wantErr(t *testing.T, call string, err, target error) {
+	t.Helper()
+	if !errors.Is(err, target) {
+		t.Errorf("%s: got error %v, want %v", call, err, target)
+	}
+}
+
+func noErr(t *testing.T, call string, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("%s: %v", call, err)
+	}
+}
+
+func names(list []*registry.Service) []string {
+	out := make([]string, 0, len(list))
+	for _, s := range list {
+		out = append(out, s.Name)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func testServices(t *testing.T, repo registry.Repository) {
+	ctx := context.Background()
+	s := newService("orders", "/api/orders")
+	s.Labels = map[string]string{"team": "checkout"}
+	mustCreate(t, repo, s)
+	if s.Version != 1 || s.CreatedAt.IsZero() || s.UpdatedAt.IsZero() {
+		t.Errorf("Create: got version %d, created %v, updated %v; want version 1 and timestamps", s.Version, s.CreatedAt, s.UpdatedAt)
+	}
+
+	got, err := repo.Get(ctx, s.ID)
+	noErr(t, "Get", err)
+	if got.Name != s.Name || got.PublicPrefix != s.PublicPrefix || got.BaseURL != s.BaseURL || !got.Enabled {
+		t.Errorf("Get: got %+v, want the created service", got)
+	}
+	if got.Protocol != "http" || got.Namespace != registry.DefaultNamespace || got.Version != 1 {
+		t.Errorf("Get: got protocol %q, namespace %q, version %d; want http, %s, 1", got.Protocol, got.Namespace, got.Version, registry.DefaultNamespace)
+	}
+	if got.Labels["team"] != "checkout" {
+		t.Errorf("Get: got labels %v, want team=checkout", got.Labels)
+	}
+
+	_, err = repo.Get(ctx, newID())
+	wantErr(t, "Get(missing)", err, sql.ErrNoRows)
+
+	dup := newService("orders-copy", "/api/orders-copy")
+	dup.ID = s.ID
+	wantErr(t, "Create(same id)", repo.Create(ctx, dup), registry.ErrDuplicate)
+	wantErr(t, "Create(same prefix)", repo.Create(ctx, newService("orders2", "/api/orders")), registry.ErrDuplicate)
+
+	disabled := newService("legacy", "/api/legacy")
+	disabled.Enabled = false
+	mustCreate(t, repo, disabled)
+	list, err := repo.List(ctx)
+	noErr(t, "List", err)
+	if want := []string{"legacy", "orders"}; !equal(names(list), want) {
+		t.Errorf("List: got %v, want %v", names(list), want)
+	}
+	enabled, err := repo.LoadEnabled(ctx)
+	noErr(t, "LoadEnabled", err)
+	if want := []string{"orders"}; !equal(names(enabled), want) {
+		t.Errorf("LoadEnabled: got %v, want %v", names(enabled), want)
+	}
+}
+
+func testUpdateService(t *testing.T, repo registry.Repository) {
+	ctx := context.Background()
+	s := mustCreate(t, repo, newService("catalog", "/api/catalog"))
+	at := time.Now().Add(-time.Minute).Truncate(time.Second)
+	noErr(t, "UpdateHealth", repo.UpdateHealth(ctx, s.ID, "healthy", at))
+	got, err := repo.Get(ctx, s.ID)
+	noErr(t, "Get", err)
+	if got.LastStatus != "healthy" || !got.LastHealthAt.Equal(at) || got.Version != 1 {
+		t.Errorf("UpdateHealth: got status %q at %v, version %d; want healthy at %v, version 1", got.LastStatus, got.LastHealthAt, got.Version, at)
+	}
+	noErr(t, "UpdateHealth(missing)", repo.UpdateHealth(ctx, newID(), "healthy", at))
+
+	edit := *got
+	edit.Description = "product catalog"
+	edit.LastStatus = "unhealthy" // not a configuration field
+	noErr(t, "Update", repo.Update(ctx, &edit))
+	if edit.Version != 2 {
+		t.Errorf("Update: got version %d, want 2", edit.Version)
+	}
+	got, err = repo.Get(ctx, s.ID)
+	noErr(t, "Get", err)
+	if got.Description != "product catalog" || got.Version != 2 || got.LastStatus != "healthy" {
+		t.Errorf("Get after Update: got description %q, version %d, status %q; want the new description, version 2, healthy", got.Description, got.Version, got.LastStatus)
+	}
+	if !got.CreatedAt.Equal(s.CreatedAt) {
+		t.Errorf("Update: CreatedAt changed from %v to %v", s.CreatedAt, got.CreatedAt)
+	}
+
+	stale := *got
+	stale.Version = 1
+	wantErr(t, "Update(stale version)", repo.Update(ctx, &stale), registry.ErrVersionConflict)
+	force := *got
+	force.Version = 0
+	force.Description = "unconditional"
+	noErr(t, "Update(version 0)", repo.Update(ctx, &force))
+	if force.Version != 3 {
+		t.Errorf("Update(version 0): got version %d, want 3", force.Version)
+	}
+
+	missing := newService("ghost", "/api/ghost")
+	wantErr(t, "Update(missing)", repo.Update(ctx, missing), sql.ErrNoRows)
+	missing.Version = 4
+	wantErr(t, "Update(missing, versioned)", repo.Update(ctx, missing), sql.ErrNoRows)
+
+	other := mustCreate(t, repo, newService("search", "/api/search"))
+	other.PublicPrefix = "/api/catalog"
+	wantErr(t, "Update(taken prefix)", repo.Update(ctx, other), registry.ErrDuplicate)
+}
+
+func testDeleteService(t *testing.T, repo registry.Repository) {
+	ctx := context.Background()
+	s := mustCreate(t, repo, newService("payments", "/api/payments"))
+	mustCreateRoute(t, repo, &registry.Route{ID: newID(), ServiceID: s.ID, Method: "POST", Path: "/v1/charges", GRPCMethod: "payments.v1.Payments/Charge"})
+	noErr(t, "SaveDescriptorSet", repo.SaveDescriptorSet(ctx, &registry.DescriptorSet{ServiceID: s.ID, SHA256: "abc", Data: []byte{1}}))
+
+	wantErr(t, "Delete(stale version)", repo.Delete(ctx, s.ID, s.Version+1), registry.ErrVersionConflict)
+	noErr(t, "Delete", repo.Delete(ctx, s.ID, s.Version))
+	_, err := repo.Get(ctx, s.ID)
+	wantErr(t, "Get(deleted)", err, sql.ErrNoRows)
+	routes, err := repo.ListRoutes(ctx, s.ID)
+	noErr(t, "ListRoutes", err)
+	if len(routes) != 0 {
+		t.Errorf("ListRoutes(deleted service): got %d routes, want none", len(routes))
+	}
+	sets, err := repo.ListDescriptorSets(ctx, s.ID)
+	noErr(t, "ListDescriptorSets", err)
+	if len(sets) != 0 {
+		t.Errorf("ListDescriptorSets(deleted service): got %d sets, want none", len(sets))
+	}
+	noErr(t, "Delete(missing)", repo.Delete(ctx, s.ID, 0))
+	wantErr(t, "Delete(missing, versioned)", repo.Delete(ctx, s.ID, 1), sql.ErrNoRows)
+
+	// the prefix is free again
+	mustCreate(t, repo, newService("payments-v2", "/api/payments"))
+}
+
+func testQueryServices(t *testing.T, repo registry.Repository) {
+	ctx := context.Background()
+	for i := 0; i < 7; i++ {
+		s := newService(fmt.Sprintf("svc-%d", i), fmt.Sprintf("/api/svc%d", i))
+		s.Enabled = i%2 == 0
+		if i < 3 {
+			s.Protocol = "grpc-json"
+			s.GRPCTarget = "dns:///svc:9090"
+			s.Labels = map[string]string{"tier": "core"}
+		}
+		mustCreate(t, repo, s)
+	}
+	query := func(q registry.ServiceQuery) []string {
+		t.Helper()
+		page, err := repo.QueryServices(ctx, q)
+		noErr(t, "QueryServices", err)
+		return names(page.Items)
+	}
+	yes := true
+	if got, want := query(registry.ServiceQuery{Enabled: &yes}), []string{"svc-0", "svc-2", "svc-4", "svc-6"}; !equal(got, want) {
+		t.Errorf("QueryServices(enabled): got %v, want %v", got, want)
+	}
+	if got, want := query(registry.ServiceQuery{Protocol: "GRPC-JSON"}), []string{"svc-0", "svc-1", "svc-2"}; !equal(got, want) {
+		t.Errorf("QueryServices(protocol): got %v, want %v", got, want)
+	}
+	if got, want := query(registry.ServiceQuery{Labels: map[string]string{"tier": "core"}, Enabled: &yes}), []string{"svc-0", "svc-2"}; !equal(got, want) {
+		t.Errorf("QueryServices(labels): got %v, want %v", got, want)
+	}
+	if got, want := query(registry.ServiceQuery{Search: "SVC5"}), []string{"svc-5"}; !equal(got, want) {
+		t.Errorf("QueryServices(search): got %v, want %v", got, want)
+	}
+	if got, want := query(registry.ServiceQuery{GRPCTarget: "dns:///svc:9090", Enabled: &yes}), []string{"svc-0", "svc-2"}; !equal(got, want) {
+		t.Errorf("QueryServices(grpc target): got %v, want %v", got, want)
+	}
+	if got := query(registry.ServiceQuery{Namespace: "elsewhere"}); len(got) != 0 {
+		t.Errorf("QueryServices(other namespace): got %v, want none", got)
+	}
+
+	for _, desc := range []bool{false, true} {
+		var seen []string
+		q := registry.ServiceQuery{Sort: "name", Desc: desc, Limit: 3}
+		for pages := 0; ; pages++ {
+			if pages > 3 {
+				t.Fatalf("QueryServices(desc=%v): cursor never ends", desc)
+			}
+			page, err := repo.QueryServices(ctx, q)
+			noErr(t, "QueryServices(page)", err)
+			for _, s := range page.Items {
+				seen = append(seen, s.Name)
+			}
+			if page.NextCursor == "" {
+				break
+			}
+			q.Cursor = page.NextCursor
+		}
+		want := []string{"svc-0", "svc-1", "svc-2", "svc-3", "svc-4", "svc-5", "svc-6"}
+		if desc {
+			sort.Sort(sort.Reverse(sort.StringSlice(want)))
+		}
+		if !equal(seen, want) {
+			t.Errorf("QueryServices(pages, desc=%v): got %v, want %v", desc, seen, want)
+		}
+	}
+}
+
+func testRoutes(t *testing.T, repo registry.Repository) {
+	ctx := context.Background()
+	s := mustCreate(t, repo, newService("inventory", "/api/inventory"))
+	rt := mustCreateRoute(t, repo, &registry.Route{
+		ID: newID(), ServiceID: s.ID, Method: "get", Path: "/v1/items/{id}", GRPCMethod: "inventory.v1.Inventory/GetItem",
+		QueryMapping: registry.RouteQueryMapping{"expand": {Field: "view"}},
+	})
+	if rt.Version != 1 || rt.Namespace != registry.DefaultNamespace {
+		t.Errorf("CreateRoute: got version %d, namespace %q; want 1, %s", rt.Version, rt.Namespace, registry.DefaultNamespace)
+	}
+	mustCreateRoute(t, repo, &registry.Route{ID: newID(), ServiceID: s.ID, Method: "POST", Path: "/v1/items", GRPCMethod: "inventory.v1.Inventory/CreateItem", Body: "*"})
+
+	got, err := repo.GetRoute(ctx, s.ID, rt.ID)
+	noErr(t, "GetRoute", err)
+	if got.Method != "GET" || got.Source != registry.RouteSourceManual || got.QueryMapping["expand"].Field != "view" {
+		t.Errorf("GetRoute: got method %q, source %q, query mapping %v; want GET, %s and the mapping", got.Method, got.Source, got.QueryMapping, registry.RouteSourceManual)
+	}
+	_, err = repo.GetRoute(ctx, s.ID, newID())
+	wantErr(t, "GetRoute(missing)", err, sql.ErrNoRows)
+
+	found, err := repo.FindRoute(ctx, s.ID, "get", "/v1/items/{id}")
+	noErr(t, "FindRoute", err)
+	if found.ID != rt.ID {
+		t.Errorf("FindRoute: got route %s, want %s", found.ID, rt.ID)
+	}
+	_, err = repo.FindRoute(ctx, s.ID, "DELETE", "/v1/items/{id}")
+	wantErr(t, "FindRoute(missing)", err, sql.ErrNoRows)
+
+	list, err := repo.ListRoutes(ctx, s.ID)
+	noErr(t, "ListRoutes", err)
+	if len(list) != 2 || list[0].Path != "/v1/items" || list[1].Path != "/v1/items/{id}" {
+		t.Errorf("ListRoutes: got %d routes, want both ordered by path", len(list))
+	}
+
+	wantErr(t, "CreateRoute(same method and path)", repo.CreateRoute(ctx, &registry.Route{ID: newID(), ServiceID: s.ID, Method: "GET", Path: "/v1/items/{id}", GRPCMethod: "x.y/Z"}), registry.ErrDuplicate)
+	wantErr(t, "CreateRoute(same id)", repo.CreateRoute(ctx, &registry.Route{ID: rt.ID, ServiceID: s.ID, Method: "PUT", Path: "/v1/other", GRPCMethod: "x.y/Z"}), registry.ErrDuplicate)
+	wantErr(t, "CreateRoute(missing service)", repo.CreateRoute(ctx, &registry.Route{ID: newID(), ServiceID: newID(), Method: "GET", Path: "/", GRPCMethod: "x.y/Z"}), sql.ErrNoRows)
+
+	edit := *got
+	edit.Path = "/v1/items/{item_id}"
+	noErr(t, "UpdateRoute", repo.UpdateRoute(ctx, &edit))
+	if edit.Version != 2 {
+		t.Errorf("UpdateRoute: got version %d, want 2", edit.Version)
+	}
+	stale := edit
+	stale.Version = 1
+	wantErr(t, "UpdateRoute(stale version)", repo.UpdateRoute(ctx, &stale), registry.ErrVersionConflict)
+	clash := edit
+	clash.Version, clash.Method, clash.Path = 0, "POST", "/v1/items"
+	wantErr(t, "UpdateRoute(taken method and path)", repo.UpdateRoute(ctx, &clash), registry.ErrDuplicate)
+	wantErr(t, "UpdateRoute(missing)", repo.UpdateRoute(ctx, &registry.Route{ID: newID(), ServiceID: s.ID, Method: "GET", Path: "/x", GRPCMethod: "x.y/Z"}), sql.ErrNoRows)
+
+	wantErr(t, "DeleteRoute(stale version)", repo.DeleteRoute(ctx, s.ID, rt.ID, 1), registry.ErrVersionConflict)
+	noErr(t, "DeleteRoute", repo.DeleteRoute(ctx, s.ID, rt.ID, edit.Version))
+	_, err = repo.GetRoute(ctx, s.ID, rt.ID)
+	wantErr(t, "GetRoute(deleted)", err, sql.ErrNoRows)
+	noErr(t, "DeleteRoute(missing)", repo.DeleteRoute(ctx, s.ID, rt.ID, 0))
+	wantErr(t, "DeleteRoute(missing, versioned)", repo.DeleteRoute(ctx, s.ID, rt.ID, 2), sql.ErrNoRows)
+}
+
+func testDescriptorSets(t *testing.T, repo registry.Repository) {
+	ctx := context.Background()
+	s := mustCreate(t, repo, newService("shipping", "/api/shipping"))
+	for i, data := range [][]byte{{1, 2, 3}, {4, 5}} {
+		ds := &registry.DescriptorSet{ServiceID: s.ID, SHA256: fmt.Sprintf("sum%d", i), Services: []string{"shipping.v1.Shipping"}, Data: data}
+		noErr(t, "SaveDescriptorSet", repo.SaveDescriptorSet(ctx, ds))
+		if ds.Version != i+1 || ds.CreatedAt.IsZero() {
+			t.Errorf("SaveDescriptorSet: got version %d, created %v; want version %d", ds.Version, ds.CreatedAt, i+1)
+		}
+	}
+	latest, err := repo.GetDescriptorSet(ctx, s.ID, 0)
+	noErr(t, "GetDescriptorSet(latest)", err)
+	if latest.Version != 2 || string(latest.Data) != string([]byte{4, 5}) || latest.Size != 2 {
+		t.Errorf("GetDescriptorSet(latest): got version %d, size %d; want version 2, size 2", latest.Version, latest.Size)
+	}
+	first, err := repo.GetDescriptorSet(ctx, s.ID, 1)
+	noErr(t, "GetDescriptorSet(1)", err)
+	if first.SHA256 != "sum0" || len(first.Services) != 1 {
+		t.Errorf("GetDescriptorSet(1): got %+v, want the first set", first)
+	}
+	_, err = repo.GetDescriptorSet(ctx, s.ID, 9)
+	wantErr(t, "GetDescriptorSet(missing version)", err, sql.ErrNoRows)
+	_, err = repo.GetDescriptorSet(ctx, newID(), 0)
+	wantErr(t, "GetDescriptorSet(missing service)", err, sql.ErrNoRows)
+
+	list, err := repo.ListDescriptorSets(ctx, s.ID)
+	noErr(t, "ListDescriptorSets", err)
+	if len(list) != 2 || list[0].Version != 2 || list[0].Data != nil || list[0].Size != 2 {
+		t.Errorf("ListDescriptorSets: want both sets newest first, sized and without data")
+	}
+	wantErr(t, "SaveDescriptorSet(missing service)", repo.SaveDescriptorSet(ctx, &registry.DescriptorSet{ServiceID: newID(), SHA256: "x", Data: []byte{1}}), sql.ErrNoRows)
+
+	// concurrent uploads get distinct consecutive versions
+	var wg sync.WaitGroup
+	versions := make([]int, 5)
+	for i := range versions {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ds := &registry.DescriptorSet{ServiceID: s.ID, SHA256: fmt.Sprintf("race%d", i), Data: []byte{byte(i)}}
+			if err := repo.SaveDescriptorSet(ctx, ds); err != nil {
+				t.Errorf("SaveDescriptorSet(concurrent): %v", err)
+			}
+			versions[i] = ds.Version
+		}(i)
+	}
+	wg.Wait()
+	sort.Ints(versions)
+	for i, v := range versions {
+		if v != i+3 {
+			t.Errorf("SaveDescriptorSet(concurrent): got versions %v, want 3..7", versions)
+			break
+		}
+	}
+}
+
+func testUpstreamTLS(t *testing.T, repo registry.Repository) {
+	ctx := context.Background()
+	s := mustCreate(t, repo, newService("ledger", "/api/ledger"))
+	got, err := repo.GetUpstreamTLS(ctx, s.ID)
+	noErr(t, "GetUpstreamTLS(unset)", err)
+	if got != nil {
+		t.Errorf("GetUpstreamTLS(unset): got %+v, want nil", got)
+	}
+	want := &registry.UpstreamTLS{ServerName: "ledger.internal", CACertPEM: "-----BEGIN CERTIFICATE-----"}
+	noErr(t, "SaveUpstreamTLS", repo.SaveUpstreamTLS(ctx, s.ID, want))
+	got, err = repo.GetUpstreamTLS(ctx, s.ID)
+	noErr(t, "GetUpstreamTLS", err)
+	if got == nil || got.ServerName != want.ServerName || got.CACertPEM != want.CACertPEM {
+		t.Errorf("GetUpstreamTLS: got %+v, want %+v", got, want)
+	}
+	noErr(t, "SaveUpstreamTLS(nil)", repo.SaveUpstreamTLS(ctx, s.ID, nil))
+	if got, err = repo.GetUpstreamTLS(ctx, s.ID); err != nil || got != nil {
+		t.Errorf("GetUpstreamTLS(cleared): got %+v, %v; want nil", got, err)
+	}
+	_, err = repo.GetUpstreamTLS(ctx, newID())
+	wantErr(t, "GetUpstreamTLS(missing service)", err, sql.ErrNoRows)
+	wantErr(t, "SaveUpstreamTLS(missing service)", repo.SaveUpstreamTLS(ctx, newID(), want), sql.ErrNoRows)
+}
+
+func testDrafts(t *testing.T, repo registry.Repository) {
+	ctx := context.Background()
+	snap := &registry.Snapshot{Services: []*registry.Service{newService("reviews", "/api/reviews")}, Routes: []*registry.Route{}}
+	d := &registry.Draft{ID: newID(), Name: "add reviews", Base: "empty", Snapshot: snap}
+	noErr(t, "CreateDraft", repo.CreateDraft(ctx, d))
+	if d.Version != 1 || d.Namespace != registry.DefaultNamespace || d.CreatedAt.IsZero() {
+		t.Errorf("CreateDraft: got version %d, namespace %q; want 1, %s", d.Version, d.Namespace, registry.DefaultNamespace)
+	}
+	wantErr(t, "CreateDraft(same id)", repo.CreateDraft(ctx, &registry.Draft{ID: d.ID, Name: "again", Base: "empty", Snapshot: snap}), registry.ErrDuplicate)
+
+	got, err := repo.GetDraft(ctx, d.ID)
+	noErr(t, "GetDraft", err)
+	if got.Name != d.Name || got.Snapshot == nil || len(got.Snapshot.Services) != 1 || got.Snapshot.Services[0].Name != "reviews" {
+		t.Errorf("GetDraft: got %+v, want the draft with its snapshot", got)
+	}
+	_, err = repo.GetDraft(ctx, newID())
+	wantErr(t, "GetDraft(missing)", err, sql.ErrNoRows)
+
+	got.Description = "reviews service"
+	noErr(t, "UpdateDraft", repo.UpdateDraft(ctx, got))
+	if got.Version != 2 {
+		t.Errorf("UpdateDraft: got version %d, want 2", got.Version)
+	}
+	got.Version = 1
+	wantErr(t, "UpdateDraft(stale version)", repo.UpdateDraft(ctx, got), registry.ErrVersionConflict)
+	wantErr(t, "UpdateDraft(missing)", repo.UpdateDraft(ctx, &registry.Draft{ID: newID(), Name: "x", Base: "empty", Snapshot: snap}), sql.ErrNoRows)
+
+	second := &registry.Draft{ID: newID(), Name: "second", Base: "empty", Snapshot: snap}
+	noErr(t, "CreateDraft", repo.CreateDraft(ctx, second))
+	list, err := repo.ListDrafts(ctx)
+	noErr(t, "ListDrafts", err)
+	if len(list) != 2 || list[0].ID != d.ID || list[1].ID != second.ID || list[0].Snapshot != nil {
+		t.Errorf("ListDrafts: want both drafts oldest first, without snapshots")
+	}
+
+	noErr(t, "DeleteDraft", repo.DeleteDraft(ctx, d.ID))
+	_, err = repo.GetDraft(ctx, d.ID)
+	wantErr(t, "GetDraft(deleted)", err, sql.ErrNoRows)
+	noErr(t, "DeleteDraft(missing)", repo.DeleteDraft(ctx, d.ID))
+}
+
+func testRevisions(t *testing.T, repo registry.Repository) {
+	ctx := context.Background()
+	_, err := repo.GetRevision(ctx, 0)
+	wantErr(t, "GetRevision(latest of none)", err, sql.ErrNoRows)
+
+	snap := &registry.Snapshot{Services: []*registry.Service{}, Routes: []*registry.Route{}}
+	var numbers []int64
+	for i := 0; i < 3; i++ {
+		rev := &registry.Revision{Source: registry.RevisionSourcePublish, Message: fmt.Sprintf("rev %d", i), Fingerprint: snap.Fingerprint(), Changes: []registry.SnapshotChange{}, Snapshot: snap}
+		noErr(t, "CreateRevision", repo.CreateRevision(ctx, rev))
+		if rev.Namespace != registry.DefaultNamespace || rev.CreatedAt.IsZero() {
+			t.Errorf("CreateRevision: got namespace %q, created %v", rev.Namespace, rev.CreatedAt)
+		}
+		if len(numbers) > 0 && rev.Number <= numbers[len(numbers)-1] {
+			t.Errorf("CreateRevision: got number %d after %d, want increasing numbers", rev.Number, numbers[len(numbers)-1])
+		}
+		numbers = append(numbers, rev.Number)
+	}
+	latest, err := repo.GetRevision(ctx, 0)
+	noErr(t, "GetRevision(latest)", err)
+	if latest.Number != numbers[2] || latest.Message != "rev 2" || latest.Snapshot == nil {
+		t.Errorf("GetRevision(latest): got number %d, want %d with its snapshot", latest.Number, numbers[2])
+	}
+	first, err := repo.GetRevision(ctx, numbers[0])
+	noErr(t, "GetRevision", err)
+	if first.Message != "rev 0" {
+		t.Errorf("GetRevision(%d): got message %q, want rev 0", numbers[0], first.Message)
+	}
+	_, err = repo.GetRevision(ctx, numbers[2]+100)
+	wantErr(t, "GetRevision(missing)", err, sql.ErrNoRows)
+
+	list, err := repo.ListRevisions(ctx)
+	noErr(t, "ListRevisions", err)
+	if len(list) != 3 || list[0].Number != numbers[2] || list[2].Number != numbers[0] || list[0].Snapshot != nil {
+		t.Errorf("ListRevisions: want all revisions newest first, without snapshots")
+	}
+
+	// concurrent publishes, in one namespace or several, each get their own number
+	noErr(t, "CreateNamespace", repo.CreateNamespace(ctx, &registry.Namespace{Name: "staging"}))
+	var wg sync.WaitGroup
+	errs := make([]error, 8)
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rev := &registry.Revision{Source: registry.RevisionSourcePublish, Fingerprint: snap.Fingerprint(), Changes: []registry.SnapshotChange{}, Snapshot: snap}
+			if i%2 == 1 {
+				rev.Namespace = "staging"
+			}
+			errs[i] = repo.CreateRevision(ctx, rev)
+		}()
+	}
+	wg.Wait()
+	for _, err := range errs {
+		noErr(t, "CreateRevision(concurrent)", err)
+	}
+	list, err = repo.ListRevisions(ctx)
+	noErr(t, "ListRevisions", err)
+	seen := map[int64]bool{}
+	for _, rev := range list {
+		seen[rev.Number] = true
+	}
+	if len(list) != 11 || len(seen) != 11 {
+		t.Errorf("ListRevisions: got %d revisions with %d distinct numbers, want 11 of each", len(list), len(seen))
+	}
+}
+
+func testNamespaces(t *testing.T, repo registry.Repository) {
+	ctx := context.Background()
+	def, err := repo.GetNamespace(ctx, registry.DefaultNamespace)
+	noErr(t, "GetNamespace(default)", err)
+	if def.Version < 1 {
+		t.Errorf("GetNamespace(default): got version %d", def.Version)
+	}
+	if err := repo.DeleteNamespace(ctx, registry.DefaultNamespace); err == nil {
+		t.Errorf("DeleteNamespace(default): got no error")
+	}
+
+	ns := &registry.Namespace{Name: "staging", Description: "pre-production", Hosts: []string{"staging.example.com"}, Members: []registry.Member{{Subject: "group:qa", Role: registry.RoleEditor}}}
+	noErr(t, "CreateNamespace", repo.CreateNamespace(ctx, ns))
+	if ns.Version != 1 || ns.CreatedAt.IsZero() {
+		t.Errorf("CreateNamespace: got version %d, created %v", ns.Version, ns.CreatedAt)
+	}
+	wantErr(t, "CreateNamespace(same name)", repo.CreateNamespace(ctx, &registry.Namespace{Name: "staging"}), registry.ErrDuplicate)
+	got, err := repo.GetNamespace(ctx, "staging")
+	noErr(t, "GetNamespace", err)
+	if got.Description != ns.Description || len(got.Hosts) != 1 || len(got.Members) != 1 || got.Members[0].Role != registry.RoleEditor {
+		t.Errorf("GetNamespace: got %+v, want %+v", got, ns)
+	}
+	_, err = repo.GetNamespace(ctx, "missing")
+	wantErr(t, "GetNamespace(missing)", err, sql.ErrNoRows)
+
+	got.Hosts = nil
+	noErr(t, "UpdateNamespace", repo.UpdateNamespace(ctx, got))
+	if got.Version != 2 {
+		t.Errorf("UpdateNamespace: got version %d, want 2", got.Version)
+	}
+	got.Version = 1
+	wantErr(t, "UpdateNamespace(stale version)", repo.UpdateNamespace(ctx, got), registry.ErrVersionConflict)
+	wantErr(t, "UpdateNamespace(missing)", repo.UpdateNamespace(ctx, &registry.Namespace{Name: "missing"}), sql.ErrNoRows)
+	list, err := repo.ListNamespaces(ctx)
+	noErr(t, "ListNamespaces", err)
+	if len(list) != 2 || list[0].Name != registry.DefaultNamespace || list[1].Name != "staging" || len(list[1].Hosts) != 0 {
+		t.Errorf("ListNamespaces: want default and staging, by name")
+	}
+
+	// Prefixes are unique per namespace only.
+	s := newService("orders", "/api/orders")
+	s.Namespace = "staging"
+	mustCreate(t, repo, s)
+	mustCreate(t, repo, newService("orders", "/api/orders"))
+	wantErr(t, "DeleteNamespace(with services)", repo.DeleteNamespace(ctx, "staging"), registry.ErrNamespaceNotEmpty)
+
+	noErr(t, "CreateDraft", repo.CreateDraft(ctx, &registry.Draft{ID: newID(), Namespace: "staging", Name: "d", Base: "empty", Snapshot: &registry.Snapshot{}}))
+	noErr(t, "CreateRevision", repo.CreateRevision(ctx, &registry.Revision{Namespace: "staging", Source: registry.RevisionSourcePublish, Changes: []registry.SnapshotChange{}, Snapshot: &registry.Snapshot{}}))
+	noErr(t, "Delete", repo.Delete(ctx, s.ID, 0))
+	noErr(t, "DeleteNamespace", repo.DeleteNamespace(ctx, "staging"))
+	_, err = repo.GetNamespace(ctx, "staging")
+	wantErr(t, "GetNamespace(deleted)", err, sql.ErrNoRows)
+	drafts, err := repo.ListDrafts(ctx)
+	noErr(t, "ListDrafts", err)
+	revisions, err := repo.ListRevisions(ctx)
+	noErr(t, "ListRevisions", err)
+	if len(drafts) != 0 || len(revisions) != 0 {
+		t.Errorf("DeleteNamespace: left %d drafts and %d revisions behind", len(drafts), len(revisions))
+	}
+}
+
+func testNamespaceView(t *testing.T, repo registry.Repository) {
+	ctx := context.Background()
+	noErr(t, "CreateNamespace", repo.CreateNamespace(ctx, &registry.Namespace{Name: "team-a"}))
+	view := registry.InNamespace(repo, "team-a")
+	s := mustCreate(t, view, newService("orders", "/api/orders"))
+	if s.Namespace != "team-a" {
+		t.Errorf("Create through view: got namespace %q, want team-a", s.Namespace)
+	}
+	outside := mustCreate(t, repo, newService("billing", "/api/billing"))
+
+	list, err := view.List(ctx)
+	noErr(t, "List through view", err)
+	if want := []string{"orders"}; !equal(names(list), want) {
+		t.Errorf("List through view: got %v, want %v", names(list), want)
+	}
+	_, err = view.Get(ctx, outside.ID)
+	wantErr(t, "Get through view(other namespace)", err, sql.ErrNoRows)
+	rt := mustCreateRoute(t, view, &registry.Route{ID: newID(), ServiceID: s.ID, Method: "GET", Path: "/v1/orders", GRPCMethod: "orders.v1.Orders/List"})
+	if rt.Namespace != "team-a" {
+		t.Errorf("CreateRoute through view: got namespace %q, want team-a", rt.Namespace)
+	}
+	all, err := registry.Unscoped(view).List(ctx)
+	noErr(t, "List unscoped", err)
+	if want := []string{"billing", "orders"}; !equal(names(all), want) {
+		t.Errorf("List unscoped: got %v, want %v", names(all), want)
+	}
+}
+
+func testCopies(t *testing.T, repo registry.Repository) {
+	ctx := context.Background()
+	s := newService("users", "/api/users")
+	s.Labels = map[string]string{"team": "identity"}
+	mustCreate(t, repo, s)
+	s.Labels["team"] = "changed after create"
+	got, err := repo.Get(ctx, s.ID)
+	noErr(t, "Get", err)
+	if got.Labels["team"] != "identity" {
+		t.Errorf("Get: labels changed through the created value: %v", got.Labels)
+	}
+	got.Labels["team"] = "changed after get"
+	again, err := repo.Get(ctx, s.ID)
+	noErr(t, "Get", err)
+	if again.Labels["team"] != "identity" {
+		t.Errorf("Get: labels changed through a returned value: %v", again.Labels)
+	}
+}
+
+func testTransactions(t *testing.T, repo registry.Repository) {
+	tr, ok := repo.(registry.Transactional)
+	if !ok {
+		t.Skip("not Transactional")
+	}
+	ctx := context.Background()
+	kept := newService("kept", "/api/kept")
+	noErr(t, "InTx", tr.InTx(ctx, func(tx registry.Repository) error {
+		return tx.Create(ctx, kept)
+	}))
+	if _, err := repo.Get(ctx, kept.ID); err != nil {
+		t.Errorf("Get after committed InTx: %v", err)
+	}
+
+	failed := errors.New("abort")
+	dropped := newService("dropped", "/api/dropped")
+	err := tr.InTx(ctx, func(tx registry.Repository) error {
+		if err := tx.Create(ctx, dropped); err != nil {
+			return err
+		}
+		if err := tx.Delete(ctx, kept.ID, 0); err != nil {
+			return err
+		}
+		return failed
+	})
+	wantErr(t, "InTx(failing)", err, failed)
+	if _, err := repo.Get(ctx, dropped.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Get after rolled back InTx: got %v, want sql.ErrNoRows", err)
+	}
+	if _, err := repo.Get(ctx, kept.ID); err != nil {
+		t.Errorf("Get after rolled back InTx: deleted service is gone: %v", err)
+	}
+}
+
+// testConcurrency races writers against each other and against readers; run it with -race.
+func testConcurrency(t *testing.T, repo registry.Repository) {
+	ctx := context.Background()
+	const workers = 8
+	shared := mustCreate(t, repo, newService("shared", "/api/shared"))
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var updated, conflicts int
+	errs := make(chan error, workers*4)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			own := newService(fmt.Sprintf("worker-%d", i), fmt.Sprintf("/api/worker%d", i))
+			if err := repo.Create(ctx, own); err != nil {
+				errs <- fmt.Errorf("Create: %w", err)
+				return
+			}
+			if err := repo.CreateRoute(ctx, &registry.Route{ID: newID(), ServiceID: shared.ID, Method: "GET", Path: fmt.Sprintf("/v1/w%d", i), GRPCMethod: "x.y/Z"}); err != nil {
+				errs <- fmt.Errorf("CreateRoute: %w", err)
+			}
+			// every worker updates the same version; exactly one of them wins
+			edit := *shared
+			edit.Description = own.Name
+			switch err := repo.Update(ctx, &edit); {
+			case err == nil:
+				mu.Lock()
+				updated++
+				mu.Unlock()
+			case errors.Is(err, registry.ErrVersionConflict):
+				mu.Lock()
+				conflicts++
+				mu.Unlock()
+			default:
+				errs <- fmt.Errorf("Update: %w", err)
+			}
+			if _, err := repo.List(ctx); err != nil {
+				errs <- fmt.Errorf("List: %w", err)
+			}
+			if err := repo.UpdateHealth(ctx, shared.ID, "healthy", time.Now()); err != nil {
+				errs <- fmt.Errorf("UpdateHealth: %w", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+	if updated != 1 || conflicts != workers-1 {
+		t.Errorf("concurrent Update of one version: %d succeeded and %d conflicted, want 1 and %d", updated, conflicts, workers-1)
+	}
+	list, err := repo.List(ctx)
+	noErr(t, "List", err)
+	if len(list) != workers+1 {
+		t.Errorf("List: got %d services, want %d", len(list), workers+1)
+	}
+	routes, err := repo.ListRoutes(ctx, shared.ID)
+	noErr(t, "ListRoutes", err)
+	if len(routes) != workers {
+		t.Errorf("ListRoutes: got %d routes, want %d", len(routes), workers)
+	}
+	got, err := repo.Get(ctx, shared.ID)
+	noErr(t, "Get", err)
+	if got.Version != 2 {
+		t.Errorf("Get: got version %d, want 2", got.Version)
+	}
+}
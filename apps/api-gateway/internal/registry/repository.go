@@ -2,33 +2,108 @@ package registry
 
 import (
 	"context"
+	"strings"
+	"time"
 )
 
 // Repository abstracts persistence for services
 type Repository interface {
-	Init() error
 	LoadEnabled(ctx context.Context) ([]*Service, error)
 	List(ctx context.Context) ([]*Service, error)
+	// QueryServices returns one filtered, sorted page of services.
+	QueryServices(ctx context.Context, q ServiceQuery) (*ServicePage, error)
 	Get(ctx context.Context, id string) (*Service, error)
 	Create(ctx context.Context, s *Service) error
+	// Update saves s. When s.Version is non-zero it must match the stored version, otherwise
+	// ErrVersionConflict is returned; on success s.Version holds the new version.
 	Update(ctx context.Context, s *Service) error
-	Delete(ctx context.Context, id string) error
+	// UpdateHealth records a health probe result without bumping the service version.
+	UpdateHealth(ctx context.Context, id, status string, at time.Time) error
+	// Delete removes a service with its routes, descriptor sets and TLS settings. When version
+	// is non-zero it must match the stored version (ErrVersionConflict) and the service must
+	// exist (sql.ErrNoRows); otherwise deleting a missing service is a no-op.
+	Delete(ctx context.Context, id string, version int64) error
 
 	// Route mappings for REST -> gRPC transcoding
 	ListRoutes(ctx context.Context, serviceID string) ([]*Route, error)
 	GetRoute(ctx context.Context, serviceID, routeID string) (*Route, error)
 	CreateRoute(ctx context.Context, r *Route) error
+	// UpdateRoute saves r, checking r.Version like Update.
 	UpdateRoute(ctx context.Context, r *Route) error
-	DeleteRoute(ctx context.Context, serviceID, routeID string) error
+	// DeleteRoute removes a route, checking version like Delete.
+	DeleteRoute(ctx context.Context, serviceID, routeID string, version int64) error
 	FindRoute(ctx context.Context, serviceID, method, path string) (*Route, error)
+
+	// Uploaded FileDescriptorSets for grpc-json services without (reliable) reflection
+	SaveDescriptorSet(ctx context.Context, ds *DescriptorSet) error
+	// GetDescriptorSet returns the given version, or the latest one when version is 0.
+	GetDescriptorSet(ctx context.Context, serviceID string, version int) (*DescriptorSet, error)
+	ListDescriptorSets(ctx context.Context, serviceID string) ([]*DescriptorSet, error)
+
+	// Upstream TLS settings, stored encrypted. GetUpstreamTLS returns nil when none are set;
+	// saving nil clears them.
+	GetUpstreamTLS(ctx context.Context, serviceID string) (*UpstreamTLS, error)
+	SaveUpstreamTLS(ctx context.Context, serviceID string, t *UpstreamTLS) error
+
+	// Drafts of staged registry changes. GetDraft includes the snapshot, ListDrafts doesn't.
+	CreateDraft(ctx context.Context, d *Draft) error
+	GetDraft(ctx context.Context, id string) (*Draft, error)
+	ListDrafts(ctx context.Context) ([]*Draft, error)
+	// UpdateDraft saves d, checking d.Version like Update.
+	UpdateDraft(ctx context.Context, d *Draft) error
+	DeleteDraft(ctx context.Context, id string) error
+
+	// Revisions of the live registry. CreateRevision assigns the next number; GetRevision
+	// returns the latest revision when number is 0. ListRevisions returns them newest first,
+	// without snapshots.
+	CreateRevision(ctx context.Context, rev *Revision) error
+	GetRevision(ctx context.Context, number int64) (*Revision, error)
+	ListRevisions(ctx context.Context) ([]*Revision, error)
+
+	// Namespaces, by name. DefaultNamespace always exists and cannot be deleted; deleting a
+	// namespace with services fails with ErrNamespaceNotEmpty and deletes its drafts and
+	// revisions otherwise.
+	ListNamespaces(ctx context.Context) ([]*Namespace, error)
+	GetNamespace(ctx context.Context, name string) (*Namespace, error)
+	CreateNamespace(ctx context.Context, ns *Namespace) error
+	// UpdateNamespace saves ns, checking ns.Version like Update.
+	UpdateNamespace(ctx context.Context, ns *Namespace) error
+	DeleteNamespace(ctx context.Context, name string) error
 }
 
-// LoadEnabled loads enabled services into runtime registry
+// Transactional is implemented by repositories that can apply several writes atomically. InTx
+// runs fn against a repository bound to one transaction, committing if fn returns nil and
+// rolling back otherwise.
+type Transactional interface {
+	InTx(ctx context.Context, fn func(tx Repository) error) error
+}
+
+// LoadEnabled loads enabled services of every namespace, the compiled route tables of
+// grpc-json services and the namespaces' hosts into runtime registry. A namespace view of a
+// repository loads all namespaces too.
 func LoadEnabled(repo Repository, reg *Registry) error {
-	list, err := repo.LoadEnabled(context.Background())
+	ctx := context.Background()
+	repo = Unscoped(repo)
+	namespaces, err := repo.ListNamespaces(ctx)
 	if err != nil {
 		return err
 	}
-	reg.Set(list)
+	list, err := repo.LoadEnabled(ctx)
+	if err != nil {
+		return err
+	}
+	tables := map[string]*RouteTable{}
+	for _, s := range list {
+		if !strings.EqualFold(s.Protocol, "grpc-json") {
+			continue
+		}
+		routes, err := repo.ListRoutes(ctx, s.ID)
+		if err != nil {
+			return err
+		}
+		tables[s.ID] = CompileRoutes(s.ID, routes)
+	}
+	reg.SetNamespaces(namespaces)
+	reg.Load(list, tables)
 	return nil
 }
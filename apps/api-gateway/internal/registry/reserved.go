@@ -0,0 +1,29 @@
+package registry
+
+import "strings"
+
+// ReservedPaths are served by the gateway itself. Entries ending in "/" reserve the whole
+// subtree. Native gRPC and gRPC-Web services are matched ahead of the gateway's own routes, so
+// their public prefixes must stay clear of these.
+var ReservedPaths = []string{"/admin/", "/swagger/", "/healthz", "/readyz", "/metrics"}
+
+// IsReservedPath reports whether the gateway itself serves path.
+func IsReservedPath(path string) bool {
+	for _, r := range ReservedPaths {
+		if path == r || path == strings.TrimSuffix(r, "/") || strings.HasSuffix(r, "/") && strings.HasPrefix(path, r) {
+			return true
+		}
+	}
+	return false
+}
+
+// ReservedConflict returns the reserved path a service with public prefix would capture or
+// be nested under, if any.
+func ReservedConflict(prefix string) (string, bool) {
+	for _, r := range ReservedPaths {
+		if strings.HasPrefix(r, prefix) || prefix == strings.TrimSuffix(r, "/") || strings.HasSuffix(r, "/") && strings.HasPrefix(prefix, r) {
+			return r, true
+		}
+	}
+	return "", false
+}
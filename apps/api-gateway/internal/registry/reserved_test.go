@@ -0,0 +1,43 @@
+package registry
+
+import "testing"
+
+func TestReservedConflict(t *testing.T) {
+	tests := []struct {
+		prefix string
+		want   string
+	}{
+		{"/catalog.v1.CatalogService/", ""},
+		{"/api/catalog/", ""},
+		{"/", "/admin/"},
+		{"/admin/", "/admin/"},
+		{"/adm", "/admin/"},
+		{"/admin/v1/", "/admin/"},
+		{"/swagger/ui/", "/swagger/"},
+		{"/healthz", "/healthz"},
+		{"/health", "/healthz"},
+		{"/healthz/", ""}, // /healthz is an exact path, not a subtree
+		{"/metrics-exporter/", ""},
+	}
+	for _, tt := range tests {
+		got, ok := ReservedConflict(tt.prefix)
+		if got != tt.want || ok != (tt.want != "") {
+			t.Errorf("ReservedConflict(%q) = %q, %v; want %q", tt.prefix, got, ok, tt.want)
+		}
+	}
+}
+
+func TestIsReservedPath(t *testing.T) {
+	for path, want := range map[string]bool{
+		"/admin/v1/services": true,
+		"/admin":             true,
+		"/healthz":           true,
+		"/healthz/x":         false,
+		"/api/admin/":        false,
+		"/pkg.Svc/Method":    false,
+	} {
+		if got := IsReservedPath(path); got != want {
+			t.Errorf("IsReservedPath(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
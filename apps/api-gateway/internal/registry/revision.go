@@ -0,0 +1,325 @@
+package registry
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrStaleDraft is returned when publishing a draft whose base is no longer the live registry.
+var ErrStaleDraft = errors.New("the live registry changed since the draft was created")
+
+// Snapshot is the configuration of a whole registry: every service, with its fetched swagger
+// document, and every route. Taken through InNamespace, it is that of one namespace, as drafts
+// and revisions hold them; descriptor sets, upstream TLS settings and health state are not part
+// of snapshots.
+type Snapshot struct {
+	Services []*Service `json:"services"`
+	Routes   []*Route   `json:"routes"`
+}
+
+// Draft is a staged copy of the registry. Services and routes of a draft are edited like live
+// ones without affecting routing; publishing makes the live registry match the draft in one
+// transaction and records a Revision.
+type Draft struct {
+	ID          string `json:"id"`
+	Namespace   string `json:"namespace"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	// Base is the fingerprint of the live registry the draft was copied from. Publishing
+	// requires the live registry to be unchanged since (ErrStaleDraft).
+	Base      string    `json:"base"`
+	Snapshot  *Snapshot `json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// Version increases on every change to the draft, like Service.Version.
+	Version int64 `json:"version"`
+}
+
+// Revision sources.
+const (
+	// RevisionSourcePublish revisions are published drafts.
+	RevisionSourcePublish = "publish"
+	// RevisionSourceRollback revisions restore an earlier revision.
+	RevisionSourceRollback = "rollback"
+	// RevisionSourcePromote revisions copy a service from another namespace.
+	RevisionSourcePromote = "promote"
+	// RevisionSourceCapture revisions record the live registry before a publish or rollback when
+	// it was changed outside of revisions (directly through the Admin API, or before the first
+	// revision), so that those states can be rolled back to as well.
+	RevisionSourceCapture = "capture"
+)
+
+// Revision is a numbered state of the live registry of a namespace. Numbers start at 1 and
+// increase with every publish, rollback and promotion in any namespace.
+type Revision struct {
+	Number    int64  `json:"number"`
+	Namespace string `json:"namespace"`
+	Source    string `json:"source"`
+	Message   string `json:"message,omitempty"`
+	// DraftID is the draft a publish revision came from.
+	DraftID string `json:"draft_id,omitempty"`
+	// RollbackOf is the revision a rollback revision restored.
+	RollbackOf int64 `json:"rollback_of,omitempty"`
+	// Fingerprint identifies Snapshot (see Snapshot.Fingerprint).
+	Fingerprint string `json:"fingerprint"`
+	// Changes made the previous live registry into this revision.
+	Changes   []SnapshotChange `json:"changes"`
+	Snapshot  *Snapshot        `json:"snapshot,omitempty"`
+	CreatedAt time.Time        `json:"created_at"`
+}
+
+// TakeSnapshot reads every service and route of repo.
+func TakeSnapshot(ctx context.Context, repo Repository) (*Snapshot, error) {
+	list, err := repo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	snap := &Snapshot{Services: []*Service{}, Routes: []*Route{}}
+	for _, s := range list {
+		// List leaves out the swagger document
+		svc, err := repo.Get(ctx, s.ID)
+		if err != nil {
+			return nil, err
+		}
+		snap.Services = append(snap.Services, svc)
+		routes, err := repo.ListRoutes(ctx, s.ID)
+		if err != nil {
+			return nil, err
+		}
+		snap.Routes = append(snap.Routes, routes...)
+	}
+	sort.Slice(snap.Services, func(i, j int) bool { return snap.Services[i].ID < snap.Services[j].ID })
+	sort.Slice(snap.Routes, func(i, j int) bool { return snap.Routes[i].ID < snap.Routes[j].ID })
+	return snap, nil
+}
+
+// Fingerprint identifies the configuration state of the snapshot by the IDs and versions of
+// its services and routes. Every configuration change bumps a version, health probes don't.
+func (s *Snapshot) Fingerprint() string {
+	var lines []string
+	for _, svc := range s.Services {
+		lines = append(lines, fmt.Sprintf("service %s %d", svc.ID, svc.Version))
+	}
+	for _, rt := range s.Routes {
+		lines = append(lines, fmt.Sprintf("route %s %d", rt.ID, rt.Version))
+	}
+	sort.Strings(lines)
+	sum := sha256.Sum256([]byte(strings.Join(lines, "\n")))
+	return hex.EncodeToString(sum[:])
+}
+
+// Snapshot change actions.
+const (
+	ChangeCreate = "create"
+	ChangeUpdate = "update"
+	ChangeDelete = "delete"
+)
+
+// SnapshotChange is one difference between two snapshots. Services and routes are matched by
+// ID, so renaming a service or changing its prefix is an update.
+type SnapshotChange struct {
+	Action string `json:"action"`
+	// Kind is "service" or "route".
+	Kind string `json:"kind"`
+	ID   string `json:"id"`
+	// Service is the public prefix of the service the change belongs to.
+	Service string `json:"service"`
+	// Route is "METHOD path" for route changes.
+	Route string `json:"route,omitempty"`
+	// Fields lists the fields an update changes.
+	Fields []string `json:"fields,omitempty"`
+
+	svc   *Service // state to write; the current service for deletions
+	route *Route   // likewise for routes
+}
+
+func (c SnapshotChange) String() string {
+	s := c.Action + " " + c.Kind + " " + c.Service
+	if c.Route != "" {
+		s += " " + c.Route
+	}
+	if len(c.Fields) > 0 {
+		s += " (" + strings.Join(c.Fields, ", ") + ")"
+	}
+	return s
+}
+
+// DiffSnapshots returns the changes that make from into to, in an order that can be applied:
+// route deletions, service deletions, service creations and updates, then route updates and
+// creations. Routes of deleted services go with them. A route that moved to another service is
+// deleted and created again.
+func DiffSnapshots(from, to *Snapshot) []SnapshotChange {
+	fromSvc, toSvc := servicesByID(from), servicesByID(to)
+	fromRoute, toRoute := routesByID(from), routesByID(to)
+	prefix := func(id string) string {
+		if s := toSvc[id]; s != nil {
+			return s.PublicPrefix
+		}
+		if s := fromSvc[id]; s != nil {
+			return s.PublicPrefix
+		}
+		return id
+	}
+	routeChange := func(action string, rt *Route) SnapshotChange {
+		return SnapshotChange{Action: action, Kind: "route", ID: rt.ID, Service: prefix(rt.ServiceID), Route: strings.ToUpper(rt.Method) + " " + rt.Path, route: rt}
+	}
+	var routeDeletes, serviceDeletes, serviceCreates, serviceUpdates, routeUpdates, routeCreates []SnapshotChange
+	for _, rt := range from.Routes {
+		next := toRoute[rt.ID]
+		if toSvc[rt.ServiceID] == nil {
+			continue // deleted with its service
+		}
+		if next == nil || next.ServiceID != rt.ServiceID {
+			routeDeletes = append(routeDeletes, routeChange(ChangeDelete, rt))
+		}
+	}
+	for _, s := range from.Services {
+		if toSvc[s.ID] == nil {
+			serviceDeletes = append(serviceDeletes, SnapshotChange{Action: ChangeDelete, Kind: "service", ID: s.ID, Service: s.PublicPrefix, svc: s})
+		}
+	}
+	for _, s := range to.Services {
+		cur := fromSvc[s.ID]
+		if cur == nil {
+			serviceCreates = append(serviceCreates, SnapshotChange{Action: ChangeCreate, Kind: "service", ID: s.ID, Service: s.PublicPrefix, svc: s})
+			continue
+		}
+		if fields := serviceFields(cur, s); len(fields) > 0 {
+			next := *s
+			next.Version = cur.Version
+			serviceUpdates = append(serviceUpdates, SnapshotChange{Action: ChangeUpdate, Kind: "service", ID: s.ID, Service: s.PublicPrefix, Fields: fields, svc: &next})
+		}
+	}
+	for _, rt := range to.Routes {
+		cur := fromRoute[rt.ID]
+		if cur == nil || cur.ServiceID != rt.ServiceID {
+			routeCreates = append(routeCreates, routeChange(ChangeCreate, rt))
+			continue
+		}
+		if fields := routeFields(cur, rt); len(fields) > 0 {
+			next := *rt
+			next.Version = cur.Version
+			c := routeChange(ChangeUpdate, &next)
+			c.Fields = fields
+			routeUpdates = append(routeUpdates, c)
+		}
+	}
+	changes := []SnapshotChange{}
+	for _, group := range [][]SnapshotChange{routeDeletes, serviceDeletes, serviceCreates, serviceUpdates, routeUpdates, routeCreates} {
+		changes = append(changes, group...)
+	}
+	return changes
+}
+
+// ApplyChanges makes the changes of DiffSnapshots(cur, target) in repo, where cur is what repo
+// holds. Updates and deletions are conditional on the versions of cur, so a concurrent change
+// makes it fail with ErrVersionConflict; run it inside InTx to apply all changes or none.
+// Swapping the public prefixes (or route paths) of two existing entries fails with ErrDuplicate.
+func ApplyChanges(ctx context.Context, repo Repository, changes []SnapshotChange) error {
+	for _, c := range changes {
+		if err := ApplyChange(ctx, repo, c.Action, c.svc, c.route); err != nil {
+			return fmt.Errorf("%s: %w", c, err)
+		}
+	}
+	return nil
+}
+
+// ApplyChange makes one change in repo: action (ChangeCreate, ChangeUpdate or ChangeDelete) of
+// svc, or of rt when svc is nil. Updates and deletions are conditional on the version the
+// service or route carries and fail with ErrVersionConflict when it is stale.
+func ApplyChange(ctx context.Context, repo Repository, action string, svc *Service, rt *Route) error {
+	switch {
+	case svc != nil && action == ChangeCreate:
+		cp := *svc
+		return repo.Create(ctx, &cp)
+	case svc != nil && action == ChangeUpdate:
+		cp := *svc
+		return repo.Update(ctx, &cp)
+	case svc != nil && action == ChangeDelete:
+		return repo.Delete(ctx, svc.ID, svc.Version)
+	case rt != nil && action == ChangeCreate:
+		cp := *rt
+		return repo.CreateRoute(ctx, &cp)
+	case rt != nil && action == ChangeUpdate:
+		cp := *rt
+		return repo.UpdateRoute(ctx, &cp)
+	case rt != nil && action == ChangeDelete:
+		return repo.DeleteRoute(ctx, rt.ServiceID, rt.ID, rt.Version)
+	}
+	return fmt.Errorf("unknown change %q", action)
+}
+
+func servicesByID(s *Snapshot) map[string]*Service {
+	m := make(map[string]*Service, len(s.Services))
+	for _, svc := range s.Services {
+		m[svc.ID] = svc
+	}
+	return m
+}
+
+func routesByID(s *Snapshot) map[string]*Route {
+	m := make(map[string]*Route, len(s.Routes))
+	for _, rt := range s.Routes {
+		m[rt.ID] = rt
+	}
+	return m
+}
+
+// serviceFields names the configuration fields that differ between a and b.
+func serviceFields(a, b *Service) []string {
+	var fields []string
+	diff := func(name string, x, y any) {
+		if !reflect.DeepEqual(x, y) {
+			fields = append(fields, name)
+		}
+	}
+	diff("name", a.Name, b.Name)
+	diff("description", a.Description, b.Description)
+	diff("public_prefix", a.PublicPrefix, b.PublicPrefix)
+	diff("base_url", a.BaseURL, b.BaseURL)
+	diff("swagger_url", a.SwaggerURL, b.SwaggerURL)
+	diff("protocol", a.Protocol, b.Protocol)
+	diff("grpc_target", a.GRPCTarget, b.GRPCTarget)
+	diff("descriptor_source", descriptorSource(a), descriptorSource(b))
+	diff("metadata", a.Metadata, b.Metadata)
+	diff("streaming", a.Streaming, b.Streaming)
+	diff("labels", nilIfEmpty(a.Labels), nilIfEmpty(b.Labels))
+	diff("enabled", a.Enabled, b.Enabled)
+	diff("swagger_json", a.SwaggerJSON, b.SwaggerJSON)
+	return fields
+}
+
+// routeFields names the fields that differ between a and b.
+func routeFields(a, b *Route) []string {
+	var fields []string
+	diff := func(name string, x, y any) {
+		if !reflect.DeepEqual(x, y) {
+			fields = append(fields, name)
+		}
+	}
+	diff("method", strings.ToUpper(a.Method), strings.ToUpper(b.Method))
+	diff("path", a.Path, b.Path)
+	diff("grpc_method", a.GRPCMethod, b.GRPCMethod)
+	diff("query_mapping", nilIfEmpty(a.QueryMapping), nilIfEmpty(b.QueryMapping))
+	diff("body", a.Body, b.Body)
+	diff("response_body", a.ResponseBody, b.ResponseBody)
+	diff("strict_query", a.StrictQuery, b.StrictQuery)
+	diff("params_override_body", a.ParamsOverrideBody, b.ParamsOverrideBody)
+	diff("source", routeSource(a), routeSource(b))
+	return fields
+}
+
+// nilIfEmpty treats an empty map like a nil one, as the JSONB columns do.
+func nilIfEmpty[M ~map[K]V, K comparable, V any](m M) M {
+	if len(m) == 0 {
+		return nil
+	}
+	return m
+}
@@ -0,0 +1,160 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// seed stores a grpc-json service with two routes and returns them.
+func seed(t *testing.T, repo Repository) (*Service, *Route, *Route) {
+	t.Helper()
+	ctx := context.Background()
+	svc := &Service{ID: uuid.NewString(), Name: "catalog", PublicPrefix: "/api/catalog/", Protocol: "grpc-json", GRPCTarget: "catalog:9090", Enabled: true}
+	get := &Route{ID: uuid.NewString(), ServiceID: svc.ID, Method: "GET", Path: "/v1/items/{id}", GRPCMethod: "catalog.v1.Catalog/GetItem"}
+	list := &Route{ID: uuid.NewString(), ServiceID: svc.ID, Method: "GET", Path: "/v1/items", GRPCMethod: "catalog.v1.Catalog/ListItems"}
+	if err := repo.Create(ctx, svc); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	for _, rt := range []*Route{get, list} {
+		if err := repo.CreateRoute(ctx, rt); err != nil {
+			t.Fatalf("CreateRoute: %v", err)
+		}
+	}
+	return svc, get, list
+}
+
+func changeStrings(changes []SnapshotChange) []string {
+	out := make([]string, 0, len(changes))
+	for _, c := range changes {
+		out = append(out, c.String())
+	}
+	return out
+}
+
+func TestDiffSnapshots(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+	_, get, list := seed(t, repo)
+	from, err := TakeSnapshot(ctx, repo)
+	if err != nil {
+		t.Fatalf("TakeSnapshot: %v", err)
+	}
+	if got := DiffSnapshots(from, from); len(got) != 0 {
+		t.Errorf("DiffSnapshots(same): got %q, want no changes", changeStrings(got))
+	}
+
+	to := copySnapshot(from)
+	to.Services[0].Description = "products"
+	to.Services[0].Labels = map[string]string{}
+	other := &Service{ID: uuid.NewString(), Name: "web", PublicPrefix: "/api/web/", Protocol: "http", BaseURL: "http://web", Enabled: true}
+	to.Services = append(to.Services, other)
+	var routes []*Route
+	for _, rt := range to.Routes {
+		if rt.ID == list.ID {
+			continue // dropped
+		}
+		if rt.ID == get.ID {
+			rt.Body = RouteBodyNone
+		}
+		routes = append(routes, rt)
+	}
+	routes = append(routes, &Route{ID: uuid.NewString(), ServiceID: get.ServiceID, Method: "post", Path: "/v1/items", GRPCMethod: "catalog.v1.Catalog/CreateItem"})
+	to.Routes = routes
+
+	want := []string{
+		"delete route /api/catalog/ GET /v1/items",
+		"create service /api/web/",
+		"update service /api/catalog/ (description)",
+		"update route /api/catalog/ GET /v1/items/{id} (body)",
+		"create route /api/catalog/ POST /v1/items",
+	}
+	changes := DiffSnapshots(from, to)
+	if got := changeStrings(changes); !reflect.DeepEqual(got, want) {
+		t.Fatalf("DiffSnapshots: got %q, want %q", got, want)
+	}
+
+	if err := ApplyChanges(ctx, repo, changes); err != nil {
+		t.Fatalf("ApplyChanges: %v", err)
+	}
+	after, err := TakeSnapshot(ctx, repo)
+	if err != nil {
+		t.Fatalf("TakeSnapshot: %v", err)
+	}
+	// applied, the target is reached except for the bookkeeping the repository owns
+	if rest := DiffSnapshots(after, to); len(rest) != 0 {
+		t.Errorf("after ApplyChanges: still differs by %q", changeStrings(rest))
+	}
+
+	// deleting the service takes its routes along
+	empty := &Snapshot{Services: []*Service{}, Routes: []*Route{}}
+	want = []string{"delete service /api/catalog/", "delete service /api/web/"}
+	got := changeStrings(DiffSnapshots(after, empty))
+	if len(got) != 2 || !(reflect.DeepEqual(got, want) || reflect.DeepEqual(got, []string{want[1], want[0]})) {
+		t.Errorf("DiffSnapshots(to empty): got %q, want only the two service deletions", got)
+	}
+}
+
+// A route that moved to another service is deleted and created again.
+func TestDiffSnapshotsMovedRoute(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository()
+	_, get, _ := seed(t, repo)
+	from, err := TakeSnapshot(ctx, repo)
+	if err != nil {
+		t.Fatalf("TakeSnapshot: %v", err)
+	}
+	to := copySnapshot(from)
+	other := &Service{ID: uuid.NewString(), Name: "items", PublicPrefix: "/api/items/", Protocol: "grpc-json", GRPCTarget: "items:9090", Enabled: true}
+	to.Services = append(to.Services, other)
+	for _, rt := range to.Routes {
+		if rt.ID == get.ID {
+			rt.ServiceID = other.ID
+		}
+	}
+	want := []string{
+		"delete route /api/catalog/ GET /v1/items/{id}",
+		"create service /api/items/",
+		"create route /api/items/ GET /v1/items/{id}",
+	}
+	if got := changeStrings(DiffSnapshots(from, to)); !reflect.DeepEqual(got, want) {
+		t.Errorf("DiffSnapshots: got %q, want %q", got, want)
+	}
+}
+
+// Changes computed from a snapshot fail once the repository moved on.
+func TestApplyChangesConflict(t *testing.T) {
+	ctx := context.Background()
+	for _, tt := range []struct {
+		name string
+		edit func(to *Snapshot)
+	}{
+		{"update", func(to *Snapshot) { to.Services[0].Description = "products" }},
+		{"delete", func(to *Snapshot) { to.Services, to.Routes = []*Service{}, []*Route{} }},
+	} {
+		repo := NewMemoryRepository()
+		svc, _, _ := seed(t, repo)
+		from, err := TakeSnapshot(ctx, repo)
+		if err != nil {
+			t.Fatalf("TakeSnapshot: %v", err)
+		}
+		to := copySnapshot(from)
+		tt.edit(to)
+		changes := DiffSnapshots(from, to)
+
+		concurrent := *from.Services[0]
+		concurrent.Name = "catalog-v2"
+		if err := repo.Update(ctx, &concurrent); err != nil {
+			t.Fatalf("Update: %v", err)
+		}
+		if err := ApplyChanges(ctx, repo, changes); !errors.Is(err, ErrVersionConflict) {
+			t.Errorf("%s: ApplyChanges after a concurrent update: got %v, want ErrVersionConflict", tt.name, err)
+		}
+		if _, err := repo.Get(ctx, svc.ID); err != nil {
+			t.Errorf("%s: Get: %v, want the service kept", tt.name, err)
+		}
+	}
+}
@@ -2,8 +2,9 @@ package registry
 
 import "time"
 
-// RouteQueryMapEntry maps a query param to an RPC field with a type hint.
-// Type can be: string (default), int, float, bool.
+// RouteQueryMapEntry renames a query param to an RPC field path. Query params without an entry
+// map to the field of the same name; values are converted using the input message descriptor,
+// so Type is only a hint kept for older routes.
 type RouteQueryMapEntry struct {
 	Field string `json:"field"`
 	Type  string `json:"type"`
@@ -11,17 +12,57 @@ type RouteQueryMapEntry struct {
 
 type RouteQueryMapping map[string]RouteQueryMapEntry
 
+// Route sources record who owns a route definition.
+const (
+	// RouteSourceManual routes are created or edited by operators.
+	RouteSourceManual = "manual"
+	// RouteSourceAnnotation routes are derived from google.api.http options and kept in sync on
+	// rediscovery. Editing one through the Admin API detaches it (it becomes manual).
+	RouteSourceAnnotation = "annotation"
+	// RouteSourceGenerated routes were inferred from gRPC method names by the route planner.
+	// Replanning may update or delete them; editing one through the Admin API makes it manual.
+	RouteSourceGenerated = "generated"
+	// RouteSourceDeclared routes come from registry configuration files (package gitops), which
+	// create, update and delete them on reconciliation. Editing one through the Admin API makes
+	// it manual, so reconciliation stops owning it.
+	RouteSourceDeclared = "declared"
+)
+
+// RouteBodyNone as Route.Body ignores the request body; the input message is built from
+// path and query params only. It can't be mistaken for a field path. An empty Body always
+// means the whole body, as "*" does.
+const RouteBodyNone = "-"
+
 // Route maps an incoming REST method+path (under a service's public prefix)
 // to a gRPC full method name (package.Service/Method) for transcoding.
-// Path can contain template params like {id} or with type hints {id:int}.
+// Path is a template (see package routing): literals, {id}, constrained {id:int} / {id:uuid} /
+// {id:[0-9]+}, multi-segment {path=**} and google.api.http sub-templates. When several routes
+// match, literal segments beat variables and constrained variables beat plain ones.
 // QueryMapping optionally maps query parameters to RPC fields with type hints.
+// Body and ResponseBody follow google.api.http: Body "" or "*" maps the whole request body
+// onto the input message, RouteBodyNone ignores it and a field path ("product", "order.item")
+// maps it onto that field; ResponseBody selects a single (possibly nested) output field as the
+// response. Path variables may be field paths too ({product.id}). Body values win over path and
+// query params unless ParamsOverrideBody is set.
 type Route struct {
-	ID           string            `json:"id"`
-	ServiceID    string            `json:"service_id"`
+	ID        string `json:"id"`
+	ServiceID string `json:"service_id"`
+	// Namespace is the namespace of the service, set by the repository.
+	Namespace    string            `json:"namespace,omitempty"`
 	Method       string            `json:"method"`
 	Path         string            `json:"path"`
 	GRPCMethod   string            `json:"grpc_method"`
 	QueryMapping RouteQueryMapping `json:"query_mapping,omitempty"`
-	CreatedAt    time.Time         `json:"created_at"`
-	UpdatedAt    time.Time         `json:"updated_at"`
+	Body         string            `json:"body,omitempty"`
+	ResponseBody string            `json:"response_body,omitempty"`
+	// StrictQuery rejects unknown or mistyped query params with 400 instead of ignoring them.
+	StrictQuery bool `json:"strict_query,omitempty"`
+	// ParamsOverrideBody lets path and query params replace values sent in the body.
+	ParamsOverrideBody bool      `json:"params_override_body,omitempty"`
+	Source             string    `json:"source,omitempty"`
+	CreatedAt          time.Time `json:"created_at"`
+	UpdatedAt          time.Time `json:"updated_at"`
+	// Version increases on every change; updates carrying a non-zero Version only apply if it
+	// is still current.
+	Version int64 `json:"version"`
 }
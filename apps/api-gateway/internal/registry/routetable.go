@@ -0,0 +1,90 @@
+package registry
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"ecomm/api-gateway/internal/routing"
+)
+
+// CompiledRoute is a route with its parsed path template.
+type CompiledRoute struct {
+	Route    *Route
+	Template *routing.Template
+}
+
+// InvalidRoute is a stored route whose template failed to compile; it never matches.
+type InvalidRoute struct {
+	Route *Route `json:"route"`
+	Error string `json:"error"`
+}
+
+// RouteTable is the compiled, immutable set of routes for one service. Routes are grouped by
+// HTTP method and ordered by template precedence, so the first match is the best one.
+// Tables are replaced wholesale when routes change, never modified in place.
+type RouteTable struct {
+	ServiceID string
+	LoadedAt  time.Time
+	byMethod  map[string][]CompiledRoute
+	invalid   []InvalidRoute
+}
+
+// CompileRoutes builds the route table for serviceID.
+func CompileRoutes(serviceID string, routes []*Route) *RouteTable {
+	t := &RouteTable{ServiceID: serviceID, LoadedAt: time.Now(), byMethod: map[string][]CompiledRoute{}}
+	for _, rt := range routes {
+		tmpl, err := routing.Parse(rt.Path)
+		if err != nil {
+			t.invalid = append(t.invalid, InvalidRoute{Route: rt, Error: err.Error()})
+			continue
+		}
+		m := strings.ToUpper(rt.Method)
+		t.byMethod[m] = append(t.byMethod[m], CompiledRoute{Route: rt, Template: tmpl})
+	}
+	for _, list := range t.byMethod {
+		sort.SliceStable(list, func(i, j int) bool { return routing.Compare(list[i].Template, list[j].Template) > 0 })
+	}
+	return t
+}
+
+// Match returns the highest-precedence route for method+path and its extracted params.
+func (t *RouteTable) Match(method, path string) (*Route, map[string]any) {
+	if t == nil {
+		return nil, nil
+	}
+	for _, c := range t.byMethod[strings.ToUpper(method)] {
+		if pm, ok := c.Template.Match(path); ok {
+			return c.Route, pm
+		}
+	}
+	return nil, nil
+}
+
+// Routes returns the compiled routes, by method then precedence.
+func (t *RouteTable) Routes() []CompiledRoute {
+	methods := make([]string, 0, len(t.byMethod))
+	for m := range t.byMethod {
+		methods = append(methods, m)
+	}
+	sort.Strings(methods)
+	var out []CompiledRoute
+	for _, m := range methods {
+		out = append(out, t.byMethod[m]...)
+	}
+	return out
+}
+
+// Invalid returns the routes that failed to compile.
+func (t *RouteTable) Invalid() []InvalidRoute { return t.invalid }
+
+// ReloadRoutes recompiles the route table of one service and swaps it into reg.
+func ReloadRoutes(ctx context.Context, repo Repository, reg *Registry, serviceID string) error {
+	routes, err := repo.ListRoutes(ctx, serviceID)
+	if err != nil {
+		return err
+	}
+	reg.SetRoutes(CompileRoutes(serviceID, routes))
+	return nil
+}
@@ -0,0 +1,131 @@
+package registry
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// countingRepo counts the route reads that reach the repository.
+type countingRepo struct {
+	Repository
+	routeReads int
+}
+
+func (c *countingRepo) ListRoutes(ctx context.Context, serviceID string) ([]*Route, error) {
+	c.routeReads++
+	return c.Repository.ListRoutes(ctx, serviceID)
+}
+
+func (c *countingRepo) FindRoute(ctx context.Context, serviceID, method, path string) (*Route, error) {
+	c.routeReads++
+	return c.Repository.FindRoute(ctx, serviceID, method, path)
+}
+
+func TestCompileRoutes(t *testing.T) {
+	routes := []*Route{
+		{ID: "wild", Method: "get", Path: "/v1/{name=**}", GRPCMethod: "a.v1.A/Wild"},
+		{ID: "list", Method: "GET", Path: "/v1/shelves", GRPCMethod: "a.v1.A/List"},
+		{ID: "get", Method: "GET", Path: "/v1/shelves/{id}", GRPCMethod: "a.v1.A/Get"},
+		{ID: "create", Method: "POST", Path: "/v1/shelves", GRPCMethod: "a.v1.A/Create"},
+		{ID: "broken", Method: "GET", Path: "/v1/{id", GRPCMethod: "a.v1.A/Broken"},
+	}
+	table := CompileRoutes("svc", routes)
+
+	tests := []struct {
+		method, path string
+		want         string
+		param        string
+	}{
+		{"GET", "/v1/shelves", "list", ""},
+		{"get", "/v1/shelves/7", "get", "7"},
+		{"GET", "/v1/shelves/7/books/1", "wild", "shelves/7/books/1"},
+		{"POST", "/v1/shelves", "create", ""},
+		{"DELETE", "/v1/shelves/7", "", ""},
+		{"GET", "/v2/shelves", "", ""},
+	}
+	for _, tt := range tests {
+		rt, params := table.Match(tt.method, tt.path)
+		got := ""
+		if rt != nil {
+			got = rt.ID
+		}
+		if got != tt.want {
+			t.Errorf("%s %s: matched %q, want %q", tt.method, tt.path, got, tt.want)
+			continue
+		}
+		var param any
+		switch tt.want {
+		case "get":
+			param = params["id"]
+		case "wild":
+			param = params["name"]
+		}
+		if tt.param != "" && param != tt.param {
+			t.Errorf("%s %s: param %v, want %q", tt.method, tt.path, param, tt.param)
+		}
+	}
+
+	if inv := table.Invalid(); len(inv) != 1 || inv[0].Route.ID != "broken" || inv[0].Error == "" {
+		t.Errorf("Invalid() = %+v, want the broken route with its error", inv)
+	}
+	var order []string
+	for _, c := range table.Routes() {
+		order = append(order, c.Route.ID)
+	}
+	if got, want := strings.Join(order, ","), "get,list,wild,create"; got != want {
+		t.Errorf("Routes() order = %s, want %s", got, want)
+	}
+	var nilTable *RouteTable
+	if rt, _ := nilTable.Match("GET", "/v1/shelves"); rt != nil {
+		t.Error("a missing table matched a route")
+	}
+}
+
+func TestRouteLookupsStayInMemory(t *testing.T) {
+	ctx := context.Background()
+	repo := &countingRepo{Repository: NewMemoryRepository()}
+	svc := &Service{ID: uuid.NewString(), Name: "shelves", PublicPrefix: "/api/shelves/", Protocol: "grpc-json", GRPCTarget: "dns:///shelves:9090", Enabled: true}
+	if err := repo.Create(ctx, svc); err != nil {
+		t.Fatal(err)
+	}
+	rt := &Route{ID: uuid.NewString(), ServiceID: svc.ID, Method: "GET", Path: "/v1/shelves/{id}", GRPCMethod: "a.v1.A/Get"}
+	if err := repo.CreateRoute(ctx, rt); err != nil {
+		t.Fatal(err)
+	}
+	reg := New()
+	if err := LoadEnabled(repo, reg); err != nil {
+		t.Fatal(err)
+	}
+
+	reads := repo.routeReads
+	for i := 0; i < 100; i++ {
+		if got, _ := reg.Routes(svc.ID).Match("GET", "/v1/shelves/1"); got == nil || got.ID != rt.ID {
+			t.Fatalf("Match = %v, want route %s", got, rt.ID)
+		}
+	}
+	if repo.routeReads != reads {
+		t.Fatalf("lookups read routes from the repository %d times", repo.routeReads-reads)
+	}
+
+	// A stored route appears only once the table is reloaded.
+	added := &Route{ID: uuid.NewString(), ServiceID: svc.ID, Method: "POST", Path: "/v1/shelves", GRPCMethod: "a.v1.A/Create"}
+	if err := repo.CreateRoute(ctx, added); err != nil {
+		t.Fatal(err)
+	}
+	before := reg.Routes(svc.ID)
+	if got, _ := before.Match("POST", "/v1/shelves"); got != nil {
+		t.Fatal("the table changed without a reload")
+	}
+	if err := ReloadRoutes(ctx, repo, reg, svc.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := reg.Routes(svc.ID).Match("POST", "/v1/shelves"); got == nil || got.ID != added.ID {
+		t.Fatalf("after reload Match = %v, want route %s", got, added.ID)
+	}
+	if got, _ := before.Match("POST", "/v1/shelves"); got != nil {
+		t.Fatal("reload modified the previous table in place")
+	}
+}
@@ -4,18 +4,34 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"regexp"
 	"strings"
+	"time"
+
+	"github.com/lib/pq"
+
+	"ecomm/api-gateway/internal/secrets"
 )
 
 type SQLRepository struct {
-	db     *sql.DB
-	schema string
+	db      dbtx
+	conn    *sql.DB // nil for repositories bound to a transaction (see InTx)
+	schema  string
+	secrets *secrets.Box
 }
 
-// NewSQLRepository creates a repository using the provided schema (e.g., "gateway").
-// If schema is empty, "public" will be used. Only [a-z_][a-z0-9_]* are allowed to prevent SQL injection.
+// dbtx is what the repository queries through: the *sql.DB, or the *sql.Tx inside InTx.
+type dbtx interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// NewSQLRepository creates a repository using the provided schema (e.g., "gateway"), whose
+// tables package migrate creates. If schema is empty, "public" will be used. Only [a-z_][a-z0-9_]*
+// are allowed to prevent SQL injection.
 func NewSQLRepository(db *sql.DB, schema string) *SQLRepository {
 	if schema == "" {
 		schema = "public"
@@ -24,101 +40,80 @@ func NewSQLRepository(db *sql.DB, schema string) *SQLRepository {
 	if !valid.MatchString(schema) {
 		schema = "public"
 	}
-	return &SQLRepository{db: db, schema: schema}
+	return &SQLRepository{db: db, conn: db, schema: schema}
 }
 
-func (r *SQLRepository) table() string { return fmt.Sprintf("%s.gateway_services", r.schema) }
-
-func (r *SQLRepository) Init() error {
-	if _, err := r.db.Exec(fmt.Sprintf("CREATE SCHEMA IF NOT EXISTS %s", r.schema)); err != nil {
-		return err
+// InTx runs fn in one database transaction. Transactions don't nest: calling InTx on the
+// repository passed to fn is an error.
+func (r *SQLRepository) InTx(ctx context.Context, fn func(tx Repository) error) error {
+	if r.conn == nil {
+		return errors.New("registry: transactions cannot be nested")
 	}
-	_, err := r.db.Exec(fmt.Sprintf(`
-	CREATE TABLE IF NOT EXISTS %s (
-	  id UUID PRIMARY KEY,
-	  name TEXT NOT NULL,
-	  description TEXT,
-	  public_prefix TEXT NOT NULL UNIQUE,
-	  base_url TEXT NOT NULL,
-	  swagger_url TEXT NOT NULL,
-	  protocol TEXT NOT NULL DEFAULT 'http',
-	  grpc_target TEXT,
-	  enabled BOOLEAN NOT NULL DEFAULT TRUE,
-	  swagger_json JSONB,
-	  last_refreshed_at TIMESTAMPTZ,
-	  last_health_at TIMESTAMPTZ,
-	  last_status TEXT,
-	  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
-	  updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
-	);`, r.table()))
+	tx, err := r.conn.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
-	// Ensure new columns exist on older tables
-	if _, err := r.db.Exec(fmt.Sprintf(`ALTER TABLE %s ADD COLUMN IF NOT EXISTS protocol TEXT NOT NULL DEFAULT 'http'`, r.table())); err != nil {
+	if err := fn(&SQLRepository{db: tx, schema: r.schema, secrets: r.secrets}); err != nil {
+		_ = tx.Rollback()
 		return err
 	}
-	if _, err := r.db.Exec(fmt.Sprintf(`ALTER TABLE %s ADD COLUMN IF NOT EXISTS grpc_target TEXT`, r.table())); err != nil {
-		return err
+	return tx.Commit()
+}
+
+// WithSecretBox sets the Box used to encrypt upstream TLS settings at rest.
+// Without one, TLS settings cannot be stored.
+func (r *SQLRepository) WithSecretBox(b *secrets.Box) *SQLRepository {
+	r.secrets = b
+	return r
+}
+
+func (r *SQLRepository) table() string { return fmt.Sprintf("%s.gateway_services", r.schema) }
+
+// routeColumns is the column list scanned by scanRoute.
+const routeColumns = `id, service_id, namespace, method, path_pattern, grpc_method, COALESCE(query_mapping,'{}'::jsonb), body, response_body, source, params_override_body, strict_query, created_at, updated_at, version`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanRoute(row rowScanner) (*Route, error) {
+	var rt Route
+	var qm json.RawMessage
+	if err := row.Scan(&rt.ID, &rt.ServiceID, &rt.Namespace, &rt.Method, &rt.Path, &rt.GRPCMethod, &qm, &rt.Body, &rt.ResponseBody, &rt.Source, &rt.ParamsOverrideBody, &rt.StrictQuery, &rt.CreatedAt, &rt.UpdatedAt, &rt.Version); err != nil {
+		return nil, err
 	}
-	// Routes table
-	if _, err := r.db.Exec(fmt.Sprintf(`
-	CREATE TABLE IF NOT EXISTS %s.gateway_routes (
-	  id UUID PRIMARY KEY,
-	  service_id UUID NOT NULL REFERENCES %s.gateway_services(id) ON DELETE CASCADE,
-	  method TEXT NOT NULL,
-	  path_pattern TEXT NOT NULL,
-	  grpc_method TEXT NOT NULL,
-	  query_mapping JSONB,
-	  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
-	  updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
-	  UNIQUE(service_id, method, path_pattern)
-	);`, r.schema, r.schema)); err != nil {
-		return err
+	if len(qm) > 0 {
+		var m RouteQueryMapping
+		_ = json.Unmarshal(qm, &m)
+		rt.QueryMapping = m
 	}
-	return nil
+	return &rt, nil
 }
 
 // --- Route methods ---
 
 func (r *SQLRepository) ListRoutes(ctx context.Context, serviceID string) ([]*Route, error) {
-	q := fmt.Sprintf(`SELECT id, service_id, method, path_pattern, grpc_method, COALESCE(query_mapping,'{}'::jsonb), created_at, updated_at FROM %s.gateway_routes WHERE service_id = $1 ORDER BY path_pattern ASC`, r.schema)
+	q := fmt.Sprintf(`SELECT %s FROM %s.gateway_routes WHERE service_id = $1 ORDER BY path_pattern ASC`, routeColumns, r.schema)
 	rows, err := r.db.QueryContext(ctx, q, serviceID)
 	if err != nil {
-		return nil, err
+		return nil, storeError(err)
 	}
 	defer rows.Close()
 	var list []*Route
 	for rows.Next() {
-		var rt Route
-		var qm json.RawMessage
-		if err := rows.Scan(&rt.ID, &rt.ServiceID, &rt.Method, &rt.Path, &rt.GRPCMethod, &qm, &rt.CreatedAt, &rt.UpdatedAt); err != nil {
+		rt, err := scanRoute(rows)
+		if err != nil {
 			return nil, err
 		}
-		if len(qm) > 0 {
-			var m RouteQueryMapping
-			_ = json.Unmarshal(qm, &m)
-			rt.QueryMapping = m
-		}
-		list = append(list, &rt)
+		list = append(list, rt)
 	}
 	return list, nil
 }
 
 func (r *SQLRepository) GetRoute(ctx context.Context, serviceID, routeID string) (*Route, error) {
-	q := fmt.Sprintf(`SELECT id, service_id, method, path_pattern, grpc_method, COALESCE(query_mapping,'{}'::jsonb), created_at, updated_at FROM %s.gateway_routes WHERE service_id=$1 AND id=$2`, r.schema)
-	row := r.db.QueryRowContext(ctx, q, serviceID, routeID)
-	var rt Route
-	var qm json.RawMessage
-	if err := row.Scan(&rt.ID, &rt.ServiceID, &rt.Method, &rt.Path, &rt.GRPCMethod, &qm, &rt.CreatedAt, &rt.UpdatedAt); err != nil {
-		return nil, err
-	}
-	if len(qm) > 0 {
-		var m RouteQueryMapping
-		_ = json.Unmarshal(qm, &m)
-		rt.QueryMapping = m
-	}
-	return &rt, nil
+	q := fmt.Sprintf(`SELECT %s FROM %s.gateway_routes WHERE service_id=$1 AND id=$2`, routeColumns, r.schema)
+	rt, err := scanRoute(r.db.QueryRowContext(ctx, q, serviceID, routeID))
+	return rt, storeError(err)
 }
 
 func (r *SQLRepository) CreateRoute(ctx context.Context, rt *Route) error {
@@ -128,9 +123,10 @@ func (r *SQLRepository) CreateRoute(ctx context.Context, rt *Route) error {
 			qm = string(b)
 		}
 	}
-	q := fmt.Sprintf(`INSERT INTO %s.gateway_routes (id, service_id, method, path_pattern, grpc_method, query_mapping) VALUES ($1,$2,$3,$4,$5,$6)`, r.schema)
-	_, err := r.db.ExecContext(ctx, q, rt.ID, rt.ServiceID, strings.ToUpper(rt.Method), rt.Path, rt.GRPCMethod, qm)
-	return err
+	// routes are in the namespace of their service
+	q := fmt.Sprintf(`INSERT INTO %[1]s.gateway_routes (id, service_id, namespace, method, path_pattern, grpc_method, query_mapping, body, response_body, source, params_override_body, strict_query)
+	VALUES ($1, $2, COALESCE((SELECT namespace FROM %[1]s.gateway_services WHERE id = $2::uuid), 'default'), $3,$4,$5,$6,$7,$8,$9,$10,$11) RETURNING namespace, version`, r.schema)
+	return storeError(r.db.QueryRowContext(ctx, q, rt.ID, rt.ServiceID, strings.ToUpper(rt.Method), rt.Path, rt.GRPCMethod, qm, rt.Body, rt.ResponseBody, routeSource(rt), rt.ParamsOverrideBody, rt.StrictQuery).Scan(&rt.Namespace, &rt.Version))
 }
 
 func (r *SQLRepository) UpdateRoute(ctx context.Context, rt *Route) error {
@@ -140,35 +136,79 @@ func (r *SQLRepository) UpdateRoute(ctx context.Context, rt *Route) error {
 			qm = string(b)
 		}
 	}
-	q := fmt.Sprintf(`UPDATE %s.gateway_routes SET method=$3, path_pattern=$4, grpc_method=$5, query_mapping=$6, updated_at=now() WHERE id=$1 AND service_id=$2`, r.schema)
-	_, err := r.db.ExecContext(ctx, q, rt.ID, rt.ServiceID, strings.ToUpper(rt.Method), rt.Path, rt.GRPCMethod, qm)
-	return err
+	q := fmt.Sprintf(`UPDATE %s.gateway_routes SET method=$3, path_pattern=$4, grpc_method=$5, query_mapping=$6, body=$7, response_body=$8, source=$9, params_override_body=$10, strict_query=$11, updated_at=now(), version=version+1 WHERE id=$1 AND service_id=$2 AND ($12 = 0 OR version = $12) RETURNING namespace, version, updated_at`, r.schema)
+	err := r.db.QueryRowContext(ctx, q, rt.ID, rt.ServiceID, strings.ToUpper(rt.Method), rt.Path, rt.GRPCMethod, qm, rt.Body, rt.ResponseBody, routeSource(rt), rt.ParamsOverrideBody, rt.StrictQuery, rt.Version).Scan(&rt.Namespace, &rt.Version, &rt.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) && rt.Version != 0 {
+		return r.versionConflict(ctx, fmt.Sprintf(`%s.gateway_routes WHERE service_id = $1 AND id = $2`, r.schema), rt.ServiceID, rt.ID)
+	}
+	return storeError(err)
 }
 
-func (r *SQLRepository) DeleteRoute(ctx context.Context, serviceID, routeID string) error {
-	q := fmt.Sprintf(`DELETE FROM %s.gateway_routes WHERE service_id=$1 AND id=$2`, r.schema)
-	_, err := r.db.ExecContext(ctx, q, serviceID, routeID)
+// versionConflict tells a stale version (ErrVersionConflict) from a missing row
+// (sql.ErrNoRows) after a conditional update matched nothing.
+func (r *SQLRepository) versionConflict(ctx context.Context, from string, args ...any) error {
+	var exists bool
+	if err := r.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM `+from+`)`, args...).Scan(&exists); err != nil {
+		return storeError(err)
+	}
+	if exists {
+		return ErrVersionConflict
+	}
+	return sql.ErrNoRows
+}
+
+// storeError translates the driver errors callers must tell apart: uniqueness violations
+// become ErrDuplicate, malformed values such as ids that aren't UUIDs ErrInvalidValue, and
+// references to missing services read as a missing row (sql.ErrNoRows).
+func storeError(err error) error {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return err
+	}
+	switch pqErr.Code {
+	case "23505": // unique_violation
+		switch {
+		case strings.Contains(pqErr.Constraint, "public_prefix"):
+			return fmt.Errorf("%w: public_prefix is already used by another service", ErrDuplicate)
+		case strings.Contains(pqErr.Constraint, "path_pattern"):
+			return fmt.Errorf("%w: the service already has a route with this method and path", ErrDuplicate)
+		}
+		return ErrDuplicate
+	case "22P02": // invalid_text_representation
+		return fmt.Errorf("%w: %s", ErrInvalidValue, pqErr.Message)
+	case "23503": // foreign_key_violation
+		return sql.ErrNoRows
+	}
 	return err
 }
 
-func (r *SQLRepository) FindRoute(ctx context.Context, serviceID, method, path string) (*Route, error) {
-	q := fmt.Sprintf(`SELECT id, service_id, method, path_pattern, grpc_method, COALESCE(query_mapping,'{}'::jsonb), created_at, updated_at FROM %s.gateway_routes WHERE service_id=$1 AND method=$2 AND path_pattern=$3`, r.schema)
-	row := r.db.QueryRowContext(ctx, q, serviceID, strings.ToUpper(method), path)
-	var rt Route
-	var qm json.RawMessage
-	if err := row.Scan(&rt.ID, &rt.ServiceID, &rt.Method, &rt.Path, &rt.GRPCMethod, &qm, &rt.CreatedAt, &rt.UpdatedAt); err != nil {
-		return nil, err
+// routeSource defaults an unset Route.Source to manual.
+func routeSource(rt *Route) string {
+	if rt.Source == "" {
+		return RouteSourceManual
 	}
-	if len(qm) > 0 {
-		var m RouteQueryMapping
-		_ = json.Unmarshal(qm, &m)
-		rt.QueryMapping = m
+	return rt.Source
+}
+
+func (r *SQLRepository) DeleteRoute(ctx context.Context, serviceID, routeID string, version int64) error {
+	q := fmt.Sprintf(`DELETE FROM %s.gateway_routes WHERE service_id=$1 AND id=$2 AND ($3 = 0 OR version = $3)`, r.schema)
+	res, err := r.db.ExecContext(ctx, q, serviceID, routeID, version)
+	if err != nil {
+		return storeError(err)
 	}
-	return &rt, nil
+	if n, _ := res.RowsAffected(); n == 0 && version != 0 {
+		return r.versionConflict(ctx, fmt.Sprintf(`%s.gateway_routes WHERE service_id = $1 AND id = $2`, r.schema), serviceID, routeID)
+	}
+	return nil
+}
+
+func (r *SQLRepository) FindRoute(ctx context.Context, serviceID, method, path string) (*Route, error) {
+	q := fmt.Sprintf(`SELECT %s FROM %s.gateway_routes WHERE service_id=$1 AND method=$2 AND path_pattern=$3`, routeColumns, r.schema)
+	return scanRoute(r.db.QueryRowContext(ctx, q, serviceID, strings.ToUpper(method), path))
 }
 
 func (r *SQLRepository) LoadEnabled(ctx context.Context) ([]*Service, error) {
-	q := fmt.Sprintf(`SELECT id, name, COALESCE(description,''), public_prefix, base_url, swagger_url, protocol, COALESCE(grpc_target,''), enabled FROM %s WHERE enabled = TRUE`, r.table())
+	q := fmt.Sprintf(`SELECT id, namespace, name, COALESCE(description,''), public_prefix, base_url, swagger_url, protocol, COALESCE(grpc_target,''), descriptor_source, COALESCE(metadata_policy,'null'::jsonb), COALESCE(streaming_policy,'null'::jsonb), enabled, COALESCE(labels,'null'::jsonb), version FROM %s WHERE enabled = TRUE`, r.table())
 	rows, err := r.db.QueryContext(ctx, q)
 	if err != nil {
 		return nil, err
@@ -177,45 +217,152 @@ func (r *SQLRepository) LoadEnabled(ctx context.Context) ([]*Service, error) {
 	var list []*Service
 	for rows.Next() {
 		var s Service
-		if err := rows.Scan(&s.ID, &s.Name, &s.Description, &s.PublicPrefix, &s.BaseURL, &s.SwaggerURL, &s.Protocol, &s.GRPCTarget, &s.Enabled); err != nil {
+		var mp, sp, lb json.RawMessage
+		if err := rows.Scan(&s.ID, &s.Namespace, &s.Name, &s.Description, &s.PublicPrefix, &s.BaseURL, &s.SwaggerURL, &s.Protocol, &s.GRPCTarget, &s.DescriptorSource, &mp, &sp, &s.Enabled, &lb, &s.Version); err != nil {
 			return nil, err
 		}
+		s.Metadata = decodeMetadataPolicy(mp)
+		s.Streaming = decodeStreamingPolicy(sp)
+		_ = json.Unmarshal(lb, &s.Labels)
 		list = append(list, &s)
 	}
 	return list, nil
 }
 
+// serviceColumns is the column list scanned by scanService (everything but swagger_json).
+const serviceColumns = `id, namespace, name, description, public_prefix, base_url, swagger_url, protocol, COALESCE(grpc_target,''), descriptor_source, COALESCE(metadata_policy,'null'::jsonb), COALESCE(streaming_policy,'null'::jsonb), enabled, COALESCE(last_refreshed_at, to_timestamp(0)), COALESCE(last_health_at, to_timestamp(0)), COALESCE(last_status,''), created_at, updated_at, COALESCE(labels,'null'::jsonb), version`
+
+func scanService(row rowScanner) (*Service, error) {
+	var s Service
+	var mp, sp, lb json.RawMessage
+	if err := row.Scan(&s.ID, &s.Namespace, &s.Name, &s.Description, &s.PublicPrefix, &s.BaseURL, &s.SwaggerURL, &s.Protocol, &s.GRPCTarget, &s.DescriptorSource, &mp, &sp, &s.Enabled, &s.LastRefreshed, &s.LastHealthAt, &s.LastStatus, &s.CreatedAt, &s.UpdatedAt, &lb, &s.Version); err != nil {
+		return nil, err
+	}
+	s.Metadata = decodeMetadataPolicy(mp)
+	s.Streaming = decodeStreamingPolicy(sp)
+	_ = json.Unmarshal(lb, &s.Labels)
+	return &s, nil
+}
+
 func (r *SQLRepository) List(ctx context.Context) ([]*Service, error) {
-	q := fmt.Sprintf(`SELECT id, name, description, public_prefix, base_url, swagger_url, protocol, COALESCE(grpc_target,''), enabled, COALESCE(last_refreshed_at, to_timestamp(0)), COALESCE(last_health_at, to_timestamp(0)), COALESCE(last_status,''), created_at, updated_at FROM %s ORDER BY created_at ASC`, r.table())
-	rows, err := r.db.QueryContext(ctx, q)
+	page, err := r.QueryServices(ctx, ServiceQuery{})
+	if err != nil {
+		return nil, err
+	}
+	return page.Items, nil
+}
+
+// QueryServices returns one page of services matching q, using keyset pagination on the sort
+// column and id.
+func (r *SQLRepository) QueryServices(ctx context.Context, q ServiceQuery) (*ServicePage, error) {
+	if err := q.Validate(); err != nil {
+		return nil, err
+	}
+	col := serviceSortColumns[q.Sort]
+	var where []string
+	var args []any
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	if q.Namespace != "" {
+		where = append(where, "namespace = "+arg(q.Namespace))
+	}
+	if q.Enabled != nil {
+		where = append(where, "enabled = "+arg(*q.Enabled))
+	}
+	if q.Protocol != "" {
+		where = append(where, "protocol = "+arg(strings.ToLower(q.Protocol)))
+	}
+	if q.GRPCTarget != "" {
+		where = append(where, "grpc_target = "+arg(q.GRPCTarget))
+	}
+	if q.Status != "" {
+		where = append(where, "lower(COALESCE(last_status,'')) = lower("+arg(q.Status)+")")
+	}
+	if q.Search != "" {
+		p := arg("%" + likeEscaper.Replace(q.Search) + "%")
+		where = append(where, "(name ILIKE "+p+" OR public_prefix ILIKE "+p+")")
+	}
+	if len(q.Labels) > 0 {
+		b, _ := json.Marshal(q.Labels)
+		where = append(where, "labels @> "+arg(string(b))+"::jsonb")
+	}
+	dir, cmp := "ASC", ">"
+	if q.Desc {
+		dir, cmp = "DESC", "<"
+	}
+	if q.Cursor != "" {
+		c, _ := decodeCursor(q.Cursor)
+		cast := ""
+		if col == "created_at" || col == "updated_at" {
+			cast = "::timestamptz"
+		}
+		where = append(where, fmt.Sprintf("(%s, id) %s (%s%s, %s::uuid)", col, cmp, arg(c.Value), cast, arg(c.ID)))
+	}
+	stmt := fmt.Sprintf(`SELECT %s FROM %s`, serviceColumns, r.table())
+	if len(where) > 0 {
+		stmt += " WHERE " + strings.Join(where, " AND ")
+	}
+	stmt += fmt.Sprintf(" ORDER BY %s %s, id %s", col, dir, dir)
+	if q.Limit > 0 {
+		// One extra row tells whether another page follows.
+		stmt += " LIMIT " + arg(q.Limit+1)
+	}
+	rows, err := r.db.QueryContext(ctx, stmt, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var list []*Service
+	page := &ServicePage{Items: []*Service{}}
 	for rows.Next() {
-		var s Service
-		if err := rows.Scan(&s.ID, &s.Name, &s.Description, &s.PublicPrefix, &s.BaseURL, &s.SwaggerURL, &s.Protocol, &s.GRPCTarget, &s.Enabled, &s.LastRefreshed, &s.LastHealthAt, &s.LastStatus, &s.CreatedAt, &s.UpdatedAt); err != nil {
+		s, err := scanService(rows)
+		if err != nil {
 			return nil, err
 		}
-		list = append(list, &s)
+		page.Items = append(page.Items, s)
 	}
-	return list, nil
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if q.Limit > 0 && len(page.Items) > q.Limit {
+		page.Items = page.Items[:q.Limit]
+		last := page.Items[q.Limit-1]
+		page.NextCursor = encodeCursor(pageCursor{Sort: q.Sort, Desc: q.Desc, Value: sortValue(last, q.Sort), ID: last.ID})
+	}
+	return page, nil
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func sortValue(s *Service, key string) string {
+	switch key {
+	case "name":
+		return s.Name
+	case "public_prefix":
+		return s.PublicPrefix
+	case "updated_at":
+		return s.UpdatedAt.Format(time.RFC3339Nano)
+	}
+	return s.CreatedAt.Format(time.RFC3339Nano)
 }
 
 func (r *SQLRepository) Get(ctx context.Context, id string) (*Service, error) {
-	q := fmt.Sprintf(`SELECT id, name, description, public_prefix, base_url, swagger_url, protocol, COALESCE(grpc_target,''), enabled, COALESCE(swagger_json,'{}'::jsonb), COALESCE(last_refreshed_at, now()), COALESCE(last_health_at, to_timestamp(0)), COALESCE(last_status,''), created_at, updated_at FROM %s WHERE id = $1`, r.table())
+	q := fmt.Sprintf(`SELECT id, namespace, name, description, public_prefix, base_url, swagger_url, protocol, COALESCE(grpc_target,''), descriptor_source, COALESCE(metadata_policy,'null'::jsonb), COALESCE(streaming_policy,'null'::jsonb), enabled, COALESCE(swagger_json,'{}'::jsonb), COALESCE(last_refreshed_at, now()), COALESCE(last_health_at, to_timestamp(0)), COALESCE(last_status,''), created_at, updated_at, COALESCE(labels,'null'::jsonb), version FROM %s WHERE id = $1`, r.table())
 	row := r.db.QueryRowContext(ctx, q, id)
 	var s Service
-	var raw json.RawMessage
-	if err := row.Scan(&s.ID, &s.Name, &s.Description, &s.PublicPrefix, &s.BaseURL, &s.SwaggerURL, &s.Protocol, &s.GRPCTarget, &s.Enabled, &raw, &s.LastRefreshed, &s.LastHealthAt, &s.LastStatus, &s.CreatedAt, &s.UpdatedAt); err != nil {
-		return nil, err
+	var raw, mp, sp, lb json.RawMessage
+	if err := row.Scan(&s.ID, &s.Namespace, &s.Name, &s.Description, &s.PublicPrefix, &s.BaseURL, &s.SwaggerURL, &s.Protocol, &s.GRPCTarget, &s.DescriptorSource, &mp, &sp, &s.Enabled, &raw, &s.LastRefreshed, &s.LastHealthAt, &s.LastStatus, &s.CreatedAt, &s.UpdatedAt, &lb, &s.Version); err != nil {
+		return nil, storeError(err)
 	}
+	_ = json.Unmarshal(lb, &s.Labels)
 	if len(raw) > 0 {
 		var v any
 		_ = json.Unmarshal(raw, &v)
 		s.SwaggerJSON = v
 	}
+	s.Metadata = decodeMetadataPolicy(mp)
+	s.Streaming = decodeStreamingPolicy(sp)
 	return &s, nil
 }
 
@@ -228,9 +375,11 @@ func (r *SQLRepository) Create(ctx context.Context, s *Service) error {
 	} else {
 		jsonParam = nil
 	}
-	q := fmt.Sprintf(`INSERT INTO %s (id, name, description, public_prefix, base_url, swagger_url, protocol, grpc_target, enabled, swagger_json, last_refreshed_at, created_at, updated_at) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11, now(), now())`, r.table())
-	_, err := r.db.ExecContext(ctx, q, s.ID, s.Name, s.Description, s.PublicPrefix, s.BaseURL, s.SwaggerURL, s.Protocol, s.GRPCTarget, s.Enabled, jsonParam, s.LastRefreshed)
-	return err
+	if s.Namespace == "" {
+		s.Namespace = DefaultNamespace
+	}
+	q := fmt.Sprintf(`INSERT INTO %s (id, name, description, public_prefix, base_url, swagger_url, protocol, grpc_target, descriptor_source, metadata_policy, streaming_policy, enabled, swagger_json, last_refreshed_at, labels, namespace, created_at, updated_at) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16, now(), now()) RETURNING version`, r.table())
+	return storeError(r.db.QueryRowContext(ctx, q, s.ID, s.Name, s.Description, s.PublicPrefix, s.BaseURL, s.SwaggerURL, s.Protocol, s.GRPCTarget, descriptorSource(s), encodeMetadataPolicy(s.Metadata), encodeStreamingPolicy(s.Streaming), s.Enabled, jsonParam, s.LastRefreshed, encodeLabels(s.Labels), s.Namespace).Scan(&s.Version))
 }
 
 func (r *SQLRepository) Update(ctx context.Context, s *Service) error {
@@ -242,13 +391,404 @@ func (r *SQLRepository) Update(ctx context.Context, s *Service) error {
 	} else {
 		jsonParam = nil
 	}
-	q := fmt.Sprintf(`UPDATE %s SET name=$2, description=$3, public_prefix=$4, base_url=$5, swagger_url=$6, protocol=$7, grpc_target=$8, enabled=$9, swagger_json=$10, updated_at=now() WHERE id=$1`, r.table())
-	_, err := r.db.ExecContext(ctx, q, s.ID, s.Name, s.Description, s.PublicPrefix, s.BaseURL, s.SwaggerURL, s.Protocol, s.GRPCTarget, s.Enabled, jsonParam)
+	q := fmt.Sprintf(`UPDATE %s SET name=$2, description=$3, public_prefix=$4, base_url=$5, swagger_url=$6, protocol=$7, grpc_target=$8, descriptor_source=$9, metadata_policy=$10, streaming_policy=$11, enabled=$12, swagger_json=$13, labels=$14, updated_at=now(), version=version+1 WHERE id=$1 AND ($15 = 0 OR version = $15) RETURNING namespace, version, updated_at`, r.table())
+	err := r.db.QueryRowContext(ctx, q, s.ID, s.Name, s.Description, s.PublicPrefix, s.BaseURL, s.SwaggerURL, s.Protocol, s.GRPCTarget, descriptorSource(s), encodeMetadataPolicy(s.Metadata), encodeStreamingPolicy(s.Streaming), s.Enabled, jsonParam, encodeLabels(s.Labels), s.Version).Scan(&s.Namespace, &s.Version, &s.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) && s.Version != 0 {
+		return r.versionConflict(ctx, r.table()+` WHERE id = $1`, s.ID)
+	}
+	return storeError(err)
+}
+
+// UpdateHealth records a health probe result. It leaves Version and UpdatedAt alone, since
+// probes are not configuration changes.
+func (r *SQLRepository) UpdateHealth(ctx context.Context, id, status string, at time.Time) error {
+	q := fmt.Sprintf(`UPDATE %s SET last_status=$2, last_health_at=$3 WHERE id=$1`, r.table())
+	_, err := r.db.ExecContext(ctx, q, id, status, at)
 	return err
 }
 
-func (r *SQLRepository) Delete(ctx context.Context, id string) error {
-	q := fmt.Sprintf(`DELETE FROM %s WHERE id = $1`, r.table())
+func (r *SQLRepository) Delete(ctx context.Context, id string, version int64) error {
+	q := fmt.Sprintf(`DELETE FROM %s WHERE id = $1 AND ($2 = 0 OR version = $2)`, r.table())
+	res, err := r.db.ExecContext(ctx, q, id, version)
+	if err != nil {
+		return storeError(err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 && version != 0 {
+		return r.versionConflict(ctx, r.table()+` WHERE id = $1`, id)
+	}
+	return nil
+}
+
+// descriptorSource defaults an unset Service.DescriptorSource to reflection.
+func descriptorSource(s *Service) string {
+	if s.DescriptorSource == "" {
+		return DescriptorSourceReflection
+	}
+	return s.DescriptorSource
+}
+
+// encodeMetadataPolicy returns the JSONB parameter for mp (NULL when unset).
+func encodeMetadataPolicy(mp *MetadataPolicy) any {
+	if mp == nil {
+		return nil
+	}
+	b, err := json.Marshal(mp)
+	if err != nil {
+		return nil
+	}
+	return string(b)
+}
+
+func decodeMetadataPolicy(raw json.RawMessage) *MetadataPolicy {
+	var mp *MetadataPolicy
+	_ = json.Unmarshal(raw, &mp)
+	return mp
+}
+
+// encodeLabels returns the JSONB parameter for labels (NULL when empty).
+func encodeLabels(labels map[string]string) any {
+	if len(labels) == 0 {
+		return nil
+	}
+	b, _ := json.Marshal(labels)
+	return string(b)
+}
+
+// encodeStreamingPolicy returns the JSONB parameter for sp (NULL when unset).
+func encodeStreamingPolicy(sp *StreamingPolicy) any {
+	if sp == nil {
+		return nil
+	}
+	b, err := json.Marshal(sp)
+	if err != nil {
+		return nil
+	}
+	return string(b)
+}
+
+func decodeStreamingPolicy(raw json.RawMessage) *StreamingPolicy {
+	var sp *StreamingPolicy
+	_ = json.Unmarshal(raw, &sp)
+	return sp
+}
+
+// --- Descriptor set methods ---
+
+// SaveDescriptorSet stores ds as the next version for its service and fills in Version and CreatedAt.
+func (r *SQLRepository) SaveDescriptorSet(ctx context.Context, ds *DescriptorSet) error {
+	if r.conn != nil {
+		return r.InTx(ctx, func(tx Repository) error { return tx.SaveDescriptorSet(ctx, ds) })
+	}
+	// Lock the service row so concurrent uploads queue up instead of both computing the same
+	// MAX(version)+1; the INSERT below then sees the version committed by the previous one.
+	var id string
+	lock := fmt.Sprintf(`SELECT id FROM %s WHERE id = $1 FOR UPDATE`, r.table())
+	if err := r.db.QueryRowContext(ctx, lock, ds.ServiceID).Scan(&id); err != nil {
+		return storeError(err)
+	}
+	services, _ := json.Marshal(ds.Services)
+	q := fmt.Sprintf(`INSERT INTO %[1]s.gateway_descriptor_sets (service_id, version, sha256, services, data)
+	SELECT $1, COALESCE(MAX(version), 0) + 1, $2, $3, $4 FROM %[1]s.gateway_descriptor_sets WHERE service_id = $1
+	RETURNING version, created_at`, r.schema)
+	return storeError(r.db.QueryRowContext(ctx, q, ds.ServiceID, ds.SHA256, string(services), ds.Data).Scan(&ds.Version, &ds.CreatedAt))
+}
+
+func (r *SQLRepository) GetDescriptorSet(ctx context.Context, serviceID string, version int) (*DescriptorSet, error) {
+	q := fmt.Sprintf(`SELECT service_id, version, sha256, COALESCE(services,'[]'::jsonb), data, created_at FROM %s.gateway_descriptor_sets WHERE service_id = $1 AND ($2 = 0 OR version = $2) ORDER BY version DESC LIMIT 1`, r.schema)
+	var ds DescriptorSet
+	var services json.RawMessage
+	if err := r.db.QueryRowContext(ctx, q, serviceID, version).Scan(&ds.ServiceID, &ds.Version, &ds.SHA256, &services, &ds.Data, &ds.CreatedAt); err != nil {
+		return nil, storeError(err)
+	}
+	_ = json.Unmarshal(services, &ds.Services)
+	ds.Size = len(ds.Data)
+	return &ds, nil
+}
+
+// ListDescriptorSets returns the metadata of every version (without Data), newest first.
+func (r *SQLRepository) ListDescriptorSets(ctx context.Context, serviceID string) ([]*DescriptorSet, error) {
+	q := fmt.Sprintf(`SELECT service_id, version, sha256, COALESCE(services,'[]'::jsonb), octet_length(data), created_at FROM %s.gateway_descriptor_sets WHERE service_id = $1 ORDER BY version DESC`, r.schema)
+	rows, err := r.db.QueryContext(ctx, q, serviceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var list []*DescriptorSet
+	for rows.Next() {
+		var ds DescriptorSet
+		var services json.RawMessage
+		if err := rows.Scan(&ds.ServiceID, &ds.Version, &ds.SHA256, &services, &ds.Size, &ds.CreatedAt); err != nil {
+			return nil, err
+		}
+		_ = json.Unmarshal(services, &ds.Services)
+		list = append(list, &ds)
+	}
+	return list, nil
+}
+
+// --- Upstream TLS ---
+
+func (r *SQLRepository) GetUpstreamTLS(ctx context.Context, serviceID string) (*UpstreamTLS, error) {
+	q := fmt.Sprintf(`SELECT upstream_tls FROM %s WHERE id = $1`, r.table())
+	var sealed []byte
+	if err := r.db.QueryRowContext(ctx, q, serviceID).Scan(&sealed); err != nil {
+		return nil, storeError(err)
+	}
+	if len(sealed) == 0 {
+		return nil, nil
+	}
+	plain, err := r.secrets.Open(sealed, []byte(serviceID))
+	if err != nil {
+		// Settings sealed before they were bound to their service open without additional
+		// data; they are sealed again, bound, on first read.
+		legacy, lerr := r.secrets.Open(sealed, nil)
+		if lerr != nil {
+			return nil, fmt.Errorf("decrypt upstream tls: %w", err)
+		}
+		plain = legacy
+		if resealed, err := r.secrets.Seal(plain, []byte(serviceID)); err == nil {
+			q := fmt.Sprintf(`UPDATE %s SET upstream_tls=$2 WHERE id=$1 AND upstream_tls=$3`, r.table())
+			_, _ = r.db.ExecContext(ctx, q, serviceID, resealed, sealed)
+		}
+	}
+	var t UpstreamTLS
+	if err := json.Unmarshal(plain, &t); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (r *SQLRepository) SaveUpstreamTLS(ctx context.Context, serviceID string, t *UpstreamTLS) error {
+	var sealed []byte
+	if t != nil {
+		plain, err := json.Marshal(t)
+		if err != nil {
+			return err
+		}
+		if sealed, err = r.secrets.Seal(plain, []byte(serviceID)); err != nil {
+			return err
+		}
+	}
+	q := fmt.Sprintf(`UPDATE %s SET upstream_tls=$2, updated_at=now() WHERE id=$1`, r.table())
+	res, err := r.db.ExecContext(ctx, q, serviceID, sealed)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// --- Drafts and revisions ---
+
+func (r *SQLRepository) CreateDraft(ctx context.Context, d *Draft) error {
+	snap, err := json.Marshal(d.Snapshot)
+	if err != nil {
+		return err
+	}
+	if d.Namespace == "" {
+		d.Namespace = DefaultNamespace
+	}
+	q := fmt.Sprintf(`INSERT INTO %s.gateway_drafts (id, namespace, name, description, base, snapshot) VALUES ($1,$2,$3,$4,$5,$6) RETURNING created_at, updated_at, version`, r.schema)
+	return storeError(r.db.QueryRowContext(ctx, q, d.ID, d.Namespace, d.Name, d.Description, d.Base, string(snap)).Scan(&d.CreatedAt, &d.UpdatedAt, &d.Version))
+}
+
+func (r *SQLRepository) GetDraft(ctx context.Context, id string) (*Draft, error) {
+	q := fmt.Sprintf(`SELECT id, namespace, name, description, base, snapshot, created_at, updated_at, version FROM %s.gateway_drafts WHERE id = $1`, r.schema)
+	var d Draft
+	var snap json.RawMessage
+	if err := r.db.QueryRowContext(ctx, q, id).Scan(&d.ID, &d.Namespace, &d.Name, &d.Description, &d.Base, &snap, &d.CreatedAt, &d.UpdatedAt, &d.Version); err != nil {
+		return nil, storeError(err)
+	}
+	if err := json.Unmarshal(snap, &d.Snapshot); err != nil {
+		return nil, fmt.Errorf("draft %s: %w", id, err)
+	}
+	return &d, nil
+}
+
+func (r *SQLRepository) ListDrafts(ctx context.Context) ([]*Draft, error) {
+	q := fmt.Sprintf(`SELECT id, namespace, name, description, base, created_at, updated_at, version FROM %s.gateway_drafts ORDER BY created_at ASC, id ASC`, r.schema)
+	rows, err := r.db.QueryContext(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []*Draft{}
+	for rows.Next() {
+		var d Draft
+		if err := rows.Scan(&d.ID, &d.Namespace, &d.Name, &d.Description, &d.Base, &d.CreatedAt, &d.UpdatedAt, &d.Version); err != nil {
+			return nil, err
+		}
+		list = append(list, &d)
+	}
+	return list, rows.Err()
+}
+
+func (r *SQLRepository) UpdateDraft(ctx context.Context, d *Draft) error {
+	snap, err := json.Marshal(d.Snapshot)
+	if err != nil {
+		return err
+	}
+	q := fmt.Sprintf(`UPDATE %s.gateway_drafts SET name=$2, description=$3, base=$4, snapshot=$5, updated_at=now(), version=version+1 WHERE id=$1 AND ($6 = 0 OR version = $6) RETURNING namespace, version, updated_at`, r.schema)
+	err = r.db.QueryRowContext(ctx, q, d.ID, d.Name, d.Description, d.Base, string(snap), d.Version).Scan(&d.Namespace, &d.Version, &d.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) && d.Version != 0 {
+		return r.versionConflict(ctx, fmt.Sprintf(`%s.gateway_drafts WHERE id = $1`, r.schema), d.ID)
+	}
+	return storeError(err)
+}
+
+func (r *SQLRepository) DeleteDraft(ctx context.Context, id string) error {
+	q := fmt.Sprintf(`DELETE FROM %s.gateway_drafts WHERE id = $1`, r.schema)
 	_, err := r.db.ExecContext(ctx, q, id)
-	return err
+	return storeError(err)
+}
+
+// CreateRevision stores rev under the next number and fills in Number and CreatedAt.
+func (r *SQLRepository) CreateRevision(ctx context.Context, rev *Revision) error {
+	changes, err := json.Marshal(rev.Changes)
+	if err != nil {
+		return err
+	}
+	snap, err := json.Marshal(rev.Snapshot)
+	if err != nil {
+		return err
+	}
+	if rev.Namespace == "" {
+		rev.Namespace = DefaultNamespace
+	}
+	// the number comes from the column's sequence, shared by all namespaces
+	q := fmt.Sprintf(`INSERT INTO %s.gateway_revisions (namespace, source, message, draft_id, rollback_of, fingerprint, changes, snapshot)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	RETURNING number, created_at`, r.schema)
+	return storeError(r.db.QueryRowContext(ctx, q, rev.Namespace, rev.Source, rev.Message, rev.DraftID, rev.RollbackOf, rev.Fingerprint, string(changes), string(snap)).Scan(&rev.Number, &rev.CreatedAt))
+}
+
+func (r *SQLRepository) GetRevision(ctx context.Context, number int64) (*Revision, error) {
+	q := fmt.Sprintf(`SELECT number, namespace, source, message, draft_id, rollback_of, fingerprint, changes, snapshot, created_at FROM %s.gateway_revisions WHERE ($1 = 0 OR number = $1) ORDER BY number DESC LIMIT 1`, r.schema)
+	var rev Revision
+	var changes, snap json.RawMessage
+	if err := r.db.QueryRowContext(ctx, q, number).Scan(&rev.Number, &rev.Namespace, &rev.Source, &rev.Message, &rev.DraftID, &rev.RollbackOf, &rev.Fingerprint, &changes, &snap, &rev.CreatedAt); err != nil {
+		return nil, err
+	}
+	_ = json.Unmarshal(changes, &rev.Changes)
+	if err := json.Unmarshal(snap, &rev.Snapshot); err != nil {
+		return nil, fmt.Errorf("revision %d: %w", rev.Number, err)
+	}
+	return &rev, nil
+}
+
+func (r *SQLRepository) ListRevisions(ctx context.Context) ([]*Revision, error) {
+	q := fmt.Sprintf(`SELECT number, namespace, source, message, draft_id, rollback_of, fingerprint, changes, created_at FROM %s.gateway_revisions ORDER BY number DESC`, r.schema)
+	rows, err := r.db.QueryContext(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []*Revision{}
+	for rows.Next() {
+		var rev Revision
+		var changes json.RawMessage
+		if err := rows.Scan(&rev.Number, &rev.Namespace, &rev.Source, &rev.Message, &rev.DraftID, &rev.RollbackOf, &rev.Fingerprint, &changes, &rev.CreatedAt); err != nil {
+			return nil, err
+		}
+		_ = json.Unmarshal(changes, &rev.Changes)
+		list = append(list, &rev)
+	}
+	return list, rows.Err()
+}
+
+// --- Namespaces ---
+
+const namespaceColumns = `name, description, hosts, members, created_at, updated_at, version`
+
+func scanNamespace(row rowScanner) (*Namespace, error) {
+	var ns Namespace
+	var hosts, members json.RawMessage
+	if err := row.Scan(&ns.Name, &ns.Description, &hosts, &members, &ns.CreatedAt, &ns.UpdatedAt, &ns.Version); err != nil {
+		return nil, err
+	}
+	_ = json.Unmarshal(hosts, &ns.Hosts)
+	_ = json.Unmarshal(members, &ns.Members)
+	return &ns, nil
+}
+
+// encodeNamespace returns the JSONB parameters for the hosts and members of ns.
+func encodeNamespace(ns *Namespace) (string, string) {
+	hosts, members := []byte("[]"), []byte("[]")
+	if len(ns.Hosts) > 0 {
+		hosts, _ = json.Marshal(ns.Hosts)
+	}
+	if len(ns.Members) > 0 {
+		members, _ = json.Marshal(ns.Members)
+	}
+	return string(hosts), string(members)
+}
+
+func (r *SQLRepository) ListNamespaces(ctx context.Context) ([]*Namespace, error) {
+	q := fmt.Sprintf(`SELECT %s FROM %s.gateway_namespaces ORDER BY name ASC`, namespaceColumns, r.schema)
+	rows, err := r.db.QueryContext(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []*Namespace{}
+	for rows.Next() {
+		ns, err := scanNamespace(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, ns)
+	}
+	return list, rows.Err()
+}
+
+func (r *SQLRepository) GetNamespace(ctx context.Context, name string) (*Namespace, error) {
+	q := fmt.Sprintf(`SELECT %s FROM %s.gateway_namespaces WHERE name = $1`, namespaceColumns, r.schema)
+	ns, err := scanNamespace(r.db.QueryRowContext(ctx, q, name))
+	return ns, storeError(err)
+}
+
+func (r *SQLRepository) CreateNamespace(ctx context.Context, ns *Namespace) error {
+	hosts, members := encodeNamespace(ns)
+	q := fmt.Sprintf(`INSERT INTO %s.gateway_namespaces (name, description, hosts, members) VALUES ($1,$2,$3,$4) RETURNING created_at, updated_at, version`, r.schema)
+	err := r.db.QueryRowContext(ctx, q, ns.Name, ns.Description, hosts, members).Scan(&ns.CreatedAt, &ns.UpdatedAt, &ns.Version)
+	if errors.Is(storeError(err), ErrDuplicate) {
+		return fmt.Errorf("%w: namespace %s already exists", ErrDuplicate, ns.Name)
+	}
+	return storeError(err)
+}
+
+func (r *SQLRepository) UpdateNamespace(ctx context.Context, ns *Namespace) error {
+	hosts, members := encodeNamespace(ns)
+	q := fmt.Sprintf(`UPDATE %s.gateway_namespaces SET description=$2, hosts=$3, members=$4, updated_at=now(), version=version+1 WHERE name=$1 AND ($5 = 0 OR version = $5) RETURNING version, updated_at`, r.schema)
+	err := r.db.QueryRowContext(ctx, q, ns.Name, ns.Description, hosts, members, ns.Version).Scan(&ns.Version, &ns.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) && ns.Version != 0 {
+		return r.versionConflict(ctx, fmt.Sprintf(`%s.gateway_namespaces WHERE name = $1`, r.schema), ns.Name)
+	}
+	return storeError(err)
+}
+
+// DeleteNamespace deletes an empty namespace with its drafts and revisions, in one statement so
+// no service can be created in it meanwhile.
+func (r *SQLRepository) DeleteNamespace(ctx context.Context, name string) error {
+	if name == DefaultNamespace {
+		return errDeleteDefaultNamespace
+	}
+	q := fmt.Sprintf(`WITH ns AS (
+	  DELETE FROM %[1]s.gateway_namespaces WHERE name = $1 AND NOT EXISTS (SELECT 1 FROM %[1]s.gateway_services WHERE namespace = $1) RETURNING name
+	), drafts AS (
+	  DELETE FROM %[1]s.gateway_drafts WHERE namespace IN (SELECT name FROM ns)
+	), revisions AS (
+	  DELETE FROM %[1]s.gateway_revisions WHERE namespace IN (SELECT name FROM ns)
+	)
+	SELECT EXISTS (SELECT 1 FROM ns), EXISTS (SELECT 1 FROM %[1]s.gateway_services WHERE namespace = $1)`, r.schema)
+	var deleted, hasServices bool
+	if err := r.db.QueryRowContext(ctx, q, name).Scan(&deleted, &hasServices); err != nil {
+		return storeError(err)
+	}
+	if !deleted && hasServices {
+		return ErrNamespaceNotEmpty
+	}
+	return nil
 }
@@ -0,0 +1,32 @@
+package registry
+
+// UpstreamTLS holds per-service TLS settings for connections from the gateway to the upstream,
+// used by both the HTTP reverse proxy (https base URLs) and grpc-json dials.
+//
+// Certificates can be given inline as PEM (stored encrypted) or as file paths on the gateway
+// host, e.g. a mounted Kubernetes secret; files are watched and reloaded when they change.
+// Inline PEM wins over the matching file. Supplying a client certificate and key enables mTLS.
+type UpstreamTLS struct {
+	CACertPEM     string `json:"ca_cert_pem,omitempty"`
+	ClientCertPEM string `json:"client_cert_pem,omitempty"`
+	ClientKeyPEM  string `json:"client_key_pem,omitempty"`
+	CAFile        string `json:"ca_file,omitempty" example:"/etc/gateway/tls/ca.pem"`
+	CertFile      string `json:"cert_file,omitempty" example:"/etc/gateway/tls/client.pem"`
+	KeyFile       string `json:"key_file,omitempty" example:"/etc/gateway/tls/client-key.pem"`
+	// ServerName overrides the SNI / verification host name.
+	ServerName string `json:"server_name,omitempty" example:"orders.internal"`
+	// InsecureSkipVerify disables server certificate verification. Development only.
+	InsecureSkipVerify bool `json:"insecure_skip_verify,omitempty"`
+}
+
+// Redacted returns a copy safe to show to operators: the private key is masked.
+func (t *UpstreamTLS) Redacted() *UpstreamTLS {
+	if t == nil {
+		return nil
+	}
+	c := *t
+	if c.ClientKeyPEM != "" {
+		c.ClientKeyPEM = "REDACTED"
+	}
+	return &c
+}
@@ -0,0 +1,377 @@
+// Package routing compiles and matches the path templates used by transcoded routes.
+//
+// A template is an absolute path whose segments are one of:
+//
+//	users              literal
+//	{id}               one segment, captured as "id" (same as {id=*})
+//	{id:int}           one segment with a type constraint: int, float, bool, uuid or string
+//	{id:[0-9]{4}}      one segment matching a regular expression (anchored)
+//	{path=**}          the rest of the path, zero or more segments, captured as "path"
+//	{name=shelves/*}   google.api.http sub-template; the matched segments are captured joined by '/'
+//	* / **             anonymous single- / multi-segment wildcards
+//
+// Variable names may be dotted field paths such as {user.id}. A multi-segment wildcard must be
+// the last segment. A google.api.http custom verb may follow the last segment, as in
+// /v1/{name=messages/*}:cancel; the verb must then end the request path.
+package routing
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type segKind int
+
+const (
+	segMulti segKind = iota
+	segParam
+	segLiteral
+)
+
+type segment struct {
+	kind segKind
+	lit  string
+	// name of the variable capturing this segment; "" for literals and anonymous wildcards.
+	name string
+	// typ is the coercion hint for single-segment variables ("string" when unset).
+	typ string
+	// constraint is the canonical constraint text; re is its compiled form.
+	constraint string
+	re         *regexp.Regexp
+}
+
+// Template is a compiled route path template.
+type Template struct {
+	Pattern string
+	segs    []segment
+	vars    []string
+	// verb is the custom verb without its colon, "" when the template has none.
+	verb string
+}
+
+var (
+	varName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)*$`)
+
+	builtinConstraints = map[string]struct {
+		typ string
+		re  string
+	}{
+		"int":     {"int", `-?[0-9]+`},
+		"integer": {"int", `-?[0-9]+`},
+		"float":   {"float", `[-+]?([0-9]+(\.[0-9]*)?|\.[0-9]+)([eE][-+]?[0-9]+)?`},
+		"double":  {"float", `[-+]?([0-9]+(\.[0-9]*)?|\.[0-9]+)([eE][-+]?[0-9]+)?`},
+		"number":  {"float", `[-+]?([0-9]+(\.[0-9]*)?|\.[0-9]+)([eE][-+]?[0-9]+)?`},
+		"bool":    {"bool", `(?i:true|false|t|f|1|0)`},
+		"boolean": {"bool", `(?i:true|false|t|f|1|0)`},
+		"uuid":    {"string", `[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`},
+	}
+)
+
+// Parse compiles pattern.
+func Parse(pattern string) (*Template, error) {
+	if !strings.HasPrefix(pattern, "/") {
+		return nil, fmt.Errorf("template %q must start with /", pattern)
+	}
+	tokens, err := splitTemplate(strings.TrimSuffix(pattern[1:], "/"))
+	if err != nil {
+		return nil, err
+	}
+	t := &Template{Pattern: pattern}
+	if n := len(tokens); n > 0 {
+		if tokens[n-1], t.verb, err = cutVerb(tokens[n-1]); err != nil {
+			return nil, fmt.Errorf("template %q: %w", pattern, err)
+		}
+	}
+	seen := map[string]bool{}
+	for _, tok := range tokens {
+		segs, name, err := parseToken(tok)
+		if err != nil {
+			return nil, fmt.Errorf("template %q: %w", pattern, err)
+		}
+		if name != "" {
+			if seen[name] {
+				return nil, fmt.Errorf("template %q: variable %q bound twice", pattern, name)
+			}
+			seen[name] = true
+			t.vars = append(t.vars, name)
+		}
+		t.segs = append(t.segs, segs...)
+	}
+	for i, s := range t.segs {
+		if s.kind == segMulti && i != len(t.segs)-1 {
+			return nil, fmt.Errorf("template %q: ** must be the last segment", pattern)
+		}
+	}
+	return t, nil
+}
+
+// splitTemplate splits on '/' outside braces.
+func splitTemplate(s string) ([]string, error) {
+	if s == "" {
+		return nil, nil
+	}
+	var out []string
+	depth, start := 0, 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth < 0 {
+				return nil, errors.New("unbalanced '}'")
+			}
+		case '/':
+			if depth == 0 {
+				out = append(out, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	if depth != 0 {
+		return nil, errors.New("unbalanced '{'")
+	}
+	return append(out, s[start:]), nil
+}
+
+// cutVerb splits a trailing ":verb" off the last token. Colons inside braces belong to
+// constraints, not verbs.
+func cutVerb(tok string) (string, string, error) {
+	i := strings.LastIndexByte(tok, ':')
+	if i < 0 || i < strings.LastIndexByte(tok, '}') {
+		return tok, "", nil
+	}
+	verb := tok[i+1:]
+	if verb == "" || strings.ContainsAny(verb, "{}:=*") {
+		return "", "", fmt.Errorf("invalid custom verb %q", tok[i:])
+	}
+	return tok[:i], verb, nil
+}
+
+func parseToken(tok string) ([]segment, string, error) {
+	switch {
+	case tok == "":
+		return nil, "", errors.New("empty path segment")
+	case tok == "*":
+		return []segment{{kind: segParam, typ: "string"}}, "", nil
+	case tok == "**":
+		return []segment{{kind: segMulti}}, "", nil
+	case tok[0] != '{':
+		if strings.ContainsAny(tok, "{}") {
+			return nil, "", fmt.Errorf("segment %q mixes literal text and a variable", tok)
+		}
+		return []segment{{kind: segLiteral, lit: tok}}, "", nil
+	case tok[len(tok)-1] != '}':
+		return nil, "", fmt.Errorf("segment %q mixes literal text and a variable", tok)
+	}
+	inner := tok[1 : len(tok)-1]
+	eq, colon := strings.IndexByte(inner, '='), strings.IndexByte(inner, ':')
+	switch {
+	case colon >= 0 && (eq < 0 || colon < eq):
+		name, c := inner[:colon], inner[colon+1:]
+		if !varName.MatchString(name) {
+			return nil, "", fmt.Errorf("invalid variable name %q", name)
+		}
+		seg, err := constrainedParam(name, c)
+		if err != nil {
+			return nil, "", err
+		}
+		return []segment{seg}, name, nil
+	case eq >= 0:
+		name, sub := inner[:eq], inner[eq+1:]
+		if !varName.MatchString(name) {
+			return nil, "", fmt.Errorf("invalid variable name %q", name)
+		}
+		var segs []segment
+		for _, p := range strings.Split(sub, "/") {
+			switch {
+			case p == "*":
+				segs = append(segs, segment{kind: segParam, name: name, typ: "string"})
+			case p == "**":
+				segs = append(segs, segment{kind: segMulti, name: name})
+			case p == "" || strings.ContainsAny(p, "{}:="):
+				return nil, "", fmt.Errorf("invalid sub-template %q for variable %q", sub, name)
+			default:
+				segs = append(segs, segment{kind: segLiteral, lit: p, name: name})
+			}
+		}
+		return segs, name, nil
+	default:
+		if !varName.MatchString(inner) {
+			return nil, "", fmt.Errorf("invalid variable name %q", inner)
+		}
+		return []segment{{kind: segParam, name: inner, typ: "string"}}, inner, nil
+	}
+}
+
+func constrainedParam(name, c string) (segment, error) {
+	if c == "" || c == "string" {
+		return segment{kind: segParam, name: name, typ: "string"}, nil
+	}
+	expr, typ := c, "string"
+	if b, ok := builtinConstraints[strings.ToLower(c)]; ok {
+		expr, typ, c = b.re, b.typ, strings.ToLower(c)
+	}
+	re, err := regexp.Compile(`^(?:` + expr + `)$`)
+	if err != nil {
+		return segment{}, fmt.Errorf("invalid constraint %q for variable %q: %v", c, name, err)
+	}
+	return segment{kind: segParam, name: name, typ: typ, constraint: c, re: re}, nil
+}
+
+// Vars returns the variable names bound by the template, in order.
+func (t *Template) Vars() []string { return t.vars }
+
+// Match reports whether path matches and returns the captured variables. Single-segment
+// variables with a numeric or boolean constraint are coerced to that type.
+func (t *Template) Match(path string) (map[string]any, bool) {
+	if t.verb != "" {
+		var ok bool
+		if path, ok = strings.CutSuffix(path, ":"+t.verb); !ok {
+			return nil, false
+		}
+	}
+	u := strings.TrimSuffix(strings.TrimPrefix(path, "/"), "/")
+	var us []string
+	if u != "" {
+		us = strings.Split(u, "/")
+	}
+	captured := map[string][]string{}
+	single := map[string]*segment{}
+	i := 0
+	for si := range t.segs {
+		s := &t.segs[si]
+		if s.kind == segMulti {
+			if s.name != "" {
+				captured[s.name] = append(captured[s.name], us[i:]...)
+			}
+			i = len(us)
+			break
+		}
+		if i >= len(us) {
+			return nil, false
+		}
+		v := us[i]
+		switch s.kind {
+		case segLiteral:
+			if v != s.lit {
+				return nil, false
+			}
+		case segParam:
+			if s.re != nil && !s.re.MatchString(v) {
+				return nil, false
+			}
+		}
+		if s.name != "" {
+			captured[s.name] = append(captured[s.name], v)
+			single[s.name] = s
+		}
+		i++
+	}
+	if i != len(us) {
+		return nil, false
+	}
+	params := make(map[string]any, len(captured))
+	for _, name := range t.vars {
+		vals := captured[name]
+		if s := single[name]; s != nil && len(vals) == 1 && s.kind == segParam {
+			params[name] = Coerce(vals[0], s.typ)
+			continue
+		}
+		params[name] = strings.Join(vals, "/")
+	}
+	return params, true
+}
+
+// Coerce converts v according to a type hint (int, float, bool; anything else is a string).
+// Values that don't parse are returned unchanged.
+func Coerce(v string, typ string) any {
+	switch strings.ToLower(typ) {
+	case "int", "integer":
+		if i, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return i
+		}
+	case "float", "double", "number":
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			return f
+		}
+	case "bool", "boolean":
+		if b, err := strconv.ParseBool(v); err == nil {
+			return b
+		}
+	}
+	return v
+}
+
+// rank orders segment kinds by specificity: literal > constrained variable > variable > **.
+func (s segment) rank() int {
+	switch {
+	case s.kind == segLiteral:
+		return 3
+	case s.kind == segParam && s.re != nil:
+		return 2
+	case s.kind == segParam:
+		return 1
+	}
+	return 0
+}
+
+// Compare orders templates by precedence when both match a path: it returns a positive
+// number when a wins, negative when b wins and 0 when neither is more specific. Segments are
+// compared left to right, so /users/me beats /users/{id}, /users/{id:uuid} beats /users/{id}
+// and /files/{name} beats /files/{path=**}. A template with a custom verb beats one without:
+// the verb only matches paths ending in it, the other only by capturing the verb as data.
+func Compare(a, b *Template) int {
+	if (a.verb != "") != (b.verb != "") {
+		if a.verb != "" {
+			return 1
+		}
+		return -1
+	}
+	for i := 0; i < len(a.segs) && i < len(b.segs); i++ {
+		if d := a.segs[i].rank() - b.segs[i].rank(); d != 0 {
+			return d
+		}
+	}
+	return len(a.segs) - len(b.segs)
+}
+
+// Conflict reports whether a and b (for the same HTTP method) are ambiguous: some path could
+// match both and Compare cannot choose between them. The returned reason explains why.
+func Conflict(a, b *Template) (string, bool) {
+	if Compare(a, b) != 0 {
+		return "", false
+	}
+	if a.verb != b.verb {
+		// Different verbs never match the same path.
+		return "", false
+	}
+	for i := range a.segs {
+		sa, sb := a.segs[i], b.segs[i]
+		switch sa.kind {
+		case segLiteral:
+			if sa.lit != sb.lit {
+				// Different literals at the same position: no path matches both.
+				return "", false
+			}
+		case segParam:
+			if sa.constraint != sb.constraint {
+				if disjointBuiltins(sa.constraint, sb.constraint) {
+					return "", false
+				}
+				return fmt.Sprintf("segment %d is constrained differently (%q vs %q); a path may match both and neither takes precedence",
+					i+1, sa.constraint, sb.constraint), true
+			}
+		}
+	}
+	return "templates match exactly the same paths (only variable names differ)", true
+}
+
+// disjointBuiltins reports constraint pairs known never to match the same segment.
+func disjointBuiltins(a, b string) bool {
+	numeric := map[string]bool{"int": true, "integer": true, "float": true, "double": true, "number": true}
+	return (a == "uuid" && numeric[b]) || (b == "uuid" && numeric[a])
+}
@@ -0,0 +1,136 @@
+package routing
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseErrors(t *testing.T) {
+	for _, pattern := range []string{
+		"users",               // not absolute
+		"/users/{id",          // unbalanced
+		"/users/id}",          // unbalanced
+		"/users//{id}",        // empty segment
+		"/users/x{id}",        // mixed literal and variable
+		"/users/{id}/{id}",    // bound twice
+		"/files/**/meta",      // ** not last
+		"/users/{1id}",        // bad name
+		"/users/{id:[0-9}",    // bad regexp
+		"/v1/{name=shelves/}", // empty sub-segment
+		"/v1/messages:",       // empty verb
+		"/v1/{name}:a:b",      // colon in verb
+		"/v1/{name}:{verb}",   // variable verb
+	} {
+		if _, err := Parse(pattern); err == nil {
+			t.Errorf("Parse(%q) succeeded, want an error", pattern)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		want    map[string]any // nil: no match
+	}{
+		{"/users", "/users/", map[string]any{}},
+		{"/users/{id}", "/users/42", map[string]any{"id": "42"}},
+		{"/users/{id}", "/users", nil},
+		{"/users/{id}", "/users/42/orders", nil},
+		{"/users/{id:int}", "/users/42", map[string]any{"id": int64(42)}},
+		{"/users/{id:int}", "/users/abc", nil},
+		{"/prices/{p:float}", "/prices/1.5", map[string]any{"p": 1.5}},
+		{"/flags/{on:bool}", "/flags/true", map[string]any{"on": true}},
+		{"/users/{id:uuid}", "/users/3d1a7e94-0a2f-4a49-9a9b-8f9f2d0c6f67", map[string]any{"id": "3d1a7e94-0a2f-4a49-9a9b-8f9f2d0c6f67"}},
+		{"/years/{y:[0-9]{4}}", "/years/2025", map[string]any{"y": "2025"}},
+		{"/years/{y:[0-9]{4}}", "/years/20250", nil},
+		{"/files/{path=**}", "/files/a/b/c.txt", map[string]any{"path": "a/b/c.txt"}},
+		{"/files/{path=**}", "/files", map[string]any{"path": ""}},
+		{"/v1/{name=shelves/*}", "/v1/shelves/7", map[string]any{"name": "shelves/7"}},
+		{"/v1/{name=shelves/*}", "/v1/books/7", nil},
+		{"/users/{user.id}/*", "/users/9/x", map[string]any{"user.id": "9"}},
+		{"/v1/{name=messages/*}:cancel", "/v1/messages/7:cancel", map[string]any{"name": "messages/7"}},
+		{"/v1/{name=messages/*}:cancel", "/v1/messages/7", nil},
+		{"/v1/{name=messages/*}:cancel", "/v1/messages/7:undo", nil},
+		{"/v1/{id:int}:cancel", "/v1/7:cancel", map[string]any{"id": int64(7)}},
+		{"/v1/messages:batchGet", "/v1/messages:batchGet", map[string]any{}},
+		{"/v1/messages:batchGet", "/v1/messages", nil},
+		{"/v1/{path=**}:undelete", "/v1/a/b:undelete", map[string]any{"path": "a/b"}},
+	}
+	for _, tt := range tests {
+		tpl, err := Parse(tt.pattern)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", tt.pattern, err)
+		}
+		got, ok := tpl.Match(tt.path)
+		if ok != (tt.want != nil) || ok && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s.Match(%q) = %v, %v; want %v", tt.pattern, tt.path, got, ok, tt.want)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int // sign
+	}{
+		{"/users/me", "/users/{id}", 1},
+		{"/users/{id:uuid}", "/users/{id}", 1},
+		{"/files/{name}", "/files/{path=**}", 1},
+		{"/users/{id}", "/users/{id}/orders", -1},
+		{"/users/{id}", "/users/{uid}", 0},
+		{"/v1/{name=messages/*}:cancel", "/v1/{name=messages/*}", 1},
+		{"/v1/{path=**}:undelete", "/v1/messages/{id}", 1},
+	}
+	for _, tt := range tests {
+		a, b := mustParse(t, tt.a), mustParse(t, tt.b)
+		if got := sign(Compare(a, b)); got != tt.want {
+			t.Errorf("Compare(%s, %s) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+		if got := sign(Compare(b, a)); got != -tt.want {
+			t.Errorf("Compare(%s, %s) = %d, want %d", tt.b, tt.a, got, -tt.want)
+		}
+	}
+}
+
+func TestConflict(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"/users/{id}", "/users/{uid}", true},
+		{"/users/{id:[0-9]+}", "/users/{id:[a-z0-9]+}", true},
+		{"/users/{id:uuid}", "/users/{id:int}", false},
+		{"/users/me", "/users/{id}", false},
+		{"/users/{id}/orders", "/users/{id}/invoices", false},
+		{"/users/{id}", "/users/{id}/orders", false},
+		{"/v1/{id}:cancel", "/v1/{name}:cancel", true},
+		{"/v1/{id}:cancel", "/v1/{id}:undo", false},
+		{"/v1/{id}:cancel", "/v1/{id}", false},
+	}
+	for _, tt := range tests {
+		reason, got := Conflict(mustParse(t, tt.a), mustParse(t, tt.b))
+		if got != tt.want || got && reason == "" {
+			t.Errorf("Conflict(%s, %s) = %q, %v; want %v", tt.a, tt.b, reason, got, tt.want)
+		}
+	}
+}
+
+func mustParse(t *testing.T, pattern string) *Template {
+	t.Helper()
+	tpl, err := Parse(pattern)
+	if err != nil {
+		t.Fatalf("Parse(%q): %v", pattern, err)
+	}
+	return tpl
+}
+
+func sign(n int) int {
+	switch {
+	case n > 0:
+		return 1
+	case n < 0:
+		return -1
+	}
+	return 0
+}
@@ -0,0 +1,76 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// ErrNoKey is returned when secrets must be stored but no encryption key was configured.
+var ErrNoKey = errors.New("no encryption key configured (set GATEWAY_ENCRYPTION_KEY)")
+
+// Box seals small secrets (upstream TLS material) with AES-256-GCM before they are persisted.
+// Sealed values are nonce||ciphertext.
+type Box struct {
+	aead cipher.AEAD
+}
+
+// NewBox creates a Box from a 32-byte key.
+func NewBox(key []byte) (*Box, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("encryption key must be 32 bytes, got %d", len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Box{aead: aead}, nil
+}
+
+// ParseKey decodes a 32-byte key given as base64 (std or URL) or hex.
+func ParseKey(s string) ([]byte, error) {
+	for _, dec := range []func(string) ([]byte, error){
+		base64.StdEncoding.DecodeString,
+		base64.RawStdEncoding.DecodeString,
+		base64.URLEncoding.DecodeString,
+		hex.DecodeString,
+	} {
+		if b, err := dec(s); err == nil && len(b) == 32 {
+			return b, nil
+		}
+	}
+	return nil, errors.New("encryption key must be 32 bytes encoded as base64 or hex")
+}
+
+// Seal encrypts plaintext and binds it to aad, typically the id of the row it is stored in,
+// so a sealed value copied to another row no longer opens. A nil Box returns ErrNoKey.
+func (b *Box) Seal(plaintext, aad []byte) ([]byte, error) {
+	if b == nil {
+		return nil, ErrNoKey
+	}
+	nonce := make([]byte, b.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return b.aead.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+// Open decrypts a value produced by Seal with the same aad. A nil Box returns ErrNoKey.
+func (b *Box) Open(sealed, aad []byte) ([]byte, error) {
+	if b == nil {
+		return nil, ErrNoKey
+	}
+	n := b.aead.NonceSize()
+	if len(sealed) < n {
+		return nil, errors.New("sealed value too short")
+	}
+	return b.aead.Open(nil, sealed[:n], sealed[n:], aad)
+}
@@ -0,0 +1,281 @@
+package upstream
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"ecomm/api-gateway/internal/registry"
+)
+
+// reloadInterval is how often certificate files are checked for changes.
+const reloadInterval = 10 * time.Second
+
+// BuildConfig turns stored settings into a client tls.Config. It also returns the files the
+// config was built from so callers can watch them for rotation.
+func BuildConfig(t *registry.UpstreamTLS) (*tls.Config, []string, error) {
+	cfg := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         t.ServerName,
+		InsecureSkipVerify: t.InsecureSkipVerify,
+	}
+	var files []string
+	caPEM := []byte(t.CACertPEM)
+	if len(caPEM) == 0 && t.CAFile != "" {
+		b, err := os.ReadFile(t.CAFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("read ca_file: %w", err)
+		}
+		caPEM = b
+		files = append(files, t.CAFile)
+	}
+	if len(caPEM) > 0 {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, nil, errors.New("ca bundle contains no PEM certificates")
+		}
+		cfg.RootCAs = pool
+	}
+
+	certPEM, keyPEM := []byte(t.ClientCertPEM), []byte(t.ClientKeyPEM)
+	if len(certPEM) == 0 && t.CertFile != "" {
+		b, err := os.ReadFile(t.CertFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("read cert_file: %w", err)
+		}
+		certPEM = b
+		files = append(files, t.CertFile)
+	}
+	if len(keyPEM) == 0 && t.KeyFile != "" {
+		b, err := os.ReadFile(t.KeyFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("read key_file: %w", err)
+		}
+		keyPEM = b
+		files = append(files, t.KeyFile)
+	}
+	switch {
+	case len(certPEM) > 0 && len(keyPEM) > 0:
+		cert, err := tls.X509KeyPair(certPEM, keyPEM)
+		if err != nil {
+			return nil, nil, fmt.Errorf("client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	case len(certPEM) > 0 || len(keyPEM) > 0:
+		return nil, nil, errors.New("client certificate and key must be set together")
+	}
+	return cfg, files, nil
+}
+
+type tlsEntry struct {
+	settings  *registry.UpstreamTLS // nil: plaintext / system defaults
+	cfg       *tls.Config
+	transport *http.Transport
+	files     map[string]time.Time
+}
+
+// TLSManager resolves per-service upstream TLS settings into tls.Configs, HTTP transports and
+// gRPC transport credentials. Results are cached per service until Invalidate is called.
+// Certificates loaded from files are re-read when the files change; listeners registered with
+// OnChange are then told so pooled connections can be re-dialed with the new material.
+type TLSManager struct {
+	repo registry.Repository
+	reg  *registry.Registry
+
+	mu        sync.Mutex
+	entries   map[string]*tlsEntry
+	listeners []func(svc *registry.Service)
+	stop      chan struct{}
+	once      sync.Once
+}
+
+// NewTLSManager creates a manager and starts its certificate file watcher.
+func NewTLSManager(repo registry.Repository, reg *registry.Registry) *TLSManager {
+	m := &TLSManager{repo: repo, reg: reg, entries: map[string]*tlsEntry{}, stop: make(chan struct{})}
+	go m.watch()
+	return m
+}
+
+// OnChange registers fn to be called after a service's TLS material changed.
+func (m *TLSManager) OnChange(fn func(svc *registry.Service)) {
+	m.mu.Lock()
+	m.listeners = append(m.listeners, fn)
+	m.mu.Unlock()
+}
+
+// Config returns the client tls.Config for svc, or nil when the service has no TLS settings.
+func (m *TLSManager) Config(ctx context.Context, svc *registry.Service) (*tls.Config, error) {
+	e, err := m.entry(ctx, svc.ID)
+	if err != nil {
+		return nil, err
+	}
+	return e.cfg, nil
+}
+
+// Transport returns the RoundTripper the reverse proxy should use for svc: a dedicated
+// transport when the service has TLS settings, otherwise http.DefaultTransport.
+func (m *TLSManager) Transport(ctx context.Context, svc *registry.Service) (http.RoundTripper, error) {
+	e, err := m.entry(ctx, svc.ID)
+	if err != nil {
+		return nil, err
+	}
+	if e.transport == nil {
+		return http.DefaultTransport, nil
+	}
+	return e.transport, nil
+}
+
+// GRPCCredentials returns transport credentials for a grpc-json upstream target. Targets that
+// don't belong to a service with TLS settings dial in plaintext.
+func (m *TLSManager) GRPCCredentials(target string) (credentials.TransportCredentials, error) {
+	for _, svc := range m.reg.Services() {
+		if svc.GRPCTarget != target {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		cfg, err := m.Config(ctx, svc)
+		cancel()
+		if err != nil {
+			return nil, err
+		}
+		if cfg != nil {
+			return credentials.NewTLS(cfg), nil
+		}
+		break
+	}
+	return insecure.NewCredentials(), nil
+}
+
+// Invalidate drops the cached settings for svc and notifies listeners. Call it after the
+// service's TLS settings or upstream address changed.
+func (m *TLSManager) Invalidate(svc *registry.Service) {
+	m.mu.Lock()
+	e := m.entries[svc.ID]
+	delete(m.entries, svc.ID)
+	listeners := m.listeners
+	m.mu.Unlock()
+	if e != nil && e.transport != nil {
+		e.transport.CloseIdleConnections()
+	}
+	for _, fn := range listeners {
+		fn(svc)
+	}
+}
+
+// Close stops the file watcher.
+func (m *TLSManager) Close() {
+	m.once.Do(func() { close(m.stop) })
+}
+
+func (m *TLSManager) entry(ctx context.Context, serviceID string) (*tlsEntry, error) {
+	m.mu.Lock()
+	e := m.entries[serviceID]
+	m.mu.Unlock()
+	if e != nil {
+		return e, nil
+	}
+	settings, err := m.repo.GetUpstreamTLS(ctx, serviceID)
+	if err != nil {
+		return nil, fmt.Errorf("load upstream tls: %w", err)
+	}
+	e, err = newEntry(settings)
+	if err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	if cur := m.entries[serviceID]; cur != nil {
+		e = cur
+	} else {
+		m.entries[serviceID] = e
+	}
+	m.mu.Unlock()
+	return e, nil
+}
+
+func newEntry(settings *registry.UpstreamTLS) (*tlsEntry, error) {
+	e := &tlsEntry{settings: settings}
+	if settings == nil {
+		return e, nil
+	}
+	cfg, files, err := BuildConfig(settings)
+	if err != nil {
+		return nil, err
+	}
+	e.cfg = cfg
+	e.files = map[string]time.Time{}
+	for _, f := range files {
+		e.files[f] = modTime(f)
+	}
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.TLSClientConfig = cfg
+	e.transport = t
+	return e, nil
+}
+
+func (m *TLSManager) watch() {
+	t := time.NewTicker(reloadInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-m.stop:
+			return
+		case <-t.C:
+			m.reloadChanged()
+		}
+	}
+}
+
+// reloadChanged rebuilds entries whose certificate files were modified. A rebuild that fails
+// (e.g. a half-written key) keeps the previous config and is retried on the next tick.
+func (m *TLSManager) reloadChanged() {
+	m.mu.Lock()
+	var changed []string
+	for id, e := range m.entries {
+		for f, mt := range e.files {
+			if !modTime(f).Equal(mt) {
+				changed = append(changed, id)
+				break
+			}
+		}
+	}
+	m.mu.Unlock()
+	for _, id := range changed {
+		m.mu.Lock()
+		old := m.entries[id]
+		m.mu.Unlock()
+		if old == nil {
+			continue
+		}
+		e, err := newEntry(old.settings)
+		if err != nil {
+			continue
+		}
+		m.mu.Lock()
+		m.entries[id] = e
+		listeners := m.listeners
+		m.mu.Unlock()
+		old.transport.CloseIdleConnections()
+		if svc := m.reg.Service(id); svc != nil {
+			for _, fn := range listeners {
+				fn(svc)
+			}
+		}
+	}
+}
+
+func modTime(path string) time.Time {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return fi.ModTime()
+}
@@ -0,0 +1,235 @@
+package upstream
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"ecomm/api-gateway/internal/registry"
+)
+
+// newCert issues a certificate for name, signed by parent or self-signed when parent is nil,
+// and returns it with its PEM encodings.
+func newCert(t *testing.T, name string, parent *tls.Certificate) (tls.Certificate, string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	signer, signerKey := tmpl, any(key)
+	if parent == nil {
+		tmpl.IsCA, tmpl.BasicConstraintsValid = true, true
+	} else {
+		signer, signerKey = parent.Leaf, parent.PrivateKey
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	keyPEM := string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}))
+	cert, err := tls.X509KeyPair([]byte(certPEM), []byte(keyPEM))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert.Leaf, _ = x509.ParseCertificate(der)
+	return cert, certPEM, keyPEM
+}
+
+// newUpstream starts a TLS server for "upstream.internal" signed by ca, requiring client
+// certificates signed by clientCA when it is set.
+func newUpstream(t *testing.T, ca tls.Certificate, clientCA *x509.Certificate) *httptest.Server {
+	t.Helper()
+	cert, _, _ := newCert(t, "upstream.internal", &ca)
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	srv.TLS = &tls.Config{Certificates: []tls.Certificate{cert}}
+	if clientCA != nil {
+		pool := x509.NewCertPool()
+		pool.AddCert(clientCA)
+		srv.TLS.ClientAuth, srv.TLS.ClientCAs = tls.RequireAndVerifyClientCert, pool
+	}
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func get(cfg *tls.Config, url string) error {
+	c := &http.Client{Transport: &http.Transport{TLSClientConfig: cfg}, Timeout: 5 * time.Second}
+	resp, err := c.Get(url)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func TestBuildConfigCA(t *testing.T) {
+	ca, caPEM, _ := newCert(t, "ca", nil)
+	srv := newUpstream(t, ca, nil)
+
+	cfg, files, err := BuildConfig(&registry.UpstreamTLS{CACertPEM: caPEM, ServerName: "upstream.internal"})
+	if err != nil {
+		t.Fatalf("BuildConfig: %v", err)
+	}
+	if len(files) != 0 {
+		t.Errorf("BuildConfig: inline PEM reported files %v", files)
+	}
+	if err := get(cfg, srv.URL); err != nil {
+		t.Errorf("GET with the upstream's CA: %v", err)
+	}
+	_, otherPEM, _ := newCert(t, "other", nil)
+	cfg, _, err = BuildConfig(&registry.UpstreamTLS{CACertPEM: otherPEM, ServerName: "upstream.internal"})
+	if err != nil {
+		t.Fatalf("BuildConfig: %v", err)
+	}
+	if err := get(cfg, srv.URL); err == nil {
+		t.Error("GET with another CA succeeded")
+	}
+	if _, _, err := BuildConfig(&registry.UpstreamTLS{CACertPEM: "not pem"}); err == nil {
+		t.Error("BuildConfig accepted a CA bundle without certificates")
+	}
+}
+
+func TestBuildConfigMTLS(t *testing.T) {
+	ca, caPEM, _ := newCert(t, "ca", nil)
+	srv := newUpstream(t, ca, ca.Leaf)
+	_, certPEM, keyPEM := newCert(t, "gateway", &ca)
+	_, _, strayKeyPEM := newCert(t, "stray", nil)
+
+	cfg, _, err := BuildConfig(&registry.UpstreamTLS{CACertPEM: caPEM, ClientCertPEM: certPEM, ClientKeyPEM: keyPEM, ServerName: "upstream.internal"})
+	if err != nil {
+		t.Fatalf("BuildConfig: %v", err)
+	}
+	if err := get(cfg, srv.URL); err != nil {
+		t.Errorf("GET with a client certificate: %v", err)
+	}
+	cfg, _, _ = BuildConfig(&registry.UpstreamTLS{CACertPEM: caPEM, ServerName: "upstream.internal"})
+	if err := get(cfg, srv.URL); err == nil {
+		t.Error("GET without a client certificate succeeded")
+	}
+
+	for name, settings := range map[string]*registry.UpstreamTLS{
+		"cert without key": {ClientCertPEM: certPEM},
+		"key without cert": {ClientKeyPEM: keyPEM},
+		"key file missing": {ClientCertPEM: certPEM, KeyFile: filepath.Join(t.TempDir(), "missing.pem")},
+		"mismatched pair":  {ClientCertPEM: certPEM, ClientKeyPEM: strayKeyPEM},
+	} {
+		if _, _, err := BuildConfig(settings); err == nil {
+			t.Errorf("%s: BuildConfig succeeded", name)
+		}
+	}
+}
+
+// tlsRepo serves upstream TLS settings; the manager reads nothing else.
+type tlsRepo struct {
+	registry.Repository
+	settings map[string]*registry.UpstreamTLS
+}
+
+func (r *tlsRepo) GetUpstreamTLS(ctx context.Context, serviceID string) (*registry.UpstreamTLS, error) {
+	return r.settings[serviceID], nil
+}
+
+func TestTLSManagerReloadsFiles(t *testing.T) {
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.pem")
+	oldCA, oldPEM, _ := newCert(t, "ca", nil)
+	newCA, newPEM, _ := newCert(t, "ca", nil)
+	if err := os.WriteFile(caFile, []byte(oldPEM), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	svc := &registry.Service{ID: "s1", PublicPrefix: "/api/s1", Enabled: true}
+	reg := registry.New()
+	reg.Set([]*registry.Service{svc})
+	m := NewTLSManager(&tlsRepo{settings: map[string]*registry.UpstreamTLS{
+		svc.ID: {CAFile: caFile, ServerName: "upstream.internal"},
+	}}, reg)
+	defer m.Close()
+	var changed []string
+	m.OnChange(func(s *registry.Service) { changed = append(changed, s.ID) })
+
+	ctx := context.Background()
+	cfg, err := m.Config(ctx, svc)
+	if err != nil {
+		t.Fatalf("Config: %v", err)
+	}
+	if err := get(cfg, newUpstream(t, oldCA, nil).URL); err != nil {
+		t.Fatalf("GET with the CA file: %v", err)
+	}
+	rt, err := m.Transport(ctx, svc)
+	if err != nil || rt == http.DefaultTransport {
+		t.Fatalf("Transport: got %v, %v; want a dedicated transport", rt, err)
+	}
+
+	// Unchanged files keep the cached config.
+	m.reloadChanged()
+	if again, _ := m.Config(ctx, svc); again != cfg || len(changed) != 0 {
+		t.Fatalf("reload without changes rebuilt the config (listeners told %v)", changed)
+	}
+
+	if err := os.WriteFile(caFile, []byte(newPEM), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(caFile, later, later); err != nil {
+		t.Fatal(err)
+	}
+	m.reloadChanged()
+	if len(changed) != 1 || changed[0] != svc.ID {
+		t.Fatalf("reload: listeners told %v, want [%s]", changed, svc.ID)
+	}
+	cfg, err = m.Config(ctx, svc)
+	if err != nil {
+		t.Fatalf("Config: %v", err)
+	}
+	if err := get(cfg, newUpstream(t, newCA, nil).URL); err != nil {
+		t.Errorf("GET with the rotated CA file: %v", err)
+	}
+
+	// A rewrite that doesn't parse keeps the previous config until the next tick.
+	if err := os.WriteFile(caFile, []byte("half written"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	later = later.Add(time.Minute)
+	os.Chtimes(caFile, later, later)
+	m.reloadChanged()
+	if again, _ := m.Config(ctx, svc); again != cfg || len(changed) != 1 {
+		t.Errorf("reload of a broken file replaced the config")
+	}
+}
+
+func TestTLSManagerWithoutSettings(t *testing.T) {
+	svc := &registry.Service{ID: "s1"}
+	m := NewTLSManager(&tlsRepo{}, registry.New())
+	defer m.Close()
+	if cfg, err := m.Config(context.Background(), svc); err != nil || cfg != nil {
+		t.Errorf("Config: got %v, %v; want nil", cfg, err)
+	}
+	if rt, err := m.Transport(context.Background(), svc); err != nil || rt != http.DefaultTransport {
+		t.Errorf("Transport: got %v, %v; want http.DefaultTransport", rt, err)
+	}
+}
@@ -3,6 +3,8 @@ package util
 import (
 	"encoding/json"
 	"net/http"
+	"net/url"
+	"strings"
 )
 
 // JSON writes JSON response with content-type
@@ -15,8 +17,9 @@ func JSON(w http.ResponseWriter, v any) {
 func CORS(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET,POST,PUT,DELETE,OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.Header().Set("Access-Control-Allow-Methods", "GET,POST,PUT,PATCH,DELETE,OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, If-Match, If-None-Match")
+		w.Header().Set("Access-Control-Expose-Headers", "ETag, X-Next-Cursor, Link")
 		if r.Method == http.MethodOptions {
 			w.WriteHeader(http.StatusNoContent)
 			return
@@ -24,3 +27,24 @@ func CORS(next http.HandlerFunc) http.HandlerFunc {
 		next(w, r)
 	}
 }
+
+// OriginAllowed reports whether the request's Origin may use a credentialed browser endpoint
+// such as a WebSocket. Requests without an Origin (non-browser clients) are allowed. allowed
+// holds exact origins ("https://app.example.com") or "*"; when it is empty only same-origin
+// requests pass.
+func OriginAllowed(r *http.Request, allowed []string) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if len(allowed) == 0 {
+		u, err := url.Parse(origin)
+		return err == nil && strings.EqualFold(u.Host, r.Host)
+	}
+	for _, a := range allowed {
+		if a == "*" || strings.EqualFold(strings.TrimSuffix(a, "/"), origin) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,37 @@
+package util
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOriginAllowed(t *testing.T) {
+	tests := []struct {
+		name    string
+		origin  string
+		allowed []string
+		want    bool
+	}{
+		{"no origin", "", nil, true},
+		{"same origin by default", "https://gw.example.com", nil, true},
+		{"cross origin by default", "https://evil.example", nil, false},
+		{"listed", "https://app.example.com", []string{"https://app.example.com"}, true},
+		{"listed with trailing slash", "https://app.example.com", []string{"https://app.example.com/"}, true},
+		{"case-insensitive", "https://APP.example.com", []string{"https://app.example.com"}, true},
+		{"not listed", "https://evil.example", []string{"https://app.example.com"}, false},
+		{"list replaces same-origin", "https://gw.example.com", []string{"https://app.example.com"}, false},
+		{"wildcard", "https://evil.example", []string{"*"}, true},
+		{"scheme matters", "http://app.example.com", []string{"https://app.example.com"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "https://gw.example.com/ws", nil)
+			if tt.origin != "" {
+				r.Header.Set("Origin", tt.origin)
+			}
+			if got := OriginAllowed(r, tt.allowed); got != tt.want {
+				t.Fatalf("OriginAllowed(%q, %v) = %v, want %v", tt.origin, tt.allowed, got, tt.want)
+			}
+		})
+	}
+}
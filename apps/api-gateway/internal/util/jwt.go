@@ -0,0 +1,98 @@
+package util
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Claims are the JWT claims the gateway acts on.
+type Claims struct {
+	Subject string `json:"sub"`
+	// Groups come from a "groups" claim, as issued by most identity providers.
+	Groups    []string `json:"groups,omitempty"`
+	ExpiresAt int64    `json:"exp,omitempty"`
+	NotBefore int64    `json:"nbf,omitempty"`
+}
+
+// ErrInvalidToken is returned (possibly wrapped) for tokens that are malformed, not signed with
+// the secret using HS256, without an expiry, expired or not yet valid.
+var ErrInvalidToken = errors.New("invalid token")
+
+// NotBeforeLeeway tolerates clock skew between the token issuer and the gateway on "nbf".
+const NotBeforeLeeway = 30 * time.Second
+
+// ParseJWT verifies an HS256 token against secret and returns its claims. Tokens must expire:
+// those without an "exp" claim are rejected, so a leaked token can't be used forever.
+func ParseJWT(token, secret string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidToken
+	}
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil || header.Alg != "HS256" {
+		return nil, ErrInvalidToken
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if !hmac.Equal(sig, mac.Sum(nil)) {
+		return nil, ErrInvalidToken
+	}
+	var c Claims
+	if err := decodeSegment(parts[1], &c); err != nil {
+		return nil, ErrInvalidToken
+	}
+	now := time.Now()
+	switch {
+	case c.ExpiresAt == 0:
+		return nil, fmt.Errorf("%w: no exp claim", ErrInvalidToken)
+	case now.Unix() >= c.ExpiresAt:
+		return nil, fmt.Errorf("%w: expired", ErrInvalidToken)
+	case c.NotBefore != 0 && now.Add(NotBeforeLeeway).Unix() < c.NotBefore:
+		return nil, fmt.Errorf("%w: not valid yet", ErrInvalidToken)
+	}
+	return &c, nil
+}
+
+func decodeSegment(seg string, v any) error {
+	b, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// BearerToken returns the token of an "Authorization: Bearer" header, or "".
+func BearerToken(r *http.Request) string {
+	h := r.Header.Get("Authorization")
+	if len(h) > 7 && strings.EqualFold(h[:7], "bearer ") {
+		return strings.TrimSpace(h[7:])
+	}
+	return ""
+}
+
+type claimsKey struct{}
+
+// WithClaims returns ctx carrying the verified claims of the request.
+func WithClaims(ctx context.Context, c *Claims) context.Context {
+	return context.WithValue(ctx, claimsKey{}, c)
+}
+
+// ClaimsFrom returns the claims stored by WithClaims, or nil.
+func ClaimsFrom(ctx context.Context) *Claims {
+	c, _ := ctx.Value(claimsKey{}).(*Claims)
+	return c
+}
@@ -0,0 +1,60 @@
+package util
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func signJWT(t *testing.T, alg, secret string, claims map[string]any) string {
+	t.Helper()
+	h, _ := json.Marshal(map[string]string{"alg": alg, "typ": "JWT"})
+	c, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unsigned := base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(c)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(unsigned))
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestParseJWT(t *testing.T) {
+	now := time.Now().Unix()
+	exp := now + 60
+	skew := int64(NotBeforeLeeway / time.Second)
+	tests := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{"valid", signJWT(t, "HS256", "s3cret", map[string]any{"sub": "alice", "groups": []string{"ops"}, "exp": exp}), false},
+		{"no exp", signJWT(t, "HS256", "s3cret", map[string]any{"sub": "alice"}), true},
+		{"expired", signJWT(t, "HS256", "s3cret", map[string]any{"sub": "alice", "exp": now - 1}), true},
+		{"nbf within leeway", signJWT(t, "HS256", "s3cret", map[string]any{"sub": "alice", "exp": exp, "nbf": now + skew/2}), false},
+		{"nbf beyond leeway", signJWT(t, "HS256", "s3cret", map[string]any{"sub": "alice", "exp": exp, "nbf": now + 2*skew}), true},
+		{"other secret", signJWT(t, "HS256", "other", map[string]any{"sub": "alice", "exp": exp}), true},
+		{"other alg", signJWT(t, "none", "s3cret", map[string]any{"sub": "alice", "exp": exp}), true},
+		{"malformed", "not.a-token", true},
+	}
+	for _, tt := range tests {
+		c, err := ParseJWT(tt.token, "s3cret")
+		if tt.wantErr {
+			if !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("%s: got %v, want ErrInvalidToken", tt.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if c.Subject != "alice" || c.ExpiresAt != exp {
+			t.Errorf("%s: got claims %+v", tt.name, c)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package util
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCORSv2(t *testing.T) {
+	h := CORSv2()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+
+	pre := httptest.NewRecorder()
+	h.ServeHTTP(pre, httptest.NewRequest(http.MethodOptions, "/admin/v1/services", nil))
+	if pre.Code != http.StatusNoContent {
+		t.Fatalf("preflight: got status %d, want 204", pre.Code)
+	}
+	if m := pre.Header().Get("Access-Control-Allow-Methods"); !strings.Contains(m, "PATCH") {
+		t.Errorf("preflight: methods %q do not allow PATCH", m)
+	}
+	for _, hdr := range []string{"If-Match", "If-None-Match"} {
+		if !strings.Contains(pre.Header().Get("Access-Control-Allow-Headers"), hdr) {
+			t.Errorf("preflight: %s is not an allowed header", hdr)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/v1/services", nil))
+	if rec.Code != http.StatusTeapot {
+		t.Fatalf("GET: got status %d, want the handler's", rec.Code)
+	}
+	if got, want := rec.Header().Get("Access-Control-Expose-Headers"), "ETag, X-Next-Cursor, Link"; got != want {
+		t.Errorf("GET: exposed headers %q, want %q", got, want)
+	}
+}